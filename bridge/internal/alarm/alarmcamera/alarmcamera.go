@@ -0,0 +1,5 @@
+// Package alarmcamera contains the cameras that alarms of every protocol are attributed to.
+package alarmcamera
+
+// Cameras maps IP addresses to camera IDs.
+type Cameras map[string]int64
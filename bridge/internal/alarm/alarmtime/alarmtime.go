@@ -0,0 +1,162 @@
+// Package alarmtime normalizes the times reported by cameras and detects their clock skew.
+package alarmtime
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LocalLayout is the layout of wall clock times reported without a UTC offset.
+// They are interpreted in the timezone of the camera.
+const LocalLayout = "2006-01-02T15:04:05"
+
+// Result is the result of a normalization.
+type Result struct {
+	// time of the alarm, in UTC.
+	Time time.Time
+	// difference between the receipt time and the reported time.
+	// It is positive when the clock of the camera is late.
+	Skew time.Duration
+	// whether Time has been replaced by the receipt time.
+	Corrected bool
+}
+
+// Skew contains the clock skew statistics of a camera.
+type Skew struct {
+	CameraID    int64
+	Last        time.Duration
+	Max         time.Duration
+	Samples     uint64
+	Corrections uint64
+	UpdatedAt   time.Time
+}
+
+// Normalizer converts times reported by cameras into UTC
+// and corrects them when the clock of the camera is too far from the one of the bridge.
+type Normalizer struct {
+	// timezone of cameras without a timezone.
+	DefaultLocation *time.Location
+	// maximum skew before correction. Zero disables correction.
+	Threshold time.Duration
+
+	mutex     sync.Mutex
+	locations map[int64]*time.Location
+	skews     map[int64]*Skew
+}
+
+// SetLocation sets the timezone of a camera.
+func (n *Normalizer) SetLocation(cameraID int64, loc *time.Location) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.locations == nil {
+		n.locations = make(map[int64]*time.Location)
+	}
+	n.locations[cameraID] = loc
+}
+
+// SetLocations replaces the timezones of all cameras.
+func (n *Normalizer) SetLocations(locations map[int64]*time.Location) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.locations = locations
+}
+
+func (n *Normalizer) location(cameraID int64) *time.Location {
+	if loc, ok := n.locations[cameraID]; ok {
+		return loc
+	}
+	if n.DefaultLocation != nil {
+		return n.DefaultLocation
+	}
+	return time.Local
+}
+
+// Parse parses a reported time, either in RFC3339 format or in LocalLayout.
+func (n *Normalizer) Parse(cameraID int64, reported string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, reported)
+	if err == nil {
+		return t, nil
+	}
+
+	n.mutex.Lock()
+	loc := n.location(cameraID)
+	n.mutex.Unlock()
+
+	t, err = time.ParseInLocation(LocalLayout, reported, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s'", reported)
+	}
+	return t, nil
+}
+
+// Normalize converts the time reported by a camera into UTC
+// and compares it with the receipt time.
+func (n *Normalizer) Normalize(cameraID int64, reported string, receivedAt time.Time) (*Result, error) {
+	t, err := n.Parse(cameraID, reported)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		Time: t.UTC(),
+		Skew: receivedAt.Sub(t),
+	}
+
+	if n.Threshold != 0 && abs(res.Skew) > n.Threshold {
+		res.Time = receivedAt.UTC()
+		res.Corrected = true
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.skews == nil {
+		n.skews = make(map[int64]*Skew)
+	}
+
+	s, ok := n.skews[cameraID]
+	if !ok {
+		s = &Skew{CameraID: cameraID}
+		n.skews[cameraID] = s
+	}
+
+	s.Last = res.Skew
+	if abs(res.Skew) > abs(s.Max) {
+		s.Max = res.Skew
+	}
+	s.Samples++
+	if res.Corrected {
+		s.Corrections++
+	}
+	s.UpdatedAt = receivedAt.UTC()
+
+	return res, nil
+}
+
+// Skews returns the clock skew statistics of cameras, sorted by camera ID.
+func (n *Normalizer) Skews() []Skew {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	ret := make([]Skew, 0, len(n.skews))
+	for _, s := range n.skews {
+		ret = append(ret, *s)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CameraID < ret[j].CameraID
+	})
+
+	return ret
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,86 @@
+package alarmtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	seoul, err := time.LoadLocation("Asia/Seoul")
+	require.NoError(t, err)
+
+	rome, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+
+	n := &Normalizer{
+		DefaultLocation: seoul,
+		Threshold:       30 * time.Second,
+	}
+	n.SetLocation(2, rome)
+
+	receivedAt := time.Date(2025, 3, 10, 1, 0, 10, 0, time.UTC)
+
+	// camera in the default timezone
+	res, err := n.Normalize(1, "2025-03-10T10:00:00", receivedAt)
+	require.NoError(t, err)
+	require.Equal(t, &Result{
+		Time: time.Date(2025, 3, 10, 1, 0, 0, 0, time.UTC),
+		Skew: 10 * time.Second,
+	}, res)
+
+	// camera with its own timezone
+	res, err = n.Normalize(2, "2025-03-10T02:00:05", receivedAt)
+	require.NoError(t, err)
+	require.Equal(t, &Result{
+		Time: time.Date(2025, 3, 10, 1, 0, 5, 0, time.UTC),
+		Skew: 5 * time.Second,
+	}, res)
+
+	// times with an offset do not depend on the timezone
+	res, err = n.Normalize(2, "2025-03-10T01:00:00Z", receivedAt)
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Second, res.Skew)
+
+	// clock ahead by more than the threshold
+	res, err = n.Normalize(1, "2025-03-10T10:05:00", receivedAt)
+	require.NoError(t, err)
+	require.Equal(t, &Result{
+		Time:      receivedAt,
+		Skew:      -(4*time.Minute + 50*time.Second),
+		Corrected: true,
+	}, res)
+
+	_, err = n.Normalize(1, "10/03/2025", receivedAt)
+	require.EqualError(t, err, "invalid time '10/03/2025'")
+
+	require.Equal(t, []Skew{
+		{
+			CameraID:    1,
+			Last:        -(4*time.Minute + 50*time.Second),
+			Max:         -(4*time.Minute + 50*time.Second),
+			Samples:     2,
+			Corrections: 1,
+			UpdatedAt:   receivedAt,
+		},
+		{
+			CameraID:  2,
+			Last:      10 * time.Second,
+			Max:       10 * time.Second,
+			Samples:   2,
+			UpdatedAt: receivedAt,
+		},
+	}, n.Skews())
+}
+
+func TestNormalizeNoThreshold(t *testing.T) {
+	n := &Normalizer{DefaultLocation: time.UTC}
+
+	receivedAt := time.Date(2025, 3, 10, 1, 0, 0, 0, time.UTC)
+
+	res, err := n.Normalize(1, "2025-03-09T01:00:00", receivedAt)
+	require.NoError(t, err)
+	require.False(t, res.Corrected)
+	require.Equal(t, 24*time.Hour, res.Skew)
+}
@@ -0,0 +1,104 @@
+// Package alarmtype contains the normalized alarm taxonomy and vendor mappings.
+package alarmtype
+
+import (
+	"strings"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+)
+
+// Type is a normalized alarm type.
+type Type string
+
+// Alarm types.
+const (
+	Motion       Type = "motion"
+	LineCrossing Type = "line-crossing"
+	Intrusion    Type = "intrusion"
+	Tamper       Type = "tamper"
+	VideoLoss    Type = "video-loss"
+	IOInput      Type = "io-input"
+	Face         Type = "face"
+	Vehicle      Type = "vehicle"
+	System       Type = "system"
+)
+
+// Priority is the priority of an alarm.
+type Priority int32
+
+// Priorities.
+const (
+	PriorityLow      Priority = 1
+	PriorityMedium   Priority = 2
+	PriorityHigh     Priority = 3
+	PriorityCritical Priority = 4
+)
+
+var priorities = map[Type]Priority{
+	Motion:       PriorityLow,
+	LineCrossing: PriorityHigh,
+	Intrusion:    PriorityCritical,
+	Tamper:       PriorityHigh,
+	VideoLoss:    PriorityHigh,
+	IOInput:      PriorityHigh,
+	Face:         PriorityMedium,
+	Vehicle:      PriorityMedium,
+	System:       PriorityMedium,
+}
+
+// Priority returns the priority of alarms of this type.
+func (t Type) Priority() Priority {
+	if p, ok := priorities[t]; ok {
+		return p
+	}
+	return PriorityMedium
+}
+
+// normalize makes vendor event names comparable, i.e. "Motion Detection" and "motion_detection".
+func normalize(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Mapping maps vendor event names to alarm types.
+type Mapping struct {
+	types map[string]Type
+}
+
+func newMapping(m map[string]Type) *Mapping {
+	ret := &Mapping{types: make(map[string]Type, len(m))}
+	for name, t := range m {
+		ret.types[normalize(name)] = t
+	}
+	return ret
+}
+
+// Find returns the alarm type of a vendor event.
+// Names are compared regardless of case, spaces and punctuation.
+func (m *Mapping) Find(name string) (Type, bool) {
+	t, ok := m.types[normalize(name)]
+	return t, ok
+}
+
+// Map returns the alarm type of a vendor event, or System if the event is unknown.
+func (m *Mapping) Map(name string) Type {
+	if t, ok := m.Find(name); ok {
+		return t
+	}
+	return System
+}
+
+// Set sets the type, the priority and the raw vendor event name of an alarm.
+func Set(alarm *defs.PublicAlarmInsert, t Type, vendorEvent string) {
+	alarm.AlarmType = string(t)
+	p := int32(t.Priority())
+	alarm.Priority = &p
+	if vendorEvent != "" {
+		alarm.VendorEvent = &vendorEvent
+	}
+}
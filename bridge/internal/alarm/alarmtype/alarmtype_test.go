@@ -0,0 +1,39 @@
+package alarmtype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapping(t *testing.T) {
+	for _, ca := range []struct {
+		mapping *Mapping
+		name    string
+		typ     Type
+	}{
+		{Dahua, "Motion Detection", Motion},
+		{Dahua, "VideoMotion", Motion},
+		{Dahua, "motion_detection", Motion},
+		{Hikvision, "VMD", Motion},
+		{Hikvision, "linedetection", LineCrossing},
+		{Hikvision, "Line Crossing Detection", LineCrossing},
+		{ContactID, "130", Intrusion},
+		{SIA, "TA", Tamper},
+		{Hikvision, "video loss", VideoLoss},
+		{ONVIF, "RuleEngine/FieldDetector/ObjectsInside", Intrusion},
+		{ONVIFObjects, "LicensePlate", Vehicle},
+		{ONVIFObjects, "person", Motion},
+		{Dahua, "Unknown Event", System},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, ca.typ, ca.mapping.Map(ca.name))
+		})
+	}
+}
+
+func TestPriority(t *testing.T) {
+	require.Equal(t, PriorityLow, Motion.Priority())
+	require.Equal(t, PriorityCritical, Intrusion.Priority())
+	require.Equal(t, PriorityMedium, Type("unknown").Priority())
+}
@@ -0,0 +1,145 @@
+package alarmtype
+
+// Dahua maps events of Dahua devices, reported by email ("Alarm Event") and syslog (event codes).
+var Dahua = newMapping(map[string]Type{
+	"Motion Detection":     Motion,
+	"Motion Detect":        Motion,
+	"VideoMotion":          Motion,
+	"MD":                   Motion,
+	"SmartMotionHuman":     Motion,
+	"SMD":                  Motion,
+	"Tripwire":             LineCrossing,
+	"CrossLineDetection":   LineCrossing,
+	"Line Crossing":        LineCrossing,
+	"Intrusion":            Intrusion,
+	"CrossRegionDetection": Intrusion,
+	"Area Intrusion":       Intrusion,
+	"Tampering":            Tamper,
+	"Video Tampering":      Tamper,
+	"VideoBlind":           Tamper,
+	"VideoAbnormal":        Tamper,
+	"Video Loss":           VideoLoss,
+	"VideoLoss":            VideoLoss,
+	"Alarm Input":          IOInput,
+	"AlarmLocal":           IOInput,
+	"Local Alarm":          IOInput,
+	"External Alarm":       IOInput,
+	"Face Detection":       Face,
+	"FaceDetection":        Face,
+	"FaceRecognition":      Face,
+	"Vehicle Detection":    Vehicle,
+	"SmartMotionVehicle":   Vehicle,
+	"TrafficJunction":      Vehicle,
+	"ANPR":                 Vehicle,
+	"LoginFailure":         System,
+	"AccountLocked":        System,
+	"StorageFailure":       System,
+	"StorageNotExist":      System,
+	"StorageReadError":     System,
+	"StorageLowSpace":      System,
+	"StorageFull":          System,
+	"ConfigChange":         System,
+	"NetAbort":             System,
+	"IPConflict":           System,
+})
+
+// Hikvision maps events of Hikvision devices, reported by email (event types) and syslog (log types).
+var Hikvision = newMapping(map[string]Type{
+	"VMD":                       Motion,
+	"Motion Detection":          Motion,
+	"linedetection":             LineCrossing,
+	"Line Crossing":             LineCrossing,
+	"Line Crossing Detection":   LineCrossing,
+	"fielddetection":            Intrusion,
+	"Intrusion Detection":       Intrusion,
+	"regionEntrance":            Intrusion,
+	"regionExiting":             Intrusion,
+	"tamperdetection":           Tamper,
+	"Video Tampering":           Tamper,
+	"shelteralarm":              Tamper,
+	"videoloss":                 VideoLoss,
+	"Video Signal Loss":         VideoLoss,
+	"Video Loss":                VideoLoss,
+	"IO":                        IOInput,
+	"Alarm Input":               IOInput,
+	"facedetection":             Face,
+	"Face Detection":            Face,
+	"vehicledetection":          Vehicle,
+	"Vehicle Detection":         Vehicle,
+	"ANPR":                      Vehicle,
+	"Illegal Login":             System,
+	"Login Locked":              System,
+	"HDD Error":                 System,
+	"HDD Not Exist":             System,
+	"HDD Full":                  System,
+	"Remote: Parameters Config": System,
+	"Local: Parameters Config":  System,
+	"Network Disconnected":      System,
+	"IP Address Conflicted":     System,
+})
+
+// ContactID maps Contact ID event codes of intrusion panels.
+var ContactID = newMapping(map[string]Type{
+	"100": IOInput,   // medical
+	"110": IOInput,   // fire
+	"120": IOInput,   // panic
+	"121": IOInput,   // duress
+	"122": IOInput,   // silent panic
+	"130": Intrusion, // burglary
+	"131": Intrusion, // perimeter
+	"132": Intrusion, // interior
+	"133": Intrusion, // 24 hour zone
+	"134": Intrusion, // entry/exit
+	"135": Intrusion, // day/night
+	"137": Tamper,
+	"139": Intrusion, // verified intrusion
+	"144": Tamper,    // sensor tamper
+	"150": IOInput,   // 24 hour non-burglary
+})
+
+// SIA maps SIA DCS event codes of intrusion panels.
+var SIA = newMapping(map[string]Type{
+	"BA": Intrusion, // burglary
+	"FA": IOInput,   // fire
+	"PA": IOInput,   // panic
+	"HA": IOInput,   // holdup
+	"MA": IOInput,   // medical
+	"TA": Tamper,
+	"GA": IOInput, // gas
+	"WA": IOInput, // water
+	"KA": IOInput, // heat
+	"QA": IOInput, // emergency
+})
+
+// ONVIF maps topics of ONVIF events, without namespace prefixes.
+var ONVIF = newMapping(map[string]Type{
+	"VideoSource/MotionAlarm":                        Motion,
+	"RuleEngine/CellMotionDetector/Motion":           Motion,
+	"RuleEngine/MotionRegionDetector/Motion":         Motion,
+	"RuleEngine/LineDetector/Crossed":                LineCrossing,
+	"RuleEngine/FieldDetector/ObjectsInside":         Intrusion,
+	"RuleEngine/TamperDetector/Tamper":               Tamper,
+	"VideoSource/GlobalSceneChange/ImagingService":   Tamper,
+	"VideoSource/GlobalSceneChange/AnalyticsService": Tamper,
+	"VideoSource/ImageTooBlurry/ImagingService":      Tamper,
+	"VideoSource/ImageTooDark/ImagingService":        Tamper,
+	"VideoSource/ImageTooBright/ImagingService":      Tamper,
+	"VideoSource/SignalLoss":                         VideoLoss,
+	"Device/Trigger/DigitalInput":                    IOInput,
+	"Device/IO/VirtualPort":                          IOInput,
+})
+
+// ONVIFObjects maps classes of objects detected by ONVIF video analytics.
+var ONVIFObjects = newMapping(map[string]Type{
+	"Human":        Motion,
+	"Face":         Face,
+	"Vehicle":      Vehicle,
+	"LicensePlate": Vehicle,
+
+	// classes of common detection models, returned by analytics plugins
+	"Person":     Motion,
+	"Car":        Vehicle,
+	"Truck":      Vehicle,
+	"Bus":        Vehicle,
+	"Motorcycle": Vehicle,
+})
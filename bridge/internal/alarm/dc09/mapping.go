@@ -0,0 +1,39 @@
+package dc09
+
+import "strconv"
+
+// Zone is a panel zone mapped to a camera.
+type Zone struct {
+	CameraID int64
+	CameraIP string
+}
+
+// Panel is an intrusion panel known to the bridge.
+type Panel struct {
+	ID     int64
+	SiteID int64
+	Zones  map[int]Zone
+}
+
+// Mapping maps panel accounts to panels.
+type Mapping map[string]*Panel
+
+// Find returns the panel and the zone of an event.
+func (m Mapping) Find(account string, zone string) (*Panel, *Zone) {
+	panel, ok := m[account]
+	if !ok {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(zone)
+	if err != nil {
+		return panel, nil
+	}
+
+	z, ok := panel.Zones[n]
+	if !ok {
+		return panel, nil
+	}
+
+	return panel, &z
+}
@@ -0,0 +1,171 @@
+// Package dc09 contains parsers for intrusion panel events received over SIA DC-09.
+package dc09
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmtype"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/servers/dc09"
+)
+
+// names of Contact ID alarm events.
+var contactIDNames = map[string]string{
+	"100": "Medical",
+	"110": "Fire",
+	"120": "Panic",
+	"121": "Duress",
+	"122": "Silent Panic",
+	"130": "Burglary",
+	"131": "Perimeter",
+	"132": "Interior",
+	"133": "24 Hour Zone",
+	"134": "Entry/Exit",
+	"135": "Day/Night",
+	"137": "Tamper",
+	"139": "Verified Intrusion",
+	"144": "Sensor Tamper",
+	"150": "24 Hour Non-Burglary",
+}
+
+// names of SIA alarm events.
+var siaNames = map[string]string{
+	"BA": "Burglary",
+	"FA": "Fire",
+	"PA": "Panic",
+	"HA": "Holdup",
+	"MA": "Medical",
+	"TA": "Tamper",
+	"GA": "Gas",
+	"WA": "Water",
+	"KA": "Heat",
+	"QA": "Emergency",
+}
+
+type parserParent interface {
+	logger.Writer
+}
+
+// NewContactIDParser allocates a ContactIDParser.
+func NewContactIDParser(parent parserParent, mapping *Mapping) *ContactIDParser {
+	return &ContactIDParser{
+		parent:  parent,
+		mapping: mapping,
+	}
+}
+
+// ContactIDParser parses Contact ID events.
+type ContactIDParser struct {
+	parent  parserParent
+	mapping *Mapping
+}
+
+func (p *ContactIDParser) Log(level logger.Level, format string, args ...interface{}) {
+	p.parent.Log(level, "[ContactIDParser] "+format, args...)
+}
+
+// IsAlarm checks if the event is a new Contact ID alarm.
+func (p *ContactIDParser) IsAlarm(data interface{}) (bool, error) {
+	e, ok := data.(*dc09.Event)
+	if !ok {
+		return false, fmt.Errorf("data is not a *dc09.Event")
+	}
+
+	return e.Protocol == dc09.ProtocolContactID &&
+		e.Qualifier == "1" &&
+		strings.HasPrefix(e.Code, "1"), nil
+}
+
+// ParseAlarm converts a Contact ID event into an alarm.
+func (p *ContactIDParser) ParseAlarm(data interface{}) (*defs.PublicAlarmInsert, error) {
+	e, ok := data.(*dc09.Event)
+	if !ok {
+		return nil, fmt.Errorf("data is not a *dc09.Event")
+	}
+
+	name, ok := contactIDNames[e.Code]
+	if !ok {
+		name = "Alarm " + e.Code
+	}
+
+	return mapAlarm(p, *p.mapping, e, name, alarmtype.ContactID.Map(e.Code))
+}
+
+// NewSIAParser allocates a SIAParser.
+func NewSIAParser(parent parserParent, mapping *Mapping) *SIAParser {
+	return &SIAParser{
+		parent:  parent,
+		mapping: mapping,
+	}
+}
+
+// SIAParser parses SIA DCS events.
+type SIAParser struct {
+	parent  parserParent
+	mapping *Mapping
+}
+
+func (p *SIAParser) Log(level logger.Level, format string, args ...interface{}) {
+	p.parent.Log(level, "[SIAParser] "+format, args...)
+}
+
+// IsAlarm checks if the event is a SIA alarm.
+func (p *SIAParser) IsAlarm(data interface{}) (bool, error) {
+	e, ok := data.(*dc09.Event)
+	if !ok {
+		return false, fmt.Errorf("data is not a *dc09.Event")
+	}
+
+	return e.Protocol == dc09.ProtocolSIA &&
+		len(e.Code) == 2 && e.Code[1] == 'A', nil
+}
+
+// ParseAlarm converts a SIA event into an alarm.
+func (p *SIAParser) ParseAlarm(data interface{}) (*defs.PublicAlarmInsert, error) {
+	e, ok := data.(*dc09.Event)
+	if !ok {
+		return nil, fmt.Errorf("data is not a *dc09.Event")
+	}
+
+	name, ok := siaNames[e.Code]
+	if !ok {
+		name = "Alarm " + e.Code
+	}
+
+	return mapAlarm(p, *p.mapping, e, name, alarmtype.SIA.Map(e.Code))
+}
+
+func mapAlarm(
+	l logger.Writer,
+	mapping Mapping,
+	e *dc09.Event,
+	name string,
+	alarmType alarmtype.Type,
+) (*defs.PublicAlarmInsert, error) {
+	panel, zone := mapping.Find(e.Account, e.Zone)
+	if panel == nil {
+		return nil, fmt.Errorf("account %s is not registered", e.Account)
+	}
+	if zone == nil {
+		return nil, fmt.Errorf("zone %s of account %s is not mapped to a camera", e.Zone, e.Account)
+	}
+
+	alarm := &defs.PublicAlarmInsert{
+		AlarmName: fmt.Sprintf("%s (zone %s)", name, e.Zone),
+		CameraId:  zone.CameraID,
+		SiteId:    panel.SiteID,
+	}
+	alarmtype.Set(alarm, alarmType, e.Code)
+
+	if e.Timestamp != nil {
+		ts := e.Timestamp.Format(time.RFC3339)
+		alarm.LastAlarmAt = &ts
+	}
+
+	l.Log(logger.Info, "Parsed panel alarm: %s from account %s", alarm.AlarmName, e.Account)
+
+	return alarm, nil
+}
@@ -0,0 +1,76 @@
+package dc09
+
+import (
+	"testing"
+
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/servers/dc09"
+	"github.com/stretchr/testify/require"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+func TestParsers(t *testing.T) {
+	mapping := Mapping{
+		"1234": &Panel{
+			ID:     1,
+			SiteID: 2,
+			Zones: map[int]Zone{
+				15: {CameraID: 3, CameraIP: "192.168.1.10"},
+			},
+		},
+	}
+
+	cid := NewContactIDParser(nilLogger{}, &mapping)
+	sia := NewSIAParser(nilLogger{}, &mapping)
+
+	e := &dc09.Event{
+		Protocol:  dc09.ProtocolContactID,
+		Account:   "1234",
+		Qualifier: "1",
+		Code:      "130",
+		Zone:      "015",
+	}
+
+	ok, err := cid.IsAlarm(e)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = sia.IsAlarm(e)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	alarm, err := cid.ParseAlarm(e)
+	require.NoError(t, err)
+	require.Equal(t, "Burglary (zone 015)", alarm.AlarmName)
+	require.Equal(t, "intrusion", alarm.AlarmType)
+	require.Equal(t, int32(4), *alarm.Priority)
+	require.Equal(t, "130", *alarm.VendorEvent)
+	require.Equal(t, int64(3), alarm.CameraId)
+	require.Equal(t, int64(2), alarm.SiteId)
+
+	e.Qualifier = "3"
+	ok, err = cid.IsAlarm(e)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	e = &dc09.Event{
+		Protocol: dc09.ProtocolSIA,
+		Account:  "1234",
+		Code:     "BA",
+		Zone:     "016",
+	}
+
+	ok, err = sia.IsAlarm(e)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = sia.ParseAlarm(e)
+	require.EqualError(t, err, "zone 016 of account 1234 is not mapped to a camera")
+
+	e.Account = "9999"
+	_, err = sia.ParseAlarm(e)
+	require.EqualError(t, err, "account 9999 is not registered")
+}
@@ -0,0 +1,182 @@
+package alarm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+)
+
+const (
+	// padding added before and after an incident when requesting clips.
+	incidentClipPadding = 10 * time.Second
+
+	// period of the check that closes expired incidents.
+	incidentCheckPeriod = 5 * time.Second
+)
+
+type incidentTimelineEntry struct {
+	AlarmID   int64  `json:"alarm_id"`
+	CameraID  int64  `json:"camera_id"`
+	AlarmName string `json:"alarm_name"`
+	AlarmType string `json:"alarm_type"`
+	At        string `json:"at"`
+}
+
+type incidentClipRequest struct {
+	CameraIDs []int64 `json:"camera_ids"`
+	StartAt   string  `json:"start_at"`
+	EndAt     string  `json:"end_at"`
+}
+
+// incident is a group of alarms of the same site.
+type incident struct {
+	id          int64
+	siteID      int64
+	startedAt   time.Time
+	lastAlarmAt time.Time
+	cameraIDs   []int64
+	timeline    []incidentTimelineEntry
+
+	// pending incidents are being stored with their first alarm.
+	// ready is closed once they have been stored, or once storing them has failed, leaving id to 0.
+	pending bool
+	ready   chan struct{}
+
+	// serializes writes of the incident, in order for the last write to be the most recent one.
+	writeMutex sync.Mutex
+}
+
+// newPendingIncident returns an incident that starts with an alarm of a camera.
+func newPendingIncident(siteID int64, cameraID int64, at time.Time) *incident {
+	return &incident{
+		siteID:      siteID,
+		startedAt:   at,
+		lastAlarmAt: at,
+		cameraIDs:   []int64{cameraID},
+		pending:     true,
+		ready:       make(chan struct{}),
+	}
+}
+
+func (i *incident) hasCamera(cameraID int64) bool {
+	for _, id := range i.cameraIDs {
+		if id == cameraID {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *incident) add(alarmID int64, cameraID int64, alarmName string, alarmType string, at time.Time) {
+	if !i.hasCamera(cameraID) {
+		i.cameraIDs = append(i.cameraIDs, cameraID)
+	}
+
+	if at.After(i.lastAlarmAt) {
+		i.lastAlarmAt = at
+	}
+
+	i.timeline = append(i.timeline, incidentTimelineEntry{
+		AlarmID:   alarmID,
+		CameraID:  cameraID,
+		AlarmName: alarmName,
+		AlarmType: alarmType,
+		At:        at.UTC().Format(time.RFC3339),
+	})
+}
+
+// fields returns the timeline, cameras and clip request of the incident, to be written.
+func (i *incident) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"camera_ids":    append([]int64(nil), i.cameraIDs...),
+		"alarm_count":   int32(len(i.timeline)),
+		"timeline":      append([]incidentTimelineEntry(nil), i.timeline...),
+		"clip_request":  i.clipRequest(),
+		"last_alarm_at": i.lastAlarmAt.Format(time.RFC3339),
+		"updated_at":    time.Now().UTC(),
+	}
+}
+
+// clipRequest returns a request for a clip that covers the whole incident on all involved cameras.
+func (i *incident) clipRequest() incidentClipRequest {
+	return incidentClipRequest{
+		CameraIDs: append([]int64(nil), i.cameraIDs...),
+		StartAt:   i.startedAt.Add(-incidentClipPadding).UTC().Format(time.RFC3339),
+		EndAt:     i.lastAlarmAt.Add(incidentClipPadding).UTC().Format(time.RFC3339),
+	}
+}
+
+// incidentGrouper groups alarms into incidents with a sliding window.
+type incidentGrouper struct {
+	window      time.Duration
+	maxDuration time.Duration
+	adjacency   conf.AlarmCameraAdjacency
+
+	open []*incident
+}
+
+func (g *incidentGrouper) enabled() bool {
+	return g.window != 0
+}
+
+func (g *incidentGrouper) isExpired(i *incident, now time.Time) bool {
+	return now.Sub(i.lastAlarmAt) > g.window ||
+		now.Sub(i.startedAt) > g.maxDuration
+}
+
+// find returns the open incident that an alarm belongs to, or nil.
+func (g *incidentGrouper) find(siteID int64, cameraID int64, at time.Time) *incident {
+	for _, i := range g.open {
+		if i.siteID != siteID || g.isExpired(i, at) {
+			continue
+		}
+
+		for _, id := range i.cameraIDs {
+			if g.adjacency.Adjacent(id, cameraID) {
+				return i
+			}
+		}
+	}
+
+	return nil
+}
+
+func (g *incidentGrouper) add(i *incident) {
+	g.open = append(g.open, i)
+}
+
+func (g *incidentGrouper) remove(i *incident) {
+	for n, other := range g.open {
+		if other == i {
+			g.open = append(g.open[:n], g.open[n+1:]...)
+			return
+		}
+	}
+}
+
+// expired removes and returns incidents that can't receive alarms anymore.
+// Pending incidents are closed once they have been stored.
+func (g *incidentGrouper) expired(now time.Time) []*incident {
+	var ret []*incident
+	n := 0
+
+	for _, i := range g.open {
+		if !i.pending && g.isExpired(i, now) {
+			ret = append(ret, i)
+		} else {
+			g.open[n] = i
+			n++
+		}
+	}
+
+	g.open = g.open[:n]
+	return ret
+}
+
+// closeAll removes and returns all open incidents.
+func (g *incidentGrouper) closeAll() []*incident {
+	ret := g.open
+	g.open = nil
+	return ret
+}
@@ -0,0 +1,120 @@
+package alarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncidentGrouperWindow(t *testing.T) {
+	g := &incidentGrouper{
+		window:      time.Minute,
+		maxDuration: 10 * time.Minute,
+	}
+
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.Nil(t, g.find(1, 1, t0))
+
+	inc := &incident{id: 10, siteID: 1, startedAt: t0, lastAlarmAt: t0}
+	inc.add(100, 1, "Motion Detection", "motion", t0)
+	g.add(inc)
+
+	// same site, different camera, inside the window
+	require.Equal(t, inc, g.find(1, 2, t0.Add(30*time.Second)))
+	inc.add(101, 2, "Motion Detection", "motion", t0.Add(30*time.Second))
+
+	// the window slides with the last alarm
+	require.Equal(t, inc, g.find(1, 3, t0.Add(80*time.Second)))
+
+	// other sites are never grouped
+	require.Nil(t, g.find(2, 1, t0.Add(40*time.Second)))
+
+	// outside the window
+	require.Nil(t, g.find(1, 1, t0.Add(100*time.Second)))
+
+	require.Empty(t, g.expired(t0.Add(80*time.Second)))
+	require.Equal(t, []*incident{inc}, g.expired(t0.Add(100*time.Second)))
+	require.Empty(t, g.open)
+}
+
+func TestIncidentGrouperPending(t *testing.T) {
+	g := &incidentGrouper{
+		window:      time.Minute,
+		maxDuration: 10 * time.Minute,
+	}
+
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// alarms of the first camera join the incident while it's being stored
+	inc := newPendingIncident(1, 1, t0)
+	g.add(inc)
+	require.Equal(t, inc, g.find(1, 1, t0.Add(time.Second)))
+
+	// pending incidents are closed once they have been stored
+	require.Empty(t, g.expired(t0.Add(2*time.Minute)))
+
+	inc.pending = false
+	require.Equal(t, []*incident{inc}, g.expired(t0.Add(2*time.Minute)))
+
+	inc2 := newPendingIncident(1, 1, t0)
+	g.add(inc2)
+	g.remove(inc2)
+	require.Empty(t, g.open)
+}
+
+func TestIncidentGrouperMaxDuration(t *testing.T) {
+	g := &incidentGrouper{
+		window:      time.Minute,
+		maxDuration: 2 * time.Minute,
+	}
+
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	inc := &incident{id: 10, siteID: 1, startedAt: t0, lastAlarmAt: t0}
+	inc.add(100, 1, "Motion Detection", "motion", t0)
+	g.add(inc)
+
+	inc.add(101, 1, "Motion Detection", "motion", t0.Add(50*time.Second))
+	inc.add(102, 1, "Motion Detection", "motion", t0.Add(100*time.Second))
+
+	require.Nil(t, g.find(1, 1, t0.Add(130*time.Second)))
+}
+
+func TestIncidentGrouperAdjacency(t *testing.T) {
+	g := &incidentGrouper{
+		window:      time.Minute,
+		maxDuration: 10 * time.Minute,
+		adjacency:   conf.AlarmCameraAdjacency{{1, 2}, {2, 3}},
+	}
+
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	inc := &incident{id: 10, siteID: 1, startedAt: t0, lastAlarmAt: t0}
+	inc.add(100, 1, "Motion Detection", "motion", t0)
+	g.add(inc)
+
+	require.Nil(t, g.find(1, 3, t0.Add(time.Second)))
+	require.Equal(t, inc, g.find(1, 2, t0.Add(time.Second)))
+	inc.add(101, 2, "Motion Detection", "motion", t0.Add(time.Second))
+
+	// camera 3 is adjacent to camera 2, which is now part of the incident
+	require.Equal(t, inc, g.find(1, 3, t0.Add(2*time.Second)))
+}
+
+func TestIncidentClipRequest(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	inc := &incident{id: 10, siteID: 1, startedAt: t0, lastAlarmAt: t0}
+	inc.add(100, 1, "Motion Detection", "motion", t0)
+	inc.add(101, 2, "Motion Detection", "motion", t0.Add(30*time.Second))
+	inc.add(102, 1, "Motion Detection", "motion", t0.Add(40*time.Second))
+
+	require.Equal(t, incidentClipRequest{
+		CameraIDs: []int64{1, 2},
+		StartAt:   "2025-01-01T11:59:50Z",
+		EndAt:     "2025-01-01T12:00:50Z",
+	}, inc.clipRequest())
+}
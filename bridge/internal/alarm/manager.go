@@ -2,79 +2,164 @@ package alarm
 
 import (
 	"archive/zip"
-	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 	"time"
 
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmcamera"
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmtime"
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmtype"
+	"github.com/kaonmir/mini-chekt/internal/alarm/dc09"
+	"github.com/kaonmir/mini-chekt/internal/alarm/onvif"
 	"github.com/kaonmir/mini-chekt/internal/alarm/smtp"
+	"github.com/kaonmir/mini-chekt/internal/alarm/syslog"
 	"github.com/kaonmir/mini-chekt/internal/conf"
 	"github.com/kaonmir/mini-chekt/internal/confdb"
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
 	"github.com/kaonmir/mini-chekt/internal/defs"
 	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/metadatareader"
+	dc09Server "github.com/kaonmir/mini-chekt/internal/servers/dc09"
 	smtpServer "github.com/kaonmir/mini-chekt/internal/servers/smtp"
-	storage "github.com/supabase-community/storage-go"
-	"github.com/supabase-community/supabase-go"
+	syslogServer "github.com/kaonmir/mini-chekt/internal/servers/syslog"
 )
 
+func interfaceIsEmpty(i interface{}) bool {
+	return reflect.ValueOf(i).Kind() != reflect.Ptr || reflect.ValueOf(i).IsNil()
+}
+
 type alarmParent interface {
 	logger.Writer
 }
 
+type alarmMetrics interface {
+	SetAlarmManager(defs.APIAlarmManager)
+}
+
 // Aalrm handles alarm events from multiple protocols (SMTP, HTTP)
 type Aalrm struct {
-	conf   *conf.Conf
-	confdb *confdb.ConfDB
-	Parent alarmParent
+	conf         *conf.Conf
+	confdb       *confdb.ConfDB
+	controlPlane controlplane.ControlPlane
+	Parent       alarmParent
+	Metrics      alarmMetrics
 
 	ctx       context.Context
 	ctxCancel func()
 	wg        sync.WaitGroup
 
 	parsers        map[string][]Parser // [protocol][parser]
-	supabaseClient *supabase.Client
+	workers        *workerPool
+	incidents      *incidentGrouper
+	incidentsMutex sync.Mutex
+	panels         dc09.Mapping
+	cameras        alarmcamera.Cameras
+	times          *alarmtime.Normalizer
+	walkTest       *walkTest
+	walkTestMutex  sync.Mutex
+	// serializes writes of walk tests, that are performed without locking walkTestMutex
+	walkTestUpdateMutex sync.Mutex
 
 	// in
-	chMail *(chan smtpServer.Mail)
+	chReloadCameras chan struct{}
+	chMail          *(chan smtpServer.Mail)
+	chPanel         *(chan dc09Server.Event)
+	chSyslog        *(chan syslogServer.Message)
+	chMetadata      *(chan metadatareader.Event)
 }
 
 // New creates a new Alarm Manager instance
-func New(conf *conf.Conf, confdb *confdb.ConfDB, parent alarmParent, chMail *(chan smtpServer.Mail)) *Aalrm {
+func New(
+	conf *conf.Conf,
+	confdb *confdb.ConfDB,
+	controlPlane controlplane.ControlPlane,
+	parent alarmParent,
+	chMail *(chan smtpServer.Mail),
+	chPanel *(chan dc09Server.Event),
+	chSyslog *(chan syslogServer.Message),
+	chMetadata *(chan metadatareader.Event),
+) *Aalrm {
 	return &Aalrm{
-		conf:   conf,
-		confdb: confdb,
-		Parent: parent,
-		chMail: chMail,
+		conf:         conf,
+		confdb:       confdb,
+		controlPlane: controlPlane,
+		Parent:       parent,
+		chMail:       chMail,
+		chPanel:      chPanel,
+		chSyslog:     chSyslog,
+		chMetadata:   chMetadata,
 	}
 }
 
 func (a *Aalrm) Initialize() error {
 	a.ctx, a.ctxCancel = context.WithCancel(context.Background())
 
-	var err error
-	a.supabaseClient, err = supabase.NewClient(a.conf.SupabaseURL, a.conf.SupabaseKey, &supabase.ClientOptions{
-		Headers: map[string]string{
-			"Authorization": "Bearer " + a.conf.SupabaseKey,
-			"apikey":        a.conf.SupabaseKey,
+	a.workers = &workerPool{
+		ctx:       a.ctx,
+		workers:   a.conf.AlarmWorkers,
+		queueSize: a.conf.AlarmQueueSize,
+		onDiscard: func(cameraID int64, count int) {
+			if count != 0 {
+				a.Log(logger.Warn, "Discarding %d pending alarms of camera %d", count, cameraID)
+			}
 		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create supabase client: %w", err)
 	}
+	a.workers.initialize()
+
+	a.incidents = &incidentGrouper{
+		window:      time.Duration(a.conf.AlarmIncidentWindow),
+		maxDuration: time.Duration(a.conf.AlarmIncidentMaxDuration),
+		adjacency:   a.conf.AlarmIncidentAdjacency,
+	}
+
+	a.panels = dc09.Mapping{}
+	if a.conf.DC09 {
+		err := a.loadPanels()
+		if err != nil {
+			a.Log(logger.Warn, "Failed to load panels: %v", err)
+		}
+	}
+
+	a.times = &alarmtime.Normalizer{
+		DefaultLocation: time.Local,
+		Threshold:       time.Duration(a.conf.AlarmClockSkewThreshold),
+	}
+	if a.conf.AlarmCameraTimezone != "" {
+		a.times.DefaultLocation, _ = time.LoadLocation(a.conf.AlarmCameraTimezone)
+	}
+
+	a.chReloadCameras = make(chan struct{}, 1)
+	a.loadCameras()
 
 	a.parsers = map[string][]Parser{
 		"smtp": {
-			smtp.NewDahuaParser(a.Parent),
+			smtp.NewDahuaParser(a.Parent, &a.cameras),
+		},
+		"dc09": {
+			dc09.NewContactIDParser(a.Parent, &a.panels),
+			dc09.NewSIAParser(a.Parent, &a.panels),
+		},
+		"syslog": {
+			syslog.NewDahuaParser(a.Parent, &a.cameras),
+			syslog.NewHikvisionParser(a.Parent, &a.cameras),
+		},
+		"onvif": {
+			onvif.NewParser(a.Parent, &a.cameras),
 		},
 	}
 
 	a.wg.Add(1)
 	go a.run()
 
+	if !interfaceIsEmpty(a.Metrics) {
+		a.Metrics.SetAlarmManager(a)
+	}
+
 	return nil
 }
 
@@ -84,6 +169,11 @@ func (a *Aalrm) Log(level logger.Level, format string, args ...interface{}) {
 
 // processEvents processes incoming events from multiple protocols
 func (a *Aalrm) run() {
+	defer a.wg.Done()
+
+	incidentTicker := time.NewTicker(incidentCheckPeriod)
+	defer incidentTicker.Stop()
+
 	for {
 		var data any
 		protocol := ""
@@ -92,8 +182,33 @@ func (a *Aalrm) run() {
 		case email := <-*a.chMail:
 			data = &email
 			protocol = "smtp"
+		case event := <-*a.chPanel:
+			a.updateArmStatus(&event)
+			data = &event
+			protocol = "dc09"
+		case message := <-*a.chSyslog:
+			data = &message
+			protocol = "syslog"
+		case ev := <-*a.chMetadata:
+			data = &ev
+			protocol = "onvif"
+		case <-a.chReloadCameras:
+			a.loadCameras()
+			continue
+		case <-incidentTicker.C:
+			now := time.Now().UTC()
+			a.incidentsMutex.Lock()
+			expired := a.incidents.expired(now)
+			a.incidentsMutex.Unlock()
+			a.closeIncidents(expired)
+			a.expireWalkTest(now)
+			continue
 		case <-a.ctx.Done():
 			a.Log(logger.Info, "AlarmManager context cancelled, stopping event processing")
+			// alarms being processed are stored, while their uploads are aborted
+			a.workers.wait()
+			a.closeIncidents(a.incidents.closeAll())
+			a.finishWalkTest(time.Now().UTC(), false)
 			return
 		}
 
@@ -111,31 +226,28 @@ func (a *Aalrm) run() {
 					continue
 				}
 				if event != nil {
-					event.SiteId = a.confdb.SiteId
-
-					// Upload recordings folder as zip to Supabase bucket
-					videoUrl, err := a.UploadRecordingsToBucket(event, data)
-					if err != nil {
-						a.Log(logger.Error, "Failed to upload recordings: %v", err)
-						// Continue processing even if file upload fails
-					} else {
-						a.Log(logger.Info, "Recordings uploaded successfully, public URL: %s", videoUrl)
+					// alarms are stored with the identity of the bridge, that row-level security enforces
+					event.SiteId = a.confdb.SiteID()
+					event.BridgeId = a.confdb.BridgeID()
+					if event.AlarmType == "" {
+						alarmtype.Set(event, alarmtype.System, "")
 					}
-
-					// insert db and broadcast
-					eventData := map[string]interface{}{
-						"site_id":    event.SiteId,
-						"alarm_name": event.AlarmName,
-						"alarm_type": event.AlarmType,
-						"bridge_id":  event.BridgeId,
-						"camera_id":  event.CameraId,
-						"created_at": time.Now().UTC(),
-						"video_url":  videoUrl,
+					now := time.Now().UTC()
+					alarmAt := a.alarmTime(event, now)
+
+					job := &alarmJob{
+						event:   event,
+						data:    data,
+						now:     now,
+						alarmAt: alarmAt,
+						// Alarms of cameras under walk test are stored as test alarms, and are not notified
+						walkTestID: a.recordWalkTestAlarm(event, now),
 					}
-					_, _, err = a.supabaseClient.From("alarm").Insert(eventData, false, "", "", "").Execute()
-					if err != nil {
-						a.Log(logger.Error, "Failed to insert alarm event into database: %v", err)
-						continue
+					if !a.workers.submit(event.CameraId, func(ctx context.Context) {
+						a.processAlarm(ctx, job)
+					}) {
+						a.Log(logger.Warn, "Too many pending alarms of camera %d, discarding alarm %s",
+							event.CameraId, event.AlarmName)
 					}
 				}
 			} else {
@@ -145,23 +257,382 @@ func (a *Aalrm) run() {
 	}
 }
 
+type alarmJob struct {
+	event      *defs.PublicAlarmInsert
+	data       any
+	now        time.Time
+	alarmAt    time.Time
+	walkTestID int64
+}
+
+// processAlarm stores an alarm, then uploads its recordings and attaches them to it.
+func (a *Aalrm) processAlarm(ctx context.Context, job *alarmJob) {
+	alarmID, err := a.insertAlarm(job)
+	if err != nil {
+		a.Log(logger.Error, "Failed to insert alarm event into database: %v", err)
+		return
+	}
+
+	// recordings of test alarms are not uploaded
+	if job.walkTestID != 0 {
+		a.storeWalkTestAlarms()
+		return
+	}
+
+	uploadCtx, uploadCtxCancel := context.WithTimeout(ctx, time.Duration(a.conf.AlarmUploadTimeout))
+	defer uploadCtxCancel()
+
+	// Upload recordings folder as zip to media storage
+	videoURL, err := a.UploadRecordingsToBucket(uploadCtx, job.event, job.data)
+	if err != nil {
+		a.Log(logger.Error, "Failed to upload recordings of alarm %d: %v", alarmID, err)
+		return
+	}
+
+	a.Log(logger.Info, "Recordings uploaded successfully, public URL: %s", videoURL)
+
+	err = a.controlPlane.SetAlarmVideo(alarmID, videoURL)
+	if err != nil {
+		a.Log(logger.Error, "Failed to attach recordings to alarm %d: %v", alarmID, err)
+	}
+}
+
+// insertAlarm inserts an alarm into the database and groups it into an incident.
+// Test alarms are tagged with their walk test and are not grouped.
+func (a *Aalrm) insertAlarm(job *alarmJob) (int64, error) {
+	event := job.event
+	grouped := a.incidents.enabled() && job.walkTestID == 0
+
+	// Group the alarm into an open incident, or open a new one. Incidents are shared by cameras
+	// of the same site, therefore they are chosen under lock, while alarms are inserted without it.
+	// New incidents are stored once their first alarm is, in order not to leave incidents without
+	// alarms when the insertion fails, and alarms that join them wait until then.
+	var inc *incident
+	opened := false
+	if grouped {
+		a.incidentsMutex.Lock()
+		inc = a.incidents.find(event.SiteId, event.CameraId, job.now)
+		if inc == nil {
+			inc = newPendingIncident(event.SiteId, event.CameraId, job.now)
+			a.incidents.add(inc)
+			opened = true
+		} else if job.now.After(inc.lastAlarmAt) {
+			// the incident is kept open while the alarm is inserted
+			inc.lastAlarmAt = job.now
+		}
+		pending := inc.pending
+		a.incidentsMutex.Unlock()
+
+		if !opened && pending {
+			<-inc.ready
+			if inc.id == 0 {
+				inc = nil
+			}
+		}
+	}
+
+	createdAt := job.now.Format(time.RFC3339Nano)
+	lastAlarmAt := job.alarmAt.Format(time.RFC3339Nano)
+
+	// insert db and broadcast
+	alarm := &defs.PublicAlarmInsert{
+		SiteId:      event.SiteId,
+		AlarmName:   event.AlarmName,
+		AlarmType:   event.AlarmType,
+		Priority:    event.Priority,
+		VendorEvent: event.VendorEvent,
+		BridgeId:    event.BridgeId,
+		CameraId:    event.CameraId,
+		CreatedAt:   &createdAt,
+		LastAlarmAt: &lastAlarmAt,
+	}
+	if inc != nil && !opened {
+		alarm.IncidentId = &inc.id
+	}
+	if job.walkTestID != 0 {
+		isTest := true
+		alarm.IsTest = &isTest
+		alarm.WalkTestId = &job.walkTestID
+	}
+	alarmID, err := a.controlPlane.InsertAlarm(alarm)
+	if err != nil {
+		if opened {
+			a.abandonIncident(inc)
+		}
+		return 0, err
+	}
+
+	if opened {
+		err = a.storeIncident(inc, alarmID)
+		if err != nil {
+			a.Log(logger.Error, "Failed to create incident: %v", err)
+			// Continue processing even if grouping fails
+			return alarmID, nil
+		}
+	}
+
+	if inc != nil {
+		a.addToIncident(inc, alarmID, event, job.now)
+	}
+
+	return alarmID, nil
+}
+
+// loadPanels loads the intrusion panels of the bridge and their zone-to-camera mapping.
+func (a *Aalrm) loadPanels() error {
+	records, err := a.controlPlane.ListPanels(a.confdb.BridgeID())
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		panel := &dc09.Panel{
+			ID:     rec.ID,
+			SiteID: rec.SiteID,
+			Zones:  make(map[int]dc09.Zone),
+		}
+		for _, z := range rec.Zones {
+			panel.Zones[z.Zone] = dc09.Zone{
+				CameraID: z.CameraID,
+				CameraIP: z.CameraIP,
+			}
+		}
+		a.panels[rec.Account] = panel
+	}
+
+	a.Log(logger.Info, "Loaded %d intrusion panels", len(records))
+	return nil
+}
+
+// ReloadCameras is called by core when cameras are synced.
+// It doesn't block, since cameras are reloaded by the dispatcher.
+func (a *Aalrm) ReloadCameras() {
+	select {
+	case a.chReloadCameras <- struct{}{}:
+	default:
+	}
+}
+
+// loadCameras loads the addresses and timezones of registered cameras of the bridge,
+// in order to attribute alarms to cameras and to normalize alarm times.
+// It must be called by the dispatcher, that is the only user of the addresses.
+func (a *Aalrm) loadCameras() {
+	a.cameras = a.confdb.CameraIDs()
+
+	zones := a.confdb.CameraTimezones()
+	locations := make(map[int64]*time.Location, len(zones))
+
+	for id, zone := range zones {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			a.Log(logger.Warn, "Camera %d has an invalid timezone: %v", id, err)
+			continue
+		}
+		locations[id] = loc
+	}
+
+	a.times.SetLocations(locations)
+
+	a.Log(logger.Info, "Loaded %d camera addresses", len(a.cameras))
+}
+
+// alarmTime returns the time of an alarm in UTC.
+// The time reported by the camera is used, unless it is missing or its clock is skewed.
+func (a *Aalrm) alarmTime(event *defs.PublicAlarmInsert, now time.Time) time.Time {
+	if event.LastAlarmAt == nil {
+		return now
+	}
+
+	res, err := a.times.Normalize(event.CameraId, *event.LastAlarmAt, now)
+	if err != nil {
+		a.Log(logger.Warn, "Camera %d: %v, using receipt time", event.CameraId, err)
+		return now
+	}
+
+	if res.Corrected {
+		a.Log(logger.Warn, "Clock of camera %d is skewed by %v, using receipt time. Check its NTP settings",
+			event.CameraId, res.Skew)
+	}
+
+	return res.Time
+}
+
+// APIClockSkewsList is called by api.
+func (a *Aalrm) APIClockSkewsList() (*defs.APIClockSkewList, error) {
+	skews := a.times.Skews()
+
+	data := &defs.APIClockSkewList{
+		Items: make([]*defs.APIClockSkew, len(skews)),
+	}
+
+	for i, s := range skews {
+		data.Items[i] = &defs.APIClockSkew{
+			CameraID:    s.CameraID,
+			Skew:        s.Last.Seconds(),
+			MaxSkew:     s.Max.Seconds(),
+			Samples:     s.Samples,
+			Corrections: s.Corrections,
+			UpdatedAt:   s.UpdatedAt,
+		}
+	}
+
+	return data, nil
+}
+
+// updateArmStatus updates the arm status of a site when a panel reports an opening or closing.
+func (a *Aalrm) updateArmStatus(event *dc09Server.Event) {
+	status, ok := event.ArmStatus()
+	if !ok {
+		return
+	}
+
+	panel, _ := a.panels.Find(event.Account, event.Zone)
+	if panel == nil {
+		a.Log(logger.Warn, "Arm status change from unknown account %s", event.Account)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	err := a.controlPlane.SetArmStatus(panel.SiteID, status, now)
+	if err != nil {
+		a.Log(logger.Error, "Failed to update arm status of site %d: %v", panel.SiteID, err)
+		return
+	}
+
+	a.Log(logger.Info, "Site %d is now %sed by panel %s", panel.SiteID, status, event.Account)
+}
+
+// storeIncident stores a pending incident that starts with a stored alarm, and attaches the alarm to it.
+func (a *Aalrm) storeIncident(inc *incident, alarmID int64) error {
+	startedAt := inc.startedAt.Format(time.RFC3339)
+	incidentData := &defs.PublicIncidentInsert{
+		SiteId:      inc.siteID,
+		BridgeId:    a.confdb.BridgeID(),
+		CameraIds:   []int64{},
+		Timeline:    []incidentTimelineEntry{},
+		StartedAt:   &startedAt,
+		LastAlarmAt: &startedAt,
+	}
+
+	id, err := a.controlPlane.InsertIncident(incidentData)
+	if err != nil {
+		a.abandonIncident(inc)
+		return err
+	}
+
+	err = a.controlPlane.SetAlarmIncident(alarmID, id)
+	if err != nil {
+		// the incident is kept, since its timeline references the alarm
+		a.Log(logger.Error, "Failed to attach alarm %d to incident %d: %v", alarmID, id, err)
+	}
+
+	a.incidentsMutex.Lock()
+	inc.id = id
+	inc.pending = false
+	a.incidentsMutex.Unlock()
+	close(inc.ready)
+
+	a.Log(logger.Info, "Opened incident %d for site %d", id, inc.siteID)
+	return nil
+}
+
+// abandonIncident discards a pending incident that can't be stored.
+// Alarms that wait for it are not grouped.
+func (a *Aalrm) abandonIncident(inc *incident) {
+	a.incidentsMutex.Lock()
+	a.incidents.remove(inc)
+	inc.pending = false
+	a.incidentsMutex.Unlock()
+	close(inc.ready)
+}
+
+// addToIncident adds a stored alarm to the timeline of its incident, and writes
+// the timeline, cameras and clip request of the incident.
+func (a *Aalrm) addToIncident(inc *incident, alarmID int64, event *defs.PublicAlarmInsert, at time.Time) {
+	inc.writeMutex.Lock()
+	defer inc.writeMutex.Unlock()
+
+	a.incidentsMutex.Lock()
+	inc.add(alarmID, event.CameraId, event.AlarmName, event.AlarmType, at)
+	fields := inc.fields()
+	a.incidentsMutex.Unlock()
+
+	err := a.controlPlane.UpdateIncident(inc.id, fields)
+	if err != nil {
+		a.Log(logger.Error, "Failed to update incident %d: %v", inc.id, err)
+	}
+}
+
+// closeIncidents marks incidents as closed.
+func (a *Aalrm) closeIncidents(incs []*incident) {
+	for _, inc := range incs {
+		now := time.Now().UTC()
+
+		err := a.controlPlane.UpdateIncident(inc.id, map[string]interface{}{
+			"status":     "closed",
+			"closed_at":  now,
+			"updated_at": now,
+		})
+		if err != nil {
+			a.Log(logger.Error, "Failed to close incident %d: %v", inc.id, err)
+			continue
+		}
+
+		a.incidentsMutex.Lock()
+		alarmCount, cameraCount := len(inc.timeline), len(inc.cameraIDs)
+		a.incidentsMutex.Unlock()
+
+		a.Log(logger.Info, "Closed incident %d (%d alarms, %d cameras)", inc.id, alarmCount, cameraCount)
+	}
+}
+
 // Stop stops the alarm manager
 func (a *Aalrm) Close() {
 	a.Log(logger.Info, "Stopping AlarmManager")
 
+	if !interfaceIsEmpty(a.Metrics) {
+		a.Metrics.SetAlarmManager(nil)
+	}
+
 	a.ctxCancel()
 	a.wg.Wait()
 }
 
-// UploadRecordingsToBucket uploads recordings folder as zip to Supabase storage bucket and returns public URL
-func (a *Aalrm) UploadRecordingsToBucket(event *defs.PublicAlarmInsert, data any) (string, error) {
-	mail, ok := data.(*smtpServer.Mail)
-	if !ok {
-		return "", fmt.Errorf("data is not a smtpServer.Mail")
+// cameraIP returns the address of the camera that originated an alarm.
+func (a *Aalrm) cameraIP(event *defs.PublicAlarmInsert, data any) (string, error) {
+	switch data := data.(type) {
+	case *smtpServer.Mail:
+		return data.FromIP, nil
+
+	case *dc09Server.Event:
+		_, zone := a.panels.Find(data.Account, data.Zone)
+		if zone == nil || zone.CameraIP == "" {
+			return "", fmt.Errorf("no camera is mapped to zone %s", data.Zone)
+		}
+		return zone.CameraIP, nil
+
+	case *syslogServer.Message:
+		return data.FromIP, nil
+
+	case *metadatareader.Event:
+		// paths are named after the address of their camera
+		return data.PathName, nil
 	}
 
+	return "", fmt.Errorf("unsupported alarm source %T", data)
+}
+
+// UploadRecordingsToBucket uploads recordings folder as zip to media storage and returns its URL.
+// The archive is streamed to the storage while it is created.
+func (a *Aalrm) UploadRecordingsToBucket(
+	ctx context.Context, event *defs.PublicAlarmInsert, data any,
+) (string, error) {
 	// Get camera information to find the recordings folder
-	cameraIP := mail.FromIP
+	cameraIP, err := a.cameraIP(event, data)
+	if err != nil {
+		return "", err
+	}
 
 	recordingsPath := fmt.Sprintf("./recordings/%s", cameraIP)
 
@@ -171,12 +642,38 @@ func (a *Aalrm) UploadRecordingsToBucket(event *defs.PublicAlarmInsert, data any
 		return "", fmt.Errorf("recordings folder does not exist: %s", recordingsPath)
 	}
 
-	// Create zip buffer
-	var zipBuffer bytes.Buffer
-	zipWriter := zip.NewWriter(&zipBuffer)
+	// Generate unique filename
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	filename := fmt.Sprintf("recordings_%s_camera_%d_site_%d.zip", timestamp, event.CameraId, event.SiteId)
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	go func() {
+		pw.CloseWithError(a.zipFolder(recordingsPath, pw))
+	}()
+
+	var size int64
+
+	publicURL, err := a.controlPlane.UploadMedia(ctx, filename, "application/zip", pr, func(uploaded int64) {
+		size = uploaded
+		a.Log(logger.Debug, "Uploading %s: %d bytes sent", filename, uploaded)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload recordings zip to bucket: %w", err)
+	}
+
+	a.Log(logger.Info, "Successfully uploaded recordings zip: %s (size: %d bytes)", filename, size)
+	a.Log(logger.Info, "Public URL: %s", publicURL)
+	return publicURL, nil
+}
+
+// zipFolder writes the files of a folder into a zip archive.
+func (a *Aalrm) zipFolder(folder string, w io.Writer) error {
+	zipWriter := zip.NewWriter(w)
 
 	// Walk through recordings folder and add files to zip
-	err := filepath.Walk(recordingsPath, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -187,67 +684,35 @@ func (a *Aalrm) UploadRecordingsToBucket(event *defs.PublicAlarmInsert, data any
 		}
 
 		// Create relative path for zip
-		relPath, err := filepath.Rel(recordingsPath, path)
+		relPath, err := filepath.Rel(folder, path)
 		if err != nil {
-			a.Log(logger.Error, "Failed to create relative path: %v", err)
 			return err
 		}
 
 		// Create zip file entry
 		zipFile, err := zipWriter.Create(relPath)
 		if err != nil {
-			a.Log(logger.Error, "Failed to create zip file entry: %v", err)
 			return err
 		}
 
 		// Open and copy file content
 		file, err := os.Open(path)
 		if err != nil {
-			a.Log(logger.Error, "Failed to open file: %v", err)
 			return err
 		}
 		defer file.Close()
 
 		_, err = io.Copy(zipFile, file)
-		if err != nil {
-			a.Log(logger.Error, "Failed to copy file: %v", err)
-		}
 		return err
 	})
-
 	if err != nil {
-		return "", fmt.Errorf("failed to create zip file: %w", err)
+		return fmt.Errorf("failed to create zip file: %w", err)
 	}
 
-	// Close zip writer
 	err = zipWriter.Close()
 	if err != nil {
-		return "", fmt.Errorf("failed to close zip writer: %w", err)
+		return fmt.Errorf("failed to close zip writer: %w", err)
 	}
 
-	// Generate unique filename
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("recordings_%s_camera_%d_site_%d.zip", timestamp, event.CameraId, event.SiteId)
-
-	// Get zip content
-	zipContent := zipBuffer.Bytes()
-	contentType := "application/zip"
-	upsert := false
-
-	// Upload to alarm-snapshots bucket
-	_, err = a.supabaseClient.Storage.UploadFile("alarm-snapshots", filename, bytes.NewReader(zipContent), storage.FileOptions{
-		ContentType: &contentType,
-		Upsert:      &upsert,
-	})
-
-	if err != nil {
-		return "", fmt.Errorf("failed to upload recordings zip to bucket: %w", err)
-	}
-
-	// Generate public URL for the uploaded file
-	publicURL := fmt.Sprintf("%s/storage/v1/object/public/alarm-snapshots/%s", a.conf.SupabaseURL, filename)
-
-	a.Log(logger.Info, "Successfully uploaded recordings zip: %s (size: %d bytes)", filename, len(zipContent))
-	a.Log(logger.Info, "Public URL: %s", publicURL)
-	return publicURL, nil
+	return nil
 }
@@ -0,0 +1,51 @@
+package alarm
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipFolder(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-alarm")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.MkdirAll(filepath.Join(dir, "2025-01-01"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "2025-01-01", "a.mp4"), []byte("first"), 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "b.mp4"), []byte("second"), 0o644)
+	require.NoError(t, err)
+
+	a := &Aalrm{}
+
+	var buf bytes.Buffer
+	err = a.zipFolder(dir, &buf)
+	require.NoError(t, err)
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	require.Equal(t, map[string]string{
+		filepath.Join("2025-01-01", "a.mp4"): "first",
+		"b.mp4":                              "second",
+	}, files)
+}
@@ -0,0 +1,187 @@
+// Package onvif contains the parser of events received in ONVIF metadata streams.
+package onvif
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmcamera"
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmtype"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/metadatareader"
+	"github.com/kaonmir/mini-chekt/internal/protocols/onvifmeta"
+)
+
+const (
+	// objects that are not seen for this duration are considered new when they reappear.
+	objectTimeout = 10 * time.Second
+)
+
+type parserParent interface {
+	logger.Writer
+}
+
+// NewParser allocates a Parser.
+func NewParser(parent parserParent, cameras *alarmcamera.Cameras) *Parser {
+	return &Parser{
+		parent:  parent,
+		cameras: cameras,
+		objects: make(map[string]time.Time),
+	}
+}
+
+// Parser converts events of ONVIF metadata streams into alarms.
+// Paths are named after the address of their camera.
+type Parser struct {
+	parent  parserParent
+	cameras *alarmcamera.Cameras
+
+	// last time objects have been seen, by path and object ID
+	objects map[string]time.Time
+}
+
+func (p *Parser) Log(level logger.Level, format string, args ...interface{}) {
+	p.parent.Log(level, "[OnvifParser] "+format, args...)
+}
+
+// isActive checks whether a notification reports the start of an event.
+// Stateful events carry a boolean item, i.e. IsMotion=true, while
+// events without state, like line crossings, are always active.
+func isActive(n *onvifmeta.Notification) bool {
+	// the initial state of properties is sent when the stream starts
+	switch n.Message.PropertyOperation {
+	case "Initialized", "Deleted":
+		return false
+	}
+
+	hasState := false
+	for _, item := range n.Message.Data.SimpleItems {
+		v, err := strconv.ParseBool(item.Value)
+		if err != nil {
+			continue
+		}
+		if v {
+			return true
+		}
+		hasState = true
+	}
+
+	return !hasState
+}
+
+func findNotification(ev *metadatareader.Event) (*onvifmeta.Notification, alarmtype.Type, bool) {
+	for i := range ev.Notifications {
+		n := &ev.Notifications[i]
+		t, ok := alarmtype.ONVIF.Find(n.TopicPath())
+		if ok && isActive(n) {
+			return n, t, true
+		}
+	}
+	return nil, "", false
+}
+
+func hasObjects(ev *metadatareader.Event) bool {
+	for _, f := range ev.Frames {
+		for _, o := range f.Objects {
+			class, _ := o.BestClass()
+			if _, ok := alarmtype.ONVIFObjects.Find(class); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsAlarm checks if the event contains an active notification or a detected object.
+func (p *Parser) IsAlarm(data interface{}) (bool, error) {
+	ev, ok := data.(*metadatareader.Event)
+	if !ok {
+		return false, fmt.Errorf("data is not a *metadatareader.Event")
+	}
+
+	if _, _, ok := findNotification(ev); ok {
+		return true, nil
+	}
+
+	return hasObjects(ev), nil
+}
+
+// ParseAlarm converts an event into an alarm.
+// Objects that were already detected do not generate alarms, and nil is returned.
+func (p *Parser) ParseAlarm(data interface{}) (*defs.PublicAlarmInsert, error) {
+	ev, ok := data.(*metadatareader.Event)
+	if !ok {
+		return nil, fmt.Errorf("data is not a *metadatareader.Event")
+	}
+
+	cameraID, ok := (*p.cameras)[ev.PathName]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a registered camera", ev.PathName)
+	}
+
+	alarm := &defs.PublicAlarmInsert{
+		CameraId: cameraID,
+	}
+
+	// objects are tracked even when a notification is reported in the same document
+	class, objectType, newObject := p.newObject(ev)
+
+	if n, t, ok := findNotification(ev); ok {
+		topic := n.TopicPath()
+		name := topic
+		if rule, ok := n.Message.Source.Get("Rule"); ok && rule != "" {
+			name = fmt.Sprintf("%s (%s)", rule, topic)
+		}
+
+		alarm.AlarmName = name
+		alarmtype.Set(alarm, t, topic)
+	} else if newObject {
+		alarm.AlarmName = fmt.Sprintf("%s detected", class)
+		alarmtype.Set(alarm, objectType, class)
+	} else {
+		return nil, nil
+	}
+
+	// the NTP timestamp of the document is aligned with the video of the path
+	ts := ev.NTP.UTC().Format(time.RFC3339Nano)
+	alarm.LastAlarmAt = &ts
+
+	p.Log(logger.Info, "Parsed ONVIF alarm: %s from %s", alarm.AlarmName, ev.PathName)
+
+	return alarm, nil
+}
+
+// newObject returns the class of the first object that has not been seen recently.
+func (p *Parser) newObject(ev *metadatareader.Event) (string, alarmtype.Type, bool) {
+	for key, seen := range p.objects {
+		if ev.NTP.Sub(seen) > objectTimeout {
+			delete(p.objects, key)
+		}
+	}
+
+	var retClass string
+	var retType alarmtype.Type
+	found := false
+
+	for _, f := range ev.Frames {
+		for _, o := range f.Objects {
+			class, _ := o.BestClass()
+			t, ok := alarmtype.ONVIFObjects.Find(class)
+			if !ok {
+				continue
+			}
+
+			key := ev.PathName + "/" + o.ObjectID
+			_, seen := p.objects[key]
+			p.objects[key] = ev.NTP
+
+			if !seen && !found {
+				retClass, retType, found = class, t, true
+			}
+		}
+	}
+
+	return retClass, retType, found
+}
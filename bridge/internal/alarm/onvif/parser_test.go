@@ -0,0 +1,159 @@
+package onvif
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmcamera"
+	"github.com/kaonmir/mini-chekt/internal/metadatareader"
+	"github.com/kaonmir/mini-chekt/internal/protocols/onvifmeta"
+	"github.com/kaonmir/mini-chekt/internal/test"
+)
+
+func notification(topic string, operation string, data ...onvifmeta.SimpleItem) onvifmeta.Notification {
+	return onvifmeta.Notification{
+		Topic: topic,
+		Message: onvifmeta.Message{
+			PropertyOperation: operation,
+			Source: onvifmeta.Items{SimpleItems: []onvifmeta.SimpleItem{
+				{Name: "Rule", Value: "MyRule"},
+			}},
+			Data: onvifmeta.Items{SimpleItems: data},
+		},
+	}
+}
+
+func objects(ids ...string) []onvifmeta.Frame {
+	f := onvifmeta.Frame{}
+	for _, id := range ids {
+		f.Objects = append(f.Objects, onvifmeta.Object{
+			ObjectID: id,
+			Class: onvifmeta.Class{
+				Types: []onvifmeta.ClassType{{Likelihood: 0.9, Value: "Vehicle"}},
+			},
+		})
+	}
+	return []onvifmeta.Frame{f}
+}
+
+func TestParserNotifications(t *testing.T) {
+	cameras := alarmcamera.Cameras{"192.168.0.10": 7}
+	p := NewParser(test.NilLogger, &cameras)
+
+	ntp := time.Date(2025, 3, 10, 1, 0, 0, 500000000, time.UTC)
+
+	for _, ca := range []struct {
+		name         string
+		notification onvifmeta.Notification
+		isAlarm      bool
+	}{
+		{
+			"initialized",
+			notification("tns1:RuleEngine/CellMotionDetector/Motion", "Initialized",
+				onvifmeta.SimpleItem{Name: "IsMotion", Value: "true"}),
+			false,
+		},
+		{
+			"inactive",
+			notification("tns1:RuleEngine/CellMotionDetector/Motion", "Changed",
+				onvifmeta.SimpleItem{Name: "IsMotion", Value: "false"}),
+			false,
+		},
+		{
+			"unknown topic",
+			notification("tns1:Monitoring/ProcessorUsage", "Changed",
+				onvifmeta.SimpleItem{Name: "Value", Value: "true"}),
+			false,
+		},
+		{
+			"stateless",
+			notification("tns1:RuleEngine/LineDetector/Crossed", "",
+				onvifmeta.SimpleItem{Name: "ObjectId", Value: "3"}),
+			true,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			ok, err := p.IsAlarm(&metadatareader.Event{
+				PathName:      "192.168.0.10",
+				NTP:           ntp,
+				Notifications: []onvifmeta.Notification{ca.notification},
+			})
+			require.NoError(t, err)
+			require.Equal(t, ca.isAlarm, ok)
+		})
+	}
+
+	alarm, err := p.ParseAlarm(&metadatareader.Event{
+		PathName: "192.168.0.10",
+		NTP:      ntp,
+		Notifications: []onvifmeta.Notification{
+			notification("tns1:RuleEngine/FieldDetector/ObjectsInside", "Changed",
+				onvifmeta.SimpleItem{Name: "IsInside", Value: "true"}),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "MyRule (RuleEngine/FieldDetector/ObjectsInside)", alarm.AlarmName)
+	require.Equal(t, "intrusion", alarm.AlarmType)
+	require.Equal(t, int64(7), alarm.CameraId)
+	require.Equal(t, "RuleEngine/FieldDetector/ObjectsInside", *alarm.VendorEvent)
+	require.Equal(t, "2025-03-10T01:00:00.5Z", *alarm.LastAlarmAt)
+
+	_, err = p.ParseAlarm(&metadatareader.Event{
+		PathName: "192.168.0.11",
+		Notifications: []onvifmeta.Notification{
+			notification("tns1:VideoSource/MotionAlarm", "Changed",
+				onvifmeta.SimpleItem{Name: "State", Value: "true"}),
+		},
+	})
+	require.EqualError(t, err, "192.168.0.11 is not a registered camera")
+}
+
+func TestParserObjects(t *testing.T) {
+	cameras := alarmcamera.Cameras{"192.168.0.10": 7}
+	p := NewParser(test.NilLogger, &cameras)
+
+	ntp := time.Date(2025, 3, 10, 1, 0, 0, 0, time.UTC)
+
+	parse := func(at time.Duration, ids ...string) string {
+		ev := &metadatareader.Event{
+			PathName: "192.168.0.10",
+			NTP:      ntp.Add(at),
+			Frames:   objects(ids...),
+		}
+
+		ok, err := p.IsAlarm(ev)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		alarm, err := p.ParseAlarm(ev)
+		require.NoError(t, err)
+		if alarm == nil {
+			return ""
+		}
+		require.Equal(t, "vehicle", alarm.AlarmType)
+		return alarm.AlarmName
+	}
+
+	require.Equal(t, "Vehicle detected", parse(0, "1"))
+	require.Equal(t, "", parse(1*time.Second, "1"))
+	require.Equal(t, "Vehicle detected", parse(2*time.Second, "1", "2"))
+	require.Equal(t, "", parse(5*time.Second, "1", "2"))
+
+	// object 1 is tracked again after it disappeared
+	require.Equal(t, "", parse(14*time.Second, "2"))
+	require.Equal(t, "Vehicle detected", parse(20*time.Second, "1"))
+
+	ok, err := p.IsAlarm(&metadatareader.Event{
+		PathName: "192.168.0.10",
+		Frames: []onvifmeta.Frame{{Objects: []onvifmeta.Object{{
+			ObjectID: "4",
+			Class: onvifmeta.Class{
+				ClassCandidates: []onvifmeta.ClassCandidate{{Type: "Animal", Likelihood: 0.8}},
+			},
+		}}}},
+	})
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -1,11 +1,13 @@
 package smtp
 
 import (
-	"encoding/base64"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmcamera"
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmtime"
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmtype"
 	"github.com/kaonmir/mini-chekt/internal/defs"
 	"github.com/kaonmir/mini-chekt/internal/logger"
 	"github.com/kaonmir/mini-chekt/internal/servers/smtp"
@@ -15,20 +17,34 @@ type dahuaParserParent interface {
 	logger.Writer
 }
 
-func NewDahuaParser(parent dahuaParserParent) *DahuaParser {
+func NewDahuaParser(parent dahuaParserParent, cameras *alarmcamera.Cameras) *DahuaParser {
 	return &DahuaParser{
-		parent: parent,
+		parent:  parent,
+		cameras: cameras,
 	}
 }
 
+// DahuaParser parses alarm emails of Dahua devices.
+// Alarms are attributed to the camera that sent the email.
 type DahuaParser struct {
-	parent dahuaParserParent
+	parent  dahuaParserParent
+	cameras *alarmcamera.Cameras
 }
 
 func (d *DahuaParser) Log(level logger.Level, format string, args ...interface{}) {
 	d.parent.Log(level, "[DahuaParser] "+format, args...)
 }
 
+// text returns the decoded text of an email, or its raw content when it has no text part.
+func (d *DahuaParser) text(email *smtp.Mail) string {
+	if content, ok := email.Text(); ok {
+		return content
+	}
+
+	d.Log(logger.Debug, "Email has no text parts, using raw content")
+	return string(email.Content)
+}
+
 // IsAlarm checks if the email content contains a Dahua alarm event
 func (d *DahuaParser) IsAlarm(data interface{}) (bool, error) {
 	email, ok := data.(*smtp.Mail)
@@ -36,31 +52,8 @@ func (d *DahuaParser) IsAlarm(data interface{}) (bool, error) {
 		return false, fmt.Errorf("data is not a *smtp.Mail")
 	}
 
-	// Check if Parts array is empty
-	if len(email.Parts) == 0 {
-		d.Log(logger.Debug, "Email has no parts, using raw content")
-		// Use raw content as fallback
-		contentStr := string(email.Content)
-		return (strings.HasPrefix(strings.TrimSpace(contentStr), "Alarm Event:") ||
-			strings.Contains(contentStr, "Alarm Device Name:") ||
-			strings.Contains(contentStr, "Alarm Input Channel:")), nil
-	}
-
-	if !strings.HasPrefix(email.Parts[0].ContentType, "text/plain") {
-		d.Log(logger.Debug, "Email content type is not text/plain: %s", email.Parts[0].ContentType)
-		return false, nil
-	}
-
-	// Try to decode as base64 first
-	content, err := base64.StdEncoding.DecodeString(string(email.Parts[0].Content))
-	if err != nil {
-		d.Log(logger.Debug, "Content is not base64 encoded, using raw content")
-		// If not base64, use raw content
-		content = email.Parts[0].Content
-	}
-
 	// Check for Dahua-specific alarm indicators
-	contentStr := string(content)
+	contentStr := d.text(email)
 	return (strings.HasPrefix(strings.TrimSpace(contentStr), "Alarm Event:") ||
 		strings.Contains(contentStr, "Alarm Device Name:") ||
 		strings.Contains(contentStr, "Alarm Input Channel:")), nil
@@ -73,23 +66,7 @@ func (d *DahuaParser) ParseAlarm(data interface{}) (*defs.PublicAlarmInsert, err
 		return nil, fmt.Errorf("data is not a *smtp.Mail")
 	}
 
-	var content string
-
-	// Check if Parts array is empty
-	if len(email.Parts) == 0 {
-		d.Log(logger.Debug, "Email has no parts, using raw content")
-		content = string(email.Content)
-	} else {
-		// Try to decode as base64 first
-		decodedContent, err := base64.StdEncoding.DecodeString(string(email.Parts[0].Content))
-		if err != nil {
-			d.Log(logger.Debug, "Content is not base64 encoded, using raw content")
-			// If not base64, use raw content
-			content = string(email.Parts[0].Content)
-		} else {
-			content = string(decodedContent)
-		}
-	}
+	content := d.text(email)
 
 	lines := strings.Split(content, "\n")
 	dahuaData := &defs.PublicAlarmInsert{}
@@ -106,20 +83,17 @@ func (d *DahuaParser) ParseAlarm(data interface{}) (*defs.PublicAlarmInsert, err
 			dahuaData.AlarmName = strings.TrimSpace(strings.TrimPrefix(line, "Alarm Event:"))
 		} else if strings.HasPrefix(line, "Alarm Start Time(D/M/Y H:M:S):") || strings.HasPrefix(line, "Alarm Stop Time(D/M/Y H:M:S):") {
 			timeStr := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "Alarm Start Time(D/M/Y H:M:S):"), "Alarm Stop Time(D/M/Y H:M:S):"))
+			// cameras report the wall clock time of their own timezone
 			if parsedTime, err := time.Parse("02/01/2006 15:04:05", timeStr); err == nil {
-				parsedTimeStr := parsedTime.Format(time.RFC3339)
+				parsedTimeStr := parsedTime.Format(alarmtime.LocalLayout)
 				dahuaData.LastAlarmAt = &parsedTimeStr
 			} else {
 				// Try alternative format if the first one fails
 				if parsedTime, err := time.Parse("2006-01-02 15:04:05", timeStr); err == nil {
-					parsedTimeStr := parsedTime.Format(time.RFC3339)
+					parsedTimeStr := parsedTime.Format(alarmtime.LocalLayout)
 					dahuaData.LastAlarmAt = &parsedTimeStr
 				}
 			}
-		} else if strings.HasPrefix(line, "Alarm Device Name:") {
-			dahuaData.CameraId = 1 // TODO!!!!
-		} else if strings.HasPrefix(line, "IP Address:") {
-			dahuaData.BridgeId = 1 // TODO!!!
 		}
 	}
 
@@ -129,15 +103,26 @@ func (d *DahuaParser) ParseAlarm(data interface{}) (*defs.PublicAlarmInsert, err
 		return nil, fmt.Errorf("missing required field: Alarm Event")
 	}
 
-	d.Log(logger.Info, "Parsed Dahua alarm event: %s from device %d", dahuaData.AlarmName, dahuaData.CameraId)
+	cameraID, ok := (*d.cameras)[email.FromIP]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a registered camera", email.FromIP)
+	}
+
+	d.Log(logger.Info, "Parsed Dahua alarm event: %s from camera %d", dahuaData.AlarmName, cameraID)
 
 	// Convert LegacyType to Event
 	event := &defs.PublicAlarmInsert{
 		AlarmName:   dahuaData.AlarmName,
 		LastAlarmAt: dahuaData.LastAlarmAt,
-		CameraId:    dahuaData.CameraId,
-		BridgeId:    dahuaData.BridgeId,
+		CameraId:    cameraID,
+	}
+
+	alarmType, ok := alarmtype.Dahua.Find(dahuaData.AlarmName)
+	if !ok {
+		d.Log(logger.Warn, "Unknown Dahua event '%s', using type %s", dahuaData.AlarmName, alarmtype.System)
+		alarmType = alarmtype.System
 	}
+	alarmtype.Set(event, alarmType, dahuaData.AlarmName)
 
 	return event, nil
 }
@@ -0,0 +1,42 @@
+package smtp
+
+import (
+	"testing"
+
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmcamera"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/servers/smtp"
+	"github.com/stretchr/testify/require"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+func TestDahuaParser(t *testing.T) {
+	cameras := alarmcamera.Cameras{"192.168.1.10": 3, "192.168.1.11": 4}
+	p := NewDahuaParser(nilLogger{}, &cameras)
+
+	content := "Alarm Event: Motion Detection\r\n" +
+		"Alarm Input Channel: 1\r\n" +
+		"Alarm Start Time(D/M/Y H:M:S): 10/03/2025 10:00:00\r\n" +
+		"Alarm Device Name: IPC\r\n" +
+		"IP Address: 192.168.1.10\r\n"
+
+	// emails are attributed to their sender, regardless of the address in their content
+	mail := &smtp.Mail{FromIP: "192.168.1.11", Content: []byte(content)}
+
+	ok, err := p.IsAlarm(mail)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	alarm, err := p.ParseAlarm(mail)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), alarm.CameraId)
+	require.Equal(t, int64(0), alarm.BridgeId)
+	require.Equal(t, "Motion Detection", alarm.AlarmName)
+	require.Equal(t, "2025-03-10T10:00:00", *alarm.LastAlarmAt)
+
+	_, err = p.ParseAlarm(&smtp.Mail{FromIP: "10.0.0.5", Content: []byte(content)})
+	require.EqualError(t, err, "10.0.0.5 is not a registered camera")
+}
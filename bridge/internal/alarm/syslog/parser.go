@@ -0,0 +1,223 @@
+// Package syslog contains parsers for camera and NVR security events received over syslog.
+package syslog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmcamera"
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmtime"
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmtype"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/servers/syslog"
+)
+
+// descriptions of security events.
+const (
+	eventUnauthorizedLogin = "Unauthorized login attempt"
+	eventStorageFailure    = "Storage failure"
+	eventStorageFull       = "Storage full"
+	eventTamper            = "Tamper"
+	eventVideoLoss         = "Video loss"
+	eventConfigChange      = "Configuration change"
+	eventNetworkFailure    = "Network failure"
+	eventIPConflict        = "IP address conflict"
+)
+
+// event codes of Dahua devices.
+var dahuaCodes = map[string]string{
+	"LoginFailure":     eventUnauthorizedLogin,
+	"AccountLocked":    eventUnauthorizedLogin,
+	"StorageFailure":   eventStorageFailure,
+	"StorageNotExist":  eventStorageFailure,
+	"StorageReadError": eventStorageFailure,
+	"StorageLowSpace":  eventStorageFull,
+	"StorageFull":      eventStorageFull,
+	"VideoBlind":       eventTamper,
+	"VideoAbnormal":    eventTamper,
+	"VideoLoss":        eventVideoLoss,
+	"ConfigChange":     eventConfigChange,
+	"NetAbort":         eventNetworkFailure,
+	"IPConflict":       eventIPConflict,
+}
+
+// log types of Hikvision devices.
+var hikvisionTypes = []struct {
+	minorType   string
+	description string
+}{
+	{"illegal login", eventUnauthorizedLogin},
+	{"login locked", eventUnauthorizedLogin},
+	{"hdd error", eventStorageFailure},
+	{"hdd not exist", eventStorageFailure},
+	{"hdd full", eventStorageFull},
+	{"video tampering", eventTamper},
+	{"video signal loss", eventVideoLoss},
+	{"video loss", eventVideoLoss},
+	{"remote: parameters config", eventConfigChange},
+	{"local: parameters config", eventConfigChange},
+	{"network disconnected", eventNetworkFailure},
+	{"ip address conflicted", eventIPConflict},
+}
+
+type parserParent interface {
+	logger.Writer
+}
+
+// NewDahuaParser allocates a DahuaParser.
+func NewDahuaParser(parent parserParent, cameras *alarmcamera.Cameras) *DahuaParser {
+	return &DahuaParser{
+		parent:  parent,
+		cameras: cameras,
+	}
+}
+
+// DahuaParser parses security events of Dahua devices.
+// Events are reported with their code, like "Code=LoginFailure;action=Start".
+type DahuaParser struct {
+	parent  parserParent
+	cameras *alarmcamera.Cameras
+}
+
+func (p *DahuaParser) Log(level logger.Level, format string, args ...interface{}) {
+	p.parent.Log(level, "[SyslogDahuaParser] "+format, args...)
+}
+
+func dahuaCode(content string) (string, bool) {
+	fields := strings.FieldsFunc(content, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9')
+	})
+
+	for i, f := range fields {
+		if _, ok := dahuaCodes[f]; ok {
+			// events with a duration are notified when they start and when they stop
+			if i+2 < len(fields) && strings.EqualFold(fields[i+1], "action") && strings.EqualFold(fields[i+2], "Stop") {
+				return "", false
+			}
+			return f, true
+		}
+	}
+
+	return "", false
+}
+
+// IsAlarm checks if the message contains a Dahua security event.
+func (p *DahuaParser) IsAlarm(data interface{}) (bool, error) {
+	m, ok := data.(*syslog.Message)
+	if !ok {
+		return false, fmt.Errorf("data is not a *syslog.Message")
+	}
+
+	_, ok = dahuaCode(m.Content)
+	return ok, nil
+}
+
+// ParseAlarm converts a Dahua security event into an alarm.
+func (p *DahuaParser) ParseAlarm(data interface{}) (*defs.PublicAlarmInsert, error) {
+	m, ok := data.(*syslog.Message)
+	if !ok {
+		return nil, fmt.Errorf("data is not a *syslog.Message")
+	}
+
+	code, ok := dahuaCode(m.Content)
+	if !ok {
+		return nil, fmt.Errorf("message does not contain a Dahua event")
+	}
+
+	return mapAlarm(p, *p.cameras, m, code, dahuaCodes[code], alarmtype.Dahua.Map(code))
+}
+
+// NewHikvisionParser allocates a HikvisionParser.
+func NewHikvisionParser(parent parserParent, cameras *alarmcamera.Cameras) *HikvisionParser {
+	return &HikvisionParser{
+		parent:  parent,
+		cameras: cameras,
+	}
+}
+
+// HikvisionParser parses security events of Hikvision devices.
+// Events are reported with the description of their log type, like "Minor Type: Illegal Login".
+type HikvisionParser struct {
+	parent  parserParent
+	cameras *alarmcamera.Cameras
+}
+
+func (p *HikvisionParser) Log(level logger.Level, format string, args ...interface{}) {
+	p.parent.Log(level, "[SyslogHikvisionParser] "+format, args...)
+}
+
+func hikvisionType(content string) (int, bool) {
+	content = strings.ToLower(content)
+
+	for i, t := range hikvisionTypes {
+		if strings.Contains(content, t.minorType) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// IsAlarm checks if the message contains a Hikvision security event.
+func (p *HikvisionParser) IsAlarm(data interface{}) (bool, error) {
+	m, ok := data.(*syslog.Message)
+	if !ok {
+		return false, fmt.Errorf("data is not a *syslog.Message")
+	}
+
+	_, ok = hikvisionType(m.Content)
+	return ok, nil
+}
+
+// ParseAlarm converts a Hikvision security event into an alarm.
+func (p *HikvisionParser) ParseAlarm(data interface{}) (*defs.PublicAlarmInsert, error) {
+	m, ok := data.(*syslog.Message)
+	if !ok {
+		return nil, fmt.Errorf("data is not a *syslog.Message")
+	}
+
+	i, ok := hikvisionType(m.Content)
+	if !ok {
+		return nil, fmt.Errorf("message does not contain a Hikvision event")
+	}
+
+	t := hikvisionTypes[i]
+	return mapAlarm(p, *p.cameras, m, t.minorType, t.description, alarmtype.Hikvision.Map(t.minorType))
+}
+
+func mapAlarm(
+	l logger.Writer,
+	cameras alarmcamera.Cameras,
+	m *syslog.Message,
+	event string,
+	description string,
+	alarmType alarmtype.Type,
+) (*defs.PublicAlarmInsert, error) {
+	cameraID, ok := cameras[m.FromIP]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a registered camera", m.FromIP)
+	}
+
+	alarm := &defs.PublicAlarmInsert{
+		AlarmName: fmt.Sprintf("%s (%s)", description, event),
+		CameraId:  cameraID,
+	}
+	alarmtype.Set(alarm, alarmType, event)
+
+	if m.Timestamp != nil {
+		var ts string
+		if m.Format == syslog.FormatRFC3164 {
+			// RFC 3164 timestamps are wall clock times of the camera, without offset
+			ts = m.Timestamp.Format(alarmtime.LocalLayout)
+		} else {
+			ts = m.Timestamp.UTC().Format(time.RFC3339)
+		}
+		alarm.LastAlarmAt = &ts
+	}
+
+	l.Log(logger.Info, "Parsed syslog alarm: %s from %s", alarm.AlarmName, m.FromIP)
+
+	return alarm, nil
+}
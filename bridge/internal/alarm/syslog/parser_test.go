@@ -0,0 +1,121 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/kaonmir/mini-chekt/internal/alarm/alarmcamera"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/servers/syslog"
+	"github.com/stretchr/testify/require"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+func TestParsers(t *testing.T) {
+	cameras := alarmcamera.Cameras{"192.168.1.10": 3}
+
+	dahua := NewDahuaParser(nilLogger{}, &cameras)
+	hik := NewHikvisionParser(nilLogger{}, &cameras)
+
+	for _, ca := range []struct {
+		name      string
+		content   string
+		dahua     bool
+		hikvision bool
+		alarmName string
+		alarmType string
+	}{
+		{
+			"dahua login failure",
+			"Code=LoginFailure;action=Pulse;index=0;data={\"Name\":\"admin\",\"Address\":\"10.0.0.5\"}",
+			true,
+			false,
+			"Unauthorized login attempt (LoginFailure)",
+			"system",
+		},
+		{
+			"dahua storage failure",
+			"Event: StorageFailure, Channel: 0",
+			true,
+			false,
+			"Storage failure (StorageFailure)",
+			"system",
+		},
+		{
+			"dahua event stop",
+			"Code=VideoBlind;action=Stop;index=0",
+			false,
+			false,
+			"",
+			"",
+		},
+		{
+			"hikvision illegal login",
+			"Major Type: Exception, Minor Type: Illegal Login, Remote Host IP: 10.0.0.5",
+			false,
+			true,
+			"Unauthorized login attempt (illegal login)",
+			"system",
+		},
+		{
+			"hikvision hdd full",
+			"Major Type: Exception, Minor Type: HDD Full",
+			false,
+			true,
+			"Storage full (hdd full)",
+			"system",
+		},
+		{
+			"unrelated",
+			"user admin logged in",
+			false,
+			false,
+			"",
+			"",
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			m := &syslog.Message{
+				Content: ca.content,
+				FromIP:  "192.168.1.10",
+			}
+
+			ok, err := dahua.IsAlarm(m)
+			require.NoError(t, err)
+			require.Equal(t, ca.dahua, ok)
+
+			ok, err = hik.IsAlarm(m)
+			require.NoError(t, err)
+			require.Equal(t, ca.hikvision, ok)
+
+			switch {
+			case ca.dahua:
+				alarm, err := dahua.ParseAlarm(m)
+				require.NoError(t, err)
+				require.Equal(t, ca.alarmName, alarm.AlarmName)
+				require.Equal(t, ca.alarmType, alarm.AlarmType)
+				require.Equal(t, int64(3), alarm.CameraId)
+
+			case ca.hikvision:
+				alarm, err := hik.ParseAlarm(m)
+				require.NoError(t, err)
+				require.Equal(t, ca.alarmName, alarm.AlarmName)
+				require.Equal(t, ca.alarmType, alarm.AlarmType)
+				require.Equal(t, int64(3), alarm.CameraId)
+			}
+		})
+	}
+}
+
+func TestParserUnknownCamera(t *testing.T) {
+	cameras := alarmcamera.Cameras{}
+	dahua := NewDahuaParser(nilLogger{}, &cameras)
+
+	_, err := dahua.ParseAlarm(&syslog.Message{
+		Content: "Code=LoginFailure;action=Pulse",
+		FromIP:  "192.168.1.20",
+	})
+	require.EqualError(t, err, "192.168.1.20 is not a registered camera")
+}
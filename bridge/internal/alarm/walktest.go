@@ -0,0 +1,329 @@
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+// ErrWalkTestNotFound is returned when there's no walk test.
+var ErrWalkTestNotFound = errors.New("walk test not found")
+
+// ErrWalkTestRunning is returned when a walk test is started while another one is running.
+var ErrWalkTestRunning = errors.New("a walk test is already running")
+
+// walkTestAlarm is an alarm received during a walk test.
+type walkTestAlarm struct {
+	CameraID  int64     `json:"camera_id"`
+	AlarmName string    `json:"alarm_name"`
+	AlarmType string    `json:"alarm_type"`
+	At        time.Time `json:"at"`
+}
+
+type walkTestCamera struct {
+	id   int64
+	name string
+}
+
+// walkTest collects alarms fired by cameras under test.
+// These alarms are stored as test alarms, and are neither grouped into incidents nor notified.
+type walkTest struct {
+	id         int64
+	startedAt  time.Time
+	expiresAt  time.Time
+	finishedAt *time.Time
+	cameras    []walkTestCamera
+	alarms     []walkTestAlarm
+	// alarms have been added since they were last stored.
+	dirty bool
+}
+
+func (w *walkTest) running() bool {
+	return w.finishedAt == nil
+}
+
+func (w *walkTest) covers(cameraID int64) bool {
+	for _, c := range w.cameras {
+		if c.id == cameraID {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *walkTest) add(cameraID int64, alarmName string, alarmType string, at time.Time) {
+	w.alarms = append(w.alarms, walkTestAlarm{
+		CameraID:  cameraID,
+		AlarmName: alarmName,
+		AlarmType: alarmType,
+		At:        at,
+	})
+	w.dirty = true
+}
+
+func (w *walkTest) finish(now time.Time) {
+	w.finishedAt = &now
+}
+
+// report returns which cameras and alarm types fired and which cameras never did.
+func (w *walkTest) report() *defs.APIWalkTest {
+	ret := &defs.APIWalkTest{
+		ID:         w.id,
+		State:      defs.APIWalkTestStateRunning,
+		StartedAt:  w.startedAt,
+		ExpiresAt:  w.expiresAt,
+		FinishedAt: w.finishedAt,
+		AlarmCount: len(w.alarms),
+		Cameras:    make([]*defs.APIWalkTestCamera, len(w.cameras)),
+	}
+
+	if !w.running() {
+		ret.State = defs.APIWalkTestStateFinished
+	}
+
+	for i, c := range w.cameras {
+		rc := &defs.APIWalkTestCamera{
+			ID:         c.id,
+			Name:       c.name,
+			AlarmTypes: []string{},
+		}
+
+		for _, a := range w.alarms {
+			if a.CameraID != c.id {
+				continue
+			}
+
+			rc.Fired = true
+			rc.AlarmCount++
+			at := a.At
+			rc.LastAlarmAt = &at
+
+			if !slices.Contains(rc.AlarmTypes, a.AlarmType) {
+				rc.AlarmTypes = append(rc.AlarmTypes, a.AlarmType)
+			}
+		}
+
+		ret.Cameras[i] = rc
+	}
+
+	return ret
+}
+
+// APIWalkTestGet returns the running walk test or the last one.
+func (a *Aalrm) APIWalkTestGet() (*defs.APIWalkTest, error) {
+	a.walkTestMutex.Lock()
+	defer a.walkTestMutex.Unlock()
+
+	if a.walkTest == nil {
+		return nil, ErrWalkTestNotFound
+	}
+
+	return a.walkTest.report(), nil
+}
+
+// APIWalkTestStart starts a walk test.
+func (a *Aalrm) APIWalkTestStart(req *defs.APIWalkTestStartReq) (*defs.APIWalkTest, error) {
+	a.walkTestUpdateMutex.Lock()
+	defer a.walkTestUpdateMutex.Unlock()
+
+	if a.walkTestRunning() {
+		return nil, ErrWalkTestRunning
+	}
+
+	timeout := time.Duration(a.conf.AlarmWalkTestTimeout)
+	if req.Timeout != nil {
+		timeout = time.Duration(*req.Timeout)
+		if timeout <= 0 {
+			return nil, fmt.Errorf("timeout must be greater than zero")
+		}
+	}
+
+	cameras, err := a.walkTestCameras(req.CameraIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	w := &walkTest{
+		startedAt: now,
+		expiresAt: now.Add(timeout),
+		cameras:   cameras,
+	}
+
+	cameraIDs := make([]int64, len(cameras))
+	for i, c := range cameras {
+		cameraIDs[i] = c.id
+	}
+
+	startedAt := w.startedAt.Format(time.RFC3339Nano)
+	expiresAt := w.expiresAt.Format(time.RFC3339Nano)
+
+	w.id, err = a.controlPlane.InsertWalkTest(&defs.PublicWalkTestInsert{
+		SiteId:    a.confdb.SiteID(),
+		BridgeId:  a.confdb.BridgeID(),
+		CameraIds: cameraIDs,
+		StartedAt: &startedAt,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.walkTestMutex.Lock()
+	a.walkTest = w
+	a.walkTestMutex.Unlock()
+
+	a.Log(logger.Info, "walk test %d started on %d cameras, expires at %s",
+		w.id, len(cameras), w.expiresAt.Format(time.RFC3339))
+
+	return w.report(), nil
+}
+
+// APIWalkTestStop stops the running walk test.
+func (a *Aalrm) APIWalkTestStop() (*defs.APIWalkTest, error) {
+	report := a.finishWalkTest(time.Now().UTC(), false)
+	if report == nil {
+		return nil, ErrWalkTestNotFound
+	}
+
+	return report, nil
+}
+
+func (a *Aalrm) walkTestRunning() bool {
+	a.walkTestMutex.Lock()
+	defer a.walkTestMutex.Unlock()
+
+	return a.walkTest != nil && a.walkTest.running()
+}
+
+// walkTestCameras returns the cameras of the bridge with the given IDs, or all of them.
+func (a *Aalrm) walkTestCameras(ids []int64) ([]walkTestCamera, error) {
+	records, err := a.controlPlane.ListCameras(a.confdb.BridgeID())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		if len(records) == 0 {
+			return nil, fmt.Errorf("site has no cameras")
+		}
+
+		ret := make([]walkTestCamera, len(records))
+		for i, rec := range records {
+			ret[i] = walkTestCamera{id: rec.Id, name: rec.CameraName}
+		}
+		return ret, nil
+	}
+
+	ret := make([]walkTestCamera, 0, len(ids))
+
+outer:
+	for _, id := range ids {
+		for _, rec := range records {
+			if rec.Id == id {
+				ret = append(ret, walkTestCamera{id: rec.Id, name: rec.CameraName})
+				continue outer
+			}
+		}
+		return nil, fmt.Errorf("camera %d does not belong to this site", id)
+	}
+
+	return ret, nil
+}
+
+// recordWalkTestAlarm adds an alarm to the running walk test, if the camera is under test.
+// It returns the ID of the walk test, or zero when the alarm is not a test alarm.
+// It is called by the dispatcher, therefore it doesn't wait for the control plane.
+func (a *Aalrm) recordWalkTestAlarm(event *defs.PublicAlarmInsert, now time.Time) int64 {
+	a.walkTestMutex.Lock()
+	defer a.walkTestMutex.Unlock()
+
+	w := a.walkTest
+	if w == nil || !w.running() || !w.covers(event.CameraId) {
+		return 0
+	}
+
+	w.add(event.CameraId, event.AlarmName, event.AlarmType, now)
+
+	a.Log(logger.Info, "walk test %d: camera %d fired %s", w.id, event.CameraId, event.AlarmType)
+
+	return w.id
+}
+
+// storeWalkTestAlarms stores alarms added to the running walk test since they were last stored.
+// Alarms added while they are being stored are stored by the next call.
+func (a *Aalrm) storeWalkTestAlarms() {
+	a.walkTestUpdateMutex.Lock()
+	defer a.walkTestUpdateMutex.Unlock()
+
+	a.walkTestMutex.Lock()
+	w := a.walkTest
+	if w == nil || !w.running() || !w.dirty {
+		a.walkTestMutex.Unlock()
+		return
+	}
+	w.dirty = false
+	alarms := slices.Clone(w.alarms)
+	a.walkTestMutex.Unlock()
+
+	err := a.controlPlane.UpdateWalkTest(w.id, map[string]interface{}{
+		"alarms":     alarms,
+		"updated_at": time.Now().UTC(),
+	})
+	if err != nil {
+		a.Log(logger.Error, "Failed to update walk test %d: %v", w.id, err)
+	}
+}
+
+// expireWalkTest stops the running walk test when its timeout has elapsed.
+func (a *Aalrm) expireWalkTest(now time.Time) {
+	a.finishWalkTest(now, true)
+}
+
+// finishWalkTest stops the running walk test and stores its report, which is returned.
+// When expiredOnly is true, the walk test is stopped only if its timeout has elapsed.
+// It returns nil when no walk test has been stopped.
+func (a *Aalrm) finishWalkTest(now time.Time, expiredOnly bool) *defs.APIWalkTest {
+	a.walkTestUpdateMutex.Lock()
+	defer a.walkTestUpdateMutex.Unlock()
+
+	a.walkTestMutex.Lock()
+	w := a.walkTest
+	if w == nil || !w.running() || (expiredOnly && now.Before(w.expiresAt)) {
+		a.walkTestMutex.Unlock()
+		return nil
+	}
+	w.finish(now)
+	w.dirty = false
+	report := w.report()
+	alarms := slices.Clone(w.alarms)
+	a.walkTestMutex.Unlock()
+
+	silent := 0
+	for _, c := range report.Cameras {
+		if !c.Fired {
+			silent++
+		}
+	}
+
+	a.Log(logger.Info, "walk test %d finished, %d alarms received, %d of %d cameras never fired",
+		w.id, len(alarms), silent, len(report.Cameras))
+
+	err := a.controlPlane.UpdateWalkTest(w.id, map[string]interface{}{
+		"status":      report.State,
+		"alarms":      alarms,
+		"report":      report.Cameras,
+		"finished_at": now,
+		"updated_at":  now,
+	})
+	if err != nil {
+		a.Log(logger.Error, "Failed to update walk test %d: %v", w.id, err)
+	}
+
+	return report
+}
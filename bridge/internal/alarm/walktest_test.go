@@ -0,0 +1,62 @@
+package alarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkTestReport(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	w := &walkTest{
+		id:        5,
+		startedAt: t0,
+		expiresAt: t0.Add(30 * time.Minute),
+		cameras: []walkTestCamera{
+			{id: 1, name: "Entrance"},
+			{id: 2, name: "Garage"},
+		},
+	}
+
+	require.True(t, w.running())
+	require.True(t, w.covers(1))
+	require.False(t, w.covers(3))
+
+	w.add(1, "Motion Detection", "motion", t0.Add(time.Minute))
+	w.add(1, "Tripwire", "crossline", t0.Add(2*time.Minute))
+	w.add(1, "Motion Detection", "motion", t0.Add(3*time.Minute))
+	require.True(t, w.dirty)
+
+	w.finish(t0.Add(5 * time.Minute))
+	require.False(t, w.running())
+
+	lastAlarmAt := t0.Add(3 * time.Minute)
+	finishedAt := t0.Add(5 * time.Minute)
+
+	require.Equal(t, &defs.APIWalkTest{
+		ID:         5,
+		State:      defs.APIWalkTestStateFinished,
+		StartedAt:  t0,
+		ExpiresAt:  t0.Add(30 * time.Minute),
+		FinishedAt: &finishedAt,
+		AlarmCount: 3,
+		Cameras: []*defs.APIWalkTestCamera{
+			{
+				ID:          1,
+				Name:        "Entrance",
+				Fired:       true,
+				AlarmCount:  3,
+				AlarmTypes:  []string{"motion", "crossline"},
+				LastAlarmAt: &lastAlarmAt,
+			},
+			{
+				ID:         2,
+				Name:       "Garage",
+				AlarmTypes: []string{},
+			},
+		},
+	}, w.report())
+}
@@ -0,0 +1,103 @@
+package alarm
+
+import (
+	"context"
+	"sync"
+)
+
+type workerJob func(ctx context.Context)
+
+// workerPool runs jobs concurrently, while running jobs with the same key
+// (the camera of an alarm) in order, one at a time.
+type workerPool struct {
+	ctx       context.Context
+	workers   int
+	queueSize int
+	onDiscard func(key int64, count int)
+
+	sem    chan struct{}
+	mutex  sync.Mutex
+	queues map[int64][]workerJob // queued jobs of keys being processed
+	wg     sync.WaitGroup
+}
+
+func (p *workerPool) initialize() {
+	p.sem = make(chan struct{}, p.workers)
+	p.queues = make(map[int64][]workerJob)
+}
+
+// submit enqueues a job.
+// It returns false when the queue of the key is full.
+func (p *workerPool) submit(key int64, job workerJob) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	queue, ok := p.queues[key]
+	if ok {
+		if len(queue) >= p.queueSize {
+			return false
+		}
+		p.queues[key] = append(queue, job)
+		return true
+	}
+
+	p.queues[key] = []workerJob{job}
+
+	p.wg.Add(1)
+	go p.runKey(key)
+
+	return true
+}
+
+// wait waits for all jobs to complete.
+// Jobs that are still queued when the context is canceled are discarded.
+func (p *workerPool) wait() {
+	p.wg.Wait()
+}
+
+func (p *workerPool) runKey(key int64) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case p.sem <- struct{}{}:
+		case <-p.ctx.Done():
+			p.discard(key)
+			return
+		}
+
+		if p.ctx.Err() != nil {
+			<-p.sem
+			p.discard(key)
+			return
+		}
+
+		p.mutex.Lock()
+		job := p.queues[key][0]
+		p.queues[key] = p.queues[key][1:]
+		p.mutex.Unlock()
+
+		job(p.ctx)
+
+		<-p.sem
+
+		p.mutex.Lock()
+		if len(p.queues[key]) == 0 {
+			delete(p.queues, key)
+			p.mutex.Unlock()
+			return
+		}
+		p.mutex.Unlock()
+	}
+}
+
+func (p *workerPool) discard(key int64) {
+	p.mutex.Lock()
+	n := len(p.queues[key])
+	delete(p.queues, key)
+	p.mutex.Unlock()
+
+	if p.onDiscard != nil {
+		p.onDiscard(key, n)
+	}
+}
@@ -0,0 +1,125 @@
+package alarm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPoolOrdering(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	p := &workerPool{
+		ctx:       ctx,
+		workers:   2,
+		queueSize: 10,
+	}
+	p.initialize()
+
+	var mutex sync.Mutex
+	order := map[int64][]int{}
+
+	// a slow camera doesn't block the others
+	started := make(chan struct{})
+	block := make(chan struct{})
+	ok := p.submit(1, func(context.Context) {
+		close(started)
+		<-block
+		mutex.Lock()
+		order[1] = append(order[1], 0)
+		mutex.Unlock()
+	})
+	require.True(t, ok)
+
+	<-started
+
+	done := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		ok = p.submit(2, func(context.Context) {
+			mutex.Lock()
+			order[2] = append(order[2], i)
+			mutex.Unlock()
+			if i == 4 {
+				close(done)
+			}
+		})
+		require.True(t, ok)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out")
+	}
+
+	done1 := make(chan struct{})
+	for i := 1; i < 5; i++ {
+		ok = p.submit(1, func(context.Context) {
+			mutex.Lock()
+			order[1] = append(order[1], i)
+			mutex.Unlock()
+			if i == 4 {
+				close(done1)
+			}
+		})
+		require.True(t, ok)
+	}
+
+	close(block)
+
+	select {
+	case <-done1:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out")
+	}
+
+	ctxCancel()
+	p.wait()
+
+	require.Equal(t, []int{0, 1, 2, 3, 4}, order[1])
+	require.Equal(t, []int{0, 1, 2, 3, 4}, order[2])
+}
+
+func TestWorkerPoolQueueFull(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	discarded := 0
+
+	p := &workerPool{
+		ctx:       ctx,
+		workers:   1,
+		queueSize: 2,
+		onDiscard: func(_ int64, count int) {
+			discarded += count
+		},
+	}
+	p.initialize()
+
+	started := make(chan struct{})
+	ok := p.submit(1, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+	require.True(t, ok)
+	<-started
+
+	for i := 0; i < 2; i++ {
+		ok = p.submit(1, func(context.Context) {
+			t.Error("should not happen")
+		})
+		require.True(t, ok)
+	}
+
+	ok = p.submit(1, func(context.Context) {})
+	require.False(t, ok)
+
+	// queued jobs are discarded when the pool is canceled
+	ctxCancel()
+	p.wait()
+
+	require.Equal(t, 2, discarded)
+}
@@ -0,0 +1,429 @@
+// Package analytics contains the interface with external analytics processes.
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/google/uuid"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/counterdumper"
+	"github.com/kaonmir/mini-chekt/internal/externalcmd"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/metadatareader"
+	"github.com/kaonmir/mini-chekt/internal/protocols/onvifmeta"
+	"github.com/kaonmir/mini-chekt/internal/stream"
+	"github.com/kaonmir/mini-chekt/internal/unit"
+)
+
+const (
+	// frames waiting to be sent. When the queue is full, frames are dropped.
+	queueSize = 4
+	// frames waiting for a result.
+	maxPending   = 64
+	writeTimeout = 10 * time.Second
+)
+
+// ErrNoVideo is returned when a stream has no video track supported by analytics.
+var ErrNoVideo = errors.New("the stream doesn't contain any H264, H265 or M-JPEG track")
+
+type frame struct {
+	header  FrameHeader
+	payload []byte
+}
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+func findVideo(desc *description.Session) (*description.Media, format.Format, string) {
+	for _, medi := range desc.Medias {
+		for _, forma := range medi.Formats {
+			switch forma.(type) {
+			case *format.H264:
+				return medi, forma, "h264"
+
+			case *format.H265:
+				return medi, forma, "h265"
+
+			case *format.MJPEG:
+				return medi, forma, "mjpeg"
+			}
+		}
+	}
+	return nil, nil, ""
+}
+
+// Plugin sends frames of a path to an external analytics process
+// and routes detections as metadata events.
+type Plugin struct {
+	Command         string
+	Frames          conf.AnalyticsFrames
+	Interval        int
+	PathName        string
+	Stream          *stream.Stream
+	ExternalCmdPool *externalcmd.Pool
+	ExternalCmdEnv  externalcmd.Environment
+	ChEvent         *chan metadatareader.Event
+	Parent          logger.Writer
+
+	media         *description.Media
+	format        format.Format
+	codec         string
+	socketPath    string
+	listener      net.Listener
+	cmd           *externalcmd.Cmd
+	queue         chan *frame
+	droppedFrames *counterdumper.CounterDumper
+	connected     atomic.Bool
+	count         int
+	nextID        uint64
+
+	mutex   sync.Mutex
+	conn    net.Conn
+	closed  bool
+	pending map[uint64]FrameHeader
+
+	terminate chan struct{}
+	done      chan struct{}
+}
+
+// Initialize initializes Plugin.
+func (p *Plugin) Initialize() error {
+	p.media, p.format, p.codec = findVideo(p.Stream.Desc)
+	if p.format == nil {
+		return ErrNoVideo
+	}
+
+	p.socketPath = filepath.Join(os.TempDir(), "mediamtx-analytics-"+uuid.New().String()+".sock")
+
+	var err error
+	p.listener, err = net.Listen("unix", p.socketPath)
+	if err != nil {
+		return err
+	}
+
+	p.queue = make(chan *frame, queueSize)
+	p.pending = make(map[uint64]FrameHeader)
+	p.terminate = make(chan struct{})
+	p.done = make(chan struct{})
+
+	p.droppedFrames = &counterdumper.CounterDumper{
+		OnReport: func(val uint64) {
+			p.Log(logger.Warn, "analytics process is too slow, discarding %d %s",
+				val,
+				func() string {
+					if val == 1 {
+						return "frame"
+					}
+					return "frames"
+				}())
+		},
+	}
+	p.droppedFrames.Start()
+
+	p.Stream.AddReader(p, p.media, p.format, p.onUnit)
+	p.Stream.StartReader(p)
+
+	env := externalcmd.Environment{}
+	for k, v := range p.ExternalCmdEnv {
+		env[k] = v
+	}
+	env["MTX_ANALYTICS_SOCKET"] = p.socketPath
+
+	p.Log(logger.Info, "runAnalytics command started")
+	p.cmd = externalcmd.NewCmd(
+		p.ExternalCmdPool,
+		p.Command,
+		true,
+		env,
+		func(err error) {
+			p.Log(logger.Info, "runAnalytics command exited: %v", err)
+		})
+
+	go p.run()
+
+	return nil
+}
+
+// Log implements logger.Writer.
+func (p *Plugin) Log(level logger.Level, format string, args ...interface{}) {
+	p.Parent.Log(level, "[analytics] "+format, args...)
+}
+
+// Close closes the Plugin.
+func (p *Plugin) Close() {
+	p.Log(logger.Info, "runAnalytics command stopped")
+	p.cmd.Close()
+	close(p.terminate)
+	<-p.done
+	p.droppedFrames.Stop()
+	os.Remove(p.socketPath) //nolint:errcheck
+}
+
+func (p *Plugin) run() {
+	defer close(p.done)
+
+	acceptDone := make(chan struct{})
+	go p.runAccept(acceptDone)
+
+	select {
+	case err := <-p.Stream.ReaderError(p):
+		p.Log(logger.Error, err.Error())
+
+	case <-p.terminate:
+	}
+
+	p.mutex.Lock()
+	p.closed = true
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.mutex.Unlock()
+
+	p.listener.Close()
+	<-acceptDone
+
+	p.Stream.RemoveReader(p)
+}
+
+// runAccept serves analytics processes, one at a time.
+func (p *Plugin) runAccept(done chan struct{}) {
+	defer close(done)
+
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		p.serve(conn)
+	}
+}
+
+func (p *Plugin) serve(conn net.Conn) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		conn.Close()
+		return
+	}
+	p.conn = conn
+	p.pending = make(map[uint64]FrameHeader)
+	p.mutex.Unlock()
+
+	p.Log(logger.Info, "analytics process connected")
+
+	// discard frames queued for the previous process
+	for len(p.queue) != 0 {
+		<-p.queue
+	}
+
+	p.connected.Store(true)
+
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- p.readResults(conn)
+	}()
+
+	err := p.writeFrames(conn, readErr)
+
+	p.connected.Store(false)
+	conn.Close()
+
+	if !errors.Is(err, errTerminated) {
+		p.Log(logger.Info, "analytics process disconnected: %v", err)
+	}
+
+	p.mutex.Lock()
+	p.conn = nil
+	p.mutex.Unlock()
+}
+
+var errTerminated = errors.New("terminated")
+
+func (p *Plugin) writeFrames(conn net.Conn, readErr chan error) error {
+	for {
+		select {
+		case f := <-p.queue:
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout)) //nolint:errcheck
+			err := writeMessage(conn, f.header, f.payload)
+			if err != nil {
+				<-readErr
+				return err
+			}
+
+		case err := <-readErr:
+			return err
+
+		case <-p.terminate:
+			conn.Close()
+			<-readErr
+			return errTerminated
+		}
+	}
+}
+
+func (p *Plugin) readResults(conn net.Conn) error {
+	for {
+		var res Result
+		_, err := readMessage(conn, &res)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		p.mutex.Lock()
+		header, ok := p.pending[res.ID]
+		// results are returned in order, older frames will not receive any
+		for id := range p.pending {
+			if id <= res.ID {
+				delete(p.pending, id)
+			}
+		}
+		p.mutex.Unlock()
+
+		if !ok {
+			p.Log(logger.Warn, "received result of unknown frame %d", res.ID)
+			continue
+		}
+
+		p.route(header, &res)
+	}
+}
+
+func (p *Plugin) route(header FrameHeader, res *Result) {
+	if len(res.Detections) == 0 {
+		return
+	}
+
+	f := onvifmeta.Frame{
+		UtcTime: header.NTP.UTC().Format(time.RFC3339Nano),
+	}
+
+	for _, d := range res.Detections {
+		// detections without a track are identified by their class
+		id := d.TrackID
+		if id == "" {
+			id = d.Class
+		}
+
+		f.Objects = append(f.Objects, onvifmeta.Object{
+			ObjectID: id,
+			Class: onvifmeta.Class{
+				Types: []onvifmeta.ClassType{{
+					Likelihood: d.Confidence,
+					Value:      d.Class,
+				}},
+			},
+		})
+	}
+
+	ev := metadatareader.Event{
+		PathName: p.PathName,
+		PTS:      time.Duration(header.PTS * float64(time.Second)),
+		NTP:      header.NTP,
+		Frames:   []onvifmeta.Frame{f},
+	}
+
+	select {
+	case *p.ChEvent <- ev:
+	default:
+		p.Log(logger.Warn, "Channel is full, dropping detections")
+	}
+}
+
+func (p *Plugin) encode(u unit.Unit) ([]byte, bool, error) {
+	switch tu := u.(type) {
+	case *unit.H264:
+		if tu.AU == nil {
+			return nil, false, nil
+		}
+		buf, err := h264.AnnexB(tu.AU).Marshal()
+		return buf, h264.IsRandomAccess(tu.AU), err
+
+	case *unit.H265:
+		if tu.AU == nil {
+			return nil, false, nil
+		}
+		// the Annex-B format of H265 is the same of H264
+		buf, err := h264.AnnexB(tu.AU).Marshal()
+		return buf, h265.IsRandomAccess(tu.AU), err
+
+	case *unit.MJPEG:
+		return tu.Frame, true, nil
+	}
+
+	return nil, false, fmt.Errorf("unsupported unit %T", u)
+}
+
+func (p *Plugin) onUnit(u unit.Unit) error {
+	if !p.connected.Load() {
+		return nil
+	}
+
+	payload, keyFrame, err := p.encode(u)
+	if err != nil {
+		return err
+	}
+	if payload == nil {
+		return nil
+	}
+
+	if p.Frames == conf.AnalyticsFramesKeyFrames && !keyFrame {
+		return nil
+	}
+
+	p.count++
+	if (p.count-1)%p.Interval != 0 {
+		return nil
+	}
+
+	p.nextID++
+
+	f := &frame{
+		header: FrameHeader{
+			ID:       p.nextID,
+			Path:     p.PathName,
+			Codec:    p.codec,
+			KeyFrame: keyFrame,
+			PTS: float64(multiplyAndDivide(u.GetPTS(), int64(time.Second),
+				int64(p.format.ClockRate()))) / float64(time.Second),
+			NTP: u.GetNTP(),
+		},
+		payload: payload,
+	}
+
+	select {
+	case p.queue <- f:
+	default:
+		p.droppedFrames.Increase()
+		return nil
+	}
+
+	p.mutex.Lock()
+	p.pending[f.header.ID] = f.header
+	if len(p.pending) > maxPending {
+		for id := range p.pending {
+			if id <= f.header.ID-maxPending {
+				delete(p.pending, id)
+			}
+		}
+	}
+	p.mutex.Unlock()
+
+	return nil
+}
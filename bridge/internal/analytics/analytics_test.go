@@ -0,0 +1,153 @@
+package analytics
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/externalcmd"
+	"github.com/kaonmir/mini-chekt/internal/metadatareader"
+	"github.com/kaonmir/mini-chekt/internal/stream"
+	"github.com/kaonmir/mini-chekt/internal/test"
+	"github.com/kaonmir/mini-chekt/internal/unit"
+)
+
+func TestMessage(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := writeMessage(&buf, Result{ID: 3}, []byte{1, 2, 3})
+	require.NoError(t, err)
+
+	var res Result
+	payload, err := readMessage(&buf, &res)
+	require.NoError(t, err)
+	require.Equal(t, Result{ID: 3}, res)
+	require.Equal(t, []byte{1, 2, 3}, payload)
+}
+
+func TestPlugin(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{{
+		Type:    description.MediaTypeVideo,
+		Formats: []format.Format{test.FormatH264},
+	}}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             test.NilLogger,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	pool := &externalcmd.Pool{}
+	pool.Initialize()
+	defer pool.Close()
+
+	chEvent := make(chan metadatareader.Event, 1)
+
+	p := &Plugin{
+		Command:         "sleep 10",
+		Frames:          conf.AnalyticsFramesKeyFrames,
+		Interval:        2,
+		PathName:        "192.168.0.10",
+		Stream:          strm,
+		ExternalCmdPool: pool,
+		ChEvent:         &chEvent,
+		Parent:          test.NilLogger,
+	}
+	err = p.Initialize()
+	require.NoError(t, err)
+	defer p.Close()
+
+	conn, err := net.Dial("unix", p.socketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for !p.connected.Load() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ntp := time.Date(2025, 3, 10, 1, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		au := [][]byte{{1, 2}} // non-IDR
+		if i != 1 {
+			au = [][]byte{{5, 1}} // IDR
+		}
+
+		strm.WriteUnit(desc.Medias[0], test.FormatH264, &unit.H264{
+			Base: unit.Base{
+				PTS: int64(i) * 90000,
+				NTP: ntp.Add(time.Duration(i) * time.Second),
+			},
+			AU: au,
+		})
+	}
+
+	// key frames are 0, 2 and 3, and one every two is sent
+	var headers []FrameHeader
+	for i := 0; i < 2; i++ {
+		var h FrameHeader
+		var payload []byte
+		payload, err = readMessage(conn, &h)
+		require.NoError(t, err)
+		// parameters are prepended to key frames, allowing decoding to start from any of them
+		require.True(t, bytes.HasPrefix(payload, []byte{0, 0, 0, 1, 0x67}))
+		require.True(t, bytes.HasSuffix(payload, []byte{0, 0, 0, 1, 5, 1}))
+		headers = append(headers, h)
+	}
+
+	require.Equal(t, []FrameHeader{
+		{
+			ID:       1,
+			Path:     "192.168.0.10",
+			Codec:    "h264",
+			KeyFrame: true,
+			PTS:      0,
+			NTP:      ntp,
+		},
+		{
+			ID:       2,
+			Path:     "192.168.0.10",
+			Codec:    "h264",
+			KeyFrame: true,
+			PTS:      3,
+			NTP:      ntp.Add(3 * time.Second),
+		},
+	}, headers)
+
+	err = writeMessage(conn, Result{
+		ID: 2,
+		Detections: []Detection{{
+			Class:      "Person",
+			Confidence: 0.9,
+			TrackID:    "7",
+		}},
+	}, nil)
+	require.NoError(t, err)
+
+	select {
+	case ev := <-chEvent:
+		require.Equal(t, "192.168.0.10", ev.PathName)
+		require.Equal(t, 3*time.Second, ev.PTS)
+		require.Equal(t, ntp.Add(3*time.Second), ev.NTP)
+		require.Len(t, ev.Frames, 1)
+		require.Len(t, ev.Frames[0].Objects, 1)
+		require.Equal(t, "7", ev.Frames[0].Objects[0].ObjectID)
+		class, likelihood := ev.Frames[0].Objects[0].BestClass()
+		require.Equal(t, "Person", class)
+		require.Equal(t, 0.9, likelihood)
+
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out")
+	}
+}
@@ -0,0 +1,87 @@
+package analytics
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	maxHeaderSize  = 64 * 1024
+	maxPayloadSize = 32 * 1024 * 1024
+)
+
+// FrameHeader is the header of a frame sent to the analytics process.
+type FrameHeader struct {
+	ID       uint64    `json:"id"`
+	Path     string    `json:"path"`
+	Codec    string    `json:"codec"`
+	KeyFrame bool      `json:"keyFrame"`
+	PTS      float64   `json:"pts"`
+	NTP      time.Time `json:"ntp"`
+}
+
+// Detection is an object detected by the analytics process.
+type Detection struct {
+	Class      string  `json:"class"`
+	Confidence float64 `json:"confidence"`
+	TrackID    string  `json:"trackID"`
+}
+
+// Result contains the detections of a frame.
+type Result struct {
+	ID         uint64      `json:"id"`
+	Detections []Detection `json:"detections"`
+}
+
+// writeMessage writes a message, made of
+// a big-endian uint32 header size, a JSON header, a big-endian uint32 payload size and a payload.
+func writeMessage(w io.Writer, header interface{}, payload []byte) error {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+len(h)+4+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(h)))
+	copy(buf[4:], h)
+	binary.BigEndian.PutUint32(buf[4+len(h):], uint32(len(payload)))
+	copy(buf[8+len(h):], payload)
+
+	_, err = w.Write(buf)
+	return err
+}
+
+func readSized(r io.Reader, maxSize uint32) ([]byte, error) {
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:])
+	if err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxSize {
+		return nil, fmt.Errorf("message size (%d) is greater than maximum allowed (%d)", n, maxSize)
+	}
+
+	buf := make([]byte, n)
+	_, err = io.ReadFull(r, buf)
+	return buf, err
+}
+
+// readMessage reads a message written with writeMessage.
+func readMessage(r io.Reader, header interface{}) ([]byte, error) {
+	h, err := readSized(r, maxHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(h, header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	return readSized(r, maxPayloadSize)
+}
@@ -2,6 +2,7 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -16,9 +17,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/kaonmir/mini-chekt/internal/alarm"
 	"github.com/kaonmir/mini-chekt/internal/auth"
 	"github.com/kaonmir/mini-chekt/internal/conf"
 	"github.com/kaonmir/mini-chekt/internal/conf/jsonwrapper"
+	"github.com/kaonmir/mini-chekt/internal/confdb"
 	"github.com/kaonmir/mini-chekt/internal/defs"
 	"github.com/kaonmir/mini-chekt/internal/logger"
 	"github.com/kaonmir/mini-chekt/internal/protocols/httpp"
@@ -105,12 +108,18 @@ type API struct {
 	HLSServer      defs.APIHLSServer
 	WebRTCServer   defs.APIWebRTCServer
 	SRTServer      defs.APISRTServer
+	AlarmManager   defs.APIAlarmManager
+	ConfDB         defs.APIConfDB
 	Parent         apiParent
 
+	router     *gin.Engine
 	httpServer *httpp.Server
 	mutex      sync.RWMutex
 }
 
+// proxiedKey marks requests received through ServeProxied.
+type proxiedKey struct{}
+
 // Initialize initializes API.
 func (a *API) Initialize() error {
 	router := gin.New()
@@ -184,10 +193,27 @@ func (a *API) Initialize() error {
 		group.POST("/srtconns/kick/:id", a.onSRTConnsKick)
 	}
 
+	if !interfaceIsEmpty(a.AlarmManager) {
+		group.GET("/walktest/get", a.onWalkTestGet)
+		group.POST("/walktest/start", a.onWalkTestStart)
+		group.POST("/walktest/stop", a.onWalkTestStop)
+		group.GET("/clockskews/list", a.onClockSkewsList)
+	}
+
+	if !interfaceIsEmpty(a.ConfDB) {
+		group.GET("/camerasync/get", a.onCameraSyncGet)
+		group.GET("/bridge/pairing", a.onBridgePairingGet)
+		group.POST("/bridge/pairing/renew", a.onBridgePairingRenew)
+		group.POST("/bridge/pairing/unpair", a.onBridgePairingUnpair)
+		group.GET("/bridge/config", a.onBridgeConfigGet)
+	}
+
 	group.GET("/recordings/list", a.onRecordingsList)
 	group.GET("/recordings/get/*name", a.onRecordingsGet)
 	group.DELETE("/recordings/deletesegment", a.onRecordingDeleteSegment)
 
+	a.router = router
+
 	a.httpServer = &httpp.Server{
 		Address:     a.Address,
 		ReadTimeout: time.Duration(a.ReadTimeout),
@@ -218,6 +244,12 @@ func (a *API) Log(level logger.Level, format string, args ...interface{}) {
 	a.Parent.Log(level, "[API] "+format, args...)
 }
 
+// ServeProxied serves a request received through the control plane.
+// Credentials are not checked, since requests have already been authorized by the caller.
+func (a *API) ServeProxied(w http.ResponseWriter, r *http.Request) {
+	a.router.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxiedKey{}, true)))
+}
+
 func (a *API) writeError(ctx *gin.Context, status int, err error) {
 	// show error in logs
 	a.Log(logger.Error, err.Error())
@@ -243,6 +275,10 @@ func (a *API) middlewareOrigin(ctx *gin.Context) {
 }
 
 func (a *API) middlewareAuth(ctx *gin.Context) {
+	if ctx.Request.Context().Value(proxiedKey{}) != nil {
+		return
+	}
+
 	req := &auth.Request{
 		Action:      conf.AuthActionAPI,
 		Query:       ctx.Request.URL.RawQuery,
@@ -1041,6 +1077,134 @@ func (a *API) onSRTConnsKick(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
+func (a *API) onWalkTestGet(ctx *gin.Context) {
+	data, err := a.AlarmManager.APIWalkTestGet()
+	if err != nil {
+		if errors.Is(err, alarm.ErrWalkTestNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onWalkTestStart(ctx *gin.Context) {
+	var req defs.APIWalkTestStartReq
+	err := jsonwrapper.Decode(ctx.Request.Body, &req)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	data, err := a.AlarmManager.APIWalkTestStart(&req)
+	if err != nil {
+		if errors.Is(err, alarm.ErrWalkTestRunning) {
+			a.writeError(ctx, http.StatusConflict, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onWalkTestStop(ctx *gin.Context) {
+	data, err := a.AlarmManager.APIWalkTestStop()
+	if err != nil {
+		if errors.Is(err, alarm.ErrWalkTestNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onClockSkewsList(ctx *gin.Context) {
+	data, err := a.AlarmManager.APIClockSkewsList()
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	data.ItemCount = len(data.Items)
+	pageCount, err := paginate(&data.Items, ctx.Query("itemsPerPage"), ctx.Query("page"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	data.PageCount = pageCount
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onCameraSyncGet(ctx *gin.Context) {
+	data, err := a.ConfDB.APICameraSyncGet()
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onBridgeConfigGet(ctx *gin.Context) {
+	data, err := a.ConfDB.APIBridgeConfigGet()
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onBridgePairingGet(ctx *gin.Context) {
+	data, err := a.ConfDB.APIBridgePairingGet()
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onBridgePairingRenew(ctx *gin.Context) {
+	data, err := a.ConfDB.APIBridgePairingRenew()
+	if err != nil {
+		switch {
+		case errors.Is(err, confdb.ErrPairingRateLimited):
+			a.writeError(ctx, http.StatusTooManyRequests, err)
+		case errors.Is(err, confdb.ErrAlreadyPaired):
+			a.writeError(ctx, http.StatusConflict, err)
+		default:
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onBridgePairingUnpair(ctx *gin.Context) {
+	data, err := a.ConfDB.APIBridgePairingUnpair()
+	if err != nil {
+		if errors.Is(err, confdb.ErrNotPaired) {
+			a.writeError(ctx, http.StatusConflict, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
 func (a *API) onRecordingsList(ctx *gin.Context) {
 	a.mutex.RLock()
 	c := a.Conf
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -757,3 +758,44 @@ func TestAuthJWKSRefresh(t *testing.T) {
 
 	require.True(t, ok)
 }
+
+func TestServeProxied(t *testing.T) {
+	cnf := tempConf(t, "api: yes\n")
+
+	api := API{
+		Address:     "localhost:9997",
+		ReadTimeout: conf.Duration(10 * time.Second),
+		Conf:        cnf,
+		AuthManager: &test.AuthManager{
+			AuthenticateImpl: func(_ *auth.Request) error {
+				return auth.Error{AskCredentials: true}
+			},
+		},
+		Parent: &testParent{},
+	}
+	err := api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	res, err := hc.Get("http://localhost:9997/v3/config/global/get")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// credentials of proxied requests are not checked
+	req, err := http.NewRequest(http.MethodGet, "/v3/config/global/get", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	api.ServeProxied(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var out map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &out)
+	require.NoError(t, err)
+	require.Equal(t, true, out["api"])
+}
@@ -0,0 +1,86 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kaonmir/mini-chekt/internal/conf/jsonwrapper"
+)
+
+// AlarmCameraAdjacency is the alarmIncidentAdjacency parameter.
+// It contains groups of camera IDs; cameras that share a group are adjacent.
+type AlarmCameraAdjacency [][]int64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *AlarmCameraAdjacency) UnmarshalJSON(b []byte) error {
+	var in [][]int64
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	*d = AlarmCameraAdjacency{}
+
+	for _, group := range in {
+		if len(group) < 2 {
+			return fmt.Errorf("camera groups must contain at least two cameras")
+		}
+		*d = append(*d, group)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+// Groups are separated by semicolons, camera IDs by commas.
+func (d *AlarmCameraAdjacency) UnmarshalEnv(_ string, v string) error {
+	in := [][]int64{}
+
+	if v != "" {
+		for _, rawGroup := range strings.Split(v, ";") {
+			var group []int64
+
+			for _, rawID := range strings.Split(rawGroup, ",") {
+				id, err := strconv.ParseInt(strings.TrimSpace(rawID), 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid camera ID '%s'", rawID)
+				}
+				group = append(group, id)
+			}
+
+			in = append(in, group)
+		}
+	}
+
+	byts, _ := json.Marshal(in)
+	return d.UnmarshalJSON(byts)
+}
+
+// Adjacent checks whether two cameras are adjacent.
+// When no groups are configured, all cameras are adjacent to each other.
+func (d AlarmCameraAdjacency) Adjacent(a int64, b int64) bool {
+	if len(d) == 0 || a == b {
+		return true
+	}
+
+	for _, group := range d {
+		hasA := false
+		hasB := false
+
+		for _, id := range group {
+			if id == a {
+				hasA = true
+			}
+			if id == b {
+				hasB = true
+			}
+		}
+
+		if hasA && hasB {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kaonmir/mini-chekt/internal/conf/jsonwrapper"
+)
+
+// AnalyticsFrames is the analyticsFrames parameter.
+type AnalyticsFrames int
+
+// supported values.
+const (
+	AnalyticsFramesKeyFrames AnalyticsFrames = iota
+	AnalyticsFramesAll
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d AnalyticsFrames) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case AnalyticsFramesAll:
+		out = "all"
+
+	default:
+		out = "keyframes"
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *AnalyticsFrames) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "all":
+		*d = AnalyticsFramesAll
+
+	case "keyframes":
+		*d = AnalyticsFramesKeyFrames
+
+	default:
+		return fmt.Errorf("invalid analytics frames '%s'", in)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (d *AnalyticsFrames) UnmarshalEnv(_ string, v string) error {
+	return d.UnmarshalJSON([]byte(`"` + v + `"`))
+}
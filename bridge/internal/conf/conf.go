@@ -2,6 +2,7 @@
 package conf
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -164,9 +165,15 @@ type Conf struct {
 	RunOnDisconnect     string          `json:"runOnDisconnect"`
 
 	// Bridge
-	BridgeUUID  string `json:"bridgeUUID"`
-	SupabaseURL string `json:"supabaseURL"`
-	SupabaseKey string `json:"supabaseKey"`
+	BridgeUUID            string   `json:"bridgeUUID"`
+	ControlPlane          string   `json:"controlPlane"`
+	ControlPlaneDirectory string   `json:"controlPlaneDirectory"`
+	SupabaseURL           string   `json:"supabaseURL"`
+	SupabaseKey           string   `json:"supabaseKey"`
+	BridgeSecretFile      string   `json:"bridgeSecretFile"`
+	BridgeCache           string   `json:"bridgeCache"`
+	HeartbeatPeriod       Duration `json:"heartbeatPeriod"`
+	RemoteConfigPinned    []string `json:"remoteConfigPinned"`
 
 	// Authentication
 	AuthMethod                AuthMethod                  `json:"authMethod"`
@@ -300,6 +307,39 @@ type Conf struct {
 	SMTP     bool `json:"smtp"`
 	SMTPPort int  `json:"smtpPort"`
 
+	// IMAP client
+	IMAP           bool          `json:"imap"`
+	IMAPMailboxes  IMAPMailboxes `json:"imapMailboxes"`
+	IMAPPollPeriod Duration      `json:"imapPollPeriod"`
+
+	// Alarm
+	AlarmIncidentWindow      Duration             `json:"alarmIncidentWindow"`
+	AlarmIncidentMaxDuration Duration             `json:"alarmIncidentMaxDuration"`
+	AlarmIncidentAdjacency   AlarmCameraAdjacency `json:"alarmIncidentAdjacency"`
+	AlarmWalkTestTimeout     Duration             `json:"alarmWalkTestTimeout"`
+	AlarmCameraTimezone      string               `json:"alarmCameraTimezone"`
+	AlarmClockSkewThreshold  Duration             `json:"alarmClockSkewThreshold"`
+	AlarmWorkers             int                  `json:"alarmWorkers"`
+	AlarmQueueSize           int                  `json:"alarmQueueSize"`
+	AlarmUploadTimeout       Duration             `json:"alarmUploadTimeout"`
+
+	// DC-09 receiver
+	DC09            bool     `json:"dc09"`
+	DC09Address     string   `json:"dc09Address"`
+	DC09Key         string   `json:"dc09Key"`
+	DC09ReadTimeout Duration `json:"dc09ReadTimeout"`
+
+	// Syslog receiver
+	Syslog           bool       `json:"syslog"`
+	SyslogAddress    string     `json:"syslogAddress"`
+	SyslogEncryption Encryption `json:"syslogEncryption"`
+	SyslogTLSAddress string     `json:"syslogTLSAddress"`
+	SyslogServerKey  string     `json:"syslogServerKey"`
+	SyslogServerCert string     `json:"syslogServerCert"`
+
+	// ONVIF metadata
+	ONVIFMetadata bool `json:"onvifMetadata"`
+
 	// Record (deprecated)
 	Record                *bool         `json:"record,omitempty"`                // deprecated
 	RecordPath            *string       `json:"recordPath,omitempty"`            // deprecated
@@ -327,6 +367,14 @@ func (conf *Conf) setDefaults() {
 	conf.WriteQueueSize = 512
 	conf.UDPMaxPayloadSize = 1472
 
+	// Bridge
+	conf.ControlPlane = "supabase"
+	conf.ControlPlaneDirectory = "./controlplane"
+	conf.BridgeSecretFile = "./cache/bridge.secret"
+	conf.BridgeCache = "./cache/bridge.cache"
+	conf.HeartbeatPeriod = 20 * Duration(time.Second)
+	conf.RemoteConfigPinned = []string{}
+
 	// Authentication
 	conf.AuthInternalUsers = defaultAuthInternalUsers
 	conf.AuthHTTPExclude = []AuthInternalUserPermission{
@@ -429,6 +477,30 @@ func (conf *Conf) setDefaults() {
 	conf.SRT = true
 	conf.SRTAddress = ":8890"
 
+	// IMAP client
+	conf.IMAPMailboxes = IMAPMailboxes{}
+	conf.IMAPPollPeriod = 60 * Duration(time.Second)
+
+	// Alarm
+	conf.AlarmIncidentWindow = 60 * Duration(time.Second)
+	conf.AlarmIncidentMaxDuration = 10 * Duration(time.Minute)
+	conf.AlarmIncidentAdjacency = AlarmCameraAdjacency{}
+	conf.AlarmWalkTestTimeout = 30 * Duration(time.Minute)
+	conf.AlarmClockSkewThreshold = 30 * Duration(time.Second)
+	conf.AlarmWorkers = 4
+	conf.AlarmQueueSize = 64
+	conf.AlarmUploadTimeout = 5 * Duration(time.Minute)
+
+	// DC-09 receiver
+	conf.DC09Address = ":9000"
+	conf.DC09ReadTimeout = 90 * Duration(time.Second)
+
+	// Syslog receiver
+	conf.SyslogAddress = ":514"
+	conf.SyslogTLSAddress = ":6514"
+	conf.SyslogServerKey = "server.key"
+	conf.SyslogServerCert = "server.crt"
+
 	conf.PathDefaults.setDefaults()
 }
 
@@ -544,6 +616,29 @@ func (conf *Conf) Validate(l logger.Writer) error {
 		return fmt.Errorf("'udpMaxPayloadSize' must be less than 1472")
 	}
 
+	// Bridge
+
+	switch conf.ControlPlane {
+	case "supabase":
+		if conf.BridgeSecretFile == "" {
+			return fmt.Errorf("'bridgeSecretFile' must be set when 'controlPlane' is 'supabase'")
+		}
+	case "local":
+		if conf.ControlPlaneDirectory == "" {
+			return fmt.Errorf("'controlPlaneDirectory' must be set when 'controlPlane' is 'local'")
+		}
+	default:
+		return fmt.Errorf("invalid 'controlPlane': %s", conf.ControlPlane)
+	}
+	if conf.HeartbeatPeriod <= 0 {
+		return fmt.Errorf("'heartbeatPeriod' must be greater than zero")
+	}
+	for _, key := range conf.RemoteConfigPinned {
+		if key == "" || key == "pathDefaults." {
+			return fmt.Errorf("'remoteConfigPinned' contains an empty key")
+		}
+	}
+
 	// Authentication
 
 	if conf.ExternalAuthenticationURL != nil {
@@ -728,6 +823,65 @@ func (conf *Conf) Validate(l logger.Writer) error {
 		}
 	}
 
+	// IMAP client
+
+	if conf.IMAPPollPeriod <= 0 {
+		return fmt.Errorf("'imapPollPeriod' must be greater than zero")
+	}
+	for _, m := range conf.IMAPMailboxes {
+		if m.Address == "" {
+			return fmt.Errorf("IMAP mailbox address is missing")
+		}
+		if _, _, err := net.SplitHostPort(m.Address); err != nil {
+			return fmt.Errorf("invalid IMAP mailbox address '%s': %w", m.Address, err)
+		}
+		if m.Username == "" {
+			return fmt.Errorf("IMAP mailbox '%s' has no username", m.Address)
+		}
+	}
+	if conf.IMAP && len(conf.IMAPMailboxes) == 0 {
+		return fmt.Errorf("'imap' is enabled but 'imapMailboxes' is empty")
+	}
+
+	// Alarm
+
+	if conf.AlarmIncidentWindow < 0 {
+		return fmt.Errorf("'alarmIncidentWindow' must not be negative")
+	}
+	if conf.AlarmIncidentWindow != 0 && conf.AlarmIncidentMaxDuration < conf.AlarmIncidentWindow {
+		return fmt.Errorf("'alarmIncidentMaxDuration' must not be lower than 'alarmIncidentWindow'")
+	}
+	if conf.AlarmWalkTestTimeout <= 0 {
+		return fmt.Errorf("'alarmWalkTestTimeout' must be greater than zero")
+	}
+	if conf.AlarmCameraTimezone != "" {
+		_, err := time.LoadLocation(conf.AlarmCameraTimezone)
+		if err != nil {
+			return fmt.Errorf("invalid 'alarmCameraTimezone': %w", err)
+		}
+	}
+	if conf.AlarmClockSkewThreshold < 0 {
+		return fmt.Errorf("'alarmClockSkewThreshold' must not be negative")
+	}
+	if conf.AlarmWorkers <= 0 {
+		return fmt.Errorf("'alarmWorkers' must be greater than zero")
+	}
+	if conf.AlarmQueueSize <= 0 {
+		return fmt.Errorf("'alarmQueueSize' must be greater than zero")
+	}
+	if conf.AlarmUploadTimeout <= 0 {
+		return fmt.Errorf("'alarmUploadTimeout' must be greater than zero")
+	}
+
+	// DC-09 receiver
+
+	if conf.DC09Key != "" {
+		key, err := hex.DecodeString(conf.DC09Key)
+		if err != nil || (len(key) != 16 && len(key) != 24 && len(key) != 32) {
+			return fmt.Errorf("'dc09Key' must be a hex-encoded AES key of 128, 192 or 256 bits")
+		}
+	}
+
 	// Record (deprecated)
 
 	if conf.Record != nil {
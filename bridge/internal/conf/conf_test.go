@@ -58,6 +58,8 @@ func TestConfFromFile(t *testing.T) {
 			RecordMaxPartSize:            50 * 1024 * 1024,
 			RecordSegmentDuration:        3600000000000,
 			RecordDeleteAfter:            86400000000000,
+			AnalyticsFrames:              AnalyticsFramesKeyFrames,
+			AnalyticsInterval:            1,
 			OverridePublisher:            true,
 			RPICameraWidth:               1920,
 			RPICameraHeight:              1080,
@@ -294,6 +296,91 @@ func TestConfErrors(t *testing.T) {
 			"udpMaxPayloadSize: 5000\n",
 			"'udpMaxPayloadSize' must be less than 1472",
 		},
+		{
+			"invalid imapMailboxes",
+			"imap: yes\n" +
+				"imapMailboxes: []\n",
+			"'imap' is enabled but 'imapMailboxes' is empty",
+		},
+		{
+			"invalid IMAP mailbox address",
+			"imapMailboxes:\n" +
+				"  - address: imap.example.com\n" +
+				"    username: cam\n",
+			"invalid IMAP mailbox address 'imap.example.com': address imap.example.com: missing port in address",
+		},
+		{
+			"invalid alarmIncidentMaxDuration",
+			"alarmIncidentWindow: 2m\n" +
+				"alarmIncidentMaxDuration: 1m\n",
+			"'alarmIncidentMaxDuration' must not be lower than 'alarmIncidentWindow'",
+		},
+		{
+			"invalid alarmIncidentAdjacency",
+			"alarmIncidentAdjacency: [[1]]\n",
+			"camera groups must contain at least two cameras",
+		},
+		{
+			"invalid alarmWalkTestTimeout",
+			"alarmWalkTestTimeout: 0s\n",
+			"'alarmWalkTestTimeout' must be greater than zero",
+		},
+		{
+			"invalid alarmCameraTimezone",
+			"alarmCameraTimezone: Mars/Olympus\n",
+			"invalid 'alarmCameraTimezone': unknown time zone Mars/Olympus",
+		},
+		{
+			"invalid alarmClockSkewThreshold",
+			"alarmClockSkewThreshold: -1s\n",
+			"'alarmClockSkewThreshold' must not be negative",
+		},
+		{
+			"invalid alarmWorkers",
+			"alarmWorkers: 0\n",
+			"'alarmWorkers' must be greater than zero",
+		},
+		{
+			"invalid alarmQueueSize",
+			"alarmQueueSize: 0\n",
+			"'alarmQueueSize' must be greater than zero",
+		},
+		{
+			"invalid controlPlane",
+			"controlPlane: cloud\n",
+			"invalid 'controlPlane': cloud",
+		},
+		{
+			"missing bridgeSecretFile",
+			"bridgeSecretFile: \"\"\n",
+			"'bridgeSecretFile' must be set when 'controlPlane' is 'supabase'",
+		},
+		{
+			"missing controlPlaneDirectory",
+			"controlPlane: local\n" +
+				"controlPlaneDirectory: \"\"\n",
+			"'controlPlaneDirectory' must be set when 'controlPlane' is 'local'",
+		},
+		{
+			"invalid heartbeatPeriod",
+			"heartbeatPeriod: 0s\n",
+			"'heartbeatPeriod' must be greater than zero",
+		},
+		{
+			"empty remoteConfigPinned key",
+			"remoteConfigPinned: [logLevel, \"\"]\n",
+			"'remoteConfigPinned' contains an empty key",
+		},
+		{
+			"invalid alarmUploadTimeout",
+			"alarmUploadTimeout: 0s\n",
+			"'alarmUploadTimeout' must be greater than zero",
+		},
+		{
+			"invalid dc09Key",
+			"dc09Key: '0011'\n",
+			"'dc09Key' must be a hex-encoded AES key of 128, 192 or 256 bits",
+		},
 		{
 			"invalid ICE server",
 			"webrtcICEServers: [testing]\n",
@@ -438,6 +525,20 @@ func TestConfErrors(t *testing.T) {
 				"    recordDeleteAfter: 20m\n",
 			`'recordDeleteAfter' cannot be lower than 'recordSegmentDuration'`,
 		},
+		{
+			"invalid analytics frames",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    analyticsFrames: some\n",
+			"invalid analytics frames 'some'",
+		},
+		{
+			"invalid analytics interval",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    analyticsInterval: 0\n",
+			"'analyticsInterval' must be greater than zero",
+		},
 	} {
 		t.Run(ca.name, func(t *testing.T) {
 			tmpf, err := createTempFile([]byte(ca.conf))
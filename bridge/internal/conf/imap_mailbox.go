@@ -0,0 +1,25 @@
+package conf
+
+import "github.com/kaonmir/mini-chekt/internal/conf/jsonwrapper"
+
+// IMAPMailbox is a mailbox polled for alarm emails.
+type IMAPMailbox struct {
+	Address    string `json:"address"`
+	Encryption bool   `json:"encryption"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Mailbox    string `json:"mailbox"`
+	MoveTo     string `json:"moveTo"`
+	CameraIP   string `json:"cameraIP"`
+}
+
+// IMAPMailboxes is a list of IMAPMailbox.
+type IMAPMailboxes []IMAPMailbox
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *IMAPMailboxes) UnmarshalJSON(b []byte) error {
+	// remove default value before loading new value
+	// https://github.com/golang/go/issues/21092
+	*s = nil
+	return jsonwrapper.Unmarshal(b, (*[]IMAPMailbox)(s))
+}
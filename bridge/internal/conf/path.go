@@ -132,6 +132,11 @@ type Path struct {
 	RecordSegmentDuration Duration     `json:"recordSegmentDuration"`
 	RecordDeleteAfter     Duration     `json:"recordDeleteAfter"`
 
+	// Analytics
+	RunAnalytics      string          `json:"runAnalytics"`
+	AnalyticsFrames   AnalyticsFrames `json:"analyticsFrames"`
+	AnalyticsInterval int             `json:"analyticsInterval"`
+
 	// Authentication (deprecated)
 	PublishUser *Credential `json:"publishUser,omitempty"` // deprecated
 	PublishPass *Credential `json:"publishPass,omitempty"` // deprecated
@@ -245,6 +250,10 @@ func (pconf *Path) setDefaults() {
 	pconf.RecordSegmentDuration = 3600 * Duration(time.Second)
 	pconf.RecordDeleteAfter = 24 * 3600 * Duration(time.Second)
 
+	// Analytics
+	pconf.AnalyticsFrames = AnalyticsFramesKeyFrames
+	pconf.AnalyticsInterval = 1
+
 	// Publisher source
 	pconf.OverridePublisher = true
 
@@ -678,6 +687,12 @@ func (pconf *Path) validate(
 		return fmt.Errorf("'recordDeleteAfter' cannot be lower than 'recordSegmentDuration'")
 	}
 
+	// Analytics
+
+	if pconf.AnalyticsInterval < 1 {
+		return fmt.Errorf("'analyticsInterval' must be greater than zero")
+	}
+
 	// Authentication (deprecated)
 
 	if deprecatedCredentialsMode {
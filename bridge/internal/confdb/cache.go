@@ -0,0 +1,174 @@
+package confdb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+var cacheMagic = []byte("MCC1")
+
+// cacheData is the state that allows the bridge to start when the cloud is unreachable.
+type cacheData struct {
+	BridgeUUID string                    `json:"bridgeUUID"`
+	BridgeId   int64                     `json:"bridgeId"`
+	SiteId     int64                     `json:"siteId"`
+	Cameras    []defs.PublicCameraSelect `json:"cameras"`
+	Config     interface{}               `json:"config,omitempty"`
+	SavedAt    time.Time                 `json:"savedAt"`
+}
+
+// cacheKey derives the encryption key of the cache from the identity of the bridge,
+// therefore the cache can't be read without the configuration, and is invalidated
+// when the identity changes.
+func cacheKey(bridgeUUID string, supabaseKey string) []byte {
+	key := sha256.Sum256([]byte("mini-chekt cache\x00" + bridgeUUID + "\x00" + supabaseKey))
+	return key[:]
+}
+
+func newCacheCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// writeCache encrypts and writes the cache atomically.
+func writeCache(fpath string, key []byte, data *cacheData) error {
+	aead, err := newCacheCipher(key)
+	if err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	buf := append([]byte{}, cacheMagic...)
+	buf = append(buf, nonce...)
+	buf = aead.Seal(buf, nonce, plain, cacheMagic)
+
+	err = os.MkdirAll(filepath.Dir(fpath), 0o700)
+	if err != nil {
+		return err
+	}
+
+	tmp := fpath + ".tmp"
+
+	err = os.WriteFile(tmp, buf, 0o600)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, fpath)
+	if err != nil {
+		os.Remove(tmp) //nolint:errcheck
+		return err
+	}
+
+	return nil
+}
+
+// readCache reads and decrypts the cache.
+func readCache(fpath string, key []byte) (*cacheData, error) {
+	buf, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newCacheCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(buf) < len(cacheMagic)+aead.NonceSize() || string(buf[:len(cacheMagic)]) != string(cacheMagic) {
+		return nil, fmt.Errorf("invalid cache file")
+	}
+	buf = buf[len(cacheMagic):]
+
+	plain, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], cacheMagic)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt cache: %w", err)
+	}
+
+	var data cacheData
+	err = json.Unmarshal(plain, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+// loadCache returns the cache of the bridge, if any.
+func (c *ConfDB) loadCache() (*cacheData, error) {
+	if c.Conf.BridgeCache == "" {
+		return nil, fmt.Errorf("cache is disabled")
+	}
+
+	data, err := readCache(c.Conf.BridgeCache, cacheKey(c.Conf.BridgeUUID, c.Conf.SupabaseKey))
+	if err != nil {
+		return nil, err
+	}
+
+	if data.BridgeUUID != c.Conf.BridgeUUID {
+		return nil, fmt.Errorf("cache belongs to another bridge")
+	}
+
+	return data, nil
+}
+
+// saveCache stores the identity of the bridge, its remote configuration and its cameras.
+func (c *ConfDB) saveCache(cameras []defs.PublicCameraSelect) {
+	if c.Conf.BridgeCache == "" {
+		return
+	}
+
+	c.mutex.Lock()
+	config := c.remoteDoc
+	bridgeID := c.bridgeID
+	siteID := c.siteID
+	c.mutex.Unlock()
+
+	err := writeCache(c.Conf.BridgeCache, cacheKey(c.Conf.BridgeUUID, c.Conf.SupabaseKey), &cacheData{
+		BridgeUUID: c.Conf.BridgeUUID,
+		BridgeId:   bridgeID,
+		SiteId:     siteID,
+		Cameras:    cameras,
+		Config:     config,
+		SavedAt:    time.Now(),
+	})
+	if err != nil {
+		c.Log(logger.Warn, "unable to save cache: %v", err)
+	}
+}
+
+// removeCache removes the cache, when the bridge is not paired anymore.
+func (c *ConfDB) removeCache() {
+	if c.Conf.BridgeCache == "" {
+		return
+	}
+
+	err := os.Remove(c.Conf.BridgeCache)
+	if err != nil && !os.IsNotExist(err) {
+		c.Log(logger.Warn, "unable to remove cache: %v", err)
+	}
+}
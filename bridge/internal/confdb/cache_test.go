@@ -0,0 +1,60 @@
+package confdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "cache", "bridge.cache")
+	key := cacheKey("c0ffee00-0000-0000-0000-000000000000", "secret")
+
+	data := &cacheData{
+		BridgeUUID: "c0ffee00-0000-0000-0000-000000000000",
+		BridgeId:   3,
+		SiteId:     5,
+		Cameras: []defs.PublicCameraSelect{{
+			IpAddress:    "192.168.0.10",
+			Source:       "rtsp://192.168.0.10/stream",
+			IsRegistered: true,
+			Password:     stringPtr("P@ssword"),
+		}},
+		SavedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	err = writeCache(fpath, key, data)
+	require.NoError(t, err)
+
+	fi, err := os.Stat(fpath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// credentials are not stored in clear
+	buf, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	require.NotContains(t, string(buf), "P@ssword")
+
+	dec, err := readCache(fpath, key)
+	require.NoError(t, err)
+	require.Equal(t, data, dec)
+
+	_, err = readCache(fpath, cacheKey("c0ffee00-0000-0000-0000-000000000000", "other"))
+	require.Error(t, err)
+
+	buf[len(buf)-1] ^= 0xFF
+	err = os.WriteFile(fpath, buf, 0o600)
+	require.NoError(t, err)
+
+	_, err = readCache(fpath, key)
+	require.Error(t, err)
+}
@@ -0,0 +1,365 @@
+package confdb
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+const (
+	// the pairing state is polled with this period until the bridge is paired.
+	pairingPollPeriod = 5 * time.Second
+	// pairing tokens are renewed when they expire.
+	pairingTokenLifetime = 15 * time.Minute
+	// pairing tokens can't be renewed through the API more often than this.
+	pairingRenewInterval = 10 * time.Second
+
+	pairingTokenLength = 10
+	// ambiguous characters (0, O, 1, I) are excluded.
+	pairingTokenCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// ErrPairingRateLimited is returned when the pairing token is renewed too often.
+var ErrPairingRateLimited = errors.New("pairing token has been renewed too recently, retry later")
+
+// ErrAlreadyPaired is returned when the pairing token is renewed while the bridge is paired.
+var ErrAlreadyPaired = errors.New("bridge is already paired")
+
+// ErrNotPaired is returned when unpairing a bridge that is not paired.
+var ErrNotPaired = errors.New("bridge is not paired")
+
+// generatePairingToken generates a random pairing token, i.e. ABCDE-FGHJK.
+func generatePairingToken() string {
+	token := make([]byte, pairingTokenLength)
+	for i := range token {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(pairingTokenCharset))))
+		token[i] = pairingTokenCharset[n.Int64()]
+	}
+	return string(token[:pairingTokenLength/2]) + "-" + string(token[pairingTokenLength/2:])
+}
+
+type pairingReq struct {
+	unpair bool
+	res    chan error
+}
+
+// PairedSite returns the site the bridge is paired to, or zero if the bridge is not paired.
+func (c *ConfDB) PairedSite() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.pairingState != defs.APIBridgePairingStatePaired {
+		return 0
+	}
+	return c.siteID
+}
+
+func (c *ConfDB) getPairingState() defs.APIBridgePairingState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.pairingState
+}
+
+func (c *ConfDB) setPairingError(err error) {
+	c.Log(logger.Warn, "%v", err)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.pairingError = err.Error()
+}
+
+func (c *ConfDB) setBridgeID(id int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.bridgeID = id
+}
+
+// updatePairing advances the pairing state machine:
+// unpaired -> pending -> paired -> revoked -> pending.
+func (c *ConfDB) updatePairing() {
+	switch c.getPairingState() {
+	case defs.APIBridgePairingStateUnpaired, defs.APIBridgePairingStateRevoked:
+		c.issueToken() //nolint:errcheck
+
+	case defs.APIBridgePairingStatePending:
+		c.checkPending()
+
+	case defs.APIBridgePairingStatePaired:
+		c.checkPaired()
+	}
+}
+
+// issueToken publishes a new pairing token into the bridge record.
+func (c *ConfDB) issueToken() error {
+	if c.BridgeID() == 0 {
+		bridgeData, err := c.ControlPlane.RegisterBridge(c.Conf.BridgeUUID)
+		if err != nil {
+			err = fmt.Errorf("unable to register bridge: %w", err)
+			c.setPairingError(err)
+			return err
+		}
+
+		c.setBridgeID(bridgeData.Id)
+
+		if bridgeData.SiteId != nil {
+			c.setPaired(*bridgeData.SiteId)
+			return nil
+		}
+	}
+
+	token := generatePairingToken()
+	expiresAt := time.Now().Add(pairingTokenLifetime)
+
+	published, err := c.ControlPlane.PublishPairingToken(c.BridgeID(), token, expiresAt)
+	if err != nil {
+		err = fmt.Errorf("unable to publish pairing token: %w", err)
+		c.setPairingError(err)
+		return err
+	}
+
+	// the bridge has been paired or deleted in the meanwhile
+	if !published {
+		bridgeData, err := c.ControlPlane.FetchBridge(c.Conf.BridgeUUID)
+		switch {
+		case err != nil:
+			err = fmt.Errorf("unable to reach CHeKT Server: %w", err)
+
+		case bridgeData == nil:
+			c.setBridgeID(0)
+			err = fmt.Errorf("bridge record has been deleted")
+
+		case bridgeData.SiteId != nil:
+			c.setPaired(*bridgeData.SiteId)
+			return nil
+
+		default:
+			err = fmt.Errorf("unable to publish pairing token")
+		}
+
+		c.setPairingError(err)
+		return err
+	}
+
+	c.mutex.Lock()
+	c.pairingState = defs.APIBridgePairingStatePending
+	c.token = token
+	c.tokenExpiresAt = &expiresAt
+	c.pairingError = ""
+	c.mutex.Unlock()
+
+	c.Log(logger.Warn, "Bridge is not paired. Visit https://chekt.kaonmir.com and enter pairing token %s "+
+		"(expires at %s)", token, expiresAt.Format(time.RFC3339))
+
+	return nil
+}
+
+// checkPending checks whether a pending bridge has been paired.
+func (c *ConfDB) checkPending() {
+	bridgeData, err := c.ControlPlane.FetchBridge(c.Conf.BridgeUUID)
+	if err != nil {
+		c.setPairingError(fmt.Errorf("unable to reach CHeKT Server: %w", err))
+		return
+	}
+
+	// bridge record has been deleted
+	if bridgeData == nil {
+		c.setBridgeID(0)
+		c.issueToken() //nolint:errcheck
+		return
+	}
+
+	if bridgeData.SiteId != nil {
+		c.setPaired(*bridgeData.SiteId)
+		return
+	}
+
+	c.mutex.Lock()
+	expired := c.tokenExpiresAt == nil || !time.Now().Before(*c.tokenExpiresAt)
+	replaced := bridgeData.AccessToken == nil || *bridgeData.AccessToken != c.token
+	c.mutex.Unlock()
+
+	if expired || replaced {
+		c.issueToken() //nolint:errcheck
+	}
+}
+
+func (c *ConfDB) setPaired(siteID int64) {
+	c.mutex.Lock()
+	c.siteID = siteID
+	c.pairingState = defs.APIBridgePairingStatePaired
+	c.token = ""
+	c.tokenExpiresAt = nil
+	c.pairingError = ""
+	c.mutex.Unlock()
+
+	c.Log(logger.Info, "Bridge %d has been paired with site %d", c.BridgeID(), siteID)
+
+	c.Parent.ConfDBPairingChanged()
+
+	c.connect()
+}
+
+// checkPaired checks whether the bridge is still paired, and connects to the cloud
+// after the bridge has been started from cache or after a subscription error.
+func (c *ConfDB) checkPaired() {
+	bridgeData, err := c.ControlPlane.FetchBridge(c.Conf.BridgeUUID)
+	if err != nil {
+		c.setSyncError(fmt.Errorf("unable to reach CHeKT Server: %w", err))
+		return
+	}
+
+	switch {
+	case bridgeData == nil:
+		c.setBridgeID(0)
+		c.revoke("bridge record has been deleted")
+		return
+
+	case bridgeData.Id != c.BridgeID():
+		c.setBridgeID(bridgeData.Id)
+		c.revoke("bridge record has been replaced")
+		return
+
+	case bridgeData.SiteId == nil || *bridgeData.SiteId != c.SiteID():
+		c.revoke("bridge has been unpaired from CHeKT Server")
+		return
+	}
+
+	if c.isOffline() {
+		c.mutex.Lock()
+		c.offline = false
+		c.mutex.Unlock()
+
+		c.Log(logger.Info, "connected to CHeKT Server, reconciling cached configuration")
+	}
+
+	c.connect()
+}
+
+// revoke stops synchronizing cameras of the site and removes them.
+func (c *ConfDB) revoke(reason string) {
+	c.Log(logger.Warn, "Pairing has been revoked: %s", reason)
+
+	c.unsubscribe()
+	c.removeCache()
+	c.setCameras(nil)
+	remoteChanged := c.setRemoteConf(nil)
+
+	c.mutex.Lock()
+	c.pairingState = defs.APIBridgePairingStateRevoked
+	c.offline = false
+	c.remoteExists = false
+	c.mutex.Unlock()
+
+	// the remote configuration can't be removed without reloading the local one
+	if remoteChanged {
+		c.Parent.ConfDBRemoteConfChanged()
+		return
+	}
+
+	c.Parent.ConfDBPairingChanged()
+}
+
+// unpair removes the bridge from its site.
+func (c *ConfDB) unpair() error {
+	if c.getPairingState() != defs.APIBridgePairingStatePaired {
+		return ErrNotPaired
+	}
+
+	err := c.ControlPlane.UnpairBridge(c.BridgeID())
+	if err != nil {
+		return fmt.Errorf("unable to unpair bridge: %w", err)
+	}
+
+	c.revoke("bridge has been unpaired through the API")
+	return nil
+}
+
+// renewToken replaces the pairing token.
+func (c *ConfDB) renewToken() error {
+	if c.getPairingState() == defs.APIBridgePairingStatePaired {
+		return ErrAlreadyPaired
+	}
+
+	if time.Since(c.lastRenew) < pairingRenewInterval {
+		return ErrPairingRateLimited
+	}
+	c.lastRenew = time.Now()
+
+	return c.issueToken()
+}
+
+func (c *ConfDB) handlePairingReq(req pairingReq) error {
+	if req.unpair {
+		return c.unpair()
+	}
+	return c.renewToken()
+}
+
+func (c *ConfDB) pairingRequest(req pairingReq) error {
+	req.res = make(chan error)
+
+	select {
+	case c.chPairing <- req:
+		return <-req.res
+	case <-c.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// APIBridgePairingGet is called by api.
+func (c *ConfDB) APIBridgePairingGet() (*defs.APIBridgePairing, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data := &defs.APIBridgePairing{
+		State: c.pairingState,
+	}
+
+	if c.bridgeID != 0 {
+		v := c.bridgeID
+		data.BridgeID = &v
+	}
+
+	if c.pairingState == defs.APIBridgePairingStatePaired {
+		v := c.siteID
+		data.SiteID = &v
+	}
+
+	if c.pairingState == defs.APIBridgePairingStatePending {
+		v := c.token
+		data.Token = &v
+		data.TokenExpiresAt = c.tokenExpiresAt
+	}
+
+	if c.pairingError != "" {
+		v := c.pairingError
+		data.LastError = &v
+	}
+
+	return data, nil
+}
+
+// APIBridgePairingRenew is called by api.
+func (c *ConfDB) APIBridgePairingRenew() (*defs.APIBridgePairing, error) {
+	err := c.pairingRequest(pairingReq{})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.APIBridgePairingGet()
+}
+
+// APIBridgePairingUnpair is called by api.
+func (c *ConfDB) APIBridgePairingUnpair() (*defs.APIBridgePairing, error) {
+	err := c.pairingRequest(pairingReq{unpair: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.APIBridgePairingGet()
+}
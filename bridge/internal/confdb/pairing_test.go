@@ -0,0 +1,131 @@
+package confdb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+)
+
+func TestGeneratePairingToken(t *testing.T) {
+	re := regexp.MustCompile("^[" + pairingTokenCharset + "]{5}-[" + pairingTokenCharset + "]{5}$")
+	tokens := make(map[string]struct{})
+
+	for i := 0; i < 100; i++ {
+		token := generatePairingToken()
+		require.Regexp(t, re, token)
+		tokens[token] = struct{}{}
+	}
+
+	require.Len(t, tokens, 100)
+}
+
+func TestPairingRenew(t *testing.T) {
+	c := &ConfDB{
+		Parent:       testParent{},
+		pairingState: defs.APIBridgePairingStatePaired,
+	}
+
+	err := c.renewToken()
+	require.ErrorIs(t, err, ErrAlreadyPaired)
+
+	c.pairingState = defs.APIBridgePairingStatePending
+	c.lastRenew = time.Now()
+
+	err = c.renewToken()
+	require.ErrorIs(t, err, ErrPairingRateLimited)
+
+	c.pairingState = defs.APIBridgePairingStateUnpaired
+
+	err = c.unpair()
+	require.ErrorIs(t, err, ErrNotPaired)
+}
+
+// pairBridge pairs a bridge stored into a local control plane, as the web application would do.
+func pairBridge(t *testing.T, dir string, siteID int64) {
+	fpath := filepath.Join(dir, "bridge.json")
+
+	buf, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+
+	var bridges []map[string]interface{}
+	err = json.Unmarshal(buf, &bridges)
+	require.NoError(t, err)
+
+	bridges[0]["site_id"] = siteID
+	bridges[0]["access_token"] = nil
+
+	buf, err = json.Marshal(bridges)
+	require.NoError(t, err)
+
+	err = os.WriteFile(fpath, buf, 0o644)
+	require.NoError(t, err)
+}
+
+func TestPairingLocal(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-confdb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cp := &controlplane.Local{Directory: dir}
+	err = cp.Initialize()
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "camera.json"), []byte(`[
+		{"id": 1, "ip_address": "192.168.0.10", "source": "rtsp://192.168.0.10/stream", "is_registered": true}
+	]`), 0o644)
+	require.NoError(t, err)
+
+	c := &ConfDB{
+		Conf:         tempConf(t, "bridgeUUID: 0b3f9a2c-0000-0000-0000-000000000000\nbridgeCache: \"\"\n"),
+		ControlPlane: cp,
+		Parent:       testParent{},
+	}
+
+	err = c.Load()
+	require.NoError(t, err)
+
+	pairing, err := c.APIBridgePairingGet()
+	require.NoError(t, err)
+	require.Equal(t, defs.APIBridgePairingStateUnpaired, pairing.State)
+	require.Equal(t, int64(1), *pairing.BridgeID)
+
+	c.updatePairing()
+
+	pairing, err = c.APIBridgePairingGet()
+	require.NoError(t, err)
+	require.Equal(t, defs.APIBridgePairingStatePending, pairing.State)
+	require.NotNil(t, pairing.Token)
+
+	bridge, err := cp.FetchBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Equal(t, *pairing.Token, *bridge.AccessToken)
+
+	pairBridge(t, dir, 5)
+
+	c.updatePairing()
+
+	require.Equal(t, int64(5), c.PairedSite())
+	require.Equal(t, map[string]int64{"192.168.0.10": 1}, c.CameraIDs())
+
+	sync, err := c.APICameraSyncGet()
+	require.NoError(t, err)
+	require.True(t, sync.Subscribed)
+
+	err = c.unpair()
+	require.NoError(t, err)
+
+	require.Equal(t, int64(0), c.PairedSite())
+	require.Empty(t, c.CameraIDs())
+
+	bridge, err = cp.FetchBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Nil(t, bridge.SiteId)
+}
@@ -0,0 +1,407 @@
+package confdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+// global settings that can't be set by the remote configuration,
+// since they identify the bridge or its connection to the cloud.
+var remoteConfDenied = map[string]struct{}{
+	"bridgeUUID":                {},
+	"controlPlane":              {},
+	"controlPlaneDirectory":     {},
+	"supabaseURL":               {},
+	"supabaseKey":               {},
+	"bridgeSecretFile":          {},
+	"bridgeCache":               {},
+	"remoteConfigPinned":        {},
+	"externalAuthenticationURL": {},
+	"paths":                     {},
+}
+
+// settings that can't be set by the remote configuration, since they
+// grant access to the bridge, expose its local API or run commands.
+var remoteConfDeniedPrefixes = []string{
+	"auth",
+	"api",
+	"runOn",
+}
+
+func sortedValueKeys(m map[string]interface{}) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func remoteConfAllowed(key string) bool {
+	if _, ok := remoteConfDenied[key]; ok {
+		return false
+	}
+
+	for _, prefix := range remoteConfDeniedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// remoteConf is the global configuration of the bridge stored in the cloud.
+type remoteConf struct {
+	doc          interface{}
+	global       map[string]interface{}
+	pathDefaults map[string]interface{}
+}
+
+// parseRemoteConf checks a remote configuration.
+func parseRemoteConf(doc interface{}) (*remoteConf, error) {
+	values, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid configuration: not an object")
+	}
+
+	rc := &remoteConf{
+		doc:          doc,
+		global:       make(map[string]interface{}),
+		pathDefaults: make(map[string]interface{}),
+	}
+
+	for _, key := range sortedValueKeys(values) {
+		if key == "pathDefaults" {
+			defaults, ok := values[key].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid setting 'pathDefaults': not an object")
+			}
+
+			for _, key2 := range sortedValueKeys(defaults) {
+				if strings.HasPrefix(key2, "runOn") {
+					return nil, fmt.Errorf("invalid setting 'pathDefaults.%s': not allowed", key2)
+				}
+				rc.pathDefaults[key2] = defaults[key2]
+			}
+			continue
+		}
+
+		if !remoteConfAllowed(key) {
+			return nil, fmt.Errorf("invalid setting '%s': not allowed", key)
+		}
+		rc.global[key] = values[key]
+	}
+
+	// check types of settings
+	enc, _ := json.Marshal(rc.global)
+	var global conf.OptionalGlobal
+	err := json.Unmarshal(enc, &global)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	enc, _ = json.Marshal(rc.pathDefaults)
+	var pathDefaults conf.OptionalPath
+	err = json.Unmarshal(enc, &pathDefaults)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: pathDefaults: %w", err)
+	}
+
+	return rc, nil
+}
+
+// split returns the keys of the remote configuration that are used,
+// and the ones that are pinned by the local configuration.
+func (rc *remoteConf) split(pinnedKeys []string) ([]string, []string) {
+	pinned := make(map[string]struct{}, len(pinnedKeys))
+	for _, key := range pinnedKeys {
+		pinned[key] = struct{}{}
+	}
+
+	var used []string
+	var skipped []string
+
+	for _, key := range sortedValueKeys(rc.global) {
+		if _, ok := pinned[key]; ok {
+			skipped = append(skipped, key)
+		} else {
+			used = append(used, key)
+		}
+	}
+
+	_, allPinned := pinned["pathDefaults"]
+
+	for _, key := range sortedValueKeys(rc.pathDefaults) {
+		key = "pathDefaults." + key
+		if _, ok := pinned[key]; ok || allPinned {
+			skipped = append(skipped, key)
+		} else {
+			used = append(used, key)
+		}
+	}
+
+	return used, skipped
+}
+
+// apply merges the remote configuration into a local configuration.
+// Keys pinned by the local configuration are skipped.
+func (rc *remoteConf) apply(c *conf.Conf) {
+	used, _ := rc.split(c.RemoteConfigPinned)
+
+	global := make(map[string]interface{})
+	pathDefaults := make(map[string]interface{})
+
+	for _, key := range used {
+		if name, ok := strings.CutPrefix(key, "pathDefaults."); ok {
+			pathDefaults[name] = rc.pathDefaults[name]
+		} else {
+			global[key] = rc.global[key]
+		}
+	}
+
+	// types have been checked by parseRemoteConf()
+	enc, _ := json.Marshal(global)
+	var optionalGlobal conf.OptionalGlobal
+	json.Unmarshal(enc, &optionalGlobal) //nolint:errcheck
+	c.PatchGlobal(&optionalGlobal)
+
+	enc, _ = json.Marshal(pathDefaults)
+	var optionalPath conf.OptionalPath
+	json.Unmarshal(enc, &optionalPath) //nolint:errcheck
+	c.PatchPathDefaults(&optionalPath)
+}
+
+// updateRemoteConf fetches the remote configuration.
+// It returns whether the configuration to apply has changed.
+func (c *ConfDB) updateRemoteConf() (bool, error) {
+	record, err := c.ControlPlane.FetchBridgeConfig(c.BridgeID())
+	if err != nil {
+		return false, err
+	}
+
+	var doc interface{}
+
+	c.mutex.Lock()
+	if record != nil {
+		doc = record.Config
+		c.remoteReported = reportedStatus(defs.APIBridgeConfigStatus(record.Status), record.Error)
+	} else {
+		c.remoteReported = ""
+	}
+	c.remoteExists = record != nil
+	c.mutex.Unlock()
+
+	return c.setRemoteConf(doc), nil
+}
+
+func reportedStatus(status defs.APIBridgeConfigStatus, message *string) string {
+	if message == nil {
+		return string(status)
+	}
+	return string(status) + "\x00" + *message
+}
+
+// setRemoteConf stores the remote configuration.
+// It returns whether the configuration to apply has changed.
+func (c *ConfDB) setRemoteConf(doc interface{}) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.remoteStatus != "" && reflect.DeepEqual(doc, c.remoteDoc) {
+		return false
+	}
+
+	c.remoteDoc = doc
+	c.remoteError = ""
+	c.remoteAppliedAt = nil
+
+	if doc == nil {
+		c.remoteConf = nil
+		c.remoteStatus = defs.APIBridgeConfigStatusNone
+		return true
+	}
+
+	rc, err := parseRemoteConf(doc)
+	if err != nil {
+		c.Log(logger.Warn, "remote configuration: %v", err)
+		c.remoteConf = nil
+		c.remoteStatus = defs.APIBridgeConfigStatusInvalid
+		c.remoteError = err.Error()
+		return false
+	}
+
+	c.remoteConf = rc
+	c.remoteStatus = defs.APIBridgeConfigStatusPending
+	return true
+}
+
+// mergeRemoteConf merges the remote configuration into a configuration.
+// When the remote configuration can't be used, the last applied one is merged instead.
+func (c *ConfDB) mergeRemoteConf(newConf *conf.Conf) {
+	rc := c.remoteConf
+	if c.remoteStatus == defs.APIBridgeConfigStatusInvalid ||
+		c.remoteStatus == defs.APIBridgeConfigStatusRolledBack {
+		rc = c.remoteApplied
+	}
+
+	if rc == nil {
+		c.remoteStaged = nil
+		return
+	}
+
+	tmp := newConf.Clone()
+	rc.apply(tmp)
+
+	err := tmp.Validate(nil)
+	if err != nil {
+		c.Log(logger.Warn, "remote configuration: %v", err)
+
+		if rc == c.remoteConf {
+			c.remoteStatus = defs.APIBridgeConfigStatusInvalid
+			c.remoteError = err.Error()
+			c.remoteAppliedAt = nil
+		}
+		if rc == c.remoteApplied {
+			c.remoteApplied = nil
+		}
+
+		c.mergeRemoteConf(newConf)
+		return
+	}
+
+	*newConf = *tmp
+	c.remoteStaged = rc
+	c.remotePinned = append([]string(nil), newConf.RemoteConfigPinned...)
+}
+
+// RemoteConfApplied is called by core when the configuration has been applied.
+func (c *ConfDB) RemoteConfApplied() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.remoteApplied = c.remoteStaged
+
+	if c.remoteStaged != nil && c.remoteStaged == c.remoteConf &&
+		c.remoteStatus == defs.APIBridgeConfigStatusPending {
+		now := time.Now()
+		c.remoteStatus = defs.APIBridgeConfigStatusApplied
+		c.remoteAppliedAt = &now
+
+		c.Log(logger.Info, "remote configuration applied")
+		c.notifyReport()
+	}
+}
+
+// RemoteConfFailed is called by core when resources can't be created with the configuration.
+// It returns whether the failure can be recovered by rolling back the remote configuration.
+func (c *ConfDB) RemoteConfFailed(err error) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.remoteStaged == nil || c.remoteStaged == c.remoteApplied {
+		return false
+	}
+
+	if c.remoteStaged == c.remoteConf {
+		c.remoteStatus = defs.APIBridgeConfigStatusRolledBack
+		c.remoteError = err.Error()
+		c.remoteAppliedAt = nil
+	}
+
+	c.Log(logger.Warn, "rolling back remote configuration: %v", err)
+	c.notifyReport()
+
+	return true
+}
+
+// notifyReport asks for the status of cameras and of the remote configuration to be reported.
+func (c *ConfDB) notifyReport() {
+	if c.chReport != nil {
+		select {
+		case c.chReport <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// reportRemoteConf stores the status of the remote configuration into its record,
+// in order to show it in the UI.
+func (c *ConfDB) reportRemoteConf() {
+	if c.getPairingState() != defs.APIBridgePairingStatePaired || c.isOffline() {
+		return
+	}
+
+	c.mutex.Lock()
+	if !c.remoteExists || c.remoteStatus == "" || c.remoteStatus == defs.APIBridgeConfigStatusNone {
+		c.mutex.Unlock()
+		return
+	}
+
+	status := c.remoteStatus
+	var message *string
+	if c.remoteError != "" {
+		v := c.remoteError
+		message = &v
+	}
+	appliedAt := c.remoteAppliedAt
+
+	reported := reportedStatus(status, message)
+	if reported == c.remoteReported {
+		c.mutex.Unlock()
+		return
+	}
+	c.mutex.Unlock()
+
+	err := c.ControlPlane.ReportBridgeConfigStatus(c.BridgeID(), string(status), message, appliedAt)
+	if err != nil {
+		c.Log(logger.Warn, "unable to report status of remote configuration: %v", err)
+		return
+	}
+
+	c.mutex.Lock()
+	c.remoteReported = reported
+	c.mutex.Unlock()
+}
+
+// APIBridgeConfigGet is called by api.
+func (c *ConfDB) APIBridgeConfigGet() (*defs.APIBridgeConfig, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data := &defs.APIBridgeConfig{
+		Status:    c.remoteStatus,
+		Keys:      []string{},
+		Pinned:    []string{},
+		AppliedAt: c.remoteAppliedAt,
+	}
+
+	if data.Status == "" {
+		data.Status = defs.APIBridgeConfigStatusNone
+	}
+
+	if c.remoteApplied != nil {
+		used, skipped := c.remoteApplied.split(c.remotePinned)
+		if used != nil {
+			data.Keys = used
+		}
+		if skipped != nil {
+			data.Pinned = skipped
+		}
+	}
+
+	if c.remoteError != "" {
+		v := c.remoteError
+		data.Error = &v
+	}
+
+	return data, nil
+}
@@ -0,0 +1,200 @@
+package confdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+func TestParseRemoteConf(t *testing.T) {
+	rc, err := parseRemoteConf(map[string]interface{}{
+		"logLevel":   "debug",
+		"hlsVariant": "mpegts",
+		"pathDefaults": map[string]interface{}{
+			"recordDeleteAfter": "72h",
+		},
+	})
+	require.NoError(t, err)
+
+	used, skipped := rc.split([]string{"hlsVariant"})
+	require.Equal(t, []string{"logLevel", "pathDefaults.recordDeleteAfter"}, used)
+	require.Equal(t, []string{"hlsVariant"}, skipped)
+
+	used, skipped = rc.split([]string{"pathDefaults"})
+	require.Equal(t, []string{"hlsVariant", "logLevel"}, used)
+	require.Equal(t, []string{"pathDefaults.recordDeleteAfter"}, skipped)
+
+	for _, ca := range []struct {
+		name string
+		doc  interface{}
+		err  string
+	}{
+		{
+			"not an object",
+			[]interface{}{"logLevel"},
+			"invalid configuration: not an object",
+		},
+		{
+			"identity",
+			map[string]interface{}{"bridgeUUID": "0b3f9a2c-0000-0000-0000-000000000000"},
+			"invalid setting 'bridgeUUID': not allowed",
+		},
+		{
+			"authentication",
+			map[string]interface{}{"authInternalUsers": []interface{}{}},
+			"invalid setting 'authInternalUsers': not allowed",
+		},
+		{
+			"hooks",
+			map[string]interface{}{"runOnConnect": "rm -rf /"},
+			"invalid setting 'runOnConnect': not allowed",
+		},
+		{
+			"path hooks",
+			map[string]interface{}{"pathDefaults": map[string]interface{}{"runOnReady": "rm -rf /"}},
+			"invalid setting 'pathDefaults.runOnReady': not allowed",
+		},
+		{
+			"path defaults not an object",
+			map[string]interface{}{"pathDefaults": true},
+			"invalid setting 'pathDefaults': not an object",
+		},
+		{
+			"unknown setting",
+			map[string]interface{}{"logLevels": "debug"},
+			"invalid configuration: json: unknown field \"logLevels\"",
+		},
+		{
+			"invalid value",
+			map[string]interface{}{"logLevel": "verbose"},
+			"invalid configuration: invalid log level: 'verbose'",
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			_, err := parseRemoteConf(ca.doc)
+			require.EqualError(t, err, ca.err)
+		})
+	}
+}
+
+func writeBridgeConfig(t *testing.T, dir string, config string) {
+	err := os.WriteFile(filepath.Join(dir, "bridge_config.json"), []byte(fmt.Sprintf(`[
+		{"bridge_id": 1, "config": %s, "status": "pending"}
+	]`, config)), 0o644)
+	require.NoError(t, err)
+}
+
+func TestRemoteConf(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-confdb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cp := &controlplane.Local{Directory: dir, SiteID: 1}
+	err = cp.Initialize()
+	require.NoError(t, err)
+
+	writeBridgeConfig(t, dir, `{"logLevel": "debug", "readTimeout": "30s", "pathDefaults": {"record": true}}`)
+
+	c := &ConfDB{
+		Conf: tempConf(t, "bridgeUUID: 0b3f9a2c-0000-0000-0000-000000000000\n"+
+			"bridgeCache: \"\"\n"+
+			"remoteConfigPinned: [readTimeout]\n"),
+		ControlPlane: cp,
+		Parent:       testParent{},
+	}
+
+	err = c.Load()
+	require.NoError(t, err)
+	require.Equal(t, conf.LogLevel(logger.Debug), c.Conf.LogLevel)
+	require.Equal(t, conf.Duration(10e9), c.Conf.ReadTimeout)
+	require.Equal(t, true, c.Conf.PathDefaults.Record)
+
+	c.RemoteConfApplied()
+	c.reportRemoteConf()
+
+	status, err := c.APIBridgeConfigGet()
+	require.NoError(t, err)
+	require.Equal(t, defs.APIBridgeConfigStatusApplied, status.Status)
+	require.Equal(t, []string{"logLevel", "pathDefaults.record"}, status.Keys)
+	require.Equal(t, []string{"readTimeout"}, status.Pinned)
+
+	record, err := cp.FetchBridgeConfig(c.BridgeID())
+	require.NoError(t, err)
+	require.Equal(t, "applied", record.Status)
+	require.NotNil(t, record.AppliedAt)
+
+	// a configuration that doesn't pass validation is not applied
+	writeBridgeConfig(t, dir, `{"logLevel": "warn", "writeQueueSize": 1000}`)
+
+	changed, err := c.updateRemoteConf()
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	newConf := tempConf(t, "remoteConfigPinned: [readTimeout]\n")
+	_, err = c.Apply(newConf)
+	require.NoError(t, err)
+	require.Equal(t, conf.LogLevel(logger.Debug), newConf.LogLevel)
+
+	c.reportRemoteConf()
+
+	record, err = cp.FetchBridgeConfig(c.BridgeID())
+	require.NoError(t, err)
+	require.Equal(t, "invalid", record.Status)
+	require.Equal(t, "'writeQueueSize' must be a power of two", *record.Error)
+	require.Nil(t, record.AppliedAt)
+
+	// a configuration that prevents resources from starting is rolled back
+	writeBridgeConfig(t, dir, `{"logLevel": "warn"}`)
+
+	changed, err = c.updateRemoteConf()
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	newConf = tempConf(t, "")
+	_, err = c.Apply(newConf)
+	require.NoError(t, err)
+	require.Equal(t, conf.LogLevel(logger.Warn), newConf.LogLevel)
+
+	require.True(t, c.RemoteConfFailed(fmt.Errorf("listen tcp :8554: bind: address already in use")))
+
+	newConf = tempConf(t, "")
+	_, err = c.Apply(newConf)
+	require.NoError(t, err)
+	require.Equal(t, conf.LogLevel(logger.Debug), newConf.LogLevel)
+
+	c.RemoteConfApplied()
+	c.reportRemoteConf()
+
+	record, err = cp.FetchBridgeConfig(c.BridgeID())
+	require.NoError(t, err)
+	require.Equal(t, "rolled_back", record.Status)
+	require.Equal(t, "listen tcp :8554: bind: address already in use", *record.Error)
+
+	// failures unrelated to the remote configuration can't be recovered
+	require.False(t, c.RemoteConfFailed(fmt.Errorf("listen tcp :8554: bind: address already in use")))
+
+	// local configuration is restored when the remote one is removed
+	err = os.WriteFile(filepath.Join(dir, "bridge_config.json"), []byte("[]"), 0o644)
+	require.NoError(t, err)
+
+	changed, err = c.updateRemoteConf()
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	newConf = tempConf(t, "")
+	_, err = c.Apply(newConf)
+	require.NoError(t, err)
+	require.Equal(t, conf.LogLevel(logger.Info), newConf.LogLevel)
+
+	status, err = c.APIBridgeConfigGet()
+	require.NoError(t, err)
+	require.Equal(t, defs.APIBridgeConfigStatusNone, status.Status)
+}
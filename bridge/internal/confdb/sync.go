@@ -0,0 +1,582 @@
+package confdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+const (
+	// cameras are synced periodically too, in case some changes are not notified.
+	cameraSyncPeriod = 5 * time.Minute
+	// changes are grouped together before syncing cameras.
+	cameraSyncDebounce = time.Second
+	// the cloud is contacted again with this period, when unreachable.
+	cameraSyncRetryPeriod = 30 * time.Second
+	// alarms look for recordings in this folder.
+	cameraRecordPath = "./recordings/%path/%Y-%m-%d_%H-%M-%S-%f"
+)
+
+// path settings that can be set by the settings of a camera.
+var cameraSettings = map[string]struct{}{
+	"record":                     {},
+	"recordFormat":               {},
+	"recordPartDuration":         {},
+	"recordSegmentDuration":      {},
+	"recordDeleteAfter":          {},
+	"sourceOnDemand":             {},
+	"sourceOnDemandStartTimeout": {},
+	"sourceOnDemandCloseAfter":   {},
+	"maxReaders":                 {},
+	"rtspTransport":              {},
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// cameraSource returns the source of a camera, with its credentials.
+func cameraSource(camera *defs.PublicCameraSelect) (string, error) {
+	u, err := url.Parse(camera.Source)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid source '%s'", camera.Source)
+	}
+
+	// credentials in the source have priority
+	if u.User == nil && camera.Username != nil && *camera.Username != "" {
+		password := ""
+		if camera.Password != nil {
+			password = *camera.Password
+		}
+		u.User = url.UserPassword(*camera.Username, password)
+	}
+
+	return u.String(), nil
+}
+
+// cameraPathConf returns the path configuration of a camera, encoded in JSON.
+func cameraPathConf(camera *defs.PublicCameraSelect) ([]byte, error) {
+	source, err := cameraSource(camera)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+
+	if camera.Settings != nil {
+		settings, ok := camera.Settings.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid settings: not an object")
+		}
+
+		keys := make([]string, 0, len(settings))
+		for key := range settings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if _, ok := cameraSettings[key]; !ok {
+				return nil, fmt.Errorf("invalid setting '%s': not allowed", key)
+			}
+			values[key] = settings[key]
+		}
+	}
+
+	values["source"] = source
+	values["recordPath"] = cameraRecordPath
+
+	enc, _ := json.Marshal(values)
+
+	// check types of settings
+	var optional conf.OptionalPath
+	err = json.Unmarshal(enc, &optional)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+
+	return enc, nil
+}
+
+// cameraPaths returns the configuration of the paths of registered cameras, encoded in JSON.
+// Paths are named after the address of their camera.
+func cameraPaths(cameras []defs.PublicCameraSelect) (map[string][]byte, map[string]error) {
+	paths := make(map[string][]byte)
+	errs := make(map[string]error)
+
+	for _, camera := range cameras {
+		if !camera.IsRegistered || camera.IpAddress == "" {
+			continue
+		}
+
+		enc, err := cameraPathConf(&camera)
+		if err != nil {
+			errs[camera.IpAddress] = err
+			continue
+		}
+
+		paths[camera.IpAddress] = enc
+	}
+
+	return paths, errs
+}
+
+// Initialize starts the synchronization of cameras.
+func (c *ConfDB) Initialize() {
+	c.ctx, c.ctxCancel = context.WithCancel(context.Background())
+	c.chSync = make(chan struct{}, 1)
+	c.chReport = make(chan struct{}, 1)
+	c.chPairing = make(chan pairingReq)
+	c.done = make(chan struct{})
+
+	go c.runSync()
+}
+
+// Close stops the synchronization of cameras.
+func (c *ConfDB) Close() {
+	if c.ctxCancel == nil {
+		return
+	}
+
+	c.ctxCancel()
+	<-c.done
+}
+
+func (c *ConfDB) runSync() {
+	defer close(c.done)
+	defer c.unsubscribe()
+
+	c.updatePairing()
+
+	pairing := time.NewTicker(pairingPollPeriod)
+	defer pairing.Stop()
+
+	periodic := time.NewTicker(cameraSyncPeriod)
+	defer periodic.Stop()
+
+	retry := time.NewTicker(cameraSyncRetryPeriod)
+	defer retry.Stop()
+
+	debounce := time.NewTimer(0)
+	debounce.Stop()
+
+	for {
+		select {
+		case req := <-c.chPairing:
+			req.res <- c.handlePairingReq(req)
+
+		case <-pairing.C:
+			if c.getPairingState() != defs.APIBridgePairingStatePaired {
+				c.updatePairing()
+			}
+
+		case <-c.chSync:
+			debounce.Reset(cameraSyncDebounce)
+
+		case <-debounce.C:
+			c.syncCameras()
+
+		case <-periodic.C:
+			c.syncCameras()
+
+		case <-c.chReport:
+			c.reportErrors()
+			c.reportRemoteConf()
+
+		case <-retry.C:
+			// detect revocations and reconnect
+			if c.getPairingState() == defs.APIBridgePairingStatePaired {
+				c.updatePairing()
+			}
+
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *ConfDB) isOffline() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.offline
+}
+
+// connect subscribes to camera changes, if not subscribed yet.
+func (c *ConfDB) connect() {
+	if c.sub != nil {
+		return
+	}
+
+	err := c.subscribe()
+	if err != nil {
+		c.setSyncError(fmt.Errorf("unable to subscribe to camera changes: %w", err))
+		return
+	}
+
+	// changes may have been missed while not subscribed
+	c.syncCameras()
+}
+
+func (c *ConfDB) unsubscribe() {
+	if c.sub == nil {
+		return
+	}
+
+	c.sub.Close()
+	c.sub = nil
+
+	c.remoteSub.Close()
+	c.remoteSub = nil
+
+	c.mutex.Lock()
+	c.subscribed = false
+	c.mutex.Unlock()
+}
+
+func (c *ConfDB) subscribe() error {
+	sub, err := c.ControlPlane.WatchCameras(c.BridgeID(), func() {
+		select {
+		case c.chSync <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	remoteSub, err := c.ControlPlane.WatchBridgeConfig(c.BridgeID(), func() {
+		select {
+		case c.chSync <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		sub.Close()
+		return err
+	}
+
+	c.sub = sub
+	c.remoteSub = remoteSub
+
+	c.mutex.Lock()
+	c.subscribed = true
+	c.mutex.Unlock()
+
+	c.Log(logger.Info, "subscribed to camera and configuration changes")
+	return nil
+}
+
+// updateCameras fetches cameras and stores their paths.
+// It returns whether paths have changed.
+func (c *ConfDB) updateCameras() (bool, error) {
+	cameras, err := c.ControlPlane.ListCameras(c.BridgeID())
+	if err != nil {
+		return false, err
+	}
+
+	c.saveCache(cameras)
+
+	return c.setCameras(cameras), nil
+}
+
+// setCameras stores the paths of cameras.
+// It returns whether paths have changed.
+func (c *ConfDB) setCameras(cameras []defs.PublicCameraSelect) bool {
+	paths, errs := cameraPaths(cameras)
+
+	for name, err := range errs {
+		c.Log(logger.Warn, "camera %s: %v", name, err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	changed := !reflect.DeepEqual(paths, c.paths)
+
+	// errors of unchanged paths are kept, since they are not applied again
+	pathErrors := make(map[string]string)
+	for name, err := range errs {
+		pathErrors[name] = err.Error()
+	}
+	for name, err := range c.pathErrors {
+		if enc, ok := paths[name]; ok && reflect.DeepEqual(enc, c.paths[name]) {
+			pathErrors[name] = err
+		}
+	}
+
+	c.paths = paths
+	c.cameraCount = len(cameras)
+	c.pathErrors = pathErrors
+
+	c.cameraIDs = make(map[string]int64)
+	c.cameraZones = make(map[int64]string)
+	c.reportedErrors = make(map[int64]string)
+	for _, camera := range cameras {
+		if camera.IsRegistered && camera.IpAddress != "" {
+			c.cameraIDs[camera.IpAddress] = camera.Id
+
+			if camera.Timezone != nil && *camera.Timezone != "" {
+				c.cameraZones[camera.Id] = *camera.Timezone
+			}
+		}
+		if camera.ConfigError != nil {
+			c.reportedErrors[camera.Id] = *camera.ConfigError
+		} else {
+			c.reportedErrors[camera.Id] = ""
+		}
+	}
+
+	return changed
+}
+
+// CameraIDs returns the IDs of registered cameras, by path name.
+func (c *ConfDB) CameraIDs() map[string]int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ret := make(map[string]int64, len(c.cameraIDs))
+	for name, id := range c.cameraIDs {
+		ret[name] = id
+	}
+	return ret
+}
+
+// CameraTimezones returns the timezones of registered cameras that have one, by camera ID.
+func (c *ConfDB) CameraTimezones() map[int64]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ret := make(map[int64]string, len(c.cameraZones))
+	for id, zone := range c.cameraZones {
+		ret[id] = zone
+	}
+	return ret
+}
+
+func (c *ConfDB) syncCameras() {
+	// identity and pairing of the bridge must be verified first
+	if c.getPairingState() != defs.APIBridgePairingStatePaired || c.isOffline() {
+		return
+	}
+
+	remoteChanged, err := c.updateRemoteConf()
+	if err != nil {
+		c.setSyncError(fmt.Errorf("unable to fetch bridge configuration: %w", err))
+		return
+	}
+
+	prevIDs, prevZones := c.CameraIDs(), c.CameraTimezones()
+
+	changed, err := c.updateCameras()
+	if err != nil {
+		c.setSyncError(fmt.Errorf("unable to fetch cameras: %w", err))
+		return
+	}
+
+	camerasChanged := !reflect.DeepEqual(prevIDs, c.CameraIDs()) ||
+		!reflect.DeepEqual(prevZones, c.CameraTimezones())
+
+	c.mutex.Lock()
+	now := time.Now()
+	c.lastSync = &now
+	c.lastError = ""
+	c.mutex.Unlock()
+
+	// the whole configuration is reloaded, including cameras
+	if remoteChanged {
+		c.Log(logger.Info, "remote configuration changed")
+		c.Parent.ConfDBRemoteConfChanged()
+		return
+	}
+
+	if changed {
+		c.Log(logger.Info, "camera list changed")
+		c.Parent.ConfDBCamerasChanged()
+		return
+	}
+
+	// addresses and timezones of cameras are used by alarms, even when paths are unchanged
+	if camerasChanged {
+		c.Log(logger.Info, "camera addresses or timezones changed")
+		c.Parent.ConfDBCamerasChanged()
+	}
+
+	c.reportErrors()
+	c.reportRemoteConf()
+}
+
+// reportErrors stores errors of cameras into their records, in order to show them in the UI.
+// Only errors that differ from the stored ones are written.
+func (c *ConfDB) reportErrors() {
+	if c.getPairingState() != defs.APIBridgePairingStatePaired || c.isOffline() {
+		return
+	}
+
+	c.mutex.Lock()
+	desired := make(map[int64]string, len(c.reportedErrors))
+	for id := range c.reportedErrors {
+		desired[id] = ""
+	}
+	for name, err := range c.pathErrors {
+		if id, ok := c.cameraIDs[name]; ok {
+			desired[id] = err
+		}
+	}
+
+	updates := make(map[int64]string)
+	for id, err := range desired {
+		if err != c.reportedErrors[id] {
+			updates[id] = err
+		}
+	}
+	c.mutex.Unlock()
+
+	for id, err := range updates {
+		var message *string
+		if err != "" {
+			message = &err
+		}
+
+		err2 := c.ControlPlane.SetCameraError(c.BridgeID(), id, message)
+		if err2 != nil {
+			c.Log(logger.Warn, "unable to report error of camera %d: %v", id, err2)
+			continue
+		}
+
+		c.mutex.Lock()
+		c.reportedErrors[id] = err
+		c.mutex.Unlock()
+	}
+}
+
+func (c *ConfDB) setSyncError(err error) {
+	c.Log(logger.Warn, "%v", err)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	c.lastError = err.Error()
+	c.lastErrorAt = &now
+}
+
+// Apply merges the remote configuration into a configuration, then replaces its paths of cameras
+// with the ones of registered cameras, leaving other paths untouched.
+// Paths with an invalid configuration are skipped.
+// It returns whether paths of cameras have changed.
+func (c *ConfDB) Apply(newConf *conf.Conf) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.mergeRemoteConf(newConf)
+
+	touched := false
+	changed := false
+
+	// remove paths of cameras that have been deleted or unregistered
+	for name := range c.applied {
+		if _, ok := c.paths[name]; !ok {
+			if newConf.RemovePath(name) == nil {
+				touched = true
+				changed = true
+			}
+		}
+	}
+
+	c.applied = make(map[string]struct{})
+
+	for _, name := range sortedKeys(c.paths) {
+		var optional conf.OptionalPath
+		err := json.Unmarshal(c.paths[name], &optional)
+		if err != nil {
+			return false, err
+		}
+
+		prev, ok := newConf.OptionalPaths[name]
+		if ok && prev != nil && reflect.DeepEqual(prev.Values, optional.Values) {
+			c.applied[name] = struct{}{}
+			continue
+		}
+
+		newConf.ReplacePath(name, &optional) //nolint:errcheck
+		touched = true
+
+		err = newConf.Validate(nil)
+		if err != nil {
+			c.Log(logger.Warn, "camera %s: %v", name, err)
+			c.pathErrors[name] = err.Error()
+
+			if ok {
+				newConf.ReplacePath(name, prev) //nolint:errcheck
+			} else {
+				newConf.RemovePath(name) //nolint:errcheck
+			}
+			continue
+		}
+
+		delete(c.pathErrors, name)
+		c.applied[name] = struct{}{}
+		changed = true
+	}
+
+	// errors are reported once the configuration has been applied
+	c.notifyReport()
+
+	if touched {
+		err := newConf.Validate(nil)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return changed, nil
+}
+
+// APICameraSyncGet is called by api.
+func (c *ConfDB) APICameraSyncGet() (*defs.APICameraSync, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data := &defs.APICameraSync{
+		Subscribed:  c.subscribed,
+		Offline:     c.offline,
+		Cameras:     c.cameraCount,
+		Paths:       make([]string, 0, len(c.applied)),
+		Errors:      make(map[string]string, len(c.pathErrors)),
+		LastSync:    c.lastSync,
+		LastErrorAt: c.lastErrorAt,
+	}
+
+	for name := range c.applied {
+		data.Paths = append(data.Paths, name)
+	}
+	sort.Strings(data.Paths)
+
+	for name, err := range c.pathErrors {
+		data.Errors[name] = err
+	}
+
+	if c.lastError != "" {
+		v := c.lastError
+		data.LastError = &v
+	}
+
+	return data, nil
+}
@@ -0,0 +1,178 @@
+// Package controlplane contains the backends that store the identity, the cameras and the alarms of the bridge.
+package controlplane
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+)
+
+// Subscription is a subscription to changes or commands.
+type Subscription interface {
+	Close()
+}
+
+// PanelZone is a zone of an intrusion panel, mapped to a camera.
+type PanelZone struct {
+	Zone     int
+	CameraID int64
+	CameraIP string
+}
+
+// Panel is an intrusion panel connected to the bridge.
+type Panel struct {
+	ID      int64
+	SiteID  int64
+	Account string
+	Zones   []PanelZone
+}
+
+// Command is a command sent to the bridge.
+type Command struct {
+	Event   string
+	Payload map[string]interface{}
+}
+
+// CommandResponse is the response to a command.
+type CommandResponse struct {
+	RequestID   string
+	RequesterID string
+	Path        string
+	Body        interface{}
+}
+
+// roles of users in a site.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// BridgeIdentity stores the identity and the pairing of the bridge.
+type BridgeIdentity interface {
+	// RegisterBridge creates the bridge record, if it doesn't exist, and returns it.
+	RegisterBridge(uuid string) (*defs.PublicBridgeSelect, error)
+
+	// FetchBridge returns the bridge record, or nil if it doesn't exist.
+	FetchBridge(uuid string) (*defs.PublicBridgeSelect, error)
+
+	// PublishPairingToken stores a pairing token into the bridge record.
+	// It returns false if the bridge has been paired or deleted in the meanwhile.
+	PublishPairingToken(bridgeID int64, token string, expiresAt time.Time) (bool, error)
+
+	// UnpairBridge removes the bridge from its site.
+	UnpairBridge(bridgeID int64) error
+
+	// UpdateBridgeStatus marks the bridge as healthy and stores its status.
+	UpdateBridgeStatus(bridgeID int64, checkedAt time.Time, version string, status interface{}) error
+}
+
+// CameraRegistry stores the cameras and the intrusion panels of the bridge.
+type CameraRegistry interface {
+	// ListCameras returns the cameras of the bridge.
+	ListCameras(bridgeID int64) ([]defs.PublicCameraSelect, error)
+
+	// WatchCameras calls onChange when cameras of the bridge are changed.
+	WatchCameras(bridgeID int64, onChange func()) (Subscription, error)
+
+	// UpdateCameraHealth sets the health of cameras of the bridge.
+	UpdateCameraHealth(bridgeID int64, cameraIDs []int64, healthy bool, checkedAt time.Time) error
+
+	// SetCameraError sets the configuration error of a camera, or clears it if message is nil.
+	SetCameraError(bridgeID int64, cameraID int64, message *string) error
+
+	// ListPanels returns the intrusion panels of the bridge.
+	ListPanels(bridgeID int64) ([]*Panel, error)
+}
+
+// ConfigStore stores the global configuration of the bridge.
+type ConfigStore interface {
+	// FetchBridgeConfig returns the global configuration of the bridge, or nil if it doesn't exist.
+	FetchBridgeConfig(bridgeID int64) (*defs.PublicBridgeConfigSelect, error)
+
+	// WatchBridgeConfig calls onChange when the global configuration of the bridge is changed.
+	WatchBridgeConfig(bridgeID int64, onChange func()) (Subscription, error)
+
+	// ReportBridgeConfigStatus stores whether the global configuration of the bridge has been applied.
+	ReportBridgeConfigStatus(bridgeID int64, status string, message *string, appliedAt *time.Time) error
+}
+
+// AlarmSink stores alarms, incidents and walk tests.
+// Fields of updates are named after the columns of records.
+type AlarmSink interface {
+	// InsertAlarm stores an alarm and returns its ID.
+	InsertAlarm(alarm *defs.PublicAlarmInsert) (int64, error)
+
+	// SetAlarmVideo attaches recordings to an alarm.
+	SetAlarmVideo(alarmID int64, videoURL string) error
+
+	// SetAlarmIncident attaches an alarm to an incident.
+	SetAlarmIncident(alarmID int64, incidentID int64) error
+
+	// InsertIncident stores an incident and returns its ID.
+	InsertIncident(incident *defs.PublicIncidentInsert) (int64, error)
+
+	// UpdateIncident updates fields of an incident.
+	UpdateIncident(incidentID int64, fields map[string]interface{}) error
+
+	// SetArmStatus sets the arm status of a site.
+	SetArmStatus(siteID int64, status string, changedAt time.Time) error
+
+	// InsertWalkTest stores a walk test and returns its ID.
+	InsertWalkTest(walkTest *defs.PublicWalkTestInsert) (int64, error)
+
+	// UpdateWalkTest updates fields of a walk test.
+	UpdateWalkTest(walkTestID int64, fields map[string]interface{}) error
+}
+
+// MediaStorage stores recordings of alarms.
+type MediaStorage interface {
+	// UploadMedia stores a file and returns its URL.
+	UploadMedia(ctx context.Context, name string, contentType string, r io.Reader,
+		onProgress func(uploaded int64)) (string, error)
+}
+
+// CommandChannel delivers commands to the bridge.
+type CommandChannel interface {
+	// ListenCommands calls onCommand when a command is sent to the bridge.
+	ListenCommands(bridgeID int64, onCommand func(*Command)) (Subscription, error)
+
+	// RespondCommand delivers the response to a command to its requester.
+	RespondCommand(bridgeID int64, res *CommandResponse) error
+
+	// RequesterRole returns the role, in the site of the bridge, of the user that sent a request
+	// with the given method, path and body digest. It returns an empty string if the request
+	// can't be attributed to a member of the site. Requests can be attributed only once.
+	RequesterRole(bridgeID int64, requestID string, method string, path string, bodyHash string) (string, error)
+}
+
+// Presence is the presence of the bridge, that makes it visible as online.
+type Presence interface {
+	Subscription
+
+	// Track replaces the metadata of the bridge.
+	Track(meta interface{}) error
+}
+
+// BridgePresence publishes the online status of the bridge.
+type BridgePresence interface {
+	// JoinBridgePresence makes the bridge online, with the given metadata, until the presence is closed.
+	// The bridge goes offline when the connection to the backend fails, too.
+	JoinBridgePresence(bridgeID int64, meta interface{}) (Presence, error)
+}
+
+// ControlPlane is a backend of the bridge.
+type ControlPlane interface {
+	BridgeIdentity
+	CameraRegistry
+	ConfigStore
+	AlarmSink
+	MediaStorage
+	CommandChannel
+	BridgePresence
+
+	// Close releases resources of the backend.
+	Close()
+}
@@ -0,0 +1,148 @@
+package controlplane
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// path of the function that exchanges the identity of bridges for access tokens.
+	supabaseAuthPath = "/functions/v1/bridge-auth"
+	// access tokens are refreshed when this fraction of their lifetime has passed.
+	tokenRefreshRatio = 0.75
+	// failed refreshes are retried with this period.
+	tokenRetryPeriod = 30 * time.Second
+	// maximum duration of a token request.
+	tokenRequestTimeout = 10 * time.Second
+)
+
+// ErrInvalidCredentials is returned when the secret of the bridge is rejected,
+// i.e. when another bridge has enrolled with the same UUID.
+var ErrInvalidCredentials = errors.New("bridge credentials have been rejected")
+
+// ErrCredentialsRevoked is returned when the credentials of the bridge have been revoked.
+// The bridge can't authenticate until it is re-provisioned, and its secret is replaced,
+// since the revoked one can't be enrolled again.
+var ErrCredentialsRevoked = errors.New("bridge credentials have been revoked")
+
+// loadSecret reads the secret of the bridge, generating it if it doesn't exist.
+func loadSecret(fpath string) (string, error) {
+	buf, err := os.ReadFile(fpath)
+	if err == nil {
+		secret := strings.TrimSpace(string(buf))
+		if secret != "" {
+			return secret, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	return newSecret(fpath)
+}
+
+// newSecret generates a secret and stores it, replacing the existing one.
+func newSecret(fpath string) (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+
+	err = os.MkdirAll(filepath.Dir(fpath), 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.WriteFile(fpath, []byte(secret+"\n"), 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// accessToken is a short-lived token, scoped to the bridge and its site.
+type accessToken struct {
+	Token     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (t *accessToken) valid() bool {
+	return time.Now().Before(t.ExpiresAt)
+}
+
+func (t *accessToken) refreshAt() time.Time {
+	return t.IssuedAt.Add(time.Duration(float64(t.ExpiresAt.Sub(t.IssuedAt)) * tokenRefreshRatio))
+}
+
+// requestToken exchanges the identity of the bridge for an access token.
+// The bridge is registered, and its secret is enrolled, if needed.
+func requestToken(
+	ctx context.Context, httpClient *http.Client, url string, key string, uuid string, secret string,
+) (*accessToken, error) {
+	body, _ := json.Marshal(map[string]string{
+		"bridge_uuid": uuid,
+		"secret":      secret,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+supabaseAuthPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("apikey", key)
+
+	issuedAt := time.Now()
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, ErrInvalidCredentials
+	}
+
+	if res.StatusCode == http.StatusForbidden {
+		return nil, ErrCredentialsRevoked
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	var data struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	if data.AccessToken == "" || data.ExpiresIn <= 0 {
+		return nil, fmt.Errorf("invalid response: missing access token")
+	}
+
+	// lifetime is used instead of expiration, in order to be robust to clock drifts
+	return &accessToken{
+		Token:     data.AccessToken,
+		IssuedAt:  issuedAt,
+		ExpiresAt: issuedAt.Add(time.Duration(data.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,201 @@
+package controlplane
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSecret(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-controlplane")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "cache", "bridge.secret")
+
+	secret, err := loadSecret(fpath)
+	require.NoError(t, err)
+	require.Len(t, secret, 64)
+
+	fi, err := os.Stat(fpath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	secret2, err := loadSecret(fpath)
+	require.NoError(t, err)
+	require.Equal(t, secret, secret2)
+}
+
+type testAuthServer struct {
+	mutex         sync.Mutex
+	secret        string
+	revokedSecret string
+	tokens        int
+	lifetime      int
+	auths         []string
+}
+
+func (ts *testAuthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/functions/v1/bridge-auth":
+		var req struct {
+			BridgeUUID string `json:"bridge_uuid"`
+			Secret     string `json:"secret"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || r.Header.Get("apikey") != "anon" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// revoked secrets can't be enrolled again
+		if req.Secret == ts.revokedSecret {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// enroll the first secret
+		if ts.secret == "" {
+			ts.secret = req.Secret
+		}
+
+		if req.Secret != ts.secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		ts.tokens++
+		json.NewEncoder(w).Encode(map[string]interface{}{ //nolint:errcheck
+			"access_token": fmt.Sprintf("token%d", ts.tokens),
+			"expires_in":   ts.lifetime,
+			"bridge_id":    1,
+		})
+
+	case "/rest/v1/bridge":
+		ts.auths = append(ts.auths, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id": 1, "bridge_name": "Bridge-0b3f9a2c", "site_id": 2}]`)) //nolint:errcheck
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (ts *testAuthServer) tokenCount() int {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	return ts.tokens
+}
+
+func TestSupabaseCredentials(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-controlplane")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := &testAuthServer{lifetime: 1}
+	srv := httptest.NewServer(ts)
+	defer srv.Close()
+
+	s := &Supabase{
+		URL:        srv.URL,
+		Key:        "anon",
+		BridgeUUID: "0b3f9a2c-0000-0000-0000-000000000000",
+		SecretFile: filepath.Join(dir, "bridge.secret"),
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	bridge, err := s.RegisterBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), bridge.Id)
+	require.Equal(t, int64(2), *bridge.SiteId)
+
+	// tokens are refreshed before they expire
+	require.Eventually(t, func() bool {
+		return ts.tokenCount() >= 3
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = s.FetchBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+
+	ts.mutex.Lock()
+	require.Equal(t, "Bearer token1", ts.auths[0])
+	require.NotEqual(t, "Bearer token1", ts.auths[len(ts.auths)-1])
+	require.NotEqual(t, "Bearer anon", ts.auths[len(ts.auths)-1])
+	ts.mutex.Unlock()
+
+	// another bridge with the same UUID is rejected
+	s2 := &Supabase{
+		URL:        srv.URL,
+		Key:        "anon",
+		BridgeUUID: "0b3f9a2c-0000-0000-0000-000000000000",
+		SecretFile: filepath.Join(dir, "bridge2.secret"),
+	}
+	err = s2.Initialize()
+	require.NoError(t, err)
+	defer s2.Close()
+
+	_, err = s2.RegisterBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestSupabaseCredentialsRevoked(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-controlplane")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := &testAuthServer{lifetime: 60}
+	srv := httptest.NewServer(ts)
+	defer srv.Close()
+
+	s := &Supabase{
+		URL:        srv.URL,
+		Key:        "anon",
+		BridgeUUID: "0b3f9a2c-0000-0000-0000-000000000000",
+		SecretFile: filepath.Join(dir, "bridge.secret"),
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.RegisterBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+
+	revoked, err := loadSecret(s.SecretFile)
+	require.NoError(t, err)
+
+	ts.mutex.Lock()
+	ts.revokedSecret = ts.secret
+	ts.secret = ""
+	ts.mutex.Unlock()
+
+	_, err = requestToken(context.Background(), http.DefaultClient, srv.URL, "anon",
+		"0b3f9a2c-0000-0000-0000-000000000000", revoked)
+	require.ErrorIs(t, err, ErrCredentialsRevoked)
+
+	// the revoked secret is replaced, and the new one is enrolled
+	require.Eventually(t, func() bool {
+		return s.refresh(true) == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	secret, err := loadSecret(s.SecretFile)
+	require.NoError(t, err)
+	require.NotEqual(t, revoked, secret)
+
+	ts.mutex.Lock()
+	require.Equal(t, secret, ts.secret)
+	ts.mutex.Unlock()
+}
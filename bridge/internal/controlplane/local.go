@@ -0,0 +1,862 @@
+package controlplane
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/confwatcher"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+)
+
+type localRecord = map[string]interface{}
+
+func recordInt(rec localRecord, key string) (int64, bool) {
+	switch v := rec[key].(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// belongsTo checks whether a record belongs to a bridge.
+// Records without a bridge belong to every bridge, in order to ease editing by hand.
+func belongsTo(rec localRecord, bridgeID int64) bool {
+	id, ok := recordInt(rec, "bridge_id")
+	return !ok || id == bridgeID
+}
+
+func hasID(id int64) func(localRecord) bool {
+	return func(rec localRecord) bool {
+		v, ok := recordInt(rec, "id")
+		return ok && v == id
+	}
+}
+
+func decodeRecords(recs []localRecord, dest interface{}) error {
+	buf, err := json.Marshal(recs)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, dest)
+}
+
+func writeFileAtomic(fpath string, r io.Reader) error {
+	tmp := fpath + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, fpath)
+}
+
+// localTable is a table stored into a JSON file, as an array of records.
+// The file is read again when it is changed by someone else.
+type localTable struct {
+	path    string
+	records []localRecord
+	modTime time.Time
+	size    int64
+}
+
+func (t *localTable) load() error {
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			t.records = nil
+			t.modTime = time.Time{}
+			t.size = 0
+			return nil
+		}
+		return err
+	}
+
+	if fi.ModTime().Equal(t.modTime) && fi.Size() == t.size {
+		return nil
+	}
+
+	buf, err := os.ReadFile(t.path)
+	if err != nil {
+		return err
+	}
+
+	var records []localRecord
+	err = json.Unmarshal(buf, &records)
+	if err != nil {
+		return fmt.Errorf("invalid table %s: %w", filepath.Base(t.path), err)
+	}
+
+	t.records = records
+	t.modTime = fi.ModTime()
+	t.size = fi.Size()
+	return nil
+}
+
+func (t *localTable) save() error {
+	if t.records == nil {
+		t.records = []localRecord{}
+	}
+
+	buf, err := json.MarshalIndent(t.records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(t.path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		return err
+	}
+	t.modTime = fi.ModTime()
+	t.size = fi.Size()
+
+	return nil
+}
+
+func (t *localTable) find(match func(localRecord) bool) []localRecord {
+	var ret []localRecord
+	for _, rec := range t.records {
+		if match(rec) {
+			ret = append(ret, rec)
+		}
+	}
+	return ret
+}
+
+// insert adds a record and returns its ID.
+func (t *localTable) insert(rec localRecord) int64 {
+	id, ok := recordInt(rec, "id")
+	if !ok {
+		for _, other := range t.records {
+			if v, ok := recordInt(other, "id"); ok && v > id {
+				id = v
+			}
+		}
+		id++
+		rec["id"] = id
+	}
+
+	if _, ok := rec["created_at"]; !ok {
+		rec["created_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	t.records = append(t.records, rec)
+	return id
+}
+
+// update sets fields of matching records and returns how many records have been updated.
+func (t *localTable) update(match func(localRecord) bool, fields map[string]interface{}) int {
+	n := 0
+	for _, rec := range t.records {
+		if match(rec) {
+			for k, v := range fields {
+				rec[k] = v
+			}
+			n++
+		}
+	}
+	return n
+}
+
+// remove deletes matching records and returns how many records have been deleted.
+func (t *localTable) remove(match func(localRecord) bool) int {
+	n := 0
+	kept := t.records[:0]
+	for _, rec := range t.records {
+		if match(rec) {
+			n++
+		} else {
+			kept = append(kept, rec)
+		}
+	}
+	t.records = kept
+	return n
+}
+
+type localWatch struct {
+	watcher *confwatcher.ConfWatcher
+	done    chan struct{}
+}
+
+func (w *localWatch) Close() {
+	w.watcher.Close()
+	<-w.done
+}
+
+type localListener struct {
+	l         *Local
+	bridgeID  int64
+	onCommand func(*Command)
+}
+
+func (s *localListener) Close() {
+	s.l.mutex.Lock()
+	defer s.l.mutex.Unlock()
+	delete(s.l.listeners, s)
+}
+
+type localPresence struct {
+	l        *Local
+	bridgeID int64
+	meta     interface{}
+}
+
+func (p *localPresence) Track(meta interface{}) error {
+	p.l.mutex.Lock()
+	defer p.l.mutex.Unlock()
+	p.meta = meta
+	return nil
+}
+
+func (p *localPresence) Close() {
+	p.l.mutex.Lock()
+	defer p.l.mutex.Unlock()
+	delete(p.l.presences, p)
+}
+
+// Local is a control plane backed by files, that allows to run the bridge without a cloud.
+// Each table is stored into a JSON file, that can be edited by hand.
+// Recordings of alarms are stored into the media subdirectory.
+type Local struct {
+	Directory string
+	// site that the bridge is paired with when it is registered.
+	// If zero, the bridge is left unpaired.
+	SiteID int64
+
+	mutex     sync.Mutex
+	tables    map[string]*localTable
+	listeners map[*localListener]struct{}
+	presences map[*localPresence]struct{}
+}
+
+// Initialize initializes Local.
+func (l *Local) Initialize() error {
+	err := os.MkdirAll(filepath.Join(l.Directory, "media"), 0o755)
+	if err != nil {
+		return err
+	}
+
+	l.tables = make(map[string]*localTable)
+	l.listeners = make(map[*localListener]struct{})
+	l.presences = make(map[*localPresence]struct{})
+
+	// the camera table is watched, therefore it must exist
+	fpath := filepath.Join(l.Directory, "camera.json")
+	if _, err = os.Stat(fpath); errors.Is(err, os.ErrNotExist) {
+		return os.WriteFile(fpath, []byte("[]\n"), 0o644)
+	}
+
+	return nil
+}
+
+// Close implements ControlPlane.
+func (l *Local) Close() {}
+
+func (l *Local) table(name string) (*localTable, error) {
+	t, ok := l.tables[name]
+	if !ok {
+		t = &localTable{path: filepath.Join(l.Directory, name+".json")}
+		l.tables[name] = t
+	}
+
+	err := t.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (l *Local) read(name string, cb func(t *localTable) error) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	t, err := l.table(name)
+	if err != nil {
+		return err
+	}
+
+	return cb(t)
+}
+
+func (l *Local) write(name string, cb func(t *localTable) error) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	t, err := l.table(name)
+	if err != nil {
+		return err
+	}
+
+	err = cb(t)
+	if err != nil {
+		t.modTime = time.Time{} // discard changes
+		return err
+	}
+
+	return t.save()
+}
+
+func (l *Local) insert(name string, record interface{}) (int64, error) {
+	rec, err := recordFields(record)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = l.write(name, func(t *localTable) error {
+		id = t.insert(rec)
+		return nil
+	})
+	return id, err
+}
+
+func (l *Local) update(name string, id int64, fields map[string]interface{}) error {
+	// values are stored as they would be encoded into JSON
+	rec, err := recordFields(fields)
+	if err != nil {
+		return err
+	}
+	for k := range fields {
+		if _, ok := rec[k]; !ok {
+			rec[k] = nil
+		}
+	}
+
+	return l.write(name, func(t *localTable) error {
+		if t.update(hasID(id), rec) == 0 {
+			return fmt.Errorf("%s %d not found", name, id)
+		}
+		return nil
+	})
+}
+
+// RegisterBridge implements BridgeIdentity.
+func (l *Local) RegisterBridge(uuid string) (*defs.PublicBridgeSelect, error) {
+	err := l.write("bridge", func(t *localTable) error {
+		if len(t.find(func(rec localRecord) bool { return rec["bridge_uuid"] == uuid })) != 0 {
+			return nil
+		}
+
+		rec := localRecord{
+			"bridge_uuid": uuid,
+			"bridge_name": "Bridge-" + uuid[:8],
+			"healthy":     true,
+		}
+		if l.SiteID != 0 {
+			rec["site_id"] = l.SiteID
+		}
+		t.insert(rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return l.FetchBridge(uuid)
+}
+
+// FetchBridge implements BridgeIdentity.
+func (l *Local) FetchBridge(uuid string) (*defs.PublicBridgeSelect, error) {
+	var bridges []defs.PublicBridgeSelect
+
+	err := l.read("bridge", func(t *localTable) error {
+		return decodeRecords(t.find(func(rec localRecord) bool { return rec["bridge_uuid"] == uuid }), &bridges)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bridges) == 0 {
+		return nil, nil
+	}
+
+	return &bridges[0], nil
+}
+
+// PublishPairingToken implements BridgeIdentity.
+func (l *Local) PublishPairingToken(bridgeID int64, token string, expiresAt time.Time) (bool, error) {
+	updated := false
+
+	err := l.write("bridge", func(t *localTable) error {
+		updated = t.update(func(rec localRecord) bool {
+			return hasID(bridgeID)(rec) && rec["site_id"] == nil
+		}, map[string]interface{}{
+			"access_token":            token,
+			"access_token_expires_at": expiresAt.UTC().Format(time.RFC3339),
+		}) != 0
+		return nil
+	})
+
+	return updated, err
+}
+
+// UnpairBridge implements BridgeIdentity.
+func (l *Local) UnpairBridge(bridgeID int64) error {
+	return l.update("bridge", bridgeID, map[string]interface{}{
+		"site_id":                 nil,
+		"access_token":            nil,
+		"access_token_expires_at": nil,
+	})
+}
+
+// UpdateBridgeStatus implements BridgeIdentity.
+func (l *Local) UpdateBridgeStatus(
+	bridgeID int64, checkedAt time.Time, version string, status interface{},
+) error {
+	return l.update("bridge", bridgeID, map[string]interface{}{
+		"healthy":         true,
+		"last_checked_at": checkedAt.UTC().Format(time.RFC3339),
+		"version":         version,
+		"status":          status,
+	})
+}
+
+// ListCameras implements CameraRegistry.
+func (l *Local) ListCameras(bridgeID int64) ([]defs.PublicCameraSelect, error) {
+	var cameras []defs.PublicCameraSelect
+
+	err := l.read("camera", func(t *localTable) error {
+		return decodeRecords(t.find(func(rec localRecord) bool { return belongsTo(rec, bridgeID) }), &cameras)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cameras, nil
+}
+
+// WatchCameras implements CameraRegistry.
+// Changes made by hand to the camera table are detected too.
+func (l *Local) WatchCameras(_ int64, onChange func()) (Subscription, error) {
+	return l.watch("camera", onChange)
+}
+
+// watch calls onChange when the file of a table is changed.
+func (l *Local) watch(name string, onChange func()) (Subscription, error) {
+	fpath := filepath.Join(l.Directory, name+".json")
+
+	// the file must exist in order to be watched
+	if _, err := os.Stat(fpath); errors.Is(err, os.ErrNotExist) {
+		err = os.WriteFile(fpath, []byte("[]\n"), 0o644)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w := &localWatch{
+		watcher: &confwatcher.ConfWatcher{FilePath: fpath},
+		done:    make(chan struct{}),
+	}
+
+	err := w.watcher.Initialize()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(w.done)
+		for range w.watcher.Watch() {
+			onChange()
+		}
+	}()
+
+	return w, nil
+}
+
+// UpdateCameraHealth implements CameraRegistry.
+func (l *Local) UpdateCameraHealth(
+	bridgeID int64, cameraIDs []int64, healthy bool, checkedAt time.Time,
+) error {
+	ids := make(map[int64]struct{}, len(cameraIDs))
+	for _, id := range cameraIDs {
+		ids[id] = struct{}{}
+	}
+
+	return l.write("camera", func(t *localTable) error {
+		t.update(func(rec localRecord) bool {
+			id, _ := recordInt(rec, "id")
+			_, ok := ids[id]
+			return ok && belongsTo(rec, bridgeID)
+		}, map[string]interface{}{
+			"healthy":         healthy,
+			"last_checked_at": checkedAt.UTC().Format(time.RFC3339),
+		})
+		return nil
+	})
+}
+
+// SetCameraError implements CameraRegistry.
+func (l *Local) SetCameraError(bridgeID int64, cameraID int64, message *string) error {
+	return l.write("camera", func(t *localTable) error {
+		if t.update(func(rec localRecord) bool {
+			return hasID(cameraID)(rec) && belongsTo(rec, bridgeID)
+		}, map[string]interface{}{
+			"config_error": message,
+		}) == 0 {
+			return fmt.Errorf("camera %d not found", cameraID)
+		}
+		return nil
+	})
+}
+
+// FetchBridgeConfig implements ConfigStore.
+func (l *Local) FetchBridgeConfig(bridgeID int64) (*defs.PublicBridgeConfigSelect, error) {
+	var configs []defs.PublicBridgeConfigSelect
+
+	err := l.read("bridge_config", func(t *localTable) error {
+		return decodeRecords(t.find(func(rec localRecord) bool { return belongsTo(rec, bridgeID) }), &configs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	return &configs[0], nil
+}
+
+// WatchBridgeConfig implements ConfigStore.
+func (l *Local) WatchBridgeConfig(_ int64, onChange func()) (Subscription, error) {
+	return l.watch("bridge_config", onChange)
+}
+
+// ReportBridgeConfigStatus implements ConfigStore.
+func (l *Local) ReportBridgeConfigStatus(
+	bridgeID int64, status string, message *string, appliedAt *time.Time,
+) error {
+	fields, err := recordFields(map[string]interface{}{
+		"status":     status,
+		"error":      message,
+		"applied_at": formatTime(appliedAt),
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range []string{"error", "applied_at"} {
+		if _, ok := fields[k]; !ok {
+			fields[k] = nil
+		}
+	}
+
+	return l.write("bridge_config", func(t *localTable) error {
+		t.update(func(rec localRecord) bool { return belongsTo(rec, bridgeID) }, fields)
+		return nil
+	})
+}
+
+// ListPanels implements CameraRegistry.
+func (l *Local) ListPanels(bridgeID int64) ([]*Panel, error) {
+	var panels []defs.PublicPanelSelect
+	err := l.read("panel", func(t *localTable) error {
+		return decodeRecords(t.find(func(rec localRecord) bool { return belongsTo(rec, bridgeID) }), &panels)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var zones []defs.PublicPanelZoneSelect
+	err = l.read("panel_zone", func(t *localTable) error {
+		return decodeRecords(t.records, &zones)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cameras, err := l.ListCameras(bridgeID)
+	if err != nil {
+		return nil, err
+	}
+
+	cameraIPs := make(map[int64]string, len(cameras))
+	for _, c := range cameras {
+		cameraIPs[c.Id] = c.IpAddress
+	}
+
+	ret := make([]*Panel, len(panels))
+	for i, p := range panels {
+		panel := &Panel{
+			ID:      p.Id,
+			SiteID:  p.SiteId,
+			Account: p.Account,
+			Zones:   []PanelZone{},
+		}
+		for _, z := range zones {
+			if z.PanelId == p.Id {
+				panel.Zones = append(panel.Zones, PanelZone{
+					Zone:     int(z.Zone),
+					CameraID: z.CameraId,
+					CameraIP: cameraIPs[z.CameraId],
+				})
+			}
+		}
+		ret[i] = panel
+	}
+
+	return ret, nil
+}
+
+// InsertAlarm implements AlarmSink.
+func (l *Local) InsertAlarm(alarm *defs.PublicAlarmInsert) (int64, error) {
+	return l.insert("alarm", alarm)
+}
+
+// SetAlarmVideo implements AlarmSink.
+func (l *Local) SetAlarmVideo(alarmID int64, videoURL string) error {
+	return l.update("alarm", alarmID, map[string]interface{}{
+		"video_url": videoURL,
+	})
+}
+
+// SetAlarmIncident implements AlarmSink.
+func (l *Local) SetAlarmIncident(alarmID int64, incidentID int64) error {
+	return l.update("alarm", alarmID, map[string]interface{}{
+		"incident_id": incidentID,
+	})
+}
+
+// InsertIncident implements AlarmSink.
+func (l *Local) InsertIncident(incident *defs.PublicIncidentInsert) (int64, error) {
+	return l.insert("incident", incident)
+}
+
+// UpdateIncident implements AlarmSink.
+func (l *Local) UpdateIncident(incidentID int64, fields map[string]interface{}) error {
+	return l.update("incident", incidentID, fields)
+}
+
+// SetArmStatus implements AlarmSink.
+// The site record is created if it doesn't exist.
+func (l *Local) SetArmStatus(siteID int64, status string, changedAt time.Time) error {
+	fields := map[string]interface{}{
+		"arm_status":            status,
+		"arm_status_changed_at": changedAt.UTC().Format(time.RFC3339Nano),
+		"updated_at":            changedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	return l.write("site", func(t *localTable) error {
+		if t.update(hasID(siteID), fields) == 0 {
+			fields["id"] = siteID
+			t.insert(fields)
+		}
+		return nil
+	})
+}
+
+// InsertWalkTest implements AlarmSink.
+func (l *Local) InsertWalkTest(walkTest *defs.PublicWalkTestInsert) (int64, error) {
+	return l.insert("walk_test", walkTest)
+}
+
+// UpdateWalkTest implements AlarmSink.
+func (l *Local) UpdateWalkTest(walkTestID int64, fields map[string]interface{}) error {
+	return l.update("walk_test", walkTestID, fields)
+}
+
+type ctxReader struct {
+	ctx        context.Context
+	r          io.Reader
+	n          int64
+	onProgress func(int64)
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	err := r.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if n != 0 && r.onProgress != nil {
+		r.onProgress(r.n)
+	}
+	return n, err
+}
+
+// UploadMedia implements MediaStorage.
+// Files are stored into the media subdirectory and their URL is a file URL.
+func (l *Local) UploadMedia(
+	ctx context.Context, name string, _ string, r io.Reader, onProgress func(uploaded int64),
+) (string, error) {
+	if name == "" || name != filepath.Base(name) {
+		return "", fmt.Errorf("invalid name '%s'", name)
+	}
+
+	fpath, err := filepath.Abs(filepath.Join(l.Directory, "media", name))
+	if err != nil {
+		return "", err
+	}
+
+	err = writeFileAtomic(fpath, &ctxReader{ctx: ctx, r: r, onProgress: onProgress})
+	if err != nil {
+		return "", err
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(fpath)}).String(), nil
+}
+
+// ListenCommands implements CommandChannel.
+// Commands are sent with SendCommand.
+func (l *Local) ListenCommands(bridgeID int64, onCommand func(*Command)) (Subscription, error) {
+	s := &localListener{
+		l:         l,
+		bridgeID:  bridgeID,
+		onCommand: onCommand,
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.listeners[s] = struct{}{}
+
+	return s, nil
+}
+
+// RespondCommand implements CommandChannel.
+// Responses are stored into the response table.
+func (l *Local) RespondCommand(bridgeID int64, res *CommandResponse) error {
+	_, err := l.insert("response", defs.PublicResponseInsert{
+		BridgeId:     bridgeID,
+		RequestId:    res.RequestID,
+		RequesterId:  res.RequesterID,
+		RequestPath:  res.Path,
+		ResponseBody: res.Body,
+	})
+	return err
+}
+
+// RequesterRole implements CommandChannel.
+// Requests are matched with the bridge_request table, from which they are removed,
+// and their senders with the site_member table.
+func (l *Local) RequesterRole(
+	bridgeID int64,
+	requestID string,
+	method string,
+	path string,
+	bodyHash string,
+) (string, error) {
+	var requests []defs.PublicBridgeRequestSelect
+
+	err := l.write("bridge_request", func(t *localTable) error {
+		match := func(rec localRecord) bool {
+			return rec["request_id"] == requestID &&
+				rec["request_method"] == method &&
+				rec["request_path"] == path &&
+				rec["request_body_hash"] == bodyHash &&
+				belongsTo(rec, bridgeID)
+		}
+
+		err := decodeRecords(t.find(match), &requests)
+		if err != nil {
+			return err
+		}
+
+		t.remove(match)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(requests) == 0 {
+		return "", nil
+	}
+
+	var members []defs.PublicSiteMemberSelect
+
+	err = l.read("site_member", func(t *localTable) error {
+		return decodeRecords(t.find(func(rec localRecord) bool {
+			id, ok := recordInt(rec, "site_id")
+			return ok && id == l.SiteID && rec["user_id"] == requests[0].UserId
+		}), &members)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(members) == 0 {
+		return "", nil
+	}
+
+	return members[0].Role, nil
+}
+
+// SendCommand sends a command to a bridge.
+func (l *Local) SendCommand(bridgeID int64, cmd *Command) {
+	l.mutex.Lock()
+	var listeners []*localListener
+	for s := range l.listeners {
+		if s.bridgeID == bridgeID {
+			listeners = append(listeners, s)
+		}
+	}
+	l.mutex.Unlock()
+
+	for _, s := range listeners {
+		s.onCommand(cmd)
+	}
+}
+
+// JoinBridgePresence implements BridgePresence.
+// Presence is kept in memory, and is read with BridgePresence.
+func (l *Local) JoinBridgePresence(bridgeID int64, meta interface{}) (Presence, error) {
+	p := &localPresence{
+		l:        l,
+		bridgeID: bridgeID,
+		meta:     meta,
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.presences[p] = struct{}{}
+
+	return p, nil
+}
+
+// BridgePresence returns the metadata of a bridge, and whether the bridge is online.
+func (l *Local) BridgePresence(bridgeID int64) (interface{}, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for p := range l.presences {
+		if p.bridgeID == bridgeID {
+			return p.meta, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,457 @@
+package controlplane
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+)
+
+func newLocal(t *testing.T, siteID int64) *Local {
+	dir, err := os.MkdirTemp("", "mediamtx-controlplane")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	l := &Local{Directory: dir, SiteID: siteID}
+	err = l.Initialize()
+	require.NoError(t, err)
+
+	return l
+}
+
+func TestLocalIdentity(t *testing.T) {
+	l := newLocal(t, 0)
+
+	bridge, err := l.FetchBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Nil(t, bridge)
+
+	bridge, err = l.RegisterBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), bridge.Id)
+	require.Equal(t, "Bridge-0b3f9a2c", bridge.BridgeName)
+	require.Nil(t, bridge.SiteId)
+
+	// registering again returns the same record
+	bridge, err = l.RegisterBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), bridge.Id)
+
+	expiresAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ok, err := l.PublishPairingToken(1, "ABCDE-FGHJK", expiresAt)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	bridge, err = l.FetchBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Equal(t, "ABCDE-FGHJK", *bridge.AccessToken)
+	require.Equal(t, "2025-01-01T12:00:00Z", *bridge.AccessTokenExpiresAt)
+
+	// tokens of paired bridges are not overridden
+	l2 := newLocal(t, 3)
+	bridge, err = l2.RegisterBridge("7d1e2f3a-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), *bridge.SiteId)
+
+	ok, err = l2.PublishPairingToken(bridge.Id, "ABCDE-FGHJK", expiresAt)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	err = l2.UnpairBridge(bridge.Id)
+	require.NoError(t, err)
+
+	bridge, err = l2.FetchBridge("7d1e2f3a-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Nil(t, bridge.SiteId)
+
+	err = l2.UpdateBridgeStatus(bridge.Id, expiresAt, "v1.0.0", map[string]interface{}{"uptime": 10})
+	require.NoError(t, err)
+
+	bridge, err = l2.FetchBridge("7d1e2f3a-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Equal(t, "v1.0.0", *bridge.Version)
+	require.Equal(t, map[string]interface{}{"uptime": float64(10)}, bridge.Status)
+
+	err = l2.UnpairBridge(10)
+	require.EqualError(t, err, "bridge 10 not found")
+}
+
+func TestLocalCameras(t *testing.T) {
+	l := newLocal(t, 1)
+
+	cameras, err := l.ListCameras(1)
+	require.NoError(t, err)
+	require.Empty(t, cameras)
+
+	changed := make(chan struct{}, 10)
+	sub, err := l.WatchCameras(1, func() {
+		changed <- struct{}{}
+	})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// cameras are edited by hand
+	err = os.WriteFile(filepath.Join(l.Directory, "camera.json"), []byte(`[
+		{"id": 1, "camera_name": "Entrance", "ip_address": "192.168.0.10",
+			"source": "rtsp://192.168.0.10/stream", "is_registered": true},
+		{"id": 2, "bridge_id": 1, "camera_name": "Garage", "ip_address": "192.168.0.11",
+			"source": "rtsp://192.168.0.11/stream", "is_registered": true},
+		{"id": 3, "bridge_id": 2, "camera_name": "Other", "ip_address": "192.168.0.12",
+			"source": "rtsp://192.168.0.12/stream", "is_registered": true}
+	]`), 0o644)
+	require.NoError(t, err)
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not notified")
+	}
+
+	cameras, err = l.ListCameras(1)
+	require.NoError(t, err)
+	require.Len(t, cameras, 2)
+	require.Equal(t, "Entrance", cameras[0].CameraName)
+	require.Equal(t, "192.168.0.11", cameras[1].IpAddress)
+
+	checkedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	err = l.UpdateCameraHealth(1, []int64{2, 3}, true, checkedAt)
+	require.NoError(t, err)
+
+	cameras, err = l.ListCameras(1)
+	require.NoError(t, err)
+	require.False(t, cameras[0].Healthy)
+	require.True(t, cameras[1].Healthy)
+	require.Equal(t, "2025-01-01T12:00:00Z", cameras[1].LastCheckedAt)
+
+	cameras, err = l.ListCameras(2)
+	require.NoError(t, err)
+	require.False(t, cameras[0].Healthy)
+
+	msg := "invalid setting 'runOnReady': not allowed"
+	err = l.SetCameraError(1, 2, &msg)
+	require.NoError(t, err)
+
+	cameras, err = l.ListCameras(1)
+	require.NoError(t, err)
+	require.Equal(t, &msg, cameras[1].ConfigError)
+
+	err = l.SetCameraError(1, 2, nil)
+	require.NoError(t, err)
+
+	cameras, err = l.ListCameras(1)
+	require.NoError(t, err)
+	require.Nil(t, cameras[1].ConfigError)
+
+	err = l.SetCameraError(1, 3, &msg)
+	require.EqualError(t, err, "camera 3 not found")
+
+	err = os.WriteFile(filepath.Join(l.Directory, "panel.json"), []byte(`[
+		{"id": 4, "site_id": 1, "account": "1234"}
+	]`), 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(l.Directory, "panel_zone.json"), []byte(`[
+		{"id": 1, "panel_id": 4, "zone": 2, "camera_id": 2},
+		{"id": 2, "panel_id": 5, "zone": 3, "camera_id": 1}
+	]`), 0o644)
+	require.NoError(t, err)
+
+	panels, err := l.ListPanels(1)
+	require.NoError(t, err)
+	require.Equal(t, []*Panel{{
+		ID:      4,
+		SiteID:  1,
+		Account: "1234",
+		Zones: []PanelZone{{
+			Zone:     2,
+			CameraID: 2,
+			CameraIP: "192.168.0.11",
+		}},
+	}}, panels)
+
+	err = os.WriteFile(filepath.Join(l.Directory, "camera.json"), []byte(`{`), 0o644)
+	require.NoError(t, err)
+
+	_, err = l.ListCameras(1)
+	require.Error(t, err)
+}
+
+func TestLocalAlarms(t *testing.T) {
+	l := newLocal(t, 1)
+
+	createdAt := "2025-01-01T12:00:00Z"
+	incidentID, err := l.InsertIncident(&defs.PublicIncidentInsert{
+		SiteId:      1,
+		BridgeId:    1,
+		CameraIds:   []int64{},
+		StartedAt:   &createdAt,
+		LastAlarmAt: &createdAt,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), incidentID)
+
+	for i := int64(1); i <= 2; i++ {
+		alarm := &defs.PublicAlarmInsert{
+			SiteId:    1,
+			BridgeId:  1,
+			CameraId:  2,
+			AlarmName: "Motion Detection",
+			AlarmType: "motion",
+			CreatedAt: &createdAt,
+		}
+		if i == 2 {
+			alarm.IncidentId = &incidentID
+		}
+
+		var alarmID int64
+		alarmID, err = l.InsertAlarm(alarm)
+		require.NoError(t, err)
+		require.Equal(t, i, alarmID)
+	}
+
+	err = l.SetAlarmIncident(1, incidentID)
+	require.NoError(t, err)
+
+	err = l.SetAlarmVideo(2, "file:///video.zip")
+	require.NoError(t, err)
+
+	err = l.UpdateIncident(incidentID, map[string]interface{}{
+		"camera_ids":  []int64{2},
+		"alarm_count": 2,
+		"closed_at":   time.Date(2025, 1, 1, 12, 5, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	err = l.SetArmStatus(1, "arm", time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	walkTestID, err := l.InsertWalkTest(&defs.PublicWalkTestInsert{
+		SiteId:    1,
+		BridgeId:  1,
+		CameraIds: []int64{2},
+		ExpiresAt: createdAt,
+	})
+	require.NoError(t, err)
+
+	err = l.UpdateWalkTest(walkTestID, map[string]interface{}{
+		"status": "finished",
+	})
+	require.NoError(t, err)
+
+	// a new instance reads tables from disk
+	l2 := &Local{Directory: l.Directory}
+	err = l2.Initialize()
+	require.NoError(t, err)
+
+	var alarms []defs.PublicAlarmSelect
+	err = l2.read("alarm", func(t *localTable) error {
+		return decodeRecords(t.records, &alarms)
+	})
+	require.NoError(t, err)
+	require.Len(t, alarms, 2)
+	require.Nil(t, alarms[0].VideoUrl)
+	require.Equal(t, incidentID, *alarms[0].IncidentId)
+	require.Equal(t, "file:///video.zip", *alarms[1].VideoUrl)
+	require.Equal(t, incidentID, *alarms[1].IncidentId)
+
+	var incidents []defs.PublicIncidentSelect
+	err = l2.read("incident", func(t *localTable) error {
+		return decodeRecords(t.records, &incidents)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int64{2}, incidents[0].CameraIds)
+	require.Equal(t, "2025-01-01T12:05:00Z", *incidents[0].ClosedAt)
+
+	var sites []defs.PublicSiteSelect
+	err = l2.read("site", func(t *localTable) error {
+		return decodeRecords(t.records, &sites)
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), sites[0].Id)
+	require.Equal(t, "arm", sites[0].ArmStatus)
+
+	var walkTests []defs.PublicWalkTestSelect
+	err = l2.read("walk_test", func(t *localTable) error {
+		return decodeRecords(t.records, &walkTests)
+	})
+	require.NoError(t, err)
+	require.Equal(t, "finished", walkTests[0].Status)
+}
+
+func TestLocalMedia(t *testing.T) {
+	l := newLocal(t, 1)
+
+	var uploaded int64
+	u, err := l.UploadMedia(context.Background(), "recordings.zip", "application/zip",
+		bytes.NewReader([]byte("content")), func(n int64) {
+			uploaded = n
+		})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), uploaded)
+
+	pu, err := url.Parse(u)
+	require.NoError(t, err)
+	require.Equal(t, "file", pu.Scheme)
+
+	buf, err := os.ReadFile(filepath.Join(l.Directory, "media", "recordings.zip"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("content"), buf)
+
+	_, err = l.UploadMedia(context.Background(), "../recordings.zip", "application/zip",
+		bytes.NewReader(nil), nil)
+	require.Error(t, err)
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	ctxCancel()
+	_, err = l.UploadMedia(ctx, "canceled.zip", "application/zip", bytes.NewReader([]byte("content")), nil)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = os.Stat(filepath.Join(l.Directory, "media", "canceled.zip"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLocalCommands(t *testing.T) {
+	l := newLocal(t, 1)
+
+	var received []*Command
+	sub, err := l.ListenCommands(1, func(cmd *Command) {
+		received = append(received, cmd)
+	})
+	require.NoError(t, err)
+
+	l.SendCommand(2, &Command{Event: "/v3/walktest/stop"})
+	l.SendCommand(1, &Command{Event: "/v3/walktest/start", Payload: map[string]interface{}{"timeout": "1m"}})
+
+	sub.Close()
+	l.SendCommand(1, &Command{Event: "/v3/walktest/stop"})
+
+	require.Equal(t, []*Command{{
+		Event:   "/v3/walktest/start",
+		Payload: map[string]interface{}{"timeout": "1m"},
+	}}, received)
+
+	err = l.RespondCommand(1, &CommandResponse{
+		RequestID:   "req1",
+		RequesterID: "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		Path:        "/v3/walktest/start",
+		Body:        map[string]interface{}{"success": true},
+	})
+	require.NoError(t, err)
+
+	buf, err := os.ReadFile(filepath.Join(l.Directory, "response.json"))
+	require.NoError(t, err)
+
+	var responses []defs.PublicResponseSelect
+	err = json.Unmarshal(buf, &responses)
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	require.Equal(t, int64(1), responses[0].BridgeId)
+	require.Equal(t, "req1", responses[0].RequestId)
+	require.Equal(t, "/v3/walktest/start", responses[0].RequestPath)
+	require.Equal(t, map[string]interface{}{"success": true}, responses[0].ResponseBody)
+}
+
+func TestLocalRequesterRole(t *testing.T) {
+	l := newLocal(t, 1)
+
+	err := os.WriteFile(filepath.Join(l.Directory, "bridge_request.json"), []byte(`[
+		{"request_id": "req1", "bridge_id": 1, "user_id": "u1", "request_method": "GET",
+			"request_path": "/v3/paths/list", "request_body_hash": "h1"},
+		{"request_id": "req2", "bridge_id": 1, "user_id": "u2", "request_method": "GET",
+			"request_path": "/v3/paths/list", "request_body_hash": "h1"}
+	]`), 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(l.Directory, "site_member.json"), []byte(`[
+		{"site_id": 1, "user_id": "u1", "role": "operator"},
+		{"site_id": 2, "user_id": "u2", "role": "admin"}
+	]`), 0o644)
+	require.NoError(t, err)
+
+	// requests are bound to their bridge, method, path and body
+	for _, ca := range []struct {
+		bridgeID int64
+		id       string
+		method   string
+		path     string
+		bodyHash string
+	}{
+		{2, "req1", "GET", "/v3/paths/list", "h1"},
+		{1, "req1", "POST", "/v3/paths/list", "h1"},
+		{1, "req1", "GET", "/v3/config/global/get", "h1"},
+		{1, "req1", "GET", "/v3/paths/list", "h2"},
+		{1, "req3", "GET", "/v3/paths/list", "h1"},
+		{1, "req2", "GET", "/v3/paths/list", "h1"}, // member of another site
+	} {
+		role, err := l.RequesterRole(ca.bridgeID, ca.id, ca.method, ca.path, ca.bodyHash)
+		require.NoError(t, err)
+		require.Equal(t, "", role)
+	}
+
+	role, err := l.RequesterRole(1, "req1", "GET", "/v3/paths/list", "h1")
+	require.NoError(t, err)
+	require.Equal(t, RoleOperator, role)
+
+	// requests can't be replayed
+	role, err = l.RequesterRole(1, "req1", "GET", "/v3/paths/list", "h1")
+	require.NoError(t, err)
+	require.Equal(t, "", role)
+}
+
+func TestDecodeCommand(t *testing.T) {
+	cmd, ok := decodeCommand(map[string]interface{}{
+		"event":   "/v3/walktest/start",
+		"payload": map[string]interface{}{"timeout": "1m"},
+	})
+	require.True(t, ok)
+	require.Equal(t, &Command{
+		Event:   "/v3/walktest/start",
+		Payload: map[string]interface{}{"timeout": "1m"},
+	}, cmd)
+
+	for _, msg := range []interface{}{
+		nil,
+		"invalid",
+		map[string]interface{}{"payload": map[string]interface{}{}},
+		map[string]interface{}{"event": 1},
+	} {
+		_, ok = decodeCommand(msg)
+		require.False(t, ok)
+	}
+}
+
+func TestLocalPresence(t *testing.T) {
+	l := newLocal(t, 1)
+
+	_, ok := l.BridgePresence(1)
+	require.False(t, ok)
+
+	p, err := l.JoinBridgePresence(1, map[string]interface{}{"cameras": 1})
+	require.NoError(t, err)
+
+	meta, ok := l.BridgePresence(1)
+	require.True(t, ok)
+	require.Equal(t, map[string]interface{}{"cameras": 1}, meta)
+
+	_, ok = l.BridgePresence(2)
+	require.False(t, ok)
+
+	err = p.Track(map[string]interface{}{"cameras": 2})
+	require.NoError(t, err)
+
+	meta, _ = l.BridgePresence(1)
+	require.Equal(t, map[string]interface{}{"cameras": 2}, meta)
+
+	p.Close()
+
+	_, ok = l.BridgePresence(1)
+	require.False(t, ok)
+}
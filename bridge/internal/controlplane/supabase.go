@@ -0,0 +1,954 @@
+package controlplane
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/supabase-community/supabase-go"
+
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/protocols/tus"
+	realtimego "github.com/kaonmir/mini-chekt/pkg/realtime-go"
+)
+
+const (
+	// bucket of recordings of alarms.
+	supabaseMediaBucket = "alarm-snapshots"
+	// path of the function that returns the role of the sender of a request.
+	supabaseRequestRolePath = "/rest/v1/rpc/request_role"
+	// key of the bridge in the presence of its channel.
+	supabasePresenceKey = "bridge"
+)
+
+// recordFields returns the fields of a record, without null ones,
+// in order to leave default values to the database.
+func recordFields(record interface{}) (map[string]interface{}, error) {
+	buf, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(buf, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range fields {
+		if v == nil {
+			delete(fields, k)
+		}
+	}
+
+	return fields, nil
+}
+
+// formatTime formats an optional time as stored into records.
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	v := t.UTC().Format(time.RFC3339)
+	return &v
+}
+
+func formatIDs(ids []int64) []string {
+	ret := make([]string, len(ids))
+	for i, id := range ids {
+		ret[i] = strconv.FormatInt(id, 10)
+	}
+	return ret
+}
+
+type supabaseSubscription struct {
+	parent *Supabase
+	client *realtimego.Client
+	ch     *realtimego.Channel
+}
+
+func (s *supabaseSubscription) forget() {
+	s.parent.mutex.Lock()
+	delete(s.parent.realtimes, s.client)
+	s.parent.mutex.Unlock()
+}
+
+// supabaseCommands is the channel that receives the commands of a bridge,
+// on which the presence of the bridge is tracked too.
+type supabaseCommands struct {
+	*supabaseSubscription
+	bridgeID int64
+}
+
+func (c *supabaseCommands) Close() {
+	c.parent.mutex.Lock()
+	if c.parent.commands[c.bridgeID] == c {
+		delete(c.parent.commands, c.bridgeID)
+	}
+	c.parent.mutex.Unlock()
+
+	c.supabaseSubscription.Close()
+}
+
+type supabasePresence struct {
+	parent   *Supabase
+	bridgeID int64
+}
+
+// Track publishes the metadata on the command channel of the bridge. The metadata is
+// stored, in order to be published again when the command channel is opened again.
+func (p *supabasePresence) Track(meta interface{}) error {
+	fields, err := recordFields(meta)
+	if err != nil {
+		return err
+	}
+
+	p.parent.mutex.Lock()
+	p.parent.presences[p.bridgeID] = fields
+	c := p.parent.commands[p.bridgeID]
+	p.parent.mutex.Unlock()
+
+	if c == nil {
+		return nil
+	}
+	return c.ch.Track(fields)
+}
+
+func (p *supabasePresence) Close() {
+	p.parent.mutex.Lock()
+	delete(p.parent.presences, p.bridgeID)
+	c := p.parent.commands[p.bridgeID]
+	p.parent.mutex.Unlock()
+
+	if c != nil {
+		c.ch.Untrack() //nolint:errcheck
+	}
+}
+
+func (s *supabaseSubscription) Close() {
+	s.forget()
+	s.ch.Unsubscribe()    //nolint:errcheck
+	s.client.Disconnect() //nolint:errcheck
+}
+
+// Supabase is a control plane backed by Supabase.
+// The bridge authenticates with a secret, that is exchanged for short-lived access tokens,
+// scoped by row-level security to the bridge and its site. Key is the public API key.
+type Supabase struct {
+	URL        string
+	Key        string
+	BridgeUUID string
+	SecretFile string
+
+	secret     string
+	httpClient *http.Client
+	ctx        context.Context
+	ctxCancel  func()
+	done       chan struct{}
+
+	refreshMutex sync.Mutex // serializes refreshes of the access token
+
+	mutex     sync.RWMutex
+	token     *accessToken
+	client    *supabase.Client
+	realtimes map[*realtimego.Client]struct{}
+	commands  map[int64]*supabaseCommands
+	presences map[int64]map[string]interface{}
+}
+
+// Initialize initializes Supabase.
+func (s *Supabase) Initialize() error {
+	if s.URL == "" || s.Key == "" {
+		return fmt.Errorf("failed to create supabase client: url and key are required")
+	}
+
+	var err error
+	s.secret, err = loadSecret(s.SecretFile)
+	if err != nil {
+		return fmt.Errorf("failed to load bridge secret: %w", err)
+	}
+
+	s.httpClient = &http.Client{}
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	s.done = make(chan struct{})
+	s.realtimes = make(map[*realtimego.Client]struct{})
+	s.commands = make(map[int64]*supabaseCommands)
+	s.presences = make(map[int64]map[string]interface{})
+
+	go s.run()
+
+	return nil
+}
+
+// Close implements ControlPlane.
+func (s *Supabase) Close() {
+	if s.ctxCancel == nil {
+		return
+	}
+
+	s.ctxCancel()
+	<-s.done
+}
+
+// run refreshes the access token before it expires.
+func (s *Supabase) run() {
+	defer close(s.done)
+
+	wait := s.untilRefresh()
+
+	for {
+		t := time.NewTimer(wait)
+
+		select {
+		case <-t.C:
+			err := s.refresh(true)
+			if err != nil {
+				wait = tokenRetryPeriod
+			} else {
+				wait = s.untilRefresh()
+			}
+
+		case <-s.ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
+
+func (s *Supabase) untilRefresh() time.Duration {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.token == nil {
+		return 0
+	}
+	return time.Until(s.token.refreshAt())
+}
+
+// refresh requests a new access token and authenticates clients with it.
+// If force is false, the token is requested only if the current one is expired.
+func (s *Supabase) refresh(force bool) error {
+	s.refreshMutex.Lock()
+	defer s.refreshMutex.Unlock()
+
+	if !force {
+		s.mutex.RLock()
+		valid := s.token != nil && s.token.valid()
+		s.mutex.RUnlock()
+
+		if valid {
+			return nil
+		}
+	}
+
+	ctx, ctxCancel := context.WithTimeout(s.ctx, tokenRequestTimeout)
+	defer ctxCancel()
+
+	token, err := requestToken(ctx, s.httpClient, s.URL, s.Key, s.BridgeUUID, s.secret)
+	if err != nil {
+		// the revoked secret can't be enrolled again, therefore a new one is enrolled
+		// once the bridge is re-provisioned.
+		if errors.Is(err, ErrCredentialsRevoked) {
+			secret, err2 := newSecret(s.SecretFile)
+			if err2 != nil {
+				return fmt.Errorf("failed to replace bridge secret: %w", err2)
+			}
+			s.secret = secret
+		}
+
+		return fmt.Errorf("unable to authenticate bridge: %w", err)
+	}
+
+	// headers of clients can't be replaced safely while requests are in progress,
+	// therefore a new client is created.
+	client, err := supabase.NewClient(s.URL, s.Key, &supabase.ClientOptions{
+		Headers: map[string]string{
+			"Authorization": "Bearer " + token.Token,
+			"apikey":        s.Key,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create supabase client: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.token = token
+	s.client = client
+
+	for rt := range s.realtimes {
+		rt.SetAuth(token.Token)
+	}
+
+	return nil
+}
+
+// getClient returns a client authenticated with a valid access token.
+func (s *Supabase) getClient() (*supabase.Client, error) {
+	s.mutex.RLock()
+	client, token := s.client, s.token
+	s.mutex.RUnlock()
+
+	if token != nil && token.valid() {
+		return client, nil
+	}
+
+	err := s.refresh(false)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.client, nil
+}
+
+func (s *Supabase) getToken() (string, error) {
+	_, err := s.getClient()
+	if err != nil {
+		return "", err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.token.Token, nil
+}
+
+func (s *Supabase) insert(table string, record interface{}) (int64, error) {
+	fields, err := recordFields(record)
+	if err != nil {
+		return 0, err
+	}
+
+	client, err := s.getClient()
+	if err != nil {
+		return 0, err
+	}
+
+	res, _, err := client.From(table).Insert(fields, false, "", "", "").Single().Execute()
+	if err != nil {
+		return 0, err
+	}
+
+	var inserted struct {
+		ID int64 `json:"id"`
+	}
+	err = json.Unmarshal(res, &inserted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal %s record: %w", table, err)
+	}
+
+	return inserted.ID, nil
+}
+
+func (s *Supabase) update(table string, id int64, fields map[string]interface{}) error {
+	client, err := s.getClient()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = client.From(table).
+		Update(fields, "minimal", "").
+		Eq("id", strconv.FormatInt(id, 10)).
+		Execute()
+	return err
+}
+
+// subscribe joins a realtime channel. The client reconnects by itself when the connection fails;
+// onReconnect, if not nil, is called after reconnections, since messages may have been missed.
+func (s *Supabase) subscribe(
+	opt realtimego.ChannelOption, setup func(ch *realtimego.Channel), onReconnect func(),
+) (Subscription, error) {
+	_, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	reconnecting := false
+
+	client, err := realtimego.NewClient(s.URL, s.Key,
+		realtimego.WithStateHandler(func(state realtimego.ConnState, _ error) {
+			switch state {
+			case realtimego.STATE_RECONNECTING:
+				reconnecting = true
+
+			case realtimego.STATE_CONNECTED:
+				if reconnecting && onReconnect != nil {
+					onReconnect()
+				}
+				reconnecting = false
+			}
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create supabase realtime client: %w", err)
+	}
+
+	// the client is authenticated again when the token is refreshed
+	s.mutex.Lock()
+	client.SetAuth(s.token.Token)
+	s.realtimes[client] = struct{}{}
+	s.mutex.Unlock()
+
+	sub := &supabaseSubscription{parent: s, client: client}
+
+	err = client.Connect()
+	if err != nil {
+		sub.forget()
+		return nil, fmt.Errorf("failed to connect to supabase realtime server: %w", err)
+	}
+
+	ch, err := client.Channel(opt)
+	if err != nil {
+		sub.forget()
+		client.Disconnect() //nolint:errcheck
+		return nil, fmt.Errorf("failed to create realtime channel: %w", err)
+	}
+
+	setup(ch)
+
+	err = ch.Subscribe()
+	if err != nil {
+		sub.forget()
+		client.Disconnect() //nolint:errcheck
+		return nil, fmt.Errorf("failed to subscribe to channel: %w", err)
+	}
+
+	sub.ch = ch
+	return sub, nil
+}
+
+// RegisterBridge implements BridgeIdentity.
+// The record is created when the bridge authenticates for the first time.
+func (s *Supabase) RegisterBridge(uuid string) (*defs.PublicBridgeSelect, error) {
+	_, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	bridgeData, err := s.FetchBridge(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if bridgeData == nil {
+		return nil, fmt.Errorf("unable to create bridge record")
+	}
+
+	return bridgeData, nil
+}
+
+// FetchBridge implements BridgeIdentity.
+func (s *Supabase) FetchBridge(uuid string) (*defs.PublicBridgeSelect, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := client.From("bridge").
+		Select("id, bridge_name, site_id, access_token, access_token_expires_at", "", false).
+		Eq("bridge_uuid", uuid).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var bridges []defs.PublicBridgeSelect
+	err = json.Unmarshal(data, &bridges)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bridge record: %w", err)
+	}
+
+	if len(bridges) == 0 {
+		return nil, nil
+	}
+
+	return &bridges[0], nil
+}
+
+// PublishPairingToken implements BridgeIdentity.
+func (s *Supabase) PublishPairingToken(bridgeID int64, token string, expiresAt time.Time) (bool, error) {
+	// tokens of paired bridges are not overridden
+	client, err := s.getClient()
+	if err != nil {
+		return false, err
+	}
+
+	data, _, err := client.From("bridge").
+		Update(map[string]interface{}{
+			"access_token":            token,
+			"access_token_expires_at": expiresAt.UTC().Format(time.RFC3339),
+		}, "representation", "").
+		Eq("id", strconv.FormatInt(bridgeID, 10)).
+		Is("site_id", "null").
+		Execute()
+	if err != nil {
+		return false, err
+	}
+
+	var updated []defs.PublicBridgeSelect
+	err = json.Unmarshal(data, &updated)
+	if err != nil {
+		return false, err
+	}
+
+	return len(updated) != 0, nil
+}
+
+// UnpairBridge implements BridgeIdentity.
+func (s *Supabase) UnpairBridge(bridgeID int64) error {
+	return s.update("bridge", bridgeID, map[string]interface{}{
+		"site_id":                 nil,
+		"access_token":            nil,
+		"access_token_expires_at": nil,
+	})
+}
+
+// UpdateBridgeStatus implements BridgeIdentity.
+func (s *Supabase) UpdateBridgeStatus(
+	bridgeID int64, checkedAt time.Time, version string, status interface{},
+) error {
+	return s.update("bridge", bridgeID, map[string]interface{}{
+		"healthy":         true,
+		"last_checked_at": checkedAt.UTC().Format(time.RFC3339),
+		"version":         version,
+		"status":          status,
+	})
+}
+
+// ListCameras implements CameraRegistry.
+func (s *Supabase) ListCameras(bridgeID int64) ([]defs.PublicCameraSelect, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := client.From("camera").
+		Select("id, camera_name, ip_address, source, is_registered, username, password, timezone, "+
+			"settings, config_error", "", false).
+		Eq("bridge_id", strconv.FormatInt(bridgeID, 10)).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var cameras []defs.PublicCameraSelect
+	err = json.Unmarshal(data, &cameras)
+	if err != nil {
+		return nil, err
+	}
+
+	return cameras, nil
+}
+
+// WatchCameras implements CameraRegistry.
+func (s *Supabase) WatchCameras(bridgeID int64, onChange func()) (Subscription, error) {
+	return s.subscribe(realtimego.WithPostgresChanges(
+		fmt.Sprintf("cameras-%d", bridgeID),
+		"public",
+		"camera",
+		fmt.Sprintf("bridge_id=eq.%d", bridgeID)),
+		func(ch *realtimego.Channel) {
+			cb := func(realtimego.Message) {
+				onChange()
+			}
+			ch.OnInsert = cb
+			ch.OnUpdate = cb
+			ch.OnDelete = cb
+		},
+		onChange)
+}
+
+// UpdateCameraHealth implements CameraRegistry.
+func (s *Supabase) UpdateCameraHealth(
+	bridgeID int64, cameraIDs []int64, healthy bool, checkedAt time.Time,
+) error {
+	client, err := s.getClient()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = client.From("camera").
+		Update(map[string]interface{}{
+			"healthy":         healthy,
+			"last_checked_at": checkedAt.UTC().Format(time.RFC3339),
+		}, "minimal", "").
+		Eq("bridge_id", strconv.FormatInt(bridgeID, 10)).
+		In("id", formatIDs(cameraIDs)).
+		Execute()
+	return err
+}
+
+// SetCameraError implements CameraRegistry.
+func (s *Supabase) SetCameraError(bridgeID int64, cameraID int64, message *string) error {
+	client, err := s.getClient()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = client.From("camera").
+		Update(map[string]interface{}{
+			"config_error": message,
+		}, "minimal", "").
+		Eq("bridge_id", strconv.FormatInt(bridgeID, 10)).
+		Eq("id", strconv.FormatInt(cameraID, 10)).
+		Execute()
+	return err
+}
+
+// FetchBridgeConfig implements ConfigStore.
+func (s *Supabase) FetchBridgeConfig(bridgeID int64) (*defs.PublicBridgeConfigSelect, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := client.From("bridge_config").
+		Select("bridge_id, config, status, error, applied_at, created_at, updated_at", "", false).
+		Eq("bridge_id", strconv.FormatInt(bridgeID, 10)).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []defs.PublicBridgeConfigSelect
+	err = json.Unmarshal(data, &configs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bridge config record: %w", err)
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	return &configs[0], nil
+}
+
+// WatchBridgeConfig implements ConfigStore.
+func (s *Supabase) WatchBridgeConfig(bridgeID int64, onChange func()) (Subscription, error) {
+	return s.subscribe(realtimego.WithPostgresChanges(
+		fmt.Sprintf("bridge-config-%d", bridgeID),
+		"public",
+		"bridge_config",
+		fmt.Sprintf("bridge_id=eq.%d", bridgeID)),
+		func(ch *realtimego.Channel) {
+			cb := func(realtimego.Message) {
+				onChange()
+			}
+			ch.OnInsert = cb
+			ch.OnUpdate = cb
+			ch.OnDelete = cb
+		},
+		onChange)
+}
+
+// ReportBridgeConfigStatus implements ConfigStore.
+func (s *Supabase) ReportBridgeConfigStatus(
+	bridgeID int64, status string, message *string, appliedAt *time.Time,
+) error {
+	client, err := s.getClient()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = client.From("bridge_config").
+		Update(map[string]interface{}{
+			"status":     status,
+			"error":      message,
+			"applied_at": formatTime(appliedAt),
+		}, "minimal", "").
+		Eq("bridge_id", strconv.FormatInt(bridgeID, 10)).
+		Execute()
+	return err
+}
+
+type supabasePanel struct {
+	ID        int64  `json:"id"`
+	SiteID    int64  `json:"site_id"`
+	Account   string `json:"account"`
+	PanelZone []struct {
+		Zone     int   `json:"zone"`
+		CameraID int64 `json:"camera_id"`
+		Camera   struct {
+			IPAddress string `json:"ip_address"`
+		} `json:"camera"`
+	} `json:"panel_zone"`
+}
+
+// ListPanels implements CameraRegistry.
+func (s *Supabase) ListPanels(bridgeID int64) ([]*Panel, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := client.From("panel").
+		Select("id, site_id, account, panel_zone(zone, camera_id, camera(ip_address))", "", false).
+		Eq("bridge_id", strconv.FormatInt(bridgeID, 10)).
+		Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []supabasePanel
+	err = json.Unmarshal(data, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*Panel, len(records))
+	for i, rec := range records {
+		panel := &Panel{
+			ID:      rec.ID,
+			SiteID:  rec.SiteID,
+			Account: rec.Account,
+			Zones:   make([]PanelZone, len(rec.PanelZone)),
+		}
+		for j, z := range rec.PanelZone {
+			panel.Zones[j] = PanelZone{
+				Zone:     z.Zone,
+				CameraID: z.CameraID,
+				CameraIP: z.Camera.IPAddress,
+			}
+		}
+		ret[i] = panel
+	}
+
+	return ret, nil
+}
+
+// InsertAlarm implements AlarmSink.
+func (s *Supabase) InsertAlarm(alarm *defs.PublicAlarmInsert) (int64, error) {
+	return s.insert("alarm", alarm)
+}
+
+// SetAlarmVideo implements AlarmSink.
+func (s *Supabase) SetAlarmVideo(alarmID int64, videoURL string) error {
+	return s.update("alarm", alarmID, map[string]interface{}{
+		"video_url": videoURL,
+	})
+}
+
+// SetAlarmIncident implements AlarmSink.
+func (s *Supabase) SetAlarmIncident(alarmID int64, incidentID int64) error {
+	return s.update("alarm", alarmID, map[string]interface{}{
+		"incident_id": incidentID,
+	})
+}
+
+// InsertIncident implements AlarmSink.
+func (s *Supabase) InsertIncident(incident *defs.PublicIncidentInsert) (int64, error) {
+	return s.insert("incident", incident)
+}
+
+// UpdateIncident implements AlarmSink.
+func (s *Supabase) UpdateIncident(incidentID int64, fields map[string]interface{}) error {
+	return s.update("incident", incidentID, fields)
+}
+
+// SetArmStatus implements AlarmSink.
+func (s *Supabase) SetArmStatus(siteID int64, status string, changedAt time.Time) error {
+	return s.update("site", siteID, map[string]interface{}{
+		"arm_status":            status,
+		"arm_status_changed_at": changedAt,
+		"updated_at":            changedAt,
+	})
+}
+
+// InsertWalkTest implements AlarmSink.
+func (s *Supabase) InsertWalkTest(walkTest *defs.PublicWalkTestInsert) (int64, error) {
+	return s.insert("walk_test", walkTest)
+}
+
+// UpdateWalkTest implements AlarmSink.
+func (s *Supabase) UpdateWalkTest(walkTestID int64, fields map[string]interface{}) error {
+	return s.update("walk_test", walkTestID, fields)
+}
+
+// UploadMedia implements MediaStorage.
+// Files are streamed to the bucket with a resumable upload.
+func (s *Supabase) UploadMedia(
+	ctx context.Context, name string, contentType string, r io.Reader, onProgress func(uploaded int64),
+) (string, error) {
+	token, err := s.getToken()
+	if err != nil {
+		return "", err
+	}
+
+	c := &tus.Client{
+		URL: s.URL + "/storage/v1/upload/resumable",
+		Header: http.Header{
+			"Authorization": []string{"Bearer " + token},
+			"apikey":        []string{s.Key},
+			"x-upsert":      []string{"false"},
+		},
+		HTTPClient: s.httpClient,
+		OnProgress: onProgress,
+	}
+
+	err = c.Upload(ctx, r, map[string]string{
+		"bucketName":  supabaseMediaBucket,
+		"objectName":  name,
+		"contentType": contentType,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/storage/v1/object/public/%s/%s", s.URL, supabaseMediaBucket, name), nil
+}
+
+// ListenCommands implements CommandChannel.
+// The presence of the bridge is tracked on the same channel, see JoinBridgePresence.
+func (s *Supabase) ListenCommands(bridgeID int64, onCommand func(*Command)) (Subscription, error) {
+	sub, err := s.subscribe(
+		func(ch *realtimego.Channel) {
+			realtimego.WithBroadcast(fmt.Sprintf("bridge-%d", bridgeID))(ch)
+			realtimego.WithPresence(supabasePresenceKey)(ch)
+		},
+		func(ch *realtimego.Channel) {
+			ch.OnBroadcast = func(m realtimego.Message) {
+				cmd, ok := decodeCommand(m.Payload)
+				if ok {
+					onCommand(cmd)
+				}
+			}
+		},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &supabaseCommands{supabaseSubscription: sub.(*supabaseSubscription), bridgeID: bridgeID}
+
+	s.mutex.Lock()
+	s.commands[bridgeID] = c
+	meta := s.presences[bridgeID]
+	s.mutex.Unlock()
+
+	// the bridge is online again, if it was before the channel was opened again
+	if meta != nil {
+		c.ch.Track(meta) //nolint:errcheck
+	}
+
+	return c, nil
+}
+
+// RespondCommand implements CommandChannel.
+// Responses are stored into the response table, that requesters watch.
+func (s *Supabase) RespondCommand(bridgeID int64, res *CommandResponse) error {
+	fields, err := recordFields(defs.PublicResponseInsert{
+		BridgeId:     bridgeID,
+		RequestId:    res.RequestID,
+		RequesterId:  res.RequesterID,
+		RequestPath:  res.Path,
+		ResponseBody: res.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	client, err := s.getClient()
+	if err != nil {
+		return err
+	}
+
+	// bridges can't read responses, therefore the record is not returned
+	_, _, err = client.From("response").Insert(fields, false, "", "minimal", "").Execute()
+	return err
+}
+
+// RequesterRole implements CommandChannel.
+// Senders of requests record them into the bridge_request table, that only the
+// request_role function can read. Requests are deleted once they have been attributed.
+func (s *Supabase) RequesterRole(
+	_ int64,
+	requestID string,
+	method string,
+	path string,
+	bodyHash string,
+) (string, error) {
+	token, err := s.getToken()
+	if err != nil {
+		return "", err
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"p_request_id": requestID,
+		"p_method":     method,
+		"p_path":       path,
+		"p_body_hash":  bodyHash,
+	})
+
+	ctx, ctxCancel := context.WithTimeout(s.ctx, tokenRequestTimeout)
+	defer ctxCancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL+supabaseRequestRolePath, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("apikey", s.Key)
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	var role *string
+	err = json.NewDecoder(res.Body).Decode(&role)
+	if err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+
+	if role == nil {
+		return "", nil
+	}
+	return *role, nil
+}
+
+// JoinBridgePresence implements BridgePresence.
+// The bridge is present on the channel that it receives commands from, opened by ListenCommands,
+// therefore clients that send commands know whether the bridge is online. Presence is tracked
+// again after reconnections, and when the command channel is opened again.
+func (s *Supabase) JoinBridgePresence(bridgeID int64, meta interface{}) (Presence, error) {
+	p := &supabasePresence{parent: s, bridgeID: bridgeID}
+
+	err := p.Track(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// decodeCommand decodes a broadcast message. Malformed messages are discarded.
+func decodeCommand(msg interface{}) (*Command, bool) {
+	fields, ok := msg.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	event, ok := fields["event"].(string)
+	if !ok {
+		return nil, false
+	}
+
+	payload, _ := fields["payload"].(map[string]interface{})
+
+	return &Command{Event: event, Payload: payload}, true
+}
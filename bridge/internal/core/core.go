@@ -24,18 +24,25 @@ import (
 	"github.com/kaonmir/mini-chekt/internal/conf"
 	"github.com/kaonmir/mini-chekt/internal/confdb"
 	"github.com/kaonmir/mini-chekt/internal/confwatcher"
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
 	"github.com/kaonmir/mini-chekt/internal/externalcmd"
+	"github.com/kaonmir/mini-chekt/internal/heartbeat"
+	"github.com/kaonmir/mini-chekt/internal/imapclient"
 	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/metadatareader"
 	"github.com/kaonmir/mini-chekt/internal/metrics"
 	"github.com/kaonmir/mini-chekt/internal/playback"
 	"github.com/kaonmir/mini-chekt/internal/pprof"
+	"github.com/kaonmir/mini-chekt/internal/presence"
 	"github.com/kaonmir/mini-chekt/internal/recordcleaner"
 	"github.com/kaonmir/mini-chekt/internal/rlimit"
+	"github.com/kaonmir/mini-chekt/internal/servers/dc09"
 	"github.com/kaonmir/mini-chekt/internal/servers/hls"
 	"github.com/kaonmir/mini-chekt/internal/servers/rtmp"
 	"github.com/kaonmir/mini-chekt/internal/servers/rtsp"
 	"github.com/kaonmir/mini-chekt/internal/servers/smtp"
 	"github.com/kaonmir/mini-chekt/internal/servers/srt"
+	"github.com/kaonmir/mini-chekt/internal/servers/syslog"
 	"github.com/kaonmir/mini-chekt/internal/servers/webrtc"
 	"github.com/kaonmir/mini-chekt/internal/subscriber"
 )
@@ -66,6 +73,44 @@ func atLeastOneRecordDeleteAfter(pathConfs map[string]*conf.Path) bool {
 	return false
 }
 
+func atLeastOneRunAnalytics(pathConfs map[string]*conf.Path) bool {
+	for _, e := range pathConfs {
+		if e.RunAnalytics != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// localSiteID is the site that bridges are paired with when there's no cloud.
+const localSiteID = 1
+
+func newControlPlane(cnf *conf.Conf) (controlplane.ControlPlane, error) {
+	if cnf.ControlPlane == "local" {
+		i := &controlplane.Local{
+			Directory: cnf.ControlPlaneDirectory,
+			SiteID:    localSiteID,
+		}
+		err := i.Initialize()
+		if err != nil {
+			return nil, err
+		}
+		return i, nil
+	}
+
+	i := &controlplane.Supabase{
+		URL:        cnf.SupabaseURL,
+		Key:        cnf.SupabaseKey,
+		BridgeUUID: cnf.BridgeUUID,
+		SecretFile: cnf.BridgeSecretFile,
+	}
+	err := i.Initialize()
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
 func getRTPMaxPayloadSize(udpMaxPayloadSize int, rtspEncryption conf.Encryption) int {
 	// UDP max payload size - 12 (RTP header)
 	v := udpMaxPayloadSize - 12
@@ -84,6 +129,7 @@ type Core struct {
 	ctxCancel       func()
 	confPath        string
 	conf            *conf.Conf
+	controlPlane    controlplane.ControlPlane
 	confdb          *confdb.ConfDB
 	logger          *logger.Logger
 	externalCmdPool *externalcmd.Pool
@@ -101,14 +147,25 @@ type Core struct {
 	webRTCServer    *webrtc.Server
 	srtServer       *srt.Server
 	smtpServer      *smtp.Server
+	dc09Server      *dc09.Server
+	imapClient      *imapclient.Client
+	syslogServer    *syslog.Server
 	alarmManager    *alarm.Aalrm
 	api             *api.API
 	confWatcher     *confwatcher.ConfWatcher
 	subscriber      *subscriber.Subscriber
+	heartbeat       *heartbeat.Heartbeat
+	presence        *presence.Presence
+	pairedSite      int64 // site of cloud resources, zero when the bridge is not paired
 
 	// in
 	chAPIConfigSet chan *conf.Conf
+	chConfDBSync   chan struct{}
+	chRemoteConf   chan struct{}
 	chMail         chan smtp.Mail
+	chPanel        chan dc09.Event
+	chSyslog       chan syslog.Message
+	chMetadata     chan metadatareader.Event
 
 	// out
 	done chan struct{}
@@ -146,6 +203,8 @@ func New(args []string) (*Core, bool) {
 		ctx:            ctx,
 		ctxCancel:      ctxCancel,
 		chAPIConfigSet: make(chan *conf.Conf),
+		chConfDBSync:   make(chan struct{}),
+		chRemoteConf:   make(chan struct{}),
 		done:           make(chan struct{}),
 	}
 
@@ -169,28 +228,56 @@ func New(args []string) (*Core, bool) {
 		}
 	}
 
+	// the control plane is used for the whole lifetime of the process
+	p.controlPlane, err = newControlPlane(p.conf)
+	if err != nil {
+		fmt.Printf("ERR: %s\n", err)
+		return nil, false
+	}
+
 	p.confdb = &confdb.ConfDB{
-		Conf:   p.conf,
-		Parent: p,
+		Conf:         p.conf,
+		ControlPlane: p.controlPlane,
+		Parent:       p,
 	}
 
 	err = p.confdb.Load()
 	if err != nil {
 		fmt.Printf("ERR: %s\n", err)
+		p.controlPlane.Close()
 		return nil, false
 	}
 
+	// pairing and synchronization of cameras run in background
+	p.confdb.Initialize()
+	p.pairedSite = p.confdb.PairedSite()
+
 	err = p.createResources(true)
+
+	// the remote configuration may prevent resources from starting
+	if err != nil && p.confdb.RemoteConfFailed(err) {
+		p.Log(logger.Error, "%s", err)
+
+		var newConf *conf.Conf
+		newConf, err = p.loadConf()
+		if err == nil {
+			err = p.ReloadConf(newConf, false)
+		}
+	}
+
 	if err != nil {
 		if p.logger != nil {
 			p.Log(logger.Error, "%s", err)
 		} else {
 			fmt.Printf("ERR: %s\n", err)
 		}
+		p.ctxCancel()
 		p.closeResources(nil, false)
 		return nil, false
 	}
 
+	p.confdb.RemoteConfApplied()
+
 	go p.run()
 
 	return p, true
@@ -240,12 +327,21 @@ outer:
 				break outer
 			}
 
+			// keep the remote configuration and paths of cameras
+			_, err = p.confdb.Apply(newConf)
+			if err != nil {
+				p.Log(logger.Error, "%s", err)
+				break outer
+			}
+
 			err = p.ReloadConf(newConf, false)
 			if err != nil {
 				p.Log(logger.Error, "%s", err)
 				break outer
 			}
 
+			p.confdb.RemoteConfApplied()
+
 		case newConf := <-p.chAPIConfigSet:
 			p.Log(logger.Info, "reloading configuration (API request)")
 
@@ -255,6 +351,64 @@ outer:
 				break outer
 			}
 
+		case <-p.chRemoteConf:
+			p.Log(logger.Info, "reloading configuration (remote configuration changed)")
+
+			newConf, err := p.loadConf()
+			if err != nil {
+				p.Log(logger.Error, "%s", err)
+				break
+			}
+
+			err = p.ReloadConf(newConf, false)
+
+			// roll back the remote configuration when resources can't be started
+			if err != nil && p.confdb.RemoteConfFailed(err) {
+				p.Log(logger.Error, "%s", err)
+
+				newConf, err = p.loadConf()
+				if err == nil {
+					err = p.ReloadConf(newConf, false)
+				}
+			}
+
+			if err != nil {
+				p.Log(logger.Error, "%s", err)
+				break outer
+			}
+
+			p.confdb.RemoteConfApplied()
+
+		case <-p.chConfDBSync:
+			newConf := p.conf.Clone()
+
+			changed, err := p.confdb.Apply(newConf)
+			if err != nil {
+				p.Log(logger.Error, "%s", err)
+				break
+			}
+
+			// addresses and timezones of cameras may have changed without their paths
+			if p.alarmManager != nil {
+				p.alarmManager.ReloadCameras()
+			}
+
+			if p.confdb.PairedSite() != p.pairedSite {
+				p.Log(logger.Info, "reloading configuration (pairing changed)")
+			} else if changed {
+				p.Log(logger.Info, "reloading configuration (cameras changed)")
+			} else {
+				break
+			}
+
+			err = p.ReloadConf(newConf, false)
+			if err != nil {
+				p.Log(logger.Error, "%s", err)
+				break outer
+			}
+
+			p.confdb.RemoteConfApplied()
+
 		case <-interrupt:
 			p.Log(logger.Info, "shutting down gracefully")
 			break outer
@@ -272,6 +426,19 @@ outer:
 func (p *Core) createResources(initial bool) error {
 	var err error
 
+	// the logger is closed when its configuration changes
+	if p.logger == nil {
+		p.logger, err = logger.New(
+			logger.Level(p.conf.LogLevel),
+			p.conf.LogDestinations,
+			p.conf.LogFile,
+			p.conf.SysLogPrefix,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	if initial {
 		p.Log(logger.Info, "MediaMTX %s", version)
 
@@ -385,6 +552,11 @@ func (p *Core) createResources(initial bool) error {
 		p.playbackServer = i
 	}
 
+	if (p.conf.ONVIFMetadata || atLeastOneRunAnalytics(p.conf.Paths)) &&
+		p.chMetadata == nil {
+		p.chMetadata = make(chan metadatareader.Event, 1000)
+	}
+
 	if p.pathManager == nil {
 		rtpMaxPayloadSize := getRTPMaxPayloadSize(p.conf.UDPMaxPayloadSize, p.conf.RTSPEncryption)
 
@@ -398,6 +570,8 @@ func (p *Core) createResources(initial bool) error {
 			rtpMaxPayloadSize: rtpMaxPayloadSize,
 			pathConfs:         p.conf.Paths,
 			externalCmdPool:   p.externalCmdPool,
+			onvifMetadata:     p.conf.ONVIFMetadata,
+			chMetadata:        &p.chMetadata,
 			metrics:           p.metrics,
 			parent:            p,
 		}
@@ -626,7 +800,9 @@ func (p *Core) createResources(initial bool) error {
 	if p.conf.SMTP &&
 		p.smtpServer == nil {
 
-		p.chMail = make(chan smtp.Mail, 1000)
+		if p.chMail == nil {
+			p.chMail = make(chan smtp.Mail, 1000)
+		}
 		i := &smtp.Server{
 			Port:   p.conf.SMTPPort,
 			Parent: p,
@@ -637,9 +813,74 @@ func (p *Core) createResources(initial bool) error {
 			return err
 		}
 		p.smtpServer = i
+	}
+
+	if p.conf.IMAP &&
+		p.imapClient == nil {
+
+		if p.chMail == nil {
+			p.chMail = make(chan smtp.Mail, 1000)
+		}
+		i := &imapclient.Client{
+			Mailboxes:  p.conf.IMAPMailboxes,
+			PollPeriod: p.conf.IMAPPollPeriod,
+			Parent:     p,
+			ChMail:     &p.chMail,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.imapClient = i
+	}
+
+	if p.conf.DC09 &&
+		p.dc09Server == nil {
+
+		p.chPanel = make(chan dc09.Event, 1000)
+		i := &dc09.Server{
+			Address:     p.conf.DC09Address,
+			Key:         p.conf.DC09Key,
+			ReadTimeout: time.Duration(p.conf.DC09ReadTimeout),
+			Parent:      p,
+			ChEvent:     &p.chPanel,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.dc09Server = i
+	}
 
-		// Initialize Alarm Manager
-		alarmMgr := alarm.New(p.conf, p.confdb, p, &p.chMail)
+	if p.conf.Syslog &&
+		p.syslogServer == nil {
+
+		p.chSyslog = make(chan syslog.Message, 1000)
+		i := &syslog.Server{
+			ServerKey:  p.conf.SyslogServerKey,
+			ServerCert: p.conf.SyslogServerCert,
+			Parent:     p,
+			ChMessage:  &p.chSyslog,
+		}
+		if p.conf.SyslogEncryption != conf.EncryptionStrict {
+			i.Address = p.conf.SyslogAddress
+		}
+		if p.conf.SyslogEncryption != conf.EncryptionNo {
+			i.TLSAddress = p.conf.SyslogTLSAddress
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.syslogServer = i
+	}
+
+	if (p.conf.SMTP || p.conf.IMAP || p.conf.DC09 || p.conf.Syslog || p.conf.ONVIFMetadata ||
+		atLeastOneRunAnalytics(p.conf.Paths)) &&
+		p.pairedSite != 0 &&
+		p.alarmManager == nil {
+		alarmMgr := alarm.New(p.conf, p.confdb, p.controlPlane, p, &p.chMail, &p.chPanel, &p.chSyslog, &p.chMetadata)
+		alarmMgr.Metrics = p.metrics
 		err = alarmMgr.Initialize()
 		if err != nil {
 			return err
@@ -667,6 +908,8 @@ func (p *Core) createResources(initial bool) error {
 			HLSServer:      p.hlsServer,
 			WebRTCServer:   p.webRTCServer,
 			SRTServer:      p.srtServer,
+			AlarmManager:   p.alarmManager,
+			ConfDB:         p.confdb,
 			Parent:         p,
 		}
 		err = i.Initialize()
@@ -676,18 +919,65 @@ func (p *Core) createResources(initial bool) error {
 		p.api = i
 	}
 
-	s := &subscriber.Subscriber{
-		Conf:   p.conf,
-		ConfDB: p.confdb,
-		Parent: p,
+	if p.pairedSite != 0 &&
+		p.subscriber == nil {
+		i := &subscriber.Subscriber{
+			ControlPlane: p.controlPlane,
+			ConfDB:       p.confdb,
+			Parent:       p,
+		}
+		if p.alarmManager != nil {
+			i.AlarmManager = p.alarmManager
+		}
+		if p.api != nil {
+			i.API = p.api
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.subscriber = i
 	}
-	err = s.Initialize()
-	if err != nil {
-		return err
+
+	if p.pairedSite != 0 &&
+		p.heartbeat == nil {
+		i := &heartbeat.Heartbeat{
+			Period:       p.conf.HeartbeatPeriod,
+			Version:      string(version),
+			DiskPath:     ".",
+			BridgeID:     p.confdb.BridgeID(),
+			ControlPlane: p.controlPlane,
+			ConfDB:       p.confdb,
+			PathManager:  p.pathManager,
+			Parent:       p,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.heartbeat = i
 	}
-	p.subscriber = s
 
-	if initial && p.confPath != "" {
+	if p.pairedSite != 0 &&
+		p.presence == nil {
+		i := &presence.Presence{
+			Version:      string(version),
+			BridgeID:     p.confdb.BridgeID(),
+			PathConfs:    p.conf.Paths,
+			ControlPlane: p.controlPlane,
+			ConfDB:       p.confdb,
+			PathManager:  p.pathManager,
+			Parent:       p,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.presence = i
+	}
+
+	// the configuration watcher is started after a rollback of the initial configuration too
+	if p.confWatcher == nil && p.confPath != "" {
 		cf := &confwatcher.ConfWatcher{FilePath: p.confPath}
 		err = cf.Initialize()
 		if err != nil {
@@ -700,6 +990,11 @@ func (p *Core) createResources(initial bool) error {
 }
 
 func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
+	pairedSite := p.confdb.PairedSite()
+
+	closePairing := newConf == nil ||
+		pairedSite != p.pairedSite
+
 	closeLogger := newConf == nil ||
 		newConf.LogLevel != p.conf.LogLevel ||
 		!reflect.DeepEqual(newConf.LogDestinations, p.conf.LogDestinations) ||
@@ -774,6 +1069,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.WriteQueueSize != p.conf.WriteQueueSize ||
 		newConf.UDPMaxPayloadSize != p.conf.UDPMaxPayloadSize ||
 		newConf.RTSPEncryption != p.conf.RTSPEncryption ||
+		newConf.ONVIFMetadata != p.conf.ONVIFMetadata ||
 		closeMetrics ||
 		closeAuthManager ||
 		closeLogger
@@ -915,6 +1211,48 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.SMTPPort != p.conf.SMTPPort ||
 		closeLogger
 
+	closeIMAPClient := newConf == nil ||
+		newConf.IMAP != p.conf.IMAP ||
+		!reflect.DeepEqual(newConf.IMAPMailboxes, p.conf.IMAPMailboxes) ||
+		newConf.IMAPPollPeriod != p.conf.IMAPPollPeriod ||
+		closeLogger
+
+	closeDC09Server := newConf == nil ||
+		newConf.DC09 != p.conf.DC09 ||
+		newConf.DC09Address != p.conf.DC09Address ||
+		newConf.DC09Key != p.conf.DC09Key ||
+		newConf.DC09ReadTimeout != p.conf.DC09ReadTimeout ||
+		closeLogger
+
+	closeSyslogServer := newConf == nil ||
+		newConf.Syslog != p.conf.Syslog ||
+		newConf.SyslogAddress != p.conf.SyslogAddress ||
+		newConf.SyslogEncryption != p.conf.SyslogEncryption ||
+		newConf.SyslogTLSAddress != p.conf.SyslogTLSAddress ||
+		newConf.SyslogServerKey != p.conf.SyslogServerKey ||
+		newConf.SyslogServerCert != p.conf.SyslogServerCert ||
+		closeLogger
+
+	closeAlarmManager := newConf == nil ||
+		newConf.AlarmIncidentWindow != p.conf.AlarmIncidentWindow ||
+		newConf.AlarmIncidentMaxDuration != p.conf.AlarmIncidentMaxDuration ||
+		!reflect.DeepEqual(newConf.AlarmIncidentAdjacency, p.conf.AlarmIncidentAdjacency) ||
+		newConf.AlarmWalkTestTimeout != p.conf.AlarmWalkTestTimeout ||
+		newConf.AlarmCameraTimezone != p.conf.AlarmCameraTimezone ||
+		newConf.AlarmClockSkewThreshold != p.conf.AlarmClockSkewThreshold ||
+		newConf.AlarmWorkers != p.conf.AlarmWorkers ||
+		newConf.AlarmQueueSize != p.conf.AlarmQueueSize ||
+		newConf.AlarmUploadTimeout != p.conf.AlarmUploadTimeout ||
+		newConf.ONVIFMetadata != p.conf.ONVIFMetadata ||
+		atLeastOneRunAnalytics(newConf.Paths) != atLeastOneRunAnalytics(p.conf.Paths) ||
+		closePairing ||
+		closeSMTPServer ||
+		closeIMAPClient ||
+		closeDC09Server ||
+		closeSyslogServer ||
+		closeMetrics ||
+		closeLogger
+
 	closeAPI := newConf == nil ||
 		newConf.API != p.conf.API ||
 		newConf.APIAddress != p.conf.APIAddress ||
@@ -933,18 +1271,45 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeWebRTCServer ||
 		closeSRTServer ||
 		closeSMTPServer ||
+		closeAlarmManager ||
 		closeLogger
 
 	closeSubscriber := newConf == nil ||
-		newConf.SupabaseURL != p.conf.SupabaseURL ||
-		newConf.SupabaseKey != p.conf.SupabaseKey ||
+		closeAPI ||
+		closeAlarmManager ||
 		closeLogger
 
+	closeHeartbeat := newConf == nil ||
+		newConf.HeartbeatPeriod != p.conf.HeartbeatPeriod ||
+		closePairing ||
+		closePathManager ||
+		closeLogger
+
+	closePresence := newConf == nil ||
+		closePairing ||
+		closePathManager ||
+		closeLogger
+	if !closePresence && p.presence != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
+		p.presence.ReloadPathConfs(newConf.Paths)
+	}
+
 	if newConf == nil && p.confWatcher != nil {
 		p.confWatcher.Close()
 		p.confWatcher = nil
 	}
 
+	if newConf == nil && p.confdb != nil {
+		p.confdb.Close()
+	}
+
+	// the subscriber forwards requests to the API, therefore it is closed first
+	if p.subscriber != nil {
+		if closeSubscriber {
+			p.subscriber.Close()
+			p.subscriber = nil
+		}
+	}
+
 	if p.api != nil {
 		if closeAPI {
 			p.api.Close()
@@ -954,11 +1319,14 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		}
 	}
 
-	if p.subscriber != nil {
-		if closeSubscriber {
-			p.subscriber.Close()
-			p.subscriber = nil
-		}
+	if closePresence && p.presence != nil {
+		p.presence.Close()
+		p.presence = nil
+	}
+
+	if closeHeartbeat && p.heartbeat != nil {
+		p.heartbeat.Close()
+		p.heartbeat = nil
 	}
 
 	if closeSRTServer && p.srtServer != nil {
@@ -971,7 +1339,22 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.smtpServer = nil
 	}
 
-	if closeSMTPServer && p.alarmManager != nil {
+	if closeIMAPClient && p.imapClient != nil {
+		p.imapClient.Close()
+		p.imapClient = nil
+	}
+
+	if closeDC09Server && p.dc09Server != nil {
+		p.dc09Server.Close()
+		p.dc09Server = nil
+	}
+
+	if closeSyslogServer && p.syslogServer != nil {
+		p.syslogServer.Close()
+		p.syslogServer = nil
+	}
+
+	if closeAlarmManager && p.alarmManager != nil {
 		p.alarmManager.Close()
 		p.alarmManager = nil
 	}
@@ -1040,10 +1423,33 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.externalCmdPool.Close()
 	}
 
+	if newConf == nil && p.controlPlane != nil {
+		p.controlPlane.Close()
+	}
+
 	if closeLogger && p.logger != nil {
 		p.logger.Close()
 		p.logger = nil
 	}
+
+	if newConf != nil {
+		p.pairedSite = pairedSite
+	}
+}
+
+// loadConf loads the local configuration and merges the remote configuration and cameras into it.
+func (p *Core) loadConf() (*conf.Conf, error) {
+	newConf, _, err := conf.Load(p.confPath, nil, p.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.confdb.Apply(newConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConf, nil
 }
 
 func (p *Core) ReloadConf(newConf *conf.Conf, calledByAPI bool) error {
@@ -1052,6 +1458,30 @@ func (p *Core) ReloadConf(newConf *conf.Conf, calledByAPI bool) error {
 	return p.createResources(false)
 }
 
+// ConfDBCamerasChanged is called by confdb.
+func (p *Core) ConfDBCamerasChanged() {
+	select {
+	case p.chConfDBSync <- struct{}{}:
+	case <-p.ctx.Done():
+	}
+}
+
+// ConfDBPairingChanged is called by confdb.
+func (p *Core) ConfDBPairingChanged() {
+	select {
+	case p.chConfDBSync <- struct{}{}:
+	case <-p.ctx.Done():
+	}
+}
+
+// ConfDBRemoteConfChanged is called by confdb.
+func (p *Core) ConfDBRemoteConfChanged() {
+	select {
+	case p.chRemoteConf <- struct{}{}:
+	case <-p.ctx.Done():
+	}
+}
+
 // APIConfigSet is called by api.
 func (p *Core) APIConfigSet(conf *conf.Conf) {
 	select {
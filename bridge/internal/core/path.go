@@ -10,11 +10,14 @@ import (
 
 	"github.com/bluenviron/gortsplib/v4/pkg/description"
 
+	"github.com/kaonmir/mini-chekt/internal/analytics"
 	"github.com/kaonmir/mini-chekt/internal/conf"
 	"github.com/kaonmir/mini-chekt/internal/defs"
 	"github.com/kaonmir/mini-chekt/internal/externalcmd"
 	"github.com/kaonmir/mini-chekt/internal/hooks"
 	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/metadatareader"
+	"github.com/kaonmir/mini-chekt/internal/protocols/onvifmeta"
 	"github.com/kaonmir/mini-chekt/internal/recorder"
 	"github.com/kaonmir/mini-chekt/internal/staticsources"
 	"github.com/kaonmir/mini-chekt/internal/stream"
@@ -76,6 +79,8 @@ type path struct {
 	matches           []string
 	wg                *sync.WaitGroup
 	externalCmdPool   *externalcmd.Pool
+	onvifMetadata     bool
+	chMetadata        *chan metadatareader.Event
 	parent            pathParent
 
 	ctx                            context.Context
@@ -85,6 +90,8 @@ type path struct {
 	publisherQuery                 string
 	stream                         *stream.Stream
 	recorder                       *recorder.Recorder
+	metadataReader                 *metadatareader.Reader
+	analytics                      *analytics.Plugin
 	readyTime                      time.Time
 	onUnDemandHook                 func(string)
 	onNotReadyHook                 func()
@@ -350,6 +357,8 @@ func (pa *path) doOnDemandPublisherCloseTimer() {
 }
 
 func (pa *path) doReloadConf(newConf *conf.Path) {
+	oldConf := pa.conf
+
 	pa.confMutex.Lock()
 	pa.conf = newConf
 	pa.confMutex.Unlock()
@@ -366,6 +375,19 @@ func (pa *path) doReloadConf(newConf *conf.Path) {
 		pa.recorder.Close()
 		pa.recorder = nil
 	}
+
+	if newConf.RunAnalytics != oldConf.RunAnalytics ||
+		newConf.AnalyticsFrames != oldConf.AnalyticsFrames ||
+		newConf.AnalyticsInterval != oldConf.AnalyticsInterval {
+		if pa.analytics != nil {
+			pa.analytics.Close()
+			pa.analytics = nil
+		}
+
+		if pa.stream != nil && newConf.RunAnalytics != "" {
+			pa.startAnalytics()
+		}
+	}
 }
 
 func (pa *path) doSourceStaticSetReady(req defs.PathSourceStaticSetReadyReq) {
@@ -695,6 +717,14 @@ func (pa *path) setReady(desc *description.Session, allocateEncoder bool) error
 		pa.startRecording()
 	}
 
+	if pa.onvifMetadata {
+		pa.startMetadataReader()
+	}
+
+	if pa.conf.RunAnalytics != "" {
+		pa.startAnalytics()
+	}
+
 	pa.onNotReadyHook = hooks.OnReady(hooks.OnReadyParams{
 		Logger:          pa,
 		ExternalCmdPool: pa.externalCmdPool,
@@ -738,6 +768,16 @@ func (pa *path) setNotReady() {
 		pa.recorder = nil
 	}
 
+	if pa.metadataReader != nil {
+		pa.metadataReader.Close()
+		pa.metadataReader = nil
+	}
+
+	if pa.analytics != nil {
+		pa.analytics.Close()
+		pa.analytics = nil
+	}
+
 	if pa.stream != nil {
 		pa.stream.Close()
 		pa.stream = nil
@@ -787,6 +827,44 @@ func (pa *path) startRecording() {
 	pa.recorder.Initialize()
 }
 
+func (pa *path) startMetadataReader() {
+	medi, forma := onvifmeta.FindFormat(pa.stream.Desc)
+	if forma == nil {
+		return
+	}
+
+	pa.metadataReader = &metadatareader.Reader{
+		PathName: pa.name,
+		Stream:   pa.stream,
+		Media:    medi,
+		Format:   forma,
+		ChEvent:  pa.chMetadata,
+		Parent:   pa,
+	}
+	pa.metadataReader.Initialize()
+}
+
+func (pa *path) startAnalytics() {
+	a := &analytics.Plugin{
+		Command:         pa.conf.RunAnalytics,
+		Frames:          pa.conf.AnalyticsFrames,
+		Interval:        pa.conf.AnalyticsInterval,
+		PathName:        pa.name,
+		Stream:          pa.stream,
+		ExternalCmdPool: pa.externalCmdPool,
+		ExternalCmdEnv:  pa.ExternalCmdEnv(),
+		ChEvent:         pa.chMetadata,
+		Parent:          pa,
+	}
+	err := a.Initialize()
+	if err != nil {
+		pa.Log(logger.Warn, "unable to start analytics: %v", err)
+		return
+	}
+
+	pa.analytics = a
+}
+
 func (pa *path) executeRemoveReader(r defs.Reader) {
 	delete(pa.readers, r)
 }
@@ -11,7 +11,9 @@ import (
 	"github.com/kaonmir/mini-chekt/internal/defs"
 	"github.com/kaonmir/mini-chekt/internal/externalcmd"
 	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/metadatareader"
 	"github.com/kaonmir/mini-chekt/internal/metrics"
+	"github.com/kaonmir/mini-chekt/internal/presence"
 	"github.com/kaonmir/mini-chekt/internal/servers/hls"
 	"github.com/kaonmir/mini-chekt/internal/stream"
 )
@@ -24,6 +26,10 @@ func pathConfCanBeUpdated(oldPathConf *conf.Path, newPathConf *conf.Path) bool {
 
 	clone.Record = newPathConf.Record
 
+	clone.RunAnalytics = newPathConf.RunAnalytics
+	clone.AnalyticsFrames = newPathConf.AnalyticsFrames
+	clone.AnalyticsInterval = newPathConf.AnalyticsInterval
+
 	clone.RPICameraBrightness = newPathConf.RPICameraBrightness
 	clone.RPICameraContrast = newPathConf.RPICameraContrast
 	clone.RPICameraSaturation = newPathConf.RPICameraSaturation
@@ -53,6 +59,11 @@ type pathSetHLSServerReq struct {
 	res chan pathSetHLSServerRes
 }
 
+type pathSetPresenceReq struct {
+	p   *presence.Presence
+	res chan struct{}
+}
+
 type pathData struct {
 	path     *path
 	ready    bool
@@ -73,6 +84,8 @@ type pathManager struct {
 	rtpMaxPayloadSize int
 	pathConfs         map[string]*conf.Path
 	externalCmdPool   *externalcmd.Pool
+	onvifMetadata     bool
+	chMetadata        *chan metadatareader.Event
 	metrics           *metrics.Metrics
 	parent            pathManagerParent
 
@@ -80,11 +93,13 @@ type pathManager struct {
 	ctxCancel func()
 	wg        sync.WaitGroup
 	hlsServer *hls.Server
+	presence  *presence.Presence
 	paths     map[string]*pathData
 
 	// in
 	chReloadConf   chan map[string]*conf.Path
 	chSetHLSServer chan pathSetHLSServerReq
+	chSetPresence  chan pathSetPresenceReq
 	chClosePath    chan *path
 	chPathReady    chan *path
 	chPathNotReady chan *path
@@ -104,6 +119,7 @@ func (pm *pathManager) initialize() {
 	pm.paths = make(map[string]*pathData)
 	pm.chReloadConf = make(chan map[string]*conf.Path)
 	pm.chSetHLSServer = make(chan pathSetHLSServerReq)
+	pm.chSetPresence = make(chan pathSetPresenceReq)
 	pm.chClosePath = make(chan *path)
 	pm.chPathReady = make(chan *path)
 	pm.chPathNotReady = make(chan *path)
@@ -159,6 +175,10 @@ outer:
 			readyPaths := pm.doSetHLSServer(req.s)
 			req.res <- pathSetHLSServerRes{readyPaths: readyPaths}
 
+		case req := <-pm.chSetPresence:
+			pm.presence = req.p
+			close(req.res)
+
 		case pa := <-pm.chClosePath:
 			pm.doClosePath(pa)
 
@@ -296,6 +316,10 @@ func (pm *pathManager) doPathReady(pa *path) {
 	if pm.hlsServer != nil {
 		pm.hlsServer.PathReady(pa)
 	}
+
+	if pm.presence != nil {
+		pm.presence.PathStateChanged()
+	}
 }
 
 func (pm *pathManager) doPathNotReady(pa *path) {
@@ -308,6 +332,10 @@ func (pm *pathManager) doPathNotReady(pa *path) {
 	if pm.hlsServer != nil {
 		pm.hlsServer.PathNotReady(pa)
 	}
+
+	if pm.presence != nil {
+		pm.presence.PathStateChanged()
+	}
 }
 
 func (pm *pathManager) doFindPathConf(req defs.PathFindPathConfReq) {
@@ -439,6 +467,8 @@ func (pm *pathManager) createPath(
 		matches:           matches,
 		wg:                &pm.wg,
 		externalCmdPool:   pm.externalCmdPool,
+		onvifMetadata:     pm.onvifMetadata,
+		chMetadata:        pm.chMetadata,
 		parent:            pm,
 	}
 	pa.initialize()
@@ -575,6 +605,21 @@ func (pm *pathManager) SetHLSServer(s *hls.Server) []defs.Path {
 	}
 }
 
+// SetPresence is called by presence.Presence.
+func (pm *pathManager) SetPresence(p *presence.Presence) {
+	req := pathSetPresenceReq{
+		p:   p,
+		res: make(chan struct{}),
+	}
+
+	select {
+	case pm.chSetPresence <- req:
+		<-req.res
+
+	case <-pm.ctx.Done():
+	}
+}
+
 // APIPathsList is called by api.
 func (pm *pathManager) APIPathsList() (*defs.APIPathList, error) {
 	req := pathAPIPathsListReq{
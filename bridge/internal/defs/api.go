@@ -50,6 +50,23 @@ type APIWebRTCServer interface {
 	APISessionsKick(uuid.UUID) error
 }
 
+// APIAlarmManager contains methods used by the API and the realtime subscriber.
+type APIAlarmManager interface {
+	APIWalkTestGet() (*APIWalkTest, error)
+	APIWalkTestStart(*APIWalkTestStartReq) (*APIWalkTest, error)
+	APIWalkTestStop() (*APIWalkTest, error)
+	APIClockSkewsList() (*APIClockSkewList, error)
+}
+
+// APIConfDB contains methods used by the API.
+type APIConfDB interface {
+	APICameraSyncGet() (*APICameraSync, error)
+	APIBridgePairingGet() (*APIBridgePairing, error)
+	APIBridgePairingRenew() (*APIBridgePairing, error)
+	APIBridgePairingUnpair() (*APIBridgePairing, error)
+	APIBridgeConfigGet() (*APIBridgeConfig, error)
+}
+
 // APIError is a generic error.
 type APIError struct {
 	Error string `json:"error"`
@@ -389,3 +406,133 @@ type APIRecordingList struct {
 	PageCount int             `json:"pageCount"`
 	Items     []*APIRecording `json:"items"`
 }
+
+// APIWalkTestState is the state of a walk test.
+type APIWalkTestState string
+
+// states.
+const (
+	APIWalkTestStateRunning  APIWalkTestState = "running"
+	APIWalkTestStateFinished APIWalkTestState = "finished"
+)
+
+// APIWalkTestStartReq is a request to start a walk test.
+type APIWalkTestStartReq struct {
+	// cameras to test. When empty, all cameras of the site are tested.
+	CameraIDs []int64 `json:"cameraIds"`
+	// duration after which the walk test is stopped automatically.
+	Timeout *conf.Duration `json:"timeout"`
+}
+
+// APIWalkTestCamera is the result of a walk test for a camera.
+type APIWalkTestCamera struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Fired       bool       `json:"fired"`
+	AlarmCount  int        `json:"alarmCount"`
+	AlarmTypes  []string   `json:"alarmTypes"`
+	LastAlarmAt *time.Time `json:"lastAlarmAt"`
+}
+
+// APIClockSkew is the clock skew of a camera.
+// Skews are the difference between the receipt time and the time reported by the camera,
+// in seconds, and are positive when the clock of the camera is late.
+type APIClockSkew struct {
+	CameraID    int64     `json:"cameraID"`
+	Skew        float64   `json:"skew"`
+	MaxSkew     float64   `json:"maxSkew"`
+	Samples     uint64    `json:"samples"`
+	Corrections uint64    `json:"corrections"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// APIClockSkewList is a list of clock skews.
+type APIClockSkewList struct {
+	ItemCount int             `json:"itemCount"`
+	PageCount int             `json:"pageCount"`
+	Items     []*APIClockSkew `json:"items"`
+}
+
+// APICameraSync is the status of the synchronization of cameras with the database.
+type APICameraSync struct {
+	Subscribed bool `json:"subscribed"`
+	// started from cache, the cloud is not reachable yet.
+	Offline bool `json:"offline"`
+	// cameras of the bridge, including unregistered ones.
+	Cameras int `json:"cameras"`
+	// paths of registered cameras.
+	Paths []string `json:"syncedPaths"`
+	// cameras whose path can't be created.
+	Errors      map[string]string `json:"errors"`
+	LastSync    *time.Time        `json:"lastSync"`
+	LastError   *string           `json:"lastError"`
+	LastErrorAt *time.Time        `json:"lastErrorAt"`
+}
+
+// APIBridgePairingState is the pairing state of the bridge.
+type APIBridgePairingState string
+
+// pairing states.
+const (
+	APIBridgePairingStateUnpaired APIBridgePairingState = "unpaired"
+	APIBridgePairingStatePending  APIBridgePairingState = "pending"
+	APIBridgePairingStatePaired   APIBridgePairingState = "paired"
+	APIBridgePairingStateRevoked  APIBridgePairingState = "revoked"
+)
+
+// APIBridgePairing is the pairing status of the bridge.
+type APIBridgePairing struct {
+	State    APIBridgePairingState `json:"state"`
+	BridgeID *int64                `json:"bridgeID"`
+	SiteID   *int64                `json:"siteID"`
+	// token to enter in the web app, when pending.
+	Token          *string    `json:"token"`
+	TokenExpiresAt *time.Time `json:"tokenExpiresAt"`
+	LastError      *string    `json:"lastError"`
+}
+
+// APIDiscoveredDevice is an ONVIF device found on the network of the bridge.
+type APIDiscoveredDevice struct {
+	Address string `json:"address"`
+	UUID    string `json:"uuid"`
+}
+
+// APIDiscoveredDeviceList is a list of discovered devices.
+type APIDiscoveredDeviceList struct {
+	ItemCount int                    `json:"itemCount"`
+	Items     []*APIDiscoveredDevice `json:"items"`
+}
+
+// APIBridgeConfigStatus is the status of the remote configuration of the bridge.
+type APIBridgeConfigStatus string
+
+// remote configuration statuses.
+const (
+	APIBridgeConfigStatusNone       APIBridgeConfigStatus = "none"
+	APIBridgeConfigStatusPending    APIBridgeConfigStatus = "pending"
+	APIBridgeConfigStatusApplied    APIBridgeConfigStatus = "applied"
+	APIBridgeConfigStatusInvalid    APIBridgeConfigStatus = "invalid"
+	APIBridgeConfigStatusRolledBack APIBridgeConfigStatus = "rolled_back"
+)
+
+// APIBridgeConfig is the status of the remote configuration of the bridge.
+type APIBridgeConfig struct {
+	Status APIBridgeConfigStatus `json:"status"`
+	// keys of the remote configuration in use.
+	Keys []string `json:"keys"`
+	// keys of the remote configuration that are overridden by the local one.
+	Pinned    []string   `json:"pinned"`
+	AppliedAt *time.Time `json:"appliedAt"`
+	Error     *string    `json:"error"`
+}
+
+// APIWalkTest is a walk test.
+type APIWalkTest struct {
+	ID         int64                `json:"id"`
+	State      APIWalkTestState     `json:"state"`
+	StartedAt  time.Time            `json:"startedAt"`
+	ExpiresAt  time.Time            `json:"expiresAt"`
+	FinishedAt *time.Time           `json:"finishedAt"`
+	AlarmCount int                  `json:"alarmCount"`
+	Cameras    []*APIWalkTestCamera `json:"cameras"`
+}
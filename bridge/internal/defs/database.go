@@ -70,78 +70,108 @@ type PublicResponseUpdate struct {
 }
 
 type PublicCameraSelect struct {
-	BridgeId      int64  `json:"bridge_id"`
-	CameraName    string `json:"camera_name"`
-	CreatedAt     string `json:"created_at"`
-	Healthy       bool   `json:"healthy"`
-	Id            int64  `json:"id"`
-	IpAddress     string `json:"ip_address"`
-	IsRegistered  bool   `json:"is_registered"`
-	LastCheckedAt string `json:"last_checked_at"`
-	Source        string `json:"source"`
-	UpdatedAt     string `json:"updated_at"`
+	BridgeId      int64       `json:"bridge_id"`
+	CameraName    string      `json:"camera_name"`
+	ConfigError   *string     `json:"config_error"`
+	CreatedAt     string      `json:"created_at"`
+	Healthy       bool        `json:"healthy"`
+	Id            int64       `json:"id"`
+	IpAddress     string      `json:"ip_address"`
+	IsRegistered  bool        `json:"is_registered"`
+	LastCheckedAt string      `json:"last_checked_at"`
+	Password      *string     `json:"password"`
+	Settings      interface{} `json:"settings"`
+	Source        string      `json:"source"`
+	Timezone      *string     `json:"timezone"`
+	UpdatedAt     string      `json:"updated_at"`
+	Username      *string     `json:"username"`
 }
 
 type PublicCameraInsert struct {
-	BridgeId      int64   `json:"bridge_id"`
-	CameraName    string  `json:"camera_name"`
-	CreatedAt     *string `json:"created_at"`
-	Healthy       *bool   `json:"healthy"`
-	Id            *int64  `json:"id"`
-	IpAddress     string  `json:"ip_address"`
-	IsRegistered  *bool   `json:"is_registered"`
-	LastCheckedAt *string `json:"last_checked_at"`
-	Source        string  `json:"source"`
-	UpdatedAt     *string `json:"updated_at"`
+	BridgeId      int64       `json:"bridge_id"`
+	CameraName    string      `json:"camera_name"`
+	ConfigError   *string     `json:"config_error"`
+	CreatedAt     *string     `json:"created_at"`
+	Healthy       *bool       `json:"healthy"`
+	Id            *int64      `json:"id"`
+	IpAddress     string      `json:"ip_address"`
+	IsRegistered  *bool       `json:"is_registered"`
+	LastCheckedAt *string     `json:"last_checked_at"`
+	Password      *string     `json:"password"`
+	Settings      interface{} `json:"settings"`
+	Source        string      `json:"source"`
+	Timezone      *string     `json:"timezone"`
+	UpdatedAt     *string     `json:"updated_at"`
+	Username      *string     `json:"username"`
 }
 
 type PublicCameraUpdate struct {
-	BridgeId      *int64  `json:"bridge_id"`
-	CameraName    *string `json:"camera_name"`
-	CreatedAt     *string `json:"created_at"`
-	Healthy       *bool   `json:"healthy"`
-	Id            *int64  `json:"id"`
-	IpAddress     *string `json:"ip_address"`
-	IsRegistered  *bool   `json:"is_registered"`
-	LastCheckedAt *string `json:"last_checked_at"`
-	Source        *string `json:"source"`
-	UpdatedAt     *string `json:"updated_at"`
+	BridgeId      *int64      `json:"bridge_id"`
+	CameraName    *string     `json:"camera_name"`
+	ConfigError   *string     `json:"config_error"`
+	CreatedAt     *string     `json:"created_at"`
+	Healthy       *bool       `json:"healthy"`
+	Id            *int64      `json:"id"`
+	IpAddress     *string     `json:"ip_address"`
+	IsRegistered  *bool       `json:"is_registered"`
+	LastCheckedAt *string     `json:"last_checked_at"`
+	Password      *string     `json:"password"`
+	Settings      interface{} `json:"settings"`
+	Source        *string     `json:"source"`
+	Timezone      *string     `json:"timezone"`
+	UpdatedAt     *string     `json:"updated_at"`
+	Username      *string     `json:"username"`
 }
 
 type PublicBridgeSelect struct {
-	AccessToken   *string `json:"access_token"`
-	BridgeName    string  `json:"bridge_name"`
-	BridgeUuid    string  `json:"bridge_uuid"`
-	CreatedAt     string  `json:"created_at"`
-	Healthy       bool    `json:"healthy"`
-	Id            int64   `json:"id"`
-	LastCheckedAt string  `json:"last_checked_at"`
-	SiteId        *int64  `json:"site_id"`
-	UpdatedAt     string  `json:"updated_at"`
+	AccessToken          *string     `json:"access_token"`
+	AccessTokenExpiresAt *string     `json:"access_token_expires_at"`
+	BridgeName           string      `json:"bridge_name"`
+	BridgeUuid           string      `json:"bridge_uuid"`
+	CreatedAt            string      `json:"created_at"`
+	CredentialsRevokedAt *string     `json:"credentials_revoked_at"`
+	Healthy              bool        `json:"healthy"`
+	Id                   int64       `json:"id"`
+	LastCheckedAt        string      `json:"last_checked_at"`
+	SecretHash           *string     `json:"secret_hash"`
+	SiteId               *int64      `json:"site_id"`
+	Status               interface{} `json:"status"`
+	UpdatedAt            string      `json:"updated_at"`
+	Version              *string     `json:"version"`
 }
 
 type PublicBridgeInsert struct {
-	AccessToken   *string `json:"access_token"`
-	BridgeName    string  `json:"bridge_name"`
-	BridgeUuid    string  `json:"bridge_uuid"`
-	CreatedAt     *string `json:"created_at"`
-	Healthy       *bool   `json:"healthy"`
-	Id            *int64  `json:"id"`
-	LastCheckedAt *string `json:"last_checked_at"`
-	SiteId        *int64  `json:"site_id"`
-	UpdatedAt     *string `json:"updated_at"`
+	AccessToken          *string     `json:"access_token"`
+	AccessTokenExpiresAt *string     `json:"access_token_expires_at"`
+	BridgeName           string      `json:"bridge_name"`
+	BridgeUuid           string      `json:"bridge_uuid"`
+	CreatedAt            *string     `json:"created_at"`
+	CredentialsRevokedAt *string     `json:"credentials_revoked_at"`
+	Healthy              *bool       `json:"healthy"`
+	Id                   *int64      `json:"id"`
+	LastCheckedAt        *string     `json:"last_checked_at"`
+	SecretHash           *string     `json:"secret_hash"`
+	SiteId               *int64      `json:"site_id"`
+	Status               interface{} `json:"status"`
+	UpdatedAt            *string     `json:"updated_at"`
+	Version              *string     `json:"version"`
 }
 
 type PublicBridgeUpdate struct {
-	AccessToken   *string `json:"access_token"`
-	BridgeName    *string `json:"bridge_name"`
-	BridgeUuid    *string `json:"bridge_uuid"`
-	CreatedAt     *string `json:"created_at"`
-	Healthy       *bool   `json:"healthy"`
-	Id            *int64  `json:"id"`
-	LastCheckedAt *string `json:"last_checked_at"`
-	SiteId        *int64  `json:"site_id"`
-	UpdatedAt     *string `json:"updated_at"`
+	AccessToken          *string     `json:"access_token"`
+	AccessTokenExpiresAt *string     `json:"access_token_expires_at"`
+	BridgeName           *string     `json:"bridge_name"`
+	BridgeUuid           *string     `json:"bridge_uuid"`
+	CreatedAt            *string     `json:"created_at"`
+	CredentialsRevokedAt *string     `json:"credentials_revoked_at"`
+	Healthy              *bool       `json:"healthy"`
+	Id                   *int64      `json:"id"`
+	LastCheckedAt        *string     `json:"last_checked_at"`
+	SecretHash           *string     `json:"secret_hash"`
+	SiteId               *int64      `json:"site_id"`
+	Status               interface{} `json:"status"`
+	UpdatedAt            *string     `json:"updated_at"`
+	Version              *string     `json:"version"`
 }
 
 type PublicAlarmSelect struct {
@@ -151,13 +181,18 @@ type PublicAlarmSelect struct {
 	CameraId    int64   `json:"camera_id"`
 	CreatedAt   string  `json:"created_at"`
 	Id          int64   `json:"id"`
+	IncidentId  *int64  `json:"incident_id"`
 	IsRead      bool    `json:"is_read"`
+	IsTest      bool    `json:"is_test"`
 	LastAlarmAt string  `json:"last_alarm_at"`
+	Priority    int32   `json:"priority"`
 	ReadAt      *string `json:"read_at"`
 	SiteId      int64   `json:"site_id"`
 	SnapshotUrl *string `json:"snapshot_url"`
 	UpdatedAt   string  `json:"updated_at"`
+	VendorEvent *string `json:"vendor_event"`
 	VideoUrl    *string `json:"video_url"`
+	WalkTestId  *int64  `json:"walk_test_id"`
 }
 
 type PublicAlarmInsert struct {
@@ -167,13 +202,18 @@ type PublicAlarmInsert struct {
 	CameraId    int64   `json:"camera_id"`
 	CreatedAt   *string `json:"created_at"`
 	Id          *int64  `json:"id"`
+	IncidentId  *int64  `json:"incident_id"`
 	IsRead      *bool   `json:"is_read"`
+	IsTest      *bool   `json:"is_test"`
 	LastAlarmAt *string `json:"last_alarm_at"`
+	Priority    *int32  `json:"priority"`
 	ReadAt      *string `json:"read_at"`
 	SiteId      int64   `json:"site_id"`
 	SnapshotUrl *string `json:"snapshot_url"`
 	UpdatedAt   *string `json:"updated_at"`
+	VendorEvent *string `json:"vendor_event"`
 	VideoUrl    *string `json:"video_url"`
+	WalkTestId  *int64  `json:"walk_test_id"`
 }
 
 type PublicAlarmUpdate struct {
@@ -183,11 +223,274 @@ type PublicAlarmUpdate struct {
 	CameraId    *int64  `json:"camera_id"`
 	CreatedAt   *string `json:"created_at"`
 	Id          *int64  `json:"id"`
+	IncidentId  *int64  `json:"incident_id"`
 	IsRead      *bool   `json:"is_read"`
+	IsTest      *bool   `json:"is_test"`
 	LastAlarmAt *string `json:"last_alarm_at"`
+	Priority    *int32  `json:"priority"`
 	ReadAt      *string `json:"read_at"`
 	SiteId      *int64  `json:"site_id"`
 	SnapshotUrl *string `json:"snapshot_url"`
 	UpdatedAt   *string `json:"updated_at"`
+	VendorEvent *string `json:"vendor_event"`
 	VideoUrl    *string `json:"video_url"`
+	WalkTestId  *int64  `json:"walk_test_id"`
+}
+
+type PublicIncidentSelect struct {
+	AlarmCount  int32       `json:"alarm_count"`
+	BridgeId    int64       `json:"bridge_id"`
+	CameraIds   []int64     `json:"camera_ids"`
+	ClipRequest interface{} `json:"clip_request"`
+	ClosedAt    *string     `json:"closed_at"`
+	CreatedAt   string      `json:"created_at"`
+	Id          int64       `json:"id"`
+	LastAlarmAt string      `json:"last_alarm_at"`
+	SiteId      int64       `json:"site_id"`
+	StartedAt   string      `json:"started_at"`
+	Status      string      `json:"status"`
+	Timeline    interface{} `json:"timeline"`
+	UpdatedAt   string      `json:"updated_at"`
+}
+
+type PublicIncidentInsert struct {
+	AlarmCount  *int32      `json:"alarm_count"`
+	BridgeId    int64       `json:"bridge_id"`
+	CameraIds   []int64     `json:"camera_ids"`
+	ClipRequest interface{} `json:"clip_request"`
+	ClosedAt    *string     `json:"closed_at"`
+	CreatedAt   *string     `json:"created_at"`
+	Id          *int64      `json:"id"`
+	LastAlarmAt *string     `json:"last_alarm_at"`
+	SiteId      int64       `json:"site_id"`
+	StartedAt   *string     `json:"started_at"`
+	Status      *string     `json:"status"`
+	Timeline    interface{} `json:"timeline"`
+	UpdatedAt   *string     `json:"updated_at"`
+}
+
+type PublicIncidentUpdate struct {
+	AlarmCount  *int32      `json:"alarm_count"`
+	BridgeId    *int64      `json:"bridge_id"`
+	CameraIds   []int64     `json:"camera_ids"`
+	ClipRequest interface{} `json:"clip_request"`
+	ClosedAt    *string     `json:"closed_at"`
+	CreatedAt   *string     `json:"created_at"`
+	Id          *int64      `json:"id"`
+	LastAlarmAt *string     `json:"last_alarm_at"`
+	SiteId      *int64      `json:"site_id"`
+	StartedAt   *string     `json:"started_at"`
+	Status      *string     `json:"status"`
+	Timeline    interface{} `json:"timeline"`
+	UpdatedAt   *string     `json:"updated_at"`
+}
+
+type PublicPanelSelect struct {
+	Account   string `json:"account"`
+	BridgeId  int64  `json:"bridge_id"`
+	CreatedAt string `json:"created_at"`
+	Id        int64  `json:"id"`
+	PanelName string `json:"panel_name"`
+	SiteId    int64  `json:"site_id"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type PublicPanelInsert struct {
+	Account   string  `json:"account"`
+	BridgeId  int64   `json:"bridge_id"`
+	CreatedAt *string `json:"created_at"`
+	Id        *int64  `json:"id"`
+	PanelName string  `json:"panel_name"`
+	SiteId    int64   `json:"site_id"`
+	UpdatedAt *string `json:"updated_at"`
+}
+
+type PublicPanelUpdate struct {
+	Account   *string `json:"account"`
+	BridgeId  *int64  `json:"bridge_id"`
+	CreatedAt *string `json:"created_at"`
+	Id        *int64  `json:"id"`
+	PanelName *string `json:"panel_name"`
+	SiteId    *int64  `json:"site_id"`
+	UpdatedAt *string `json:"updated_at"`
+}
+
+type PublicPanelZoneSelect struct {
+	CameraId  int64   `json:"camera_id"`
+	CreatedAt string  `json:"created_at"`
+	Id        int64   `json:"id"`
+	PanelId   int64   `json:"panel_id"`
+	UpdatedAt string  `json:"updated_at"`
+	Zone      int32   `json:"zone"`
+	ZoneName  *string `json:"zone_name"`
+}
+
+type PublicPanelZoneInsert struct {
+	CameraId  int64   `json:"camera_id"`
+	CreatedAt *string `json:"created_at"`
+	Id        *int64  `json:"id"`
+	PanelId   int64   `json:"panel_id"`
+	UpdatedAt *string `json:"updated_at"`
+	Zone      int32   `json:"zone"`
+	ZoneName  *string `json:"zone_name"`
+}
+
+type PublicPanelZoneUpdate struct {
+	CameraId  *int64  `json:"camera_id"`
+	CreatedAt *string `json:"created_at"`
+	Id        *int64  `json:"id"`
+	PanelId   *int64  `json:"panel_id"`
+	UpdatedAt *string `json:"updated_at"`
+	Zone      *int32  `json:"zone"`
+	ZoneName  *string `json:"zone_name"`
+}
+
+type PublicWalkTestSelect struct {
+	Alarms     interface{} `json:"alarms"`
+	BridgeId   int64       `json:"bridge_id"`
+	CameraIds  []int64     `json:"camera_ids"`
+	CreatedAt  string      `json:"created_at"`
+	ExpiresAt  string      `json:"expires_at"`
+	FinishedAt *string     `json:"finished_at"`
+	Id         int64       `json:"id"`
+	Report     interface{} `json:"report"`
+	SiteId     int64       `json:"site_id"`
+	StartedAt  string      `json:"started_at"`
+	Status     string      `json:"status"`
+	UpdatedAt  string      `json:"updated_at"`
+}
+
+type PublicWalkTestInsert struct {
+	Alarms     interface{} `json:"alarms"`
+	BridgeId   int64       `json:"bridge_id"`
+	CameraIds  []int64     `json:"camera_ids"`
+	CreatedAt  *string     `json:"created_at"`
+	ExpiresAt  string      `json:"expires_at"`
+	FinishedAt *string     `json:"finished_at"`
+	Id         *int64      `json:"id"`
+	Report     interface{} `json:"report"`
+	SiteId     int64       `json:"site_id"`
+	StartedAt  *string     `json:"started_at"`
+	Status     *string     `json:"status"`
+	UpdatedAt  *string     `json:"updated_at"`
+}
+
+type PublicWalkTestUpdate struct {
+	Alarms     interface{} `json:"alarms"`
+	BridgeId   *int64      `json:"bridge_id"`
+	CameraIds  []int64     `json:"camera_ids"`
+	CreatedAt  *string     `json:"created_at"`
+	ExpiresAt  *string     `json:"expires_at"`
+	FinishedAt *string     `json:"finished_at"`
+	Id         *int64      `json:"id"`
+	Report     interface{} `json:"report"`
+	SiteId     *int64      `json:"site_id"`
+	StartedAt  *string     `json:"started_at"`
+	Status     *string     `json:"status"`
+	UpdatedAt  *string     `json:"updated_at"`
+}
+
+type PublicPairingAttemptSelect struct {
+	CreatedAt string `json:"created_at"`
+	Id        int64  `json:"id"`
+	Succeeded bool   `json:"succeeded"`
+	UserId    string `json:"user_id"`
+}
+
+type PublicPairingAttemptInsert struct {
+	CreatedAt *string `json:"created_at"`
+	Id        *int64  `json:"id"`
+	Succeeded bool    `json:"succeeded"`
+	UserId    string  `json:"user_id"`
+}
+
+type PublicPairingAttemptUpdate struct {
+	CreatedAt *string `json:"created_at"`
+	Id        *int64  `json:"id"`
+	Succeeded *bool   `json:"succeeded"`
+	UserId    *string `json:"user_id"`
+}
+
+type PublicBridgeConfigSelect struct {
+	AppliedAt *string     `json:"applied_at"`
+	BridgeId  int64       `json:"bridge_id"`
+	Config    interface{} `json:"config"`
+	CreatedAt string      `json:"created_at"`
+	Error     *string     `json:"error"`
+	Status    string      `json:"status"`
+	UpdatedAt string      `json:"updated_at"`
+}
+
+type PublicBridgeConfigInsert struct {
+	AppliedAt *string     `json:"applied_at"`
+	BridgeId  int64       `json:"bridge_id"`
+	Config    interface{} `json:"config"`
+	CreatedAt *string     `json:"created_at"`
+	Error     *string     `json:"error"`
+	Status    *string     `json:"status"`
+	UpdatedAt *string     `json:"updated_at"`
+}
+
+type PublicBridgeConfigUpdate struct {
+	AppliedAt *string     `json:"applied_at"`
+	BridgeId  *int64      `json:"bridge_id"`
+	Config    interface{} `json:"config"`
+	CreatedAt *string     `json:"created_at"`
+	Error     *string     `json:"error"`
+	Status    *string     `json:"status"`
+	UpdatedAt *string     `json:"updated_at"`
+}
+
+type PublicSiteMemberSelect struct {
+	CreatedAt string `json:"created_at"`
+	Role      string `json:"role"`
+	SiteId    int64  `json:"site_id"`
+	UpdatedAt string `json:"updated_at"`
+	UserId    string `json:"user_id"`
+}
+
+type PublicSiteMemberInsert struct {
+	CreatedAt *string `json:"created_at"`
+	Role      *string `json:"role"`
+	SiteId    int64   `json:"site_id"`
+	UpdatedAt *string `json:"updated_at"`
+	UserId    string  `json:"user_id"`
+}
+
+type PublicSiteMemberUpdate struct {
+	CreatedAt *string `json:"created_at"`
+	Role      *string `json:"role"`
+	SiteId    *int64  `json:"site_id"`
+	UpdatedAt *string `json:"updated_at"`
+	UserId    *string `json:"user_id"`
+}
+
+type PublicBridgeRequestSelect struct {
+	BridgeId        int64  `json:"bridge_id"`
+	CreatedAt       string `json:"created_at"`
+	RequestBodyHash string `json:"request_body_hash"`
+	RequestId       string `json:"request_id"`
+	RequestMethod   string `json:"request_method"`
+	RequestPath     string `json:"request_path"`
+	UserId          string `json:"user_id"`
+}
+
+type PublicBridgeRequestInsert struct {
+	BridgeId        int64   `json:"bridge_id"`
+	CreatedAt       *string `json:"created_at"`
+	RequestBodyHash string  `json:"request_body_hash"`
+	RequestId       string  `json:"request_id"`
+	RequestMethod   string  `json:"request_method"`
+	RequestPath     string  `json:"request_path"`
+	UserId          *string `json:"user_id"`
+}
+
+type PublicBridgeRequestUpdate struct {
+	BridgeId        *int64  `json:"bridge_id"`
+	CreatedAt       *string `json:"created_at"`
+	RequestBodyHash *string `json:"request_body_hash"`
+	RequestId       *string `json:"request_id"`
+	RequestMethod   *string `json:"request_method"`
+	RequestPath     *string `json:"request_path"`
+	UserId          *string `json:"user_id"`
 }
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package heartbeat
+
+import (
+	"syscall"
+)
+
+func getDiskUsage(path string) (*diskUsage, error) {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(path, &st)
+	if err != nil {
+		return nil, err
+	}
+
+	bsize := uint64(st.Bsize) //nolint:gosec,unconvert
+	total := st.Blocks * bsize
+	free := st.Bavail * bsize
+
+	return &diskUsage{
+		Total: total,
+		Free:  free,
+		Used:  total - st.Bfree*bsize,
+	}, nil
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package heartbeat
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+func getDiskUsage(path string) (*diskUsage, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var free, total, totalFree uint64
+	err = windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree)
+	if err != nil {
+		return nil, err
+	}
+
+	return &diskUsage{
+		Total: total,
+		Free:  free,
+		Used:  total - totalFree,
+	}, nil
+}
@@ -0,0 +1,253 @@
+// Package heartbeat contains the heartbeat reporter, that updates the health of the bridge and of its cameras.
+package heartbeat
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+// uptime is computed from the start of the process, since Heartbeat is
+// recreated when the configuration changes.
+var processStart = time.Now()
+
+type diskUsage struct {
+	Path  string `json:"path"`
+	Total uint64 `json:"total"`
+	Free  uint64 `json:"free"`
+	Used  uint64 `json:"used"`
+}
+
+type cameraStats struct {
+	CameraID      int64      `json:"camera_id"`
+	Path          string     `json:"path"`
+	Ready         bool       `json:"ready"`
+	ReadyTime     *time.Time `json:"ready_time"`
+	Tracks        []string   `json:"tracks"`
+	BytesReceived uint64     `json:"bytes_received"`
+	BytesSent     uint64     `json:"bytes_sent"`
+	Readers       int        `json:"readers"`
+}
+
+type streamStats struct {
+	Total   int           `json:"total"`
+	Ready   int           `json:"ready"`
+	Cameras []cameraStats `json:"cameras"`
+}
+
+// status is stored into the bridge row.
+type status struct {
+	Version string       `json:"version"`
+	Uptime  int64        `json:"uptime"` // seconds
+	Disk    *diskUsage   `json:"disk"`
+	Streams *streamStats `json:"streams"`
+}
+
+// report is the result of a check.
+type report struct {
+	status      *status
+	readyIDs    []int64
+	notReadyIDs []int64
+	lastChecked time.Time
+}
+
+// newReport computes the health of cameras from the readiness of their paths.
+func newReport(cameraIDs map[string]int64, paths *defs.APIPathList, now time.Time) *report {
+	byName := make(map[string]*defs.APIPath)
+	if paths != nil {
+		for _, pa := range paths.Items {
+			byName[pa.Name] = pa
+		}
+	}
+
+	names := make([]string, 0, len(cameraIDs))
+	for name := range cameraIDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := &report{
+		status: &status{
+			Streams: &streamStats{
+				Cameras: []cameraStats{},
+			},
+		},
+		readyIDs:    []int64{},
+		notReadyIDs: []int64{},
+		lastChecked: now,
+	}
+
+	for _, name := range names {
+		id := cameraIDs[name]
+		st := cameraStats{
+			CameraID: id,
+			Path:     name,
+			Tracks:   []string{},
+		}
+
+		if pa, ok := byName[name]; ok {
+			st.Ready = pa.Ready
+			st.ReadyTime = pa.ReadyTime
+			st.Tracks = pa.Tracks
+			st.BytesReceived = pa.BytesReceived
+			st.BytesSent = pa.BytesSent
+			st.Readers = len(pa.Readers)
+		}
+
+		r.status.Streams.Cameras = append(r.status.Streams.Cameras, st)
+
+		if st.Ready {
+			r.readyIDs = append(r.readyIDs, id)
+		} else {
+			r.notReadyIDs = append(r.notReadyIDs, id)
+		}
+	}
+
+	r.status.Streams.Total = len(names)
+	r.status.Streams.Ready = len(r.readyIDs)
+
+	return r
+}
+
+type heartbeatParent interface {
+	logger.Writer
+}
+
+type heartbeatControlPlane interface {
+	UpdateBridgeStatus(bridgeID int64, checkedAt time.Time, version string, status interface{}) error
+	UpdateCameraHealth(bridgeID int64, cameraIDs []int64, healthy bool, checkedAt time.Time) error
+}
+
+type heartbeatConfDB interface {
+	CameraIDs() map[string]int64
+}
+
+// Heartbeat periodically updates last_checked_at and healthy of the bridge
+// and of its cameras.
+// Updates are batched: the bridge row is updated with a single request,
+// cameras are updated with a request for each state.
+type Heartbeat struct {
+	Period  conf.Duration
+	Version string
+	// directory whose disk usage is reported.
+	DiskPath     string
+	BridgeID     int64
+	ControlPlane heartbeatControlPlane
+	ConfDB       heartbeatConfDB
+	PathManager  defs.APIPathManager
+	Parent       heartbeatParent
+
+	failing bool
+
+	terminate chan struct{}
+	done      chan struct{}
+}
+
+// Initialize initializes Heartbeat.
+func (h *Heartbeat) Initialize() error {
+	h.terminate = make(chan struct{})
+	h.done = make(chan struct{})
+
+	go h.run()
+
+	h.Log(logger.Info, "reporting every %v", time.Duration(h.Period))
+
+	return nil
+}
+
+// Close closes Heartbeat.
+func (h *Heartbeat) Close() {
+	close(h.terminate)
+	<-h.done
+}
+
+// Log implements logger.Writer.
+func (h *Heartbeat) Log(level logger.Level, format string, args ...interface{}) {
+	h.Parent.Log(level, "[heartbeat] "+format, args...)
+}
+
+func (h *Heartbeat) run() {
+	defer close(h.done)
+
+	h.beat()
+
+	t := time.NewTicker(time.Duration(h.Period))
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			h.beat()
+
+		case <-h.terminate:
+			return
+		}
+	}
+}
+
+func (h *Heartbeat) beat() {
+	err := h.send(h.check())
+
+	// log only changes of state, in order not to flood logs when offline
+	if err != nil {
+		if !h.failing {
+			h.Log(logger.Warn, "unable to send heartbeat: %v", err)
+			h.failing = true
+		}
+		return
+	}
+
+	if h.failing {
+		h.Log(logger.Info, "heartbeat restored")
+		h.failing = false
+	}
+}
+
+func (h *Heartbeat) check() *report {
+	paths, err := h.PathManager.APIPathsList()
+	if err != nil {
+		h.Log(logger.Warn, "unable to list paths: %v", err)
+	}
+
+	r := newReport(h.ConfDB.CameraIDs(), paths, time.Now())
+	r.status.Version = h.Version
+	r.status.Uptime = int64(time.Since(processStart).Seconds())
+
+	disk, err := getDiskUsage(h.DiskPath)
+	if err == nil {
+		disk.Path = h.DiskPath
+		r.status.Disk = disk
+	}
+
+	return r
+}
+
+func (h *Heartbeat) send(r *report) error {
+	err := h.ControlPlane.UpdateBridgeStatus(h.BridgeID, r.lastChecked, r.status.Version, r.status)
+	if err != nil {
+		return fmt.Errorf("unable to update bridge: %w", err)
+	}
+
+	for _, group := range []struct {
+		healthy bool
+		ids     []int64
+	}{
+		{true, r.readyIDs},
+		{false, r.notReadyIDs},
+	} {
+		if len(group.ids) == 0 {
+			continue
+		}
+
+		err = h.ControlPlane.UpdateCameraHealth(h.BridgeID, group.ids, group.healthy, r.lastChecked)
+		if err != nil {
+			return fmt.Errorf("unable to update cameras: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package heartbeat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+)
+
+func TestNewReport(t *testing.T) {
+	readyTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := readyTime.Add(time.Hour)
+
+	r := newReport(map[string]int64{
+		"192.168.0.10": 1,
+		"192.168.0.11": 2,
+		"192.168.0.12": 3,
+	}, &defs.APIPathList{
+		Items: []*defs.APIPath{
+			{
+				Name:          "192.168.0.10",
+				Ready:         true,
+				ReadyTime:     &readyTime,
+				Tracks:        []string{"H264"},
+				BytesReceived: 1000,
+				Readers:       []defs.APIPathSourceOrReader{{Type: "rtspSession", ID: "1"}},
+			},
+			{
+				Name: "192.168.0.11",
+			},
+			{
+				Name:  "static",
+				Ready: true,
+			},
+		},
+	}, now)
+
+	require.Equal(t, []int64{1}, r.readyIDs)
+	require.Equal(t, []int64{2, 3}, r.notReadyIDs)
+	require.Equal(t, now, r.lastChecked)
+	require.Equal(t, &streamStats{
+		Total: 3,
+		Ready: 1,
+		Cameras: []cameraStats{
+			{
+				CameraID:      1,
+				Path:          "192.168.0.10",
+				Ready:         true,
+				ReadyTime:     &readyTime,
+				Tracks:        []string{"H264"},
+				BytesReceived: 1000,
+				Readers:       1,
+			},
+			{
+				CameraID: 2,
+				Path:     "192.168.0.11",
+			},
+			{
+				CameraID: 3,
+				Path:     "192.168.0.12",
+				Tracks:   []string{},
+			},
+		},
+	}, r.status.Streams)
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-heartbeat")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	disk, err := getDiskUsage(dir)
+	require.NoError(t, err)
+	require.NotZero(t, disk.Total)
+	require.LessOrEqual(t, disk.Free, disk.Total)
+	require.LessOrEqual(t, disk.Used, disk.Total)
+}
+
+func TestSend(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-heartbeat")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cp := &controlplane.Local{Directory: dir, SiteID: 1}
+	err = cp.Initialize()
+	require.NoError(t, err)
+
+	bridge, err := cp.RegisterBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "camera.json"), []byte(`[
+		{"id": 1, "ip_address": "192.168.0.10", "healthy": false},
+		{"id": 2, "ip_address": "192.168.0.11", "healthy": true}
+	]`), 0o644)
+	require.NoError(t, err)
+
+	h := &Heartbeat{
+		BridgeID:     bridge.Id,
+		ControlPlane: cp,
+	}
+
+	r := newReport(map[string]int64{
+		"192.168.0.10": 1,
+		"192.168.0.11": 2,
+	}, &defs.APIPathList{
+		Items: []*defs.APIPath{{Name: "192.168.0.10", Ready: true}},
+	}, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	r.status.Version = "v1.0.0"
+
+	err = h.send(r)
+	require.NoError(t, err)
+
+	bridge, err = cp.FetchBridge("0b3f9a2c-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	require.Equal(t, "v1.0.0", *bridge.Version)
+	require.Equal(t, "2024-05-01T10:00:00Z", bridge.LastCheckedAt)
+
+	cameras, err := cp.ListCameras(bridge.Id)
+	require.NoError(t, err)
+	require.True(t, cameras[0].Healthy)
+	require.False(t, cameras[1].Healthy)
+}
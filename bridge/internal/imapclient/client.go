@@ -0,0 +1,70 @@
+// Package imapclient contains a client that fetches alarm emails from IMAP mailboxes.
+package imapclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/servers/smtp"
+)
+
+type clientParent interface {
+	logger.Writer
+}
+
+// Client fetches messages from IMAP mailboxes and forwards them to the alarm manager.
+// Messages are fetched as soon as the server notifies them through IDLE,
+// or periodically when IDLE is not supported.
+type Client struct {
+	Mailboxes  conf.IMAPMailboxes
+	PollPeriod conf.Duration
+	Parent     clientParent
+
+	ctx       context.Context
+	ctxCancel func()
+	wg        sync.WaitGroup
+
+	// out
+	ChMail *chan smtp.Mail
+}
+
+// Initialize initializes the client.
+func (c *Client) Initialize() error {
+	c.ctx, c.ctxCancel = context.WithCancel(context.Background())
+
+	for _, mc := range c.Mailboxes {
+		m := &mailbox{
+			conf:       mc,
+			pollPeriod: time.Duration(c.PollPeriod),
+			parent:     c,
+			chMail:     c.ChMail,
+			ctx:        c.ctx,
+		}
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			m.run()
+		}()
+	}
+
+	c.Log(logger.Info, "fetching messages from %d mailboxes", len(c.Mailboxes))
+
+	return nil
+}
+
+// Log implements logger.Writer.
+func (c *Client) Log(level logger.Level, format string, args ...interface{}) {
+	c.Parent.Log(level, "[IMAP] "+format, args...)
+}
+
+// Close closes the client.
+func (c *Client) Close() {
+	c.Log(logger.Info, "client is closing")
+
+	c.ctxCancel()
+	c.wg.Wait()
+}
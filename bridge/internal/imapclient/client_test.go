@@ -0,0 +1,356 @@
+package imapclient
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/servers/smtp"
+	"github.com/stretchr/testify/require"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+type testMessage struct {
+	uid     uint32
+	seen    bool
+	content string
+}
+
+// testServer is a local IMAP stand-in that supports the commands used by the client.
+type testServer struct {
+	idle bool
+	move bool
+
+	ln      net.Listener
+	mutex   sync.Mutex
+	boxes   map[string][]*testMessage
+	nextUID uint32
+	chNew   chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newTestServer(t *testing.T, idle bool, move bool) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &testServer{
+		idle:    idle,
+		move:    move,
+		ln:      ln,
+		boxes:   map[string][]*testMessage{"INBOX": {}},
+		nextUID: 1,
+		chNew:   make(chan struct{}, 1),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+func (s *testServer) close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *testServer) deliver(content string) {
+	s.mutex.Lock()
+	s.boxes["INBOX"] = append(s.boxes["INBOX"], &testMessage{uid: s.nextUID, content: content})
+	s.nextUID++
+	s.mutex.Unlock()
+
+	select {
+	case s.chNew <- struct{}{}:
+	default:
+	}
+}
+
+func (s *testServer) count(box string, seen bool) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n := 0
+	for _, m := range s.boxes[box] {
+		if m.seen == seen {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *testServer) run() {
+	defer s.wg.Done()
+
+	for {
+		nconn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer nconn.Close()
+			s.runConn(nconn)
+		}()
+	}
+}
+
+func (s *testServer) runConn(nconn net.Conn) {
+	lines := make(chan string)
+
+	go func() {
+		defer close(lines)
+		br := bufio.NewReader(nconn)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+
+	write := func(format string, args ...interface{}) {
+		fmt.Fprintf(nconn, format+"\r\n", args...)
+	}
+
+	write("* OK IMAP4rev1 ready")
+
+	for line := range lines {
+		tag, cmd, _ := strings.Cut(line, " ")
+		fields := strings.Fields(cmd)
+
+		switch strings.ToUpper(fields[0]) {
+		case "CAPABILITY":
+			capa := "IMAP4rev1"
+			if s.idle {
+				capa += " IDLE"
+			}
+			if s.move {
+				capa += " MOVE"
+			}
+			write("* CAPABILITY %s", capa)
+			write("%s OK done", tag)
+
+		case "LOGIN":
+			if fields[1] != `"cam"` || fields[2] != `"secret"` {
+				write("%s NO invalid credentials", tag)
+				continue
+			}
+			write("%s OK logged in", tag)
+
+		case "SELECT":
+			s.mutex.Lock()
+			write("* %d EXISTS", len(s.boxes["INBOX"]))
+			s.mutex.Unlock()
+			write("%s OK [READ-WRITE] selected", tag)
+
+		case "UID":
+			s.handleUID(tag, fields[1:], write)
+
+		case "IDLE":
+			write("+ idling")
+
+		idle:
+			for {
+				select {
+				case l, ok := <-lines:
+					if !ok {
+						return
+					}
+					if l == "DONE" {
+						write("%s OK idle done", tag)
+						break idle
+					}
+
+				case <-s.chNew:
+					s.mutex.Lock()
+					write("* %d EXISTS", len(s.boxes["INBOX"]))
+					s.mutex.Unlock()
+				}
+			}
+
+		case "LOGOUT":
+			write("* BYE")
+			write("%s OK bye", tag)
+			return
+
+		default:
+			write("%s BAD unknown command", tag)
+		}
+	}
+}
+
+func (s *testServer) handleUID(tag string, fields []string, write func(string, ...interface{})) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	find := func(v string) (int, *testMessage) {
+		uid, _ := strconv.ParseUint(v, 10, 32)
+		for i, m := range s.boxes["INBOX"] {
+			if m.uid == uint32(uid) {
+				return i, m
+			}
+		}
+		return 0, nil
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "SEARCH":
+		var uids []string
+		for _, m := range s.boxes["INBOX"] {
+			if fields[1] == "ALL" || !m.seen {
+				uids = append(uids, strconv.FormatUint(uint64(m.uid), 10))
+			}
+		}
+		write("* SEARCH %s", strings.Join(uids, " "))
+		write("%s OK search done", tag)
+
+	case "FETCH":
+		i, m := find(fields[1])
+		if m == nil {
+			write("%s OK fetch done", tag)
+			return
+		}
+		write("* %d FETCH (UID %d BODY[] {%d}\r\n%s)", i+1, m.uid, len(m.content), m.content)
+		write("%s OK fetch done", tag)
+
+	case "STORE":
+		_, m := find(fields[1])
+		if m != nil {
+			m.seen = true
+		}
+		write("%s OK store done", tag)
+
+	case "MOVE":
+		i, m := find(fields[1])
+		if m != nil {
+			dest := strings.Trim(fields[2], `"`)
+			s.boxes["INBOX"] = append(s.boxes["INBOX"][:i], s.boxes["INBOX"][i+1:]...)
+			s.boxes[dest] = append(s.boxes[dest], m)
+		}
+		write("%s OK move done", tag)
+
+	default:
+		write("%s BAD unknown command", tag)
+	}
+}
+
+func testMail(subject string) string {
+	return "From: Camera <camera@example.com>\r\n" +
+		"To: alarms@example.com\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Alarm Event: Motion Detection\r\n" +
+		"Alarm Input Channel: 1\r\n"
+}
+
+func receiveMail(t *testing.T, ch chan smtp.Mail) smtp.Mail {
+	select {
+	case m := <-ch:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for mail")
+		return smtp.Mail{}
+	}
+}
+
+func TestClientIdleMove(t *testing.T) {
+	s := newTestServer(t, true, true)
+	defer s.close()
+
+	s.deliver(testMail("first"))
+
+	chMail := make(chan smtp.Mail, 10)
+
+	c := &Client{
+		Mailboxes: conf.IMAPMailboxes{{
+			Address:  s.ln.Addr().String(),
+			Username: "cam",
+			Password: "secret",
+			MoveTo:   "Processed",
+			CameraIP: "192.168.1.10",
+		}},
+		PollPeriod: conf.Duration(time.Hour),
+		Parent:     nilLogger{},
+		ChMail:     &chMail,
+	}
+	err := c.Initialize()
+	require.NoError(t, err)
+	defer c.Close()
+
+	m := receiveMail(t, chMail)
+	require.Equal(t, "camera@example.com", m.From)
+	require.Equal(t, "192.168.1.10", m.FromIP)
+	require.Equal(t, []string{"alarms@example.com"}, m.To)
+	require.Equal(t, "Alarm Event: Motion Detection\r\nAlarm Input Channel: 1\r\n", string(m.Parts[0].Content))
+
+	require.Eventually(t, func() bool {
+		return s.count("Processed", false) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// new messages are notified through IDLE, since polling is disabled
+	s.deliver(testMail("second"))
+
+	receiveMail(t, chMail)
+
+	require.Eventually(t, func() bool {
+		return s.count("Processed", false) == 2 && s.count("INBOX", false) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestClientPollSeen(t *testing.T) {
+	s := newTestServer(t, false, false)
+	defer s.close()
+
+	s.deliver(testMail("first"))
+
+	chMail := make(chan smtp.Mail, 10)
+
+	c := &Client{
+		Mailboxes: conf.IMAPMailboxes{{
+			Address:  s.ln.Addr().String(),
+			Username: "cam",
+			Password: "secret",
+		}},
+		PollPeriod: conf.Duration(50 * time.Millisecond),
+		Parent:     nilLogger{},
+		ChMail:     &chMail,
+	}
+	err := c.Initialize()
+	require.NoError(t, err)
+	defer c.Close()
+
+	receiveMail(t, chMail)
+
+	require.Eventually(t, func() bool {
+		return s.count("INBOX", true) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.deliver(testMail("second"))
+
+	receiveMail(t, chMail)
+
+	require.Eventually(t, func() bool {
+		return s.count("INBOX", true) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// processed messages are not fetched twice
+	select {
+	case <-chMail:
+		t.Fatal("unexpected mail")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,241 @@
+package imapclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/protocols/imap"
+	"github.com/kaonmir/mini-chekt/internal/servers/smtp"
+)
+
+const (
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+	retryPeriod  = 10 * time.Second
+
+	// RFC 2177 recommends to re-issue IDLE at least every 29 minutes.
+	idleTimeout = 25 * time.Minute
+)
+
+type mailboxParent interface {
+	logger.Writer
+}
+
+// mailbox fetches messages from a single mailbox.
+type mailbox struct {
+	conf         conf.IMAPMailbox
+	pollPeriod   time.Duration
+	parent       mailboxParent
+	chMail       *chan smtp.Mail
+	ctx          context.Context
+	processedIDs map[uint32]struct{}
+}
+
+func (m *mailbox) Log(level logger.Level, format string, args ...interface{}) {
+	m.parent.Log(level, "[%s] "+format, append([]interface{}{m.conf.Username}, args...)...)
+}
+
+func (m *mailbox) name() string {
+	if m.conf.Mailbox == "" {
+		return "INBOX"
+	}
+	return m.conf.Mailbox
+}
+
+func (m *mailbox) run() {
+	for {
+		err := m.runSession()
+
+		select {
+		case <-m.ctx.Done():
+			return
+		default:
+		}
+
+		m.Log(logger.Warn, "%v", err)
+
+		select {
+		case <-time.After(retryPeriod):
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *mailbox) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	if m.conf.Encryption {
+		host, _, _ := net.SplitHostPort(m.conf.Address)
+		td := &tls.Dialer{
+			NetDialer: dialer,
+			Config:    &tls.Config{ServerName: host},
+		}
+		return td.DialContext(m.ctx, "tcp", m.conf.Address)
+	}
+
+	return dialer.DialContext(m.ctx, "tcp", m.conf.Address)
+}
+
+func (m *mailbox) runSession() error {
+	nconn, err := m.dial()
+	if err != nil {
+		return err
+	}
+	defer nconn.Close()
+
+	// close the connection on shutdown, in order to unblock reads
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-m.ctx.Done():
+			nconn.Close()
+		case <-done:
+		}
+	}()
+
+	conn, err := imap.NewConn(nconn, writeTimeout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.Capability()
+	if err != nil {
+		return err
+	}
+
+	err = conn.Login(m.conf.Username, m.conf.Password)
+	if err != nil {
+		return err
+	}
+
+	err = conn.Select(m.name())
+	if err != nil {
+		return err
+	}
+
+	useIdle := conn.HasCapability("IDLE")
+
+	if useIdle {
+		m.Log(logger.Info, "connected to %s, waiting for messages with IDLE", m.conf.Address)
+	} else {
+		m.Log(logger.Info, "connected to %s, polling messages every %v", m.conf.Address, m.pollPeriod)
+	}
+
+	// UIDs are valid until the mailbox is selected again
+	m.processedIDs = make(map[uint32]struct{})
+
+	for {
+		err = m.fetch(conn)
+		if err != nil {
+			return err
+		}
+
+		if useIdle {
+			_, err = conn.Idle(m.ctx.Done(), idleTimeout)
+			if err != nil {
+				return err
+			}
+		} else {
+			select {
+			case <-time.After(m.pollPeriod):
+			case <-m.ctx.Done():
+				return fmt.Errorf("terminated")
+			}
+		}
+	}
+}
+
+// fetch fetches new messages and marks them as processed.
+func (m *mailbox) fetch(conn *imap.Conn) error {
+	// when messages are moved, every message left in the mailbox is new
+	criteria := "UNSEEN"
+	if m.conf.MoveTo != "" {
+		criteria = "ALL"
+	}
+
+	uids, err := conn.UIDSearch(criteria)
+	if err != nil {
+		return err
+	}
+
+	for _, uid := range uids {
+		if _, ok := m.processedIDs[uid]; ok {
+			continue
+		}
+
+		buf, err := conn.UIDFetchBody(uid)
+		if err != nil {
+			return err
+		}
+
+		m.Log(logger.Debug, "fetched message %d (%d bytes)", uid, len(buf))
+
+		select {
+		case *m.chMail <- m.toMail(buf):
+		case <-m.ctx.Done():
+			return fmt.Errorf("terminated")
+		}
+
+		m.processedIDs[uid] = struct{}{}
+
+		if m.conf.MoveTo != "" {
+			err = conn.UIDMove(uid, m.conf.MoveTo)
+		} else {
+			err = conn.UIDMarkSeen(uid)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to mark message %d as processed: %w", uid, err)
+		}
+	}
+
+	return nil
+}
+
+// toMail converts a message into the same structure produced by the SMTP server.
+func (m *mailbox) toMail(buf []byte) smtp.Mail {
+	ret := smtp.Mail{
+		FromIP:  m.conf.CameraIP,
+		Content: buf,
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(buf))
+	if err == nil {
+		if from, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+			ret.From = from.Address
+		}
+
+		if to, err := msg.Header.AddressList("To"); err == nil {
+			for _, addr := range to {
+				ret.To = append(ret.To, addr.Address)
+			}
+		}
+
+		ret.Subject = smtp.DecodeHeader(msg.Header.Get("Subject"))
+	}
+
+	parts, err := smtp.ParseMultipartEmail(buf)
+	if err != nil {
+		m.Log(logger.Warn, "failed to parse multipart email: %v", err)
+		// Create a simple text part as fallback
+		parts = []smtp.EmailPart{
+			{
+				ContentType: "text/plain",
+				Content:     buf,
+				Headers:     make(map[string]string),
+			},
+		}
+	}
+	ret.Parts = parts
+
+	return ret
+}
@@ -0,0 +1,132 @@
+// Package metadatareader contains the ONVIF metadata reader.
+package metadatareader
+
+import (
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/protocols/onvifmeta"
+	"github.com/kaonmir/mini-chekt/internal/stream"
+	"github.com/kaonmir/mini-chekt/internal/unit"
+)
+
+// Event contains the analytics of a metadata document.
+type Event struct {
+	PathName string
+	// PTS of the document, on the same timeline of the video of the path.
+	PTS time.Duration
+	// NTP timestamp of the document.
+	NTP           time.Time
+	Frames        []onvifmeta.Frame
+	Notifications []onvifmeta.Notification
+}
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+// Reader reads the ONVIF metadata track of a path and routes its events.
+type Reader struct {
+	PathName string
+	Stream   *stream.Stream
+	Media    *description.Media
+	Format   format.Format
+	ChEvent  *chan Event
+	Parent   logger.Writer
+
+	reassembler onvifmeta.Reassembler
+
+	terminate chan struct{}
+	done      chan struct{}
+}
+
+// Initialize initializes Reader.
+func (r *Reader) Initialize() {
+	r.terminate = make(chan struct{})
+	r.done = make(chan struct{})
+
+	r.Stream.AddReader(r, r.Media, r.Format, r.onUnit)
+	r.Stream.StartReader(r)
+
+	r.Log(logger.Info, "reading ONVIF metadata")
+
+	go r.run()
+}
+
+// Log implements logger.Writer.
+func (r *Reader) Log(level logger.Level, format string, args ...interface{}) {
+	r.Parent.Log(level, "[metadata reader] "+format, args...)
+}
+
+// Close closes the Reader.
+func (r *Reader) Close() {
+	close(r.terminate)
+	<-r.done
+}
+
+func (r *Reader) run() {
+	defer close(r.done)
+
+	select {
+	case err := <-r.Stream.ReaderError(r):
+		r.Log(logger.Error, err.Error())
+
+	case <-r.terminate:
+	}
+
+	r.Stream.RemoveReader(r)
+}
+
+func (r *Reader) onUnit(u unit.Unit) error {
+	for _, pkt := range u.GetRTPPackets() {
+		doc, err := r.reassembler.Push(pkt)
+		if err != nil {
+			r.Log(logger.Warn, "%v", err)
+			continue
+		}
+		if doc == nil {
+			continue
+		}
+
+		ms, err := onvifmeta.Unmarshal(doc)
+		if err != nil {
+			r.Log(logger.Warn, "%v", err)
+			continue
+		}
+
+		r.route(ms, u)
+	}
+
+	return nil
+}
+
+func (r *Reader) route(ms *onvifmeta.MetadataStream, u unit.Unit) {
+	ev := Event{
+		PathName:      r.PathName,
+		PTS:           time.Duration(multiplyAndDivide(u.GetPTS(), int64(time.Second), int64(r.Format.ClockRate()))),
+		NTP:           u.GetNTP(),
+		Notifications: ms.Notifications,
+	}
+
+	// frames are sent continuously, keep only the ones with objects
+	for _, f := range ms.Frames {
+		if len(f.Objects) != 0 {
+			ev.Frames = append(ev.Frames, f)
+		}
+	}
+
+	if len(ev.Notifications) == 0 && len(ev.Frames) == 0 {
+		return
+	}
+
+	select {
+	case *r.ChEvent <- ev:
+	default:
+		r.Log(logger.Warn, "Channel is full, dropping metadata event")
+	}
+}
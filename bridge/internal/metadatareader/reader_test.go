@@ -0,0 +1,108 @@
+package metadatareader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/stream"
+	"github.com/kaonmir/mini-chekt/internal/test"
+)
+
+func TestReader(t *testing.T) {
+	forma := &format.Generic{
+		PayloadTyp: 107,
+		RTPMa:      "vnd.onvif.metadata/90000",
+	}
+	err := forma.Init()
+	require.NoError(t, err)
+
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{test.FormatH264},
+		},
+		{
+			Type:    description.MediaTypeApplication,
+			Formats: []format.Format{forma},
+		},
+	}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:    512,
+		RTPMaxPayloadSize: 1450,
+		Desc:              desc,
+		Parent:            test.NilLogger,
+	}
+	err = strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	chEvent := make(chan Event, 1)
+
+	r := &Reader{
+		PathName: "192.168.0.10",
+		Stream:   strm,
+		Media:    desc.Medias[1],
+		Format:   forma,
+		ChEvent:  &chEvent,
+		Parent:   test.NilLogger,
+	}
+	r.Initialize()
+	defer r.Close()
+
+	ntp := time.Date(2025, 3, 10, 1, 0, 0, 0, time.UTC)
+
+	docs := []string{
+		// frame without objects
+		`<tt:MetadataStream xmlns:tt="http://www.onvif.org/ver10/schema">` +
+			`<tt:VideoAnalytics><tt:Frame UtcTime="2025-03-10T01:00:00Z"/></tt:VideoAnalytics>` +
+			`</tt:MetadataStream>`,
+		`<tt:MetadataStream xmlns:tt="http://www.onvif.org/ver10/schema"` +
+			` xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2">` +
+			`<tt:Event><wsnt:NotificationMessage>` +
+			`<wsnt:Topic>tns1:VideoSource/MotionAlarm</wsnt:Topic>` +
+			`<wsnt:Message><tt:Message UtcTime="2025-03-10T01:00:01Z" PropertyOperation="Changed">` +
+			`<tt:Data><tt:SimpleItem Name="State" Value="true"/></tt:Data>` +
+			`</tt:Message></wsnt:Message>` +
+			`</wsnt:NotificationMessage></tt:Event>` +
+			`</tt:MetadataStream>`,
+	}
+
+	seq := uint16(0)
+	for i, doc := range docs {
+		pts := int64(i) * 90000
+		half := len(doc) / 2
+
+		for j, payload := range []string{doc[:half], doc[half:]} {
+			seq++
+			strm.WriteRTPPacket(desc.Medias[1], forma, &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    107,
+					SequenceNumber: seq,
+					Timestamp:      uint32(pts),
+					Marker:         j == 1,
+				},
+				Payload: []byte(payload),
+			}, ntp.Add(time.Duration(i)*time.Second), pts)
+		}
+	}
+
+	select {
+	case ev := <-chEvent:
+		require.Equal(t, "192.168.0.10", ev.PathName)
+		require.Equal(t, 1*time.Second, ev.PTS)
+		require.Equal(t, ntp.Add(1*time.Second), ev.NTP)
+		require.Empty(t, ev.Frames)
+		require.Len(t, ev.Notifications, 1)
+		require.Equal(t, "VideoSource/MotionAlarm", ev.Notifications[0].TopicPath())
+
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out")
+	}
+}
@@ -90,6 +90,7 @@ type Metrics struct {
 	rtmpsServer  defs.APIRTMPServer
 	srtServer    defs.APISRTServer
 	webRTCServer defs.APIWebRTCServer
+	alarmManager defs.APIAlarmManager
 }
 
 // Initialize initializes metrics.
@@ -587,6 +588,28 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 		}
 	}
 
+	if !interfaceIsEmpty(m.alarmManager) &&
+		(typ == "" || typ == "clock_skews") &&
+		!anyFilterActive {
+		data, err := m.alarmManager.APIClockSkewsList()
+		if err == nil && len(data.Items) != 0 {
+			for _, i := range data.Items {
+				ta := tags(map[string]string{
+					"cameraID": strconv.FormatInt(i.CameraID, 10),
+				})
+				out += metricFloat("clock_skews_seconds", ta, i.Skew)
+				out += metricFloat("clock_skews_max_seconds", ta, i.MaxSkew)
+				out += metric("clock_skews_samples", ta, int64(i.Samples))
+				out += metric("clock_skews_corrections", ta, int64(i.Corrections))
+			}
+		} else {
+			out += metricFloat("clock_skews_seconds", "", 0)
+			out += metricFloat("clock_skews_max_seconds", "", 0)
+			out += metric("clock_skews_samples", "", 0)
+			out += metric("clock_skews_corrections", "", 0)
+		}
+	}
+
 	ctx.Writer.WriteHeader(http.StatusOK)
 	io.WriteString(ctx.Writer, out) //nolint:errcheck
 }
@@ -646,3 +669,10 @@ func (m *Metrics) SetWebRTCServer(s defs.APIWebRTCServer) {
 	defer m.mutex.Unlock()
 	m.webRTCServer = s
 }
+
+// SetAlarmManager is called by core.
+func (m *Metrics) SetAlarmManager(s defs.APIAlarmManager) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.alarmManager = s
+}
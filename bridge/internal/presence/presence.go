@@ -0,0 +1,240 @@
+// Package presence contains the presence reporter, that makes the bridge visible as online
+// and publishes its status without writing to the database.
+package presence
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+const (
+	// delay between attempts to join the presence, when the backend is unreachable.
+	presenceRetryPeriod = 10 * time.Second
+	// minimum delay between updates of the metadata, since paths may change state in bursts.
+	presenceMinInterval = time.Second
+)
+
+// the bridge has been started when the process has, regardless of reloads of the configuration.
+var processStart = time.Now()
+
+// meta is published on the presence of the bridge.
+// The channel of the bridge is public, therefore it contains counts only, and not addresses of cameras.
+type meta struct {
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"started_at"`
+	// seconds, when the metadata was published.
+	Uptime           int64 `json:"uptime"`
+	Cameras          int   `json:"cameras"`
+	CamerasReady     int   `json:"cameras_ready"`
+	CamerasRecording int   `json:"cameras_recording"`
+}
+
+// changed returns whether the state of cameras is different.
+func (m *meta) changed(other *meta) bool {
+	return other == nil ||
+		m.Cameras != other.Cameras ||
+		m.CamerasReady != other.CamerasReady ||
+		m.CamerasRecording != other.CamerasRecording
+}
+
+// newMeta computes the state of cameras from the readiness of their paths.
+// Cameras are recorded when their paths are ready and have recording enabled.
+func newMeta(cameraIDs map[string]int64, paths *defs.APIPathList, pathConfs map[string]*conf.Path) *meta {
+	byName := make(map[string]*defs.APIPath)
+	if paths != nil {
+		for _, pa := range paths.Items {
+			byName[pa.Name] = pa
+		}
+	}
+
+	m := &meta{
+		Cameras: len(cameraIDs),
+	}
+
+	for name := range cameraIDs {
+		pa, ok := byName[name]
+		if !ok || !pa.Ready {
+			continue
+		}
+
+		m.CamerasReady++
+
+		if pathConf, ok := pathConfs[pa.ConfName]; ok && pathConf.Record {
+			m.CamerasRecording++
+		}
+	}
+
+	return m
+}
+
+type presenceParent interface {
+	logger.Writer
+}
+
+type presenceControlPlane interface {
+	JoinBridgePresence(bridgeID int64, meta interface{}) (controlplane.Presence, error)
+}
+
+type presenceConfDB interface {
+	CameraIDs() map[string]int64
+}
+
+type presencePathManager interface {
+	defs.APIPathManager
+	SetPresence(*Presence)
+}
+
+// Presence keeps the bridge present on its channel, with metadata about its version, its uptime
+// and its cameras, so that clients see whether it's online within seconds.
+// Metadata is published again when paths change state.
+type Presence struct {
+	Version      string
+	BridgeID     int64
+	PathConfs    map[string]*conf.Path
+	ControlPlane presenceControlPlane
+	ConfDB       presenceConfDB
+	PathManager  presencePathManager
+	Parent       presenceParent
+
+	pathConfsMutex sync.Mutex
+
+	// in
+	chPathStateChanged chan struct{}
+
+	terminate chan struct{}
+	done      chan struct{}
+}
+
+// Initialize initializes Presence.
+func (p *Presence) Initialize() error {
+	p.chPathStateChanged = make(chan struct{}, 1)
+	p.terminate = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go p.run()
+
+	return nil
+}
+
+// Close closes Presence.
+func (p *Presence) Close() {
+	close(p.terminate)
+	<-p.done
+}
+
+// Log implements logger.Writer.
+func (p *Presence) Log(level logger.Level, format string, args ...interface{}) {
+	p.Parent.Log(level, "[presence] "+format, args...)
+}
+
+// PathStateChanged is called by pathManager when a path becomes ready or not ready.
+// It doesn't block, since the metadata is computed by querying pathManager.
+func (p *Presence) PathStateChanged() {
+	select {
+	case p.chPathStateChanged <- struct{}{}:
+	default:
+	}
+}
+
+// ReloadPathConfs is called by core.
+func (p *Presence) ReloadPathConfs(pathConfs map[string]*conf.Path) {
+	p.pathConfsMutex.Lock()
+	p.PathConfs = pathConfs
+	p.pathConfsMutex.Unlock()
+
+	// recording may have been enabled or disabled
+	p.PathStateChanged()
+}
+
+func (p *Presence) run() {
+	defer close(p.done)
+
+	p.PathManager.SetPresence(p)
+	defer p.PathManager.SetPresence(nil)
+
+	pr, last := p.join()
+	if pr == nil {
+		return
+	}
+	defer pr.Close()
+
+	p.Log(logger.Info, "bridge %d is online", p.BridgeID)
+
+	for {
+		select {
+		case <-p.chPathStateChanged:
+
+		case <-p.terminate:
+			return
+		}
+
+		m := p.check()
+		if !m.changed(last) {
+			continue
+		}
+
+		err := pr.Track(m)
+		if err != nil {
+			p.Log(logger.Warn, "unable to update presence: %v", err)
+		} else {
+			last = m
+		}
+
+		// wait before the next update, leaving changes in the meanwhile to be coalesced
+		select {
+		case <-time.After(presenceMinInterval):
+		case <-p.terminate:
+			return
+		}
+	}
+}
+
+// join joins the presence, retrying until it succeeds, and returns it with the published metadata.
+// It returns nil when Presence is closed.
+func (p *Presence) join() (controlplane.Presence, *meta) {
+	failing := false
+
+	for {
+		m := p.check()
+
+		pr, err := p.ControlPlane.JoinBridgePresence(p.BridgeID, m)
+		if err == nil {
+			return pr, m
+		}
+
+		// log only the first failure, in order not to flood logs when offline
+		if !failing {
+			p.Log(logger.Warn, "unable to join presence: %v", err)
+			failing = true
+		}
+
+		select {
+		case <-time.After(presenceRetryPeriod):
+		case <-p.terminate:
+			return nil, nil
+		}
+	}
+}
+
+func (p *Presence) check() *meta {
+	paths, err := p.PathManager.APIPathsList()
+	if err != nil {
+		p.Log(logger.Warn, "unable to list paths: %v", err)
+	}
+
+	p.pathConfsMutex.Lock()
+	pathConfs := p.PathConfs
+	p.pathConfsMutex.Unlock()
+
+	m := newMeta(p.ConfDB.CameraIDs(), paths, pathConfs)
+	m.Version = p.Version
+	m.StartedAt = processStart
+	m.Uptime = int64(time.Since(processStart).Seconds())
+
+	return m
+}
@@ -0,0 +1,152 @@
+package presence
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+	"github.com/kaonmir/mini-chekt/internal/defs"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+type dummyConfDB struct{}
+
+func (dummyConfDB) CameraIDs() map[string]int64 {
+	return map[string]int64{
+		"192.168.0.10": 1,
+		"192.168.0.11": 2,
+		"192.168.0.12": 3,
+	}
+}
+
+type dummyPathManager struct {
+	mutex    sync.Mutex
+	ready    map[string]bool
+	presence *Presence
+}
+
+func (pm *dummyPathManager) APIPathsList() (*defs.APIPathList, error) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	ret := &defs.APIPathList{}
+	for name, ready := range pm.ready {
+		ret.Items = append(ret.Items, &defs.APIPath{Name: name, ConfName: name, Ready: ready})
+	}
+	return ret, nil
+}
+
+func (pm *dummyPathManager) APIPathsGet(string) (*defs.APIPath, error) {
+	panic("unused")
+}
+
+func (pm *dummyPathManager) SetPresence(p *Presence) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	pm.presence = p
+}
+
+func (pm *dummyPathManager) setReady(name string, ready bool) {
+	pm.mutex.Lock()
+	pm.ready[name] = ready
+	p := pm.presence
+	pm.mutex.Unlock()
+
+	p.PathStateChanged()
+}
+
+func TestNewMeta(t *testing.T) {
+	m := newMeta(dummyConfDB{}.CameraIDs(), &defs.APIPathList{
+		Items: []*defs.APIPath{
+			{Name: "192.168.0.10", ConfName: "192.168.0.10", Ready: true},
+			{Name: "192.168.0.11", ConfName: "192.168.0.11", Ready: true},
+			{Name: "192.168.0.12", ConfName: "192.168.0.12"},
+			{Name: "static", ConfName: "static", Ready: true},
+		},
+	}, map[string]*conf.Path{
+		"192.168.0.10": {Record: true},
+		"192.168.0.11": {},
+		"192.168.0.12": {Record: true},
+		"static":       {Record: true},
+	})
+
+	require.Equal(t, &meta{
+		Cameras:          3,
+		CamerasReady:     2,
+		CamerasRecording: 1,
+	}, m)
+}
+
+func TestPresence(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-presence")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cp := &controlplane.Local{Directory: dir}
+	err = cp.Initialize()
+	require.NoError(t, err)
+
+	pm := &dummyPathManager{
+		ready: map[string]bool{"192.168.0.10": true},
+	}
+
+	p := &Presence{
+		Version:  "v1.0.0",
+		BridgeID: 1,
+		PathConfs: map[string]*conf.Path{
+			"192.168.0.10": {Record: true},
+			"192.168.0.11": {Record: true},
+		},
+		ControlPlane: cp,
+		ConfDB:       dummyConfDB{},
+		PathManager:  pm,
+		Parent:       nilLogger{},
+	}
+	err = p.Initialize()
+	require.NoError(t, err)
+
+	getMeta := func() *meta {
+		v, ok := cp.BridgePresence(1)
+		if !ok {
+			return nil
+		}
+		return v.(*meta)
+	}
+
+	require.Eventually(t, func() bool { return getMeta() != nil }, 5*time.Second, 10*time.Millisecond)
+
+	m := getMeta()
+	require.Equal(t, "v1.0.0", m.Version)
+	require.Equal(t, processStart, m.StartedAt)
+	require.Equal(t, 3, m.Cameras)
+	require.Equal(t, 1, m.CamerasReady)
+	require.Equal(t, 1, m.CamerasRecording)
+
+	// metadata is published again when paths change state
+	pm.setReady("192.168.0.11", true)
+	require.Eventually(t, func() bool { return getMeta().CamerasReady == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 2, getMeta().CamerasRecording)
+
+	// and when recording is disabled
+	p.ReloadPathConfs(map[string]*conf.Path{
+		"192.168.0.10": {Record: true},
+		"192.168.0.11": {},
+	})
+	require.Eventually(t, func() bool { return getMeta().CamerasRecording == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// the bridge goes offline when closed
+	p.Close()
+
+	_, ok := cp.BridgePresence(1)
+	require.False(t, ok)
+	require.Nil(t, pm.presence)
+}
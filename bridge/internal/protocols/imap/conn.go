@@ -0,0 +1,415 @@
+// Package imap contains a minimal IMAP4rev1 client.
+package imap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxLiteralSize = 32 * 1024 * 1024
+)
+
+// response is a server response, with literals stripped from the text.
+type response struct {
+	tag      string // "*" for untagged responses, "+" for continuations
+	text     string
+	literals [][]byte
+}
+
+// status returns the status of a tagged response (OK, NO, BAD).
+func (r *response) status() string {
+	s, _, _ := strings.Cut(r.text, " ")
+	return strings.ToUpper(s)
+}
+
+// Conn is an IMAP connection.
+type Conn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	writeTimeout time.Duration
+	tagCount     int
+	capabilities []string
+}
+
+// NewConn allocates a Conn and reads the server greeting.
+func NewConn(nconn net.Conn, writeTimeout time.Duration) (*Conn, error) {
+	c := &Conn{
+		conn:         nconn,
+		br:           bufio.NewReader(nconn),
+		writeTimeout: writeTimeout,
+	}
+
+	res, err := c.readResponse()
+	if err != nil {
+		return nil, err
+	}
+
+	if res.tag != "*" || (res.status() != "OK" && res.status() != "PREAUTH") {
+		return nil, fmt.Errorf("unexpected greeting: %s", res.text)
+	}
+
+	return c, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// HasCapability checks whether the server advertised a capability.
+func (c *Conn) HasCapability(name string) bool {
+	for _, capa := range c.capabilities {
+		if strings.EqualFold(capa, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Capability fetches the server capabilities.
+func (c *Conn) Capability() error {
+	_, err := c.command("CAPABILITY")
+	return err
+}
+
+// Login authenticates with a username and password.
+func (c *Conn) Login(username string, password string) error {
+	_, err := c.command("LOGIN " + quote(username) + " " + quote(password))
+	if err != nil {
+		return err
+	}
+
+	// capabilities may change after authentication
+	return c.Capability()
+}
+
+// Select selects a mailbox.
+func (c *Conn) Select(mailbox string) error {
+	_, err := c.command("SELECT " + quote(mailbox))
+	return err
+}
+
+// UIDSearch returns the UIDs of messages that match the criteria.
+func (c *Conn) UIDSearch(criteria string) ([]uint32, error) {
+	ress, err := c.command("UID SEARCH " + criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []uint32
+
+	for _, res := range ress {
+		fields := strings.Fields(res.text)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "SEARCH") {
+			continue
+		}
+
+		for _, f := range fields[1:] {
+			uid, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UID '%s'", f)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+
+	return uids, nil
+}
+
+// UIDFetchBody returns the full content of a message, without setting the \Seen flag.
+func (c *Conn) UIDFetchBody(uid uint32) ([]byte, error) {
+	ress, err := c.command(fmt.Sprintf("UID FETCH %d (UID BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, res := range ress {
+		if !strings.Contains(strings.ToUpper(res.text), " FETCH ") {
+			continue
+		}
+
+		if fetchUID(res.text) != uid || len(res.literals) == 0 {
+			continue
+		}
+
+		return res.literals[0], nil
+	}
+
+	return nil, fmt.Errorf("message %d not found", uid)
+}
+
+// UIDMarkSeen sets the \Seen flag of a message.
+func (c *Conn) UIDMarkSeen(uid uint32) error {
+	_, err := c.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid))
+	return err
+}
+
+// UIDMove moves a message into another mailbox.
+// When the server doesn't support MOVE, the message is copied and then deleted.
+func (c *Conn) UIDMove(uid uint32, mailbox string) error {
+	if c.HasCapability("MOVE") {
+		_, err := c.command(fmt.Sprintf("UID MOVE %d %s", uid, quote(mailbox)))
+		return err
+	}
+
+	_, err := c.command(fmt.Sprintf("UID COPY %d %s", uid, quote(mailbox)))
+	if err != nil {
+		return err
+	}
+
+	_, err = c.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen \Deleted)`, uid))
+	if err != nil {
+		return err
+	}
+
+	if c.HasCapability("UIDPLUS") {
+		_, err = c.command(fmt.Sprintf("UID EXPUNGE %d", uid))
+	} else {
+		_, err = c.command("EXPUNGE")
+	}
+	return err
+}
+
+// Idle waits until the server notifies new messages, stop is closed or timeout elapses.
+// It returns whether new messages are available.
+func (c *Conn) Idle(stop <-chan struct{}, timeout time.Duration) (bool, error) {
+	tag, err := c.writeCommand("IDLE")
+	if err != nil {
+		return false, err
+	}
+
+	res, err := c.readResponse()
+	if err != nil {
+		return false, err
+	}
+	if res.tag != "+" {
+		return false, fmt.Errorf("IDLE rejected: %s", res.text)
+	}
+
+	type readRes struct {
+		res *response
+		err error
+	}
+
+	chRead := make(chan readRes)
+	readerDone := make(chan struct{})
+	defer close(readerDone)
+
+	go func() {
+		for {
+			res, err := c.readResponse()
+			select {
+			case chRead <- readRes{res, err}:
+			case <-readerDone:
+				return
+			}
+			if err != nil || res.tag == tag {
+				return
+			}
+		}
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	newMessages := false
+	done := false
+
+	for {
+		select {
+		case r := <-chRead:
+			if r.err != nil {
+				return false, r.err
+			}
+
+			if r.res.tag == tag {
+				if r.res.status() != "OK" {
+					return false, fmt.Errorf("IDLE failed: %s", r.res.text)
+				}
+				return newMessages, nil
+			}
+
+			if r.res.tag == "*" && strings.HasSuffix(strings.ToUpper(r.res.text), " EXISTS") {
+				newMessages = true
+				if !done {
+					done = true
+					err = c.writeLine("DONE")
+					if err != nil {
+						return false, err
+					}
+				}
+			}
+
+		case <-stop:
+			if !done {
+				done = true
+				// the connection is about to be closed, do not wait for the reply
+				c.writeLine("DONE") //nolint:errcheck
+			}
+			return false, fmt.Errorf("terminated")
+
+		case <-t.C:
+			if !done {
+				done = true
+				err = c.writeLine("DONE")
+				if err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+}
+
+// Logout closes the session.
+func (c *Conn) Logout() error {
+	_, err := c.command("LOGOUT")
+	return err
+}
+
+// command sends a command and returns untagged responses.
+func (c *Conn) command(cmd string) ([]*response, error) {
+	tag, err := c.writeCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var untagged []*response
+
+	for {
+		res, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		switch res.tag {
+		case tag:
+			if res.status() != "OK" {
+				name, _, _ := strings.Cut(cmd, " ")
+				return nil, fmt.Errorf("%s failed: %s", name, res.text)
+			}
+			return untagged, nil
+
+		case "*":
+			c.handleUntagged(res)
+			untagged = append(untagged, res)
+
+		case "+":
+			return nil, fmt.Errorf("unexpected continuation request")
+		}
+	}
+}
+
+func (c *Conn) handleUntagged(res *response) {
+	fields := strings.Fields(res.text)
+	if len(fields) == 0 {
+		return
+	}
+
+	if strings.EqualFold(fields[0], "CAPABILITY") {
+		c.capabilities = fields[1:]
+		return
+	}
+
+	// capabilities can be provided in response codes, like "OK [CAPABILITY ...]"
+	if i := strings.Index(strings.ToUpper(res.text), "[CAPABILITY "); i >= 0 {
+		end := strings.IndexByte(res.text[i:], ']')
+		if end > 0 {
+			c.capabilities = strings.Fields(res.text[i+len("[CAPABILITY ") : i+end])
+		}
+	}
+}
+
+func (c *Conn) writeCommand(cmd string) (string, error) {
+	c.tagCount++
+	tag := fmt.Sprintf("A%03d", c.tagCount)
+	return tag, c.writeLine(tag + " " + cmd)
+}
+
+func (c *Conn) writeLine(line string) error {
+	if c.writeTimeout != 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)) //nolint:errcheck
+	}
+	_, err := io.WriteString(c.conn, line+"\r\n")
+	return err
+}
+
+// readResponse reads a response, including literals.
+func (c *Conn) readResponse() (*response, error) {
+	res := &response{}
+	var text strings.Builder
+
+	for {
+		line, err := c.br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		size, ok := literalSize(line)
+		if !ok {
+			text.WriteString(line)
+			break
+		}
+
+		if size > maxLiteralSize {
+			return nil, fmt.Errorf("literal is too big (%d bytes)", size)
+		}
+
+		text.WriteString(line[:strings.LastIndexByte(line, '{')])
+
+		lit := make([]byte, size)
+		_, err = io.ReadFull(c.br, lit)
+		if err != nil {
+			return nil, err
+		}
+		res.literals = append(res.literals, lit)
+	}
+
+	res.tag, res.text, _ = strings.Cut(text.String(), " ")
+
+	return res, nil
+}
+
+// literalSize returns the size of the literal that terminates a line, if any.
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+
+	size, err := strconv.Atoi(strings.TrimSuffix(line[i+1:len(line)-1], "+"))
+	if err != nil || size < 0 {
+		return 0, false
+	}
+
+	return size, true
+}
+
+// fetchUID extracts the UID from a FETCH response.
+func fetchUID(text string) uint32 {
+	fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(text))
+	for i, f := range fields {
+		if strings.EqualFold(f, "UID") && i+1 < len(fields) {
+			uid, err := strconv.ParseUint(fields[i+1], 10, 32)
+			if err == nil {
+				return uint32(uid)
+			}
+		}
+	}
+	return 0
+}
+
+// quote encodes a string as an IMAP quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
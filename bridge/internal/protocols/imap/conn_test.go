@@ -0,0 +1,46 @@
+package imap
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadResponse(t *testing.T) {
+	c := &Conn{br: bufio.NewReader(strings.NewReader(
+		"* 1 FETCH (UID 42 BODY[] {5}\r\nhello)\r\n" +
+			"A001 OK done\r\n"))}
+
+	res, err := c.readResponse()
+	require.NoError(t, err)
+	require.Equal(t, "*", res.tag)
+	require.Equal(t, "1 FETCH (UID 42 BODY[] )", res.text)
+	require.Equal(t, [][]byte{[]byte("hello")}, res.literals)
+	require.Equal(t, uint32(42), fetchUID(res.text))
+
+	res, err = c.readResponse()
+	require.NoError(t, err)
+	require.Equal(t, "A001", res.tag)
+	require.Equal(t, "OK", res.status())
+}
+
+func TestLiteralSize(t *testing.T) {
+	for _, ca := range []struct {
+		line string
+		size int
+		ok   bool
+	}{
+		{"* 1 FETCH (BODY[] {12}", 12, true},
+		{"* 1 FETCH (BODY[] {12+}", 12, true},
+		{"* OK [CAPABILITY IMAP4rev1]", 0, false},
+		{"* 1 FETCH (BODY[] {abc}", 0, false},
+	} {
+		t.Run(ca.line, func(t *testing.T) {
+			size, ok := literalSize(ca.line)
+			require.Equal(t, ca.ok, ok)
+			require.Equal(t, ca.size, size)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package onvifmeta contains utilities to read ONVIF metadata streams.
+package onvifmeta
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"golang.org/x/net/html/charset"
+)
+
+// encoding name of ONVIF metadata tracks.
+const encodingName = "vnd.onvif.metadata"
+
+// SimpleItem is a name-value pair.
+type SimpleItem struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+// Items is a list of SimpleItems.
+type Items struct {
+	SimpleItems []SimpleItem `xml:"SimpleItem"`
+}
+
+// Get returns the value of an item.
+func (i Items) Get(name string) (string, bool) {
+	for _, item := range i.SimpleItems {
+		if item.Name == name {
+			return item.Value, true
+		}
+	}
+	return "", false
+}
+
+// Message is the content of a notification.
+type Message struct {
+	UtcTime           string `xml:"UtcTime,attr"`
+	PropertyOperation string `xml:"PropertyOperation,attr"`
+	Source            Items  `xml:"Source"`
+	Key               Items  `xml:"Key"`
+	Data              Items  `xml:"Data"`
+}
+
+// Notification is a WS-BaseNotification NotificationMessage.
+type Notification struct {
+	Topic   string  `xml:"Topic"`
+	Message Message `xml:"Message>Message"`
+}
+
+// TopicPath returns the topic without namespace prefixes,
+// i.e. "RuleEngine/CellMotionDetector/Motion".
+func (n *Notification) TopicPath() string {
+	parts := strings.Split(strings.TrimSpace(n.Topic), "/")
+	for i, p := range parts {
+		if j := strings.IndexByte(p, ':'); j >= 0 {
+			parts[i] = p[j+1:]
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ClassCandidate is a candidate class of an object.
+type ClassCandidate struct {
+	Type       string  `xml:"Type"`
+	Likelihood float64 `xml:"Likelihood"`
+}
+
+// ClassType is the class of an object.
+type ClassType struct {
+	Likelihood float64 `xml:"Likelihood,attr"`
+	Value      string  `xml:",chardata"`
+}
+
+// Class contains the classification of an object.
+type Class struct {
+	// ONVIF 1.x
+	ClassCandidates []ClassCandidate `xml:"ClassCandidate"`
+	// ONVIF 2.x
+	Types []ClassType `xml:"Type"`
+}
+
+// Object is an object detected in a frame.
+type Object struct {
+	ObjectID string `xml:"ObjectId,attr"`
+	Class    Class  `xml:"Appearance>Class"`
+}
+
+// BestClass returns the class with the highest likelihood.
+func (o *Object) BestClass() (string, float64) {
+	class := ""
+	likelihood := -1.0
+
+	for _, c := range o.Class.ClassCandidates {
+		if c.Likelihood > likelihood {
+			class, likelihood = strings.TrimSpace(c.Type), c.Likelihood
+		}
+	}
+	for _, t := range o.Class.Types {
+		if t.Likelihood > likelihood {
+			class, likelihood = strings.TrimSpace(t.Value), t.Likelihood
+		}
+	}
+
+	return class, likelihood
+}
+
+// Frame contains the objects detected in a video frame.
+type Frame struct {
+	UtcTime string   `xml:"UtcTime,attr"`
+	Objects []Object `xml:"Object"`
+}
+
+// MetadataStream is a ONVIF metadata document.
+type MetadataStream struct {
+	XMLName       xml.Name       `xml:"MetadataStream"`
+	Frames        []Frame        `xml:"VideoAnalytics>Frame"`
+	Notifications []Notification `xml:"Event>NotificationMessage"`
+}
+
+// Unmarshal decodes a MetadataStream document.
+func Unmarshal(buf []byte) (*MetadataStream, error) {
+	dec := xml.NewDecoder(bytes.NewReader(buf))
+	dec.CharsetReader = charset.NewReaderLabel
+
+	var ms MetadataStream
+	err := dec.Decode(&ms)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata document: %w", err)
+	}
+
+	return &ms, nil
+}
+
+// IsMetadata checks whether a format is a ONVIF metadata track.
+func IsMetadata(forma format.Format) bool {
+	g, ok := forma.(*format.Generic)
+	if !ok {
+		return false
+	}
+
+	name, _, _ := strings.Cut(g.RTPMa, "/")
+	return strings.EqualFold(name, encodingName)
+}
+
+// FindFormat finds the ONVIF metadata track of a session.
+func FindFormat(desc *description.Session) (*description.Media, format.Format) {
+	for _, medi := range desc.Medias {
+		for _, forma := range medi.Formats {
+			if IsMetadata(forma) {
+				return medi, forma
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,162 @@
+package onvifmeta
+
+import (
+	"testing"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+const testDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<tt:MetadataStream xmlns:tt="http://www.onvif.org/ver10/schema"
+  xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2"
+  xmlns:tns1="http://www.onvif.org/ver10/topics">
+<tt:VideoAnalytics>
+<tt:Frame UtcTime="2025-03-10T01:00:00.120Z">
+<tt:Object ObjectId="12">
+<tt:Appearance>
+<tt:Class>
+<tt:ClassCandidate><tt:Type>Animal</tt:Type><tt:Likelihood>0.2</tt:Likelihood></tt:ClassCandidate>
+<tt:ClassCandidate><tt:Type>Human</tt:Type><tt:Likelihood>0.8</tt:Likelihood></tt:ClassCandidate>
+</tt:Class>
+</tt:Appearance>
+</tt:Object>
+<tt:Object ObjectId="13">
+<tt:Appearance>
+<tt:Class><tt:Type Likelihood="0.9">Vehicle</tt:Type></tt:Class>
+</tt:Appearance>
+</tt:Object>
+</tt:Frame>
+</tt:VideoAnalytics>
+<tt:Event>
+<wsnt:NotificationMessage>
+<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">tns1:RuleEngine/CellMotionDetector/Motion</wsnt:Topic>
+<wsnt:Message>
+<tt:Message UtcTime="2025-03-10T01:00:00Z" PropertyOperation="Changed">
+<tt:Source>
+<tt:SimpleItem Name="VideoSourceConfigurationToken" Value="VideoSourceToken"/>
+<tt:SimpleItem Name="Rule" Value="MyMotionDetectorRule"/>
+</tt:Source>
+<tt:Data><tt:SimpleItem Name="IsMotion" Value="true"/></tt:Data>
+</tt:Message>
+</wsnt:Message>
+</wsnt:NotificationMessage>
+</tt:Event>
+</tt:MetadataStream>`
+
+func TestUnmarshal(t *testing.T) {
+	ms, err := Unmarshal([]byte(testDocument))
+	require.NoError(t, err)
+
+	require.Len(t, ms.Frames, 1)
+	require.Equal(t, "2025-03-10T01:00:00.120Z", ms.Frames[0].UtcTime)
+	require.Len(t, ms.Frames[0].Objects, 2)
+
+	class, likelihood := ms.Frames[0].Objects[0].BestClass()
+	require.Equal(t, "Human", class)
+	require.Equal(t, 0.8, likelihood)
+
+	class, likelihood = ms.Frames[0].Objects[1].BestClass()
+	require.Equal(t, "Vehicle", class)
+	require.Equal(t, 0.9, likelihood)
+
+	require.Len(t, ms.Notifications, 1)
+	n := ms.Notifications[0]
+	require.Equal(t, "RuleEngine/CellMotionDetector/Motion", n.TopicPath())
+	require.Equal(t, "Changed", n.Message.PropertyOperation)
+
+	v, ok := n.Message.Source.Get("Rule")
+	require.True(t, ok)
+	require.Equal(t, "MyMotionDetectorRule", v)
+
+	v, ok = n.Message.Data.Get("IsMotion")
+	require.True(t, ok)
+	require.Equal(t, "true", v)
+}
+
+func TestUnmarshalError(t *testing.T) {
+	_, err := Unmarshal([]byte("<tt:MetadataStream><tt:Event>"))
+	require.Error(t, err)
+
+	_, err = Unmarshal([]byte("<other/>"))
+	require.Error(t, err)
+}
+
+func TestFindFormat(t *testing.T) {
+	metadata := &format.Generic{
+		PayloadTyp: 107,
+		RTPMa:      "VND.ONVIF.METADATA/90000",
+	}
+	require.NoError(t, metadata.Init())
+
+	desc := &description.Session{
+		Medias: []*description.Media{
+			{
+				Type:    description.MediaTypeVideo,
+				Formats: []format.Format{&format.H264{PayloadTyp: 96}},
+			},
+			{
+				Type:    description.MediaTypeApplication,
+				Formats: []format.Format{metadata},
+			},
+		},
+	}
+
+	medi, forma := FindFormat(desc)
+	require.Equal(t, desc.Medias[1], medi)
+	require.Equal(t, metadata, forma)
+
+	medi, forma = FindFormat(&description.Session{Medias: desc.Medias[:1]})
+	require.Nil(t, medi)
+	require.Nil(t, forma)
+}
+
+func TestReassembler(t *testing.T) {
+	doc := []byte(testDocument)
+
+	var pkts []*rtp.Packet
+	for i := 0; i < 3; i++ {
+		pkts = append(pkts, &rtp.Packet{
+			Header: rtp.Header{
+				SequenceNumber: uint16(65534 + i),
+				Marker:         i == 2,
+			},
+			Payload: doc[i*len(doc)/3 : (i+1)*len(doc)/3],
+		})
+	}
+
+	var r Reassembler
+
+	for i, pkt := range pkts {
+		out, err := r.Push(pkt)
+		require.NoError(t, err)
+		if i != 2 {
+			require.Nil(t, out)
+		} else {
+			require.Equal(t, doc, out)
+		}
+	}
+
+	// lost packet
+	_, err := r.Push(&rtp.Packet{
+		Header:  rtp.Header{SequenceNumber: 2},
+		Payload: []byte("<tt:MetadataStream>"),
+	})
+	require.EqualError(t, err, "1 RTP packets lost, discarding metadata document")
+
+	out, err := r.Push(&rtp.Packet{
+		Header:  rtp.Header{SequenceNumber: 3, Marker: true},
+		Payload: []byte("</tt:MetadataStream>"),
+	})
+	require.NoError(t, err)
+	require.Nil(t, out)
+
+	out, err = r.Push(&rtp.Packet{
+		Header:  rtp.Header{SequenceNumber: 4, Marker: true},
+		Payload: []byte("<tt:MetadataStream/>"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte("<tt:MetadataStream/>"), out)
+}
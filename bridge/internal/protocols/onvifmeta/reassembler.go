@@ -0,0 +1,58 @@
+package onvifmeta
+
+import (
+	"fmt"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	maxDocumentSize = 1024 * 1024
+)
+
+// Reassembler reassembles metadata documents from RTP packets.
+// Documents are split into the payloads of consecutive packets,
+// and the marker bit is set on the last one.
+type Reassembler struct {
+	buf       []byte
+	sequence  uint16
+	started   bool
+	discarded bool
+}
+
+// Push adds a RTP packet.
+// It returns a document when the packet completes it.
+func (r *Reassembler) Push(pkt *rtp.Packet) ([]byte, error) {
+	if r.started && pkt.SequenceNumber != r.sequence+1 {
+		lost := pkt.SequenceNumber - r.sequence - 1
+		r.sequence = pkt.SequenceNumber
+		r.buf = r.buf[:0]
+		r.discarded = !pkt.Marker
+		return nil, fmt.Errorf("%d RTP packets lost, discarding metadata document", lost)
+	}
+
+	r.started = true
+	r.sequence = pkt.SequenceNumber
+
+	// wait for the beginning of the next document
+	if r.discarded {
+		r.discarded = !pkt.Marker
+		return nil, nil
+	}
+
+	if len(r.buf)+len(pkt.Payload) > maxDocumentSize {
+		r.buf = r.buf[:0]
+		r.discarded = !pkt.Marker
+		return nil, fmt.Errorf("metadata document is too big")
+	}
+
+	r.buf = append(r.buf, pkt.Payload...)
+
+	if !pkt.Marker {
+		return nil, nil
+	}
+
+	doc := r.buf
+	r.buf = nil
+	return doc, nil
+}
@@ -0,0 +1,308 @@
+// Package tus contains a client of the TUS resumable upload protocol.
+package tus
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// chunk size required by Supabase storage.
+	defaultChunkSize  = 6 * 1024 * 1024
+	defaultMaxRetries = 5
+	defaultRetryPause = 2 * time.Second
+
+	tusVersion = "1.0.0"
+)
+
+func encodeMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + " " + base64.StdEncoding.EncodeToString([]byte(metadata[k]))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+var errInvalidOffset = errors.New("server offset is out of chunk boundaries")
+
+type statusError struct {
+	code int
+	body string
+}
+
+func (e statusError) Error() string {
+	if e.body != "" {
+		return fmt.Sprintf("bad status code: %d (%s)", e.code, e.body)
+	}
+	return fmt.Sprintf("bad status code: %d", e.code)
+}
+
+func newStatusError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return statusError{
+		code: res.StatusCode,
+		body: string(bytes.TrimSpace(body)),
+	}
+}
+
+// retryable returns whether an upload can be resumed after an error.
+func retryable(err error) bool {
+	if errors.Is(err, errInvalidOffset) {
+		return false
+	}
+
+	var se statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusConflict
+	}
+	return true
+}
+
+// Client is a TUS client.
+// Data is read and sent in chunks, therefore memory usage is bounded by the chunk size,
+// and the length of data doesn't need to be known in advance.
+// Chunks that fail because of network errors are resumed from the offset stored by the server.
+type Client struct {
+	// URL of the creation endpoint.
+	URL        string
+	Header     http.Header
+	HTTPClient *http.Client
+	ChunkSize  int
+	MaxRetries int
+	RetryPause time.Duration
+	// called after each chunk with the number of uploaded bytes.
+	OnProgress func(uploaded int64)
+}
+
+func (c *Client) chunkSize() int {
+	if c.ChunkSize != 0 {
+		return c.ChunkSize
+	}
+	return defaultChunkSize
+}
+
+func (c *Client) maxRetries() int {
+	if c.MaxRetries != 0 {
+		return c.MaxRetries
+	}
+	return defaultMaxRetries
+}
+
+func (c *Client) retryPause() time.Duration {
+	if c.RetryPause != 0 {
+		return c.RetryPause
+	}
+	return defaultRetryPause
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, ur string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, ur, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vals := range c.Header {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Tus-Resumable", tusVersion)
+
+	return req, nil
+}
+
+// retry calls cb until it succeeds or fails with an error that can't be recovered.
+func (c *Client) retry(ctx context.Context, cb func() error) error {
+	for i := 0; ; i++ {
+		err := cb()
+		if err == nil || !retryable(err) || i == c.maxRetries() {
+			return err
+		}
+
+		select {
+		case <-time.After(c.retryPause() << i):
+		case <-ctx.Done():
+			return fmt.Errorf("terminated")
+		}
+	}
+}
+
+// Upload uploads data, that is read until EOF.
+func (c *Client) Upload(ctx context.Context, r io.Reader, metadata map[string]string) error {
+	var location string
+
+	err := c.retry(ctx, func() error {
+		var err2 error
+		location, err2 = c.create(ctx, metadata)
+		return err2
+	})
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, c.chunkSize())
+	offset := int64(0)
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		last := false
+
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			last = true
+
+		case err != nil:
+			return err
+		}
+
+		chunk := buf[:n]
+		chunkStart := offset
+
+		err = c.retry(ctx, func() error {
+			// the chunk was stored, but the response was lost
+			if offset == chunkStart+int64(len(chunk)) && !last {
+				return nil
+			}
+
+			var err2 error
+			offset, err2 = c.patch(ctx, location, offset, chunk[offset-chunkStart:], last, chunkStart+int64(len(chunk)))
+			if err2 == nil {
+				return nil
+			}
+
+			// find out how much of the chunk has been stored
+			serverOffset, err3 := c.head(ctx, location)
+			if err3 != nil {
+				return err2
+			}
+
+			if serverOffset < chunkStart || serverOffset > chunkStart+int64(len(chunk)) {
+				return fmt.Errorf("%w (%d)", errInvalidOffset, serverOffset)
+			}
+			offset = serverOffset
+
+			return err2
+		})
+		if err != nil {
+			return err
+		}
+
+		if c.OnProgress != nil {
+			c.OnProgress(offset)
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+func (c *Client) create(ctx context.Context, metadata map[string]string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, c.URL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// length is provided with the last chunk
+	req.Header.Set("Upload-Defer-Length", "1")
+	req.Header.Set("Upload-Metadata", encodeMetadata(metadata))
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		return "", newStatusError(res)
+	}
+
+	loc, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || res.Header.Get("Location") == "" {
+		return "", fmt.Errorf("invalid Location header")
+	}
+
+	base, err := url.Parse(c.URL)
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(loc).String(), nil
+}
+
+func (c *Client) patch(
+	ctx context.Context,
+	location string,
+	offset int64,
+	data []byte,
+	last bool,
+	length int64,
+) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodPatch, location, bytes.NewReader(data))
+	if err != nil {
+		return offset, err
+	}
+
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if last {
+		req.Header.Set("Upload-Length", strconv.FormatInt(length, 10))
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return offset, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return offset, newStatusError(res)
+	}
+
+	newOffset, err := strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return offset, fmt.Errorf("invalid Upload-Offset header")
+	}
+
+	if newOffset != offset+int64(len(data)) {
+		return offset, fmt.Errorf("server stored %d bytes instead of %d", newOffset-offset, len(data))
+	}
+
+	return newOffset, nil
+}
+
+func (c *Client) head(ctx context.Context, location string) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, location, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return 0, newStatusError(res)
+	}
+
+	return strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+}
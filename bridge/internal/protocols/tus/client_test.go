@@ -0,0 +1,162 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testServer struct {
+	mutex    sync.Mutex
+	metadata string
+	data     []byte
+	length   int64
+	// number of bytes to store before failing the next PATCH request
+	failAfter int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if r.Header.Get("Tus-Resumable") != "1.0.0" {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload":
+		if r.Header.Get("Upload-Defer-Length") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.metadata = r.Header.Get("Upload-Metadata")
+		s.length = -1
+		w.Header().Set("Location", "/upload/1")
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodHead && r.URL.Path == "/upload/1":
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPatch && r.URL.Path == "/upload/1":
+		offset, _ := strconv.Atoi(r.Header.Get("Upload-Offset"))
+		if offset != len(s.data) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		buf, _ := io.ReadAll(r.Body)
+
+		if s.failAfter != 0 {
+			s.data = append(s.data, buf[:s.failAfter]...)
+			s.failAfter = 0
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		s.data = append(s.data, buf...)
+
+		if v := r.Header.Get("Upload-Length"); v != "" {
+			s.length, _ = strconv.ParseInt(v, 10, 64)
+		}
+
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClientUpload(t *testing.T) {
+	s := &testServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	data := make([]byte, 25)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	var progress []int64
+
+	c := &Client{
+		URL:        srv.URL + "/upload",
+		HTTPClient: &http.Client{},
+		ChunkSize:  10,
+		RetryPause: time.Millisecond,
+		OnProgress: func(uploaded int64) {
+			progress = append(progress, uploaded)
+		},
+	}
+
+	err := c.Upload(context.Background(), bytes.NewReader(data), map[string]string{
+		"objectName": "a.zip",
+		"bucketName": "test",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "bucketName dGVzdA==,objectName YS56aXA=", s.metadata)
+	require.Equal(t, data, s.data)
+	require.Equal(t, int64(25), s.length)
+	require.Equal(t, []int64{10, 20, 25}, progress)
+}
+
+func TestClientResume(t *testing.T) {
+	s := &testServer{failAfter: 4}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	data := []byte("0123456789abcdef")
+
+	c := &Client{
+		URL:        srv.URL + "/upload",
+		HTTPClient: &http.Client{},
+		ChunkSize:  10,
+		RetryPause: time.Millisecond,
+	}
+
+	err := c.Upload(context.Background(), bytes.NewReader(data), nil)
+	require.NoError(t, err)
+
+	require.Equal(t, data, s.data)
+	require.Equal(t, int64(16), s.length)
+}
+
+func TestClientCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	c := &Client{
+		URL:        srv.URL + "/upload",
+		HTTPClient: &http.Client{},
+		RetryPause: time.Hour,
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- c.Upload(ctx, bytes.NewReader([]byte{1, 2, 3}), nil)
+	}()
+
+	ctxCancel()
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out")
+	}
+}
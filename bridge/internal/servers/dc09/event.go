@@ -0,0 +1,120 @@
+package dc09
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Protocols carried by DC-09 frames.
+const (
+	ProtocolContactID = "ADM-CID"
+	ProtocolSIA       = "SIA-DCS"
+)
+
+// Event is an event reported by an intrusion panel.
+type Event struct {
+	Protocol  string
+	Account   string
+	Receiver  string
+	Line      string
+	Sequence  string
+	Qualifier string // Contact ID only: 1 new event or opening, 3 restore or closing, 6 status
+	Code      string // Contact ID event code (i.e. 130) or SIA event code (i.e. BA)
+	Partition string
+	Zone      string
+	Timestamp *time.Time
+	FromIP    string
+}
+
+// ArmStatus returns the arm status reported by an opening/closing event.
+func (e *Event) ArmStatus() (string, bool) {
+	switch e.Protocol {
+	case ProtocolContactID:
+		// 4xx are open/close events
+		if !strings.HasPrefix(e.Code, "4") {
+			return "", false
+		}
+		switch e.Qualifier {
+		case "1":
+			return "disarm", true
+		case "3":
+			return "arm", true
+		}
+
+	case ProtocolSIA:
+		switch e.Code {
+		case "OP", "OA", "OG", "OR", "OK", "OS":
+			return "disarm", true
+		case "CL", "CA", "CG", "CF", "CK", "CS":
+			return "arm", true
+		}
+	}
+
+	return "", false
+}
+
+// parseData decodes the data block of a frame into an event.
+func parseData(f *frame) (*Event, error) {
+	e := &Event{
+		Protocol:  f.id,
+		Account:   f.account,
+		Receiver:  f.receiver,
+		Line:      f.line,
+		Sequence:  f.sequence,
+		Timestamp: f.timestamp,
+	}
+
+	data := f.data
+
+	// data starts with the account number
+	i := strings.IndexByte(data, '|')
+	if i < 0 {
+		return nil, fmt.Errorf("missing account separator")
+	}
+	if e.Account == "" {
+		e.Account = strings.TrimPrefix(data[:i], "#")
+	}
+	data = data[i+1:]
+
+	switch f.id {
+	case ProtocolContactID:
+		// QEEE GG ZZZ
+		fields := strings.Fields(data)
+		if len(fields) != 3 || len(fields[0]) != 4 {
+			return nil, fmt.Errorf("invalid Contact ID data '%s'", data)
+		}
+		e.Qualifier = fields[0][:1]
+		e.Code = fields[0][1:]
+		e.Partition = fields[1]
+		e.Zone = fields[2]
+
+	case ProtocolSIA:
+		// N[ri<partition>/]<code><zone>[/<code><zone>...]
+		data = strings.TrimPrefix(data, "N")
+		blocks := strings.Split(data, "/")
+
+		for _, block := range blocks {
+			switch {
+			case strings.HasPrefix(block, "ri"):
+				e.Partition = block[2:]
+
+			case strings.HasPrefix(block, "id") || strings.HasPrefix(block, "ti"):
+				// user ID and time are not used
+
+			case len(block) >= 2:
+				e.Code = block[:2]
+				e.Zone = block[2:]
+			}
+		}
+
+		if e.Code == "" {
+			return nil, fmt.Errorf("invalid SIA data '%s'", data)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported protocol '%s'", f.id)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,352 @@
+package dc09
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	timestampTimeLayout = "15:04:05"
+	timestampDateLayout = "01-02-2006"
+
+	// accepted difference between the panel timestamp and the receiver clock.
+	maxTimestampAhead  = 20 * time.Second
+	maxTimestampBehind = 40 * time.Second
+)
+
+// frame is a DC-09 message.
+type frame struct {
+	id        string // SIA-DCS, ADM-CID, NULL, ACK, NAK
+	encrypted bool
+	sequence  string
+	receiver  string // without the R prefix
+	line      string // without the L prefix
+	account   string // without the # prefix
+	data      string // content between brackets, without brackets
+	timestamp *time.Time
+}
+
+// crc16 computes the CRC-16/ARC of a buffer, as required by DC-09.
+func crc16(buf []byte) uint16 {
+	var crc uint16
+	for _, b := range buf {
+		crc ^= uint16(b)
+		for range 8 {
+			if (crc & 1) != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// unmarshalFrame decodes a frame. Leading LF and trailing CR must already be removed.
+func unmarshalFrame(buf []byte, key []byte) (*frame, error) {
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("frame is too short")
+	}
+
+	crc, err := strconv.ParseUint(string(buf[:4]), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRC: %w", err)
+	}
+
+	if buf[4] != '0' {
+		return nil, fmt.Errorf("invalid length")
+	}
+	le, err := strconv.ParseUint(string(buf[5:8]), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid length: %w", err)
+	}
+
+	body := buf[8:]
+
+	if int(le) != len(body) {
+		return nil, fmt.Errorf("length mismatch: expected %d, got %d", le, len(body))
+	}
+
+	if uint16(crc) != crc16(body) {
+		return nil, fmt.Errorf("CRC mismatch")
+	}
+
+	return unmarshalBody(string(body), key)
+}
+
+func unmarshalBody(body string, key []byte) (*frame, error) {
+	f := &frame{}
+
+	if !strings.HasPrefix(body, `"`) {
+		return nil, fmt.Errorf("missing ID token")
+	}
+	end := strings.IndexByte(body[1:], '"')
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated ID token")
+	}
+	f.id = body[1 : end+1]
+	body = body[end+2:]
+
+	if strings.HasPrefix(f.id, "*") {
+		f.encrypted = true
+		f.id = f.id[1:]
+	}
+
+	// sequence number
+	i := strings.IndexAny(body, "RL#[")
+	if i < 0 {
+		return nil, fmt.Errorf("missing sequence number")
+	}
+	f.sequence = body[:i]
+	if len(f.sequence) != 4 {
+		return nil, fmt.Errorf("invalid sequence number '%s'", f.sequence)
+	}
+	body = body[i:]
+
+	// receiver number
+	if strings.HasPrefix(body, "R") {
+		i = strings.IndexAny(body, "L#[")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid receiver number")
+		}
+		f.receiver = body[1:i]
+		body = body[i:]
+	}
+
+	// account prefix
+	if !strings.HasPrefix(body, "L") {
+		return nil, fmt.Errorf("missing account prefix")
+	}
+	i = strings.IndexAny(body, "#[")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid account prefix")
+	}
+	f.line = body[1:i]
+	body = body[i:]
+
+	// account number
+	if strings.HasPrefix(body, "#") {
+		i = strings.IndexByte(body, '[')
+		if i < 0 {
+			return nil, fmt.Errorf("missing data")
+		}
+		f.account = body[1:i]
+		body = body[i:]
+	}
+
+	if !strings.HasPrefix(body, "[") {
+		return nil, fmt.Errorf("missing data")
+	}
+	body = body[1:]
+
+	if f.encrypted {
+		if key == nil {
+			return nil, fmt.Errorf("received an encrypted message but no key is configured")
+		}
+
+		dec, err := decrypt(body, key)
+		if err != nil {
+			return nil, err
+		}
+
+		// remove padding
+		i = strings.IndexByte(dec, '|')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid encrypted data")
+		}
+		body = dec[i:]
+	}
+
+	i = strings.LastIndexByte(body, ']')
+	if i < 0 {
+		return nil, fmt.Errorf("unterminated data")
+	}
+	f.data = body[:i]
+	body = body[i+1:]
+
+	// extended data blocks are not used
+	for strings.HasPrefix(body, "[") {
+		i = strings.IndexByte(body, ']')
+		if i < 0 {
+			return nil, fmt.Errorf("unterminated extended data")
+		}
+		body = body[i+1:]
+	}
+
+	if strings.HasPrefix(body, "_") {
+		ts, err := parseTimestamp(body[1:])
+		if err != nil {
+			return nil, err
+		}
+		f.timestamp = &ts
+	} else if f.encrypted {
+		return nil, fmt.Errorf("encrypted messages must have a timestamp")
+	}
+
+	return f, nil
+}
+
+func (f *frame) marshal(key []byte) ([]byte, error) {
+	var body strings.Builder
+
+	body.WriteString(`"`)
+	if f.encrypted {
+		body.WriteString("*")
+	}
+	body.WriteString(f.id + `"` + f.sequence)
+	if f.receiver != "" {
+		body.WriteString("R" + f.receiver)
+	}
+	body.WriteString("L" + f.line)
+	if f.account != "" {
+		body.WriteString("#" + f.account)
+	}
+	body.WriteString("[")
+
+	tail := f.data + "]"
+	if f.timestamp != nil {
+		tail += "_" + formatTimestamp(*f.timestamp)
+	}
+
+	if f.encrypted {
+		// padding is separated from data by a pipe
+		if !strings.HasPrefix(tail, "|") {
+			tail = "|" + tail
+		}
+
+		enc, err := encrypt(tail, key)
+		if err != nil {
+			return nil, err
+		}
+		body.WriteString(enc)
+	} else {
+		body.WriteString(tail)
+	}
+
+	b := body.String()
+
+	return []byte(fmt.Sprintf("\n%04X0%03X%s\r", crc16([]byte(b)), len(b), b)), nil
+}
+
+// parseTimestamp parses a timestamp in the HH:MM:SS,MM-DD-YYYY format.
+// The two parts are parsed separately since time.Parse treats the comma as a decimal separator.
+func parseTimestamp(v string) (time.Time, error) {
+	parts := strings.Split(v, ",")
+	if len(parts) != 2 {
+		return time.Time{}, fmt.Errorf("invalid timestamp '%s'", v)
+	}
+
+	tm, err := time.Parse(timestampTimeLayout, parts[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	date, err := time.Parse(timestampDateLayout, parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	return date.Add(tm.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC))), nil
+}
+
+func formatTimestamp(t time.Time) string {
+	t = t.UTC()
+	return t.Format(timestampTimeLayout) + "," + t.Format(timestampDateLayout)
+}
+
+// validateTimestamp checks that the frame timestamp is close to the receiver clock.
+func (f *frame) validateTimestamp(now time.Time) error {
+	if f.timestamp == nil {
+		return nil
+	}
+
+	diff := f.timestamp.Sub(now)
+	if diff > maxTimestampAhead || diff < -maxTimestampBehind {
+		return fmt.Errorf("timestamp %s is out of the accepted window", formatTimestamp(*f.timestamp))
+	}
+
+	return nil
+}
+
+// ack returns the acknowledgement of the frame.
+func (f *frame) ack(now time.Time) *frame {
+	ret := &frame{
+		id:        "ACK",
+		encrypted: f.encrypted,
+		sequence:  f.sequence,
+		receiver:  f.receiver,
+		line:      f.line,
+		account:   f.account,
+	}
+	if f.encrypted {
+		ret.timestamp = &now
+	}
+	return ret
+}
+
+// nak returns a negative acknowledgement that carries the receiver clock.
+func nak(now time.Time) *frame {
+	return &frame{
+		id:        "NAK",
+		sequence:  "0000",
+		receiver:  "0",
+		line:      "0",
+		timestamp: &now,
+	}
+}
+
+func decrypt(hexData string, key []byte) (string, error) {
+	buf, err := hex.DecodeString(hexData)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted data: %w", err)
+	}
+
+	if len(buf) == 0 || (len(buf)%aes.BlockSize) != 0 {
+		return "", fmt.Errorf("invalid encrypted data length")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(buf, buf)
+
+	return string(buf), nil
+}
+
+func encrypt(data string, key []byte) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	// pad at the beginning with random characters that are not part of the syntax
+	padLen := aes.BlockSize - (len(data) % aes.BlockSize)
+	if padLen == aes.BlockSize && len(data) != 0 {
+		padLen = 0
+	}
+
+	pad := make([]byte, padLen)
+	_, err = rand.Read(pad)
+	if err != nil {
+		return "", err
+	}
+	for i := range pad {
+		pad[i] = 'A' + (pad[i] % 26)
+	}
+
+	buf := append(pad, []byte(data)...)
+
+	iv := make([]byte, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(buf, buf)
+
+	return strings.ToUpper(hex.EncodeToString(buf)), nil
+}
@@ -0,0 +1,156 @@
+package dc09
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRC16(t *testing.T) {
+	// reference value of CRC-16/ARC
+	require.Equal(t, uint16(0xBB3D), crc16([]byte("123456789")))
+}
+
+func TestFrameUnmarshal(t *testing.T) {
+	for _, ca := range []struct {
+		name  string
+		body  string
+		frame *frame
+		event *Event
+	}{
+		{
+			"contact id",
+			`"ADM-CID"0001R1L0#1234[#1234|1130 01 015]`,
+			&frame{
+				id:       "ADM-CID",
+				sequence: "0001",
+				receiver: "1",
+				line:     "0",
+				account:  "1234",
+				data:     "#1234|1130 01 015",
+			},
+			&Event{
+				Protocol:  ProtocolContactID,
+				Account:   "1234",
+				Receiver:  "1",
+				Line:      "0",
+				Sequence:  "0001",
+				Qualifier: "1",
+				Code:      "130",
+				Partition: "01",
+				Zone:      "015",
+			},
+		},
+		{
+			"sia",
+			`"SIA-DCS"0002L0#AB12[#AB12|Nri1/BA001]`,
+			&frame{
+				id:       "SIA-DCS",
+				sequence: "0002",
+				line:     "0",
+				account:  "AB12",
+				data:     "#AB12|Nri1/BA001",
+			},
+			&Event{
+				Protocol:  ProtocolSIA,
+				Account:   "AB12",
+				Line:      "0",
+				Sequence:  "0002",
+				Code:      "BA",
+				Partition: "1",
+				Zone:      "001",
+			},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			buf := []byte(strings.TrimSuffix(strings.TrimPrefix(string(mustMarshal(t, ca.frame, nil)), "\n"), "\r"))
+			require.Equal(t, ca.body, string(buf[8:]))
+
+			f, err := unmarshalFrame(buf, nil)
+			require.NoError(t, err)
+			require.Equal(t, ca.frame, f)
+
+			e, err := parseData(f)
+			require.NoError(t, err)
+			require.Equal(t, ca.event, e)
+		})
+	}
+}
+
+func TestFrameUnmarshalErrors(t *testing.T) {
+	_, err := unmarshalFrame([]byte(`00000012"NULL"0000R0L0#1234[]`), nil)
+	require.EqualError(t, err, "length mismatch: expected 18, got 21")
+
+	_, err = unmarshalFrame([]byte(`00000015"NULL"0000R0L0#1234[]`), nil)
+	require.EqualError(t, err, "CRC mismatch")
+}
+
+func TestFrameEncrypted(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	f := &frame{
+		id:        "SIA-DCS",
+		encrypted: true,
+		sequence:  "0003",
+		line:      "0",
+		account:   "1234",
+		data:      "|Nri1/CL501",
+		timestamp: &ts,
+	}
+
+	buf := mustMarshal(t, f, key)
+	require.NotContains(t, string(buf), "CL501")
+
+	dec, err := unmarshalFrame(buf[1:len(buf)-1], key)
+	require.NoError(t, err)
+	require.Equal(t, f, dec)
+
+	e, err := parseData(dec)
+	require.NoError(t, err)
+	status, ok := e.ArmStatus()
+	require.True(t, ok)
+	require.Equal(t, "arm", status)
+
+	_, err = unmarshalFrame(buf[1:len(buf)-1], nil)
+	require.EqualError(t, err, "received an encrypted message but no key is configured")
+}
+
+func TestFrameTimestamp(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	ts := now.Add(-30 * time.Second)
+	require.NoError(t, (&frame{timestamp: &ts}).validateTimestamp(now))
+
+	ts = now.Add(-50 * time.Second)
+	require.Error(t, (&frame{timestamp: &ts}).validateTimestamp(now))
+
+	ts = now.Add(30 * time.Second)
+	require.Error(t, (&frame{timestamp: &ts}).validateTimestamp(now))
+}
+
+func TestArmStatus(t *testing.T) {
+	for _, ca := range []struct {
+		event  Event
+		status string
+		ok     bool
+	}{
+		{Event{Protocol: ProtocolContactID, Qualifier: "1", Code: "401"}, "disarm", true},
+		{Event{Protocol: ProtocolContactID, Qualifier: "3", Code: "401"}, "arm", true},
+		{Event{Protocol: ProtocolContactID, Qualifier: "1", Code: "130"}, "", false},
+		{Event{Protocol: ProtocolSIA, Code: "OP"}, "disarm", true},
+		{Event{Protocol: ProtocolSIA, Code: "BA"}, "", false},
+	} {
+		status, ok := ca.event.ArmStatus()
+		require.Equal(t, ca.status, status)
+		require.Equal(t, ca.ok, ok)
+	}
+}
+
+func mustMarshal(t *testing.T, f *frame, key []byte) []byte {
+	buf, err := f.marshal(key)
+	require.NoError(t, err)
+	return buf
+}
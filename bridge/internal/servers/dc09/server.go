@@ -0,0 +1,258 @@
+// Package dc09 contains a SIA DC-09 receiver for intrusion panels.
+package dc09
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+const (
+	maxFrameSize = 1024
+)
+
+type serverParent interface {
+	logger.Writer
+}
+
+// Server is a DC-09 receiver that listens on both TCP and UDP.
+type Server struct {
+	Address     string
+	Key         string // hex-encoded AES key, optional
+	ReadTimeout time.Duration
+	Parent      serverParent
+
+	ctx       context.Context
+	ctxCancel func()
+	wg        sync.WaitGroup
+
+	key   []byte
+	tcpLn net.Listener
+	udpLn net.PacketConn
+
+	// out
+	ChEvent *chan Event
+}
+
+// Initialize initializes the server.
+func (s *Server) Initialize() error {
+	if s.Key != "" {
+		var err error
+		s.key, err = hex.DecodeString(s.Key)
+		if err != nil {
+			return fmt.Errorf("invalid DC-09 key: %w", err)
+		}
+
+		switch len(s.key) {
+		case 16, 24, 32:
+		default:
+			return fmt.Errorf("invalid DC-09 key: must be 128, 192 or 256 bits long")
+		}
+	}
+
+	var err error
+	s.tcpLn, err = net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+
+	s.udpLn, err = net.ListenPacket("udp", s.Address)
+	if err != nil {
+		s.tcpLn.Close()
+		return err
+	}
+
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+
+	s.wg.Add(2)
+	go s.runTCP()
+	go s.runUDP()
+
+	s.Log(logger.Info, "listener opened on %s (TCP/UDP)", s.Address)
+
+	return nil
+}
+
+// Log implements logger.Writer.
+func (s *Server) Log(level logger.Level, format string, args ...interface{}) {
+	s.Parent.Log(level, "[DC-09] "+format, args...)
+}
+
+// Close closes the server.
+func (s *Server) Close() {
+	s.Log(logger.Info, "listener is closing")
+
+	s.ctxCancel()
+	s.tcpLn.Close()
+	s.udpLn.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) runTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcpLn.Accept()
+		if err != nil {
+			select {
+			case <-s.ctx.Done():
+			default:
+				s.Log(logger.Error, "accept error: %v", err)
+			}
+			return
+		}
+
+		s.wg.Add(1)
+		go s.runConn(conn)
+	}
+}
+
+func (s *Server) runConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	fromIP := remoteIP(conn.RemoteAddr())
+	r := bufio.NewReaderSize(conn, maxFrameSize)
+
+	for {
+		if s.ReadTimeout != 0 {
+			conn.SetReadDeadline(time.Now().Add(s.ReadTimeout)) //nolint:errcheck
+		}
+
+		buf, err := r.ReadBytes('\r')
+		if err != nil {
+			return
+		}
+
+		res := s.handleFrame(buf, fromIP)
+		if res == nil {
+			continue
+		}
+
+		_, err = conn.Write(res)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) runUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxFrameSize)
+
+	for {
+		n, addr, err := s.udpLn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.ctx.Done():
+			default:
+				s.Log(logger.Error, "read error: %v", err)
+			}
+			return
+		}
+
+		res := s.handleFrame(buf[:n], remoteIP(addr))
+		if res == nil {
+			continue
+		}
+
+		s.udpLn.WriteTo(res, addr) //nolint:errcheck
+	}
+}
+
+// handleFrame processes a frame and returns the response to send back.
+func (s *Server) handleFrame(buf []byte, fromIP string) []byte {
+	now := time.Now().UTC()
+
+	// frames start with LF and end with CR
+	i := bytes.IndexByte(buf, '\n')
+	if i < 0 {
+		s.Log(logger.Warn, "[%s] discarding data without a frame start", fromIP)
+		return nil
+	}
+	buf = bytes.TrimSuffix(buf[i+1:], []byte("\r"))
+
+	f, err := unmarshalFrame(buf, s.key)
+	if err != nil {
+		s.Log(logger.Warn, "[%s] invalid frame: %v", fromIP, err)
+		return s.marshalResponse(nak(now))
+	}
+
+	err = f.validateTimestamp(now)
+	if err != nil {
+		s.Log(logger.Warn, "[%s] %v", fromIP, err)
+		return s.marshalResponse(nak(now))
+	}
+
+	switch f.id {
+	case "NULL":
+		s.Log(logger.Debug, "[%s] link test from account %s", fromIP, f.account)
+
+	case ProtocolContactID, ProtocolSIA:
+		e, err := parseData(f)
+		if err != nil {
+			s.Log(logger.Warn, "[%s] invalid data: %v", fromIP, err)
+			return s.marshalResponse(nak(now))
+		}
+		e.FromIP = fromIP
+
+		s.Log(logger.Debug, "[%s] event %s %s%s zone %s from account %s",
+			fromIP, e.Protocol, e.Qualifier, e.Code, e.Zone, e.Account)
+
+		select {
+		case (*s.ChEvent) <- *e:
+		default:
+			s.Log(logger.Warn, "Channel is full, dropping event")
+		}
+
+	default:
+		s.Log(logger.Warn, "[%s] unsupported message type '%s'", fromIP, f.id)
+		return s.marshalResponse(&frame{
+			id:       "DUH",
+			sequence: f.sequence,
+			receiver: f.receiver,
+			line:     f.line,
+			account:  f.account,
+		})
+	}
+
+	return s.marshalResponse(f.ack(now))
+}
+
+func (s *Server) marshalResponse(f *frame) []byte {
+	buf, err := f.marshal(s.key)
+	if err != nil {
+		s.Log(logger.Error, "unable to encode response: %v", err)
+		return nil
+	}
+	return buf
+}
+
+func remoteIP(addr net.Addr) string {
+	if addr == nil {
+		return "unknown"
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
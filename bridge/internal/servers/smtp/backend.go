@@ -135,6 +135,7 @@ func (s *Session) Data(r io.Reader) error {
 		From:    s.from,
 		FromIP:  s.fromIP,
 		To:      s.to,
+		Subject: ParseSubject(data),
 		Content: data,
 		Parts:   parts,
 	}
@@ -2,213 +2,227 @@ package smtp
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
+	"mime/quotedprintable"
 	"net/mail"
+	"net/textproto"
 	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+const (
+	maxPartDepth = 10
 )
 
-// EmailPart represents a part of a multipart email
+// EmailPart represents a leaf part of an email, with its content decoded.
+// Text parts are converted to UTF-8.
 type EmailPart struct {
-	ContentType string
+	ContentType string // media type, i.e. text/plain
+	Charset     string // original charset of text parts
+	Filename    string
+	Attachment  bool
 	Content     []byte
-	Headers     map[string]string
+	Headers     map[string]string // with RFC 2047 encoded-words decoded
 }
 
-// ParseMultipartEmail parses a multipart email and returns an array of EmailPart
-func ParseMultipartEmail(data []byte) ([]EmailPart, error) {
-	var parts []EmailPart
-
-	// Parse the email using net/mail
-	msg, err := mail.ReadMessage(bytes.NewReader(data))
-	if err != nil {
-		return nil, fmt.Errorf("failed to read message: %v", err)
-	}
-
-	// Get the content type
-	contentType := msg.Header.Get("Content-Type")
-
-	// If not multipart, return as single part
-	if !strings.Contains(contentType, "multipart") {
-		body, err := io.ReadAll(msg.Body)
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(charset)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read body: %v", err)
-		}
-
-		headers := make(map[string]string)
-		for key, values := range msg.Header {
-			headers[key] = values[0]
+			return nil, err
 		}
+		return transform.NewReader(input, enc.NewDecoder()), nil
+	},
+}
 
-		parts = append(parts, EmailPart{
-			ContentType: contentType,
-			Content:     body,
-			Headers:     headers,
-		})
-		return parts, nil
-	}
-
-	// Parse multipart content
-	_, params, err := mime.ParseMediaType(contentType)
+// DecodeHeader decodes RFC 2047 encoded-words in a header value.
+// Values that can't be decoded are returned as they are.
+func DecodeHeader(v string) string {
+	dec, err := wordDecoder.DecodeHeader(v)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse media type: %v", err)
+		return v
 	}
+	return dec
+}
 
-	boundary, ok := params["boundary"]
-	if !ok {
-		return nil, fmt.Errorf("no boundary found in multipart content type")
+// ParseSubject returns the decoded subject of an email.
+func ParseSubject(data []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return ""
 	}
+	return DecodeHeader(msg.Header.Get("Subject"))
+}
 
-	// Clean up boundary string (remove quotes if present)
-	boundary = strings.Trim(boundary, `"'`)
-
-	// Read the entire body first
-	bodyBytes, err := io.ReadAll(msg.Body)
+// ParseMultipartEmail parses an email and returns its leaf parts.
+// Nested multipart entities are walked recursively; Content-Transfer-Encoding
+// and charsets are decoded.
+func ParseMultipartEmail(data []byte) ([]EmailPart, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read body: %v", err)
+		return nil, fmt.Errorf("failed to read message: %w", err)
 	}
 
-	// Try to parse using multipart reader first
-	parts, err = parseWithMultipartReader(bodyBytes, boundary)
+	var parts []EmailPart
+	err = parseEntity(textproto.MIMEHeader(msg.Header), msg.Body, 0, &parts)
 	if err != nil {
-		// If multipart reader fails, try manual parsing as fallback
-		parts, err = parseManually(bodyBytes, boundary)
-		if err != nil {
-			return nil, fmt.Errorf("both multipart reader and manual parsing failed: %v", err)
+		// keep what has been decoded before a malformed boundary
+		if len(parts) != 0 {
+			return parts, nil
 		}
+		return nil, err
 	}
 
 	return parts, nil
 }
 
-// parseManually parses multipart content manually as a fallback
-func parseManually(bodyBytes []byte, boundary string) ([]EmailPart, error) {
-	var emailParts []EmailPart
-
-	// Convert body to string for easier manipulation
-	bodyStr := string(bodyBytes)
-
-	// Split by boundary
-	boundaryMarker := "--" + boundary
-	sections := strings.Split(bodyStr, boundaryMarker)
-
-	// Skip the first section if it's empty or doesn't contain content
-	startIndex := 0
-	if len(sections) > 0 && strings.TrimSpace(sections[0]) == "" {
-		startIndex = 1
+func parseEntity(header textproto.MIMEHeader, body io.Reader, depth int, parts *[]EmailPart) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// RFC 2045: default is text/plain; charset=us-ascii
+		mediaType, params = "text/plain", map[string]string{}
 	}
 
-	// Process each section
-	for i := startIndex; i < len(sections); i++ {
-		section := sections[i]
-
-		// Skip empty sections or the final boundary
-		if strings.TrimSpace(section) == "" || strings.TrimSpace(section) == "--" {
-			continue
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxPartDepth {
+			return fmt.Errorf("too many nested parts")
 		}
 
-		// Find the first double newline to separate headers from content
-		sectionParts := strings.SplitN(section, "\r\n\r\n", 2)
-		if len(sectionParts) < 2 {
-			sectionParts = strings.SplitN(section, "\n\n", 2)
+		boundary := params["boundary"]
+		if boundary == "" {
+			return fmt.Errorf("no boundary found in multipart content type")
 		}
 
-		if len(sectionParts) < 2 {
-			// If we can't find proper separation, treat the whole section as content
-			content := strings.TrimSpace(section)
-			if content != "" {
-				emailParts = append(emailParts, EmailPart{
-					ContentType: "text/plain",
-					Content:     []byte(content),
-					Headers:     make(map[string]string),
-				})
-			}
-			continue
-		}
+		mr := multipart.NewReader(body, boundary)
 
-		// Parse headers
-		headers := make(map[string]string)
-		headerLines := strings.Split(sectionParts[0], "\n")
-		for _, line := range headerLines {
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
+		for {
+			// raw parts are requested, since NextPart() decodes quoted-printable only
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read next part: %w", err)
 			}
 
-			if strings.Contains(line, ":") {
-				headerParts := strings.SplitN(line, ":", 2)
-				if len(headerParts) == 2 {
-					key := strings.TrimSpace(headerParts[0])
-					value := strings.TrimSpace(headerParts[1])
-					headers[key] = value
-				}
+			err = parseEntity(part.Header, part, depth+1, parts)
+			if err != nil {
+				return err
 			}
 		}
+	}
 
-		// Get content type
-		contentType := headers["Content-Type"]
-		if contentType == "" {
-			contentType = "text/plain"
-		}
+	content, err := io.ReadAll(decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode part: %w", err)
+	}
 
-		// Clean up content (remove trailing boundary markers)
-		content := strings.TrimSpace(sectionParts[1])
-		content = strings.TrimSuffix(content, "--")
-		content = strings.TrimSpace(content)
-
-		if content != "" {
-			emailParts = append(emailParts, EmailPart{
-				ContentType: contentType,
-				Content:     []byte(content),
-				Headers:     headers,
-			})
-		}
+	part := EmailPart{
+		ContentType: mediaType,
+		Headers:     make(map[string]string),
+	}
+
+	for key, values := range header {
+		part.Headers[key] = DecodeHeader(values[0])
+	}
+
+	disposition, dparams, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err == nil {
+		part.Filename = dparams["filename"]
+		part.Attachment = disposition == "attachment"
+	}
+	if part.Filename == "" {
+		part.Filename = params["name"]
+	}
+	part.Filename = DecodeHeader(part.Filename)
+
+	if part.Filename != "" && !strings.HasPrefix(mediaType, "text/") {
+		part.Attachment = true
+	}
+
+	if strings.HasPrefix(mediaType, "text/") {
+		part.Charset = strings.ToLower(params["charset"])
+		content = decodeCharset(part.Charset, content)
 	}
 
-	return emailParts, nil
+	part.Content = content
+	*parts = append(*parts, part)
+
+	return nil
 }
 
-// parseWithMultipartReader tries to parse using the standard multipart reader
-func parseWithMultipartReader(bodyBytes []byte, boundary string) ([]EmailPart, error) {
-	var parts []EmailPart
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// line breaks are skipped by the decoder, other whitespace is not
+		return base64.NewDecoder(base64.StdEncoding, &whitespaceSkipper{r: r})
 
-	mr := multipart.NewReader(bytes.NewReader(bodyBytes), boundary)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
 
+	// 7bit, 8bit, binary
+	return r
+}
+
+// whitespaceSkipper removes spaces and tabs that some encoders put into base64 bodies.
+type whitespaceSkipper struct {
+	r io.Reader
+}
+
+func (s *whitespaceSkipper) Read(p []byte) (int, error) {
 	for {
-		part, err := mr.NextPart()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to read next part: %v", err)
-		}
+		n, err := s.r.Read(p)
 
-		// Read the part content
-		content, err := io.ReadAll(part)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read part content: %v", err)
+		j := 0
+		for _, c := range p[:n] {
+			if c != ' ' && c != '\t' {
+				p[j] = c
+				j++
+			}
 		}
 
-		// Get part headers
-		headers := make(map[string]string)
-		for key, values := range part.Header {
-			headers[key] = values[0]
+		if j != 0 || err != nil {
+			return j, err
 		}
+	}
+}
 
-		// Get content type of this part
-		partContentType := part.Header.Get("Content-Type")
-		if partContentType == "" {
-			partContentType = "text/plain"
-		}
+// decodeCharset converts text into UTF-8. Text in an unknown charset is left unchanged.
+func decodeCharset(charset string, content []byte) []byte {
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii":
+		return content
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return content
+	}
 
-		parts = append(parts, EmailPart{
-			ContentType: partContentType,
-			Content:     content,
-			Headers:     headers,
-		})
+	dec, _, err := transform.Bytes(enc.NewDecoder(), content)
+	if err != nil {
+		return content
 	}
 
-	return parts, nil
+	return dec
+}
+
+// Text returns the content of the first text part that is not an attachment.
+// Plain text is preferred to HTML.
+func (m *Mail) Text() (string, bool) {
+	for _, mediaType := range []string{"text/plain", "text/html"} {
+		for _, part := range m.Parts {
+			if part.ContentType == mediaType && !part.Attachment {
+				return string(part.Content), true
+			}
+		}
+	}
+	return "", false
 }
@@ -0,0 +1,126 @@
+package smtp
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func mustEncode(t *testing.T, s string, enc interface{ Bytes([]byte) ([]byte, error) }) []byte {
+	buf, err := enc.Bytes([]byte(s))
+	require.NoError(t, err)
+	return buf
+}
+
+func TestParseMultipartEmailNested(t *testing.T) {
+	eucKR := mustEncode(t, "알람 이벤트: 움직임 감지", korean.EUCKR.NewEncoder())
+	gb2312 := mustEncode(t, "报警事件: 移动侦测", simplifiedchinese.GBK.NewEncoder())
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10}
+
+	data := "From: camera@example.com\r\n" +
+		"To: alarms@example.com\r\n" +
+		"Subject: =?EUC-KR?B?" + base64.StdEncoding.EncodeToString(eucKR) + "?=\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+		"\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/related; boundary=inner\r\n" +
+		"\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/plain; charset=EUC-KR\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		base64.StdEncoding.EncodeToString(eucKR) + "\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/html; charset=gb2312\r\n" +
+		"Content-Transfer-Encoding: 8bit\r\n" +
+		"\r\n" +
+		string(gb2312) + "\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Alarm Event: Motion Detection=0D=0A=\r\n" +
+		"Alarm Input Channel: 1\r\n" +
+		"--outer\r\n" +
+		"Content-Type: image/jpeg; name=\"=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte("스냅샷.jpg")) + "?=\"\r\n" +
+		"Content-Disposition: inline\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		base64.StdEncoding.EncodeToString(jpeg) + "\r\n" +
+		"--outer--\r\n"
+
+	parts, err := ParseMultipartEmail([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, parts, 4)
+
+	require.Equal(t, "text/plain", parts[0].ContentType)
+	require.Equal(t, "euc-kr", parts[0].Charset)
+	require.Equal(t, "알람 이벤트: 움직임 감지", string(parts[0].Content))
+	require.False(t, parts[0].Attachment)
+
+	require.Equal(t, "text/html", parts[1].ContentType)
+	require.Equal(t, "报警事件: 移动侦测", string(parts[1].Content))
+
+	require.Equal(t, "Alarm Event: Motion Detection\r\nAlarm Input Channel: 1", string(parts[2].Content))
+
+	require.Equal(t, "image/jpeg", parts[3].ContentType)
+	require.Equal(t, "스냅샷.jpg", parts[3].Filename)
+	require.True(t, parts[3].Attachment)
+	require.Equal(t, jpeg, parts[3].Content)
+
+	require.Equal(t, "알람 이벤트: 움직임 감지", ParseSubject([]byte(data)))
+
+	mail := &Mail{Parts: parts}
+	text, ok := mail.Text()
+	require.True(t, ok)
+	require.Equal(t, "알람 이벤트: 움직임 감지", text)
+}
+
+func TestParseMultipartEmailSinglePart(t *testing.T) {
+	data := "From: camera@example.com\r\n" +
+		"Subject: =?UTF-8?Q?Alarm_=EC=95=8C=EB=9E=8C?=\r\n" +
+		"Content-Type: text/plain; charset=\"utf-8\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"QWxhcm0gRXZlbnQ6IE1v dGlvbiBEZXRlY3Rpb24=\r\n"
+
+	parts, err := ParseMultipartEmail([]byte(data))
+	require.NoError(t, err)
+	require.Equal(t, []EmailPart{{
+		ContentType: "text/plain",
+		Charset:     "utf-8",
+		Content:     []byte("Alarm Event: Motion Detection"),
+		Headers: map[string]string{
+			"From":                      "camera@example.com",
+			"Subject":                   "Alarm 알람",
+			"Content-Type":              "text/plain; charset=\"utf-8\"",
+			"Content-Transfer-Encoding": "base64",
+		},
+	}}, parts)
+}
+
+func TestParseMultipartEmailAttachment(t *testing.T) {
+	data := "Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"Content-Disposition: attachment; filename*=UTF-8''%EC%98%81%EC%83%81.dav\r\n" +
+		"\r\n" +
+		"binary\r\n" +
+		"--b--\r\n"
+
+	parts, err := ParseMultipartEmail([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	require.Equal(t, "영상.dav", parts[0].Filename)
+	require.True(t, parts[0].Attachment)
+	require.Equal(t, []byte("binary"), parts[0].Content)
+
+	_, ok := (&Mail{Parts: parts}).Text()
+	require.False(t, ok)
+}
@@ -14,6 +14,7 @@ type Mail struct {
 	From    string
 	FromIP  string
 	To      []string
+	Subject string
 	Parts   []EmailPart
 	Content []byte
 }
@@ -0,0 +1,223 @@
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of syslog messages.
+const (
+	FormatRFC3164 = "rfc3164"
+	FormatRFC5424 = "rfc5424"
+)
+
+// Severities of syslog messages.
+const (
+	SeverityEmergency = 0
+	SeverityAlert     = 1
+	SeverityCritical  = 2
+	SeverityError     = 3
+	SeverityWarning   = 4
+	SeverityNotice    = 5
+	SeverityInfo      = 6
+	SeverityDebug     = 7
+)
+
+// Message is a syslog message.
+type Message struct {
+	Format         string
+	Facility       int
+	Severity       int
+	Timestamp      *time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]map[string]string // [SD-ID][param]
+	Content        string
+	FromIP         string
+}
+
+// Unmarshal decodes a RFC 3164 or RFC 5424 message.
+// RFC 3164 is decoded in a lenient way, since devices often deviate from it.
+func (m *Message) Unmarshal(buf []byte, now time.Time) error {
+	s := strings.TrimRight(string(buf), "\r\n\x00")
+
+	if !strings.HasPrefix(s, "<") {
+		return fmt.Errorf("missing priority")
+	}
+
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return fmt.Errorf("invalid priority")
+	}
+
+	pri, err := strconv.ParseUint(s[1:end], 10, 8)
+	if err != nil || pri > 191 {
+		return fmt.Errorf("invalid priority '%s'", s[1:end])
+	}
+	m.Facility = int(pri / 8)
+	m.Severity = int(pri % 8)
+
+	s = s[end+1:]
+
+	if strings.HasPrefix(s, "1 ") {
+		m.Format = FormatRFC5424
+		return m.unmarshalRFC5424(s[2:])
+	}
+
+	m.Format = FormatRFC3164
+	m.unmarshalRFC3164(s, now)
+	return nil
+}
+
+// <TIMESTAMP> <HOSTNAME> <APP-NAME> <PROCID> <MSGID> <STRUCTURED-DATA> [MSG]
+func (m *Message) unmarshalRFC5424(s string) error {
+	fields := make([]string, 5)
+
+	for i := range fields {
+		var ok bool
+		fields[i], s, ok = strings.Cut(s, " ")
+		if !ok {
+			return fmt.Errorf("truncated header")
+		}
+		if fields[i] == "-" {
+			fields[i] = ""
+		}
+	}
+
+	if fields[0] != "" {
+		t, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid timestamp '%s'", fields[0])
+		}
+		m.Timestamp = &t
+	}
+
+	m.Hostname = fields[1]
+	m.AppName = fields[2]
+	m.ProcID = fields[3]
+	m.MsgID = fields[4]
+
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	} else {
+		var err error
+		m.StructuredData, s, err = unmarshalStructuredData(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	s = strings.TrimPrefix(s, " ")
+	s = strings.TrimPrefix(s, "\xEF\xBB\xBF") // BOM
+	m.Content = s
+
+	return nil
+}
+
+// unmarshalStructuredData decodes one or more [SD-ID param="value" ...] elements.
+func unmarshalStructuredData(s string) (map[string]map[string]string, string, error) {
+	ret := make(map[string]map[string]string)
+
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+
+		i := strings.IndexAny(s, " ]")
+		if i <= 0 {
+			return nil, "", fmt.Errorf("invalid structured data")
+		}
+
+		params := make(map[string]string)
+		ret[s[:i]] = params
+		s = s[i:]
+
+		for {
+			s = strings.TrimLeft(s, " ")
+
+			if strings.HasPrefix(s, "]") {
+				s = s[1:]
+				break
+			}
+
+			name, rest, ok := strings.Cut(s, `="`)
+			if !ok || name == "" {
+				return nil, "", fmt.Errorf("invalid structured data parameter")
+			}
+
+			var value strings.Builder
+			closed := false
+
+			for i := 0; i < len(rest); i++ {
+				c := rest[i]
+
+				if c == '\\' && i+1 < len(rest) && strings.IndexByte(`"\]`, rest[i+1]) >= 0 {
+					value.WriteByte(rest[i+1])
+					i++
+					continue
+				}
+
+				if c == '"' {
+					s = rest[i+1:]
+					closed = true
+					break
+				}
+
+				value.WriteByte(c)
+			}
+
+			if !closed {
+				return nil, "", fmt.Errorf("unterminated structured data parameter")
+			}
+
+			params[name] = value.String()
+		}
+	}
+
+	return ret, s, nil
+}
+
+// <TIMESTAMP> <HOSTNAME> <TAG>: <MSG>
+func (m *Message) unmarshalRFC3164(s string, now time.Time) {
+	// timestamp is in the "Mmm dd hh:mm:ss" format and doesn't include the year
+	hasHeader := false
+	if len(s) >= 16 && s[15] == ' ' {
+		t, err := time.ParseInLocation(time.Stamp, s[:15], now.Location())
+		if err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+
+			// messages sent at the end of the year and received at the beginning of the next one
+			if t.Sub(now) > 24*time.Hour {
+				t = t.AddDate(-1, 0, 0)
+			}
+
+			m.Timestamp = &t
+			s = s[16:]
+			hasHeader = true
+
+			// hostname is present only when the timestamp is
+			if host, rest, ok := strings.Cut(s, " "); ok && !strings.HasSuffix(host, ":") {
+				m.Hostname = host
+				s = rest
+			}
+		}
+	}
+
+	// tag is made of alphanumeric characters, optionally followed by [pid], and ends with a colon.
+	// It is not parsed when the header is missing, since the content may contain colons too.
+	if i := strings.Index(s, ": "); hasHeader && i > 0 && !strings.ContainsAny(s[:i], " \t") {
+		tag := s[:i]
+
+		if j := strings.IndexByte(tag, '['); j > 0 && strings.HasSuffix(tag, "]") {
+			m.ProcID = tag[j+1 : len(tag)-1]
+			tag = tag[:j]
+		}
+
+		m.AppName = tag
+		s = s[i+2:]
+	}
+
+	m.Content = strings.TrimSpace(s)
+}
@@ -0,0 +1,135 @@
+package syslog
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageUnmarshal(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	ts1 := time.Date(2024, 3, 10, 11, 59, 58, 0, time.UTC)
+	ts2 := time.Date(2024, 3, 10, 11, 59, 58, 123000000, time.UTC)
+	ts3 := time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	for _, ca := range []struct {
+		name string
+		raw  string
+		msg  Message
+	}{
+		{
+			"rfc3164",
+			"<36>Mar 10 11:59:58 NVR-01 sshd[123]: Failed password for admin",
+			Message{
+				Format:    FormatRFC3164,
+				Facility:  4,
+				Severity:  SeverityWarning,
+				Timestamp: &ts1,
+				Hostname:  "NVR-01",
+				AppName:   "sshd",
+				ProcID:    "123",
+				Content:   "Failed password for admin",
+			},
+		},
+		{
+			"rfc3164 previous year",
+			"<14>Dec 31 23:59:59 cam kernel: disk error\n",
+			Message{
+				Format:    FormatRFC3164,
+				Facility:  1,
+				Severity:  SeverityInfo,
+				Timestamp: &ts3,
+				Hostname:  "cam",
+				AppName:   "kernel",
+				Content:   "disk error",
+			},
+		},
+		{
+			"rfc3164 without header",
+			"<11>Event: LoginFailure, User: admin",
+			Message{
+				Format:   FormatRFC3164,
+				Facility: 1,
+				Severity: SeverityError,
+				Content:  "Event: LoginFailure, User: admin",
+			},
+		},
+		{
+			"rfc5424",
+			`<165>1 2024-03-10T11:59:58.123Z cam01 dahua 77 LOGIN [origin ip="10.0.0.2" x="a\"b\]"][meta seq="1"] ` +
+				"\xEF\xBB\xBFLogin failed",
+			Message{
+				Format:    FormatRFC5424,
+				Facility:  20,
+				Severity:  SeverityNotice,
+				Timestamp: &ts2,
+				Hostname:  "cam01",
+				AppName:   "dahua",
+				ProcID:    "77",
+				MsgID:     "LOGIN",
+				StructuredData: map[string]map[string]string{
+					"origin": {"ip": "10.0.0.2", "x": `a"b]`},
+					"meta":   {"seq": "1"},
+				},
+				Content: "Login failed",
+			},
+		},
+		{
+			"rfc5424 nil values",
+			"<14>1 - - - - - -",
+			Message{
+				Format:   FormatRFC5424,
+				Facility: 1,
+				Severity: SeverityInfo,
+			},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			var m Message
+			err := m.Unmarshal([]byte(ca.raw), now)
+			require.NoError(t, err)
+			require.Equal(t, ca.msg, m)
+		})
+	}
+}
+
+func TestMessageUnmarshalErrors(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		raw  string
+		err  string
+	}{
+		{"missing priority", "hello", "missing priority"},
+		{"invalid priority", "<999>hello", "invalid priority '999'"},
+		{"truncated header", "<14>1 2024-03-10T11:59:58Z host", "truncated header"},
+		{"invalid timestamp", "<14>1 yesterday host app - - - msg", "invalid timestamp 'yesterday'"},
+		{"unterminated sd", `<14>1 - host app - - [a b="c] msg`, "unterminated structured data parameter"},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			var m Message
+			err := m.Unmarshal([]byte(ca.raw), time.Now())
+			require.EqualError(t, err, ca.err)
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader(
+		"15 <14>1 - - - - -" +
+			"<14>plain message\n" +
+			"\n" +
+			"<14>nul terminated\x00"))
+
+	for _, expected := range []string{
+		"<14>1 - - - - -",
+		"<14>plain message",
+		"<14>nul terminated",
+	} {
+		buf, err := readFrame(r)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(buf))
+	}
+}
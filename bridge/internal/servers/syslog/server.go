@@ -0,0 +1,287 @@
+// Package syslog contains a syslog receiver for camera and NVR security events.
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/certloader"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/kaonmir/mini-chekt/internal/restrictnetwork"
+)
+
+const (
+	maxMessageSize = 64 * 1024
+)
+
+type serverParent interface {
+	logger.Writer
+}
+
+// Server is a syslog receiver.
+// It listens on UDP and TCP (RFC 5426, RFC 6587) and optionally on TLS (RFC 5425).
+type Server struct {
+	Address    string // UDP and TCP, optional
+	TLSAddress string // optional
+	ServerKey  string
+	ServerCert string
+	Parent     serverParent
+
+	ctx       context.Context
+	ctxCancel func()
+	wg        sync.WaitGroup
+
+	loader *certloader.CertLoader
+	lns    []net.Listener
+	udpLn  net.PacketConn
+
+	// out
+	ChMessage *chan Message
+}
+
+// Initialize initializes the server.
+func (s *Server) Initialize() error {
+	err := s.listen()
+	if err != nil {
+		s.closeListeners()
+		return err
+	}
+
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+
+	for _, ln := range s.lns {
+		s.wg.Add(1)
+		go s.runTCP(ln)
+	}
+
+	if s.udpLn != nil {
+		s.wg.Add(1)
+		go s.runUDP()
+	}
+
+	if s.Address != "" {
+		s.Log(logger.Info, "listener opened on %s (TCP/UDP)", s.Address)
+	}
+	if s.TLSAddress != "" {
+		s.Log(logger.Info, "listener opened on %s (TLS)", s.TLSAddress)
+	}
+
+	return nil
+}
+
+func (s *Server) listen() error {
+	if s.Address != "" {
+		ln, err := net.Listen(restrictnetwork.Restrict("tcp", s.Address))
+		if err != nil {
+			return err
+		}
+		s.lns = append(s.lns, ln)
+
+		s.udpLn, err = net.ListenPacket(restrictnetwork.Restrict("udp", s.Address))
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.TLSAddress != "" {
+		s.loader = &certloader.CertLoader{
+			CertPath: s.ServerCert,
+			KeyPath:  s.ServerKey,
+			Parent:   s.Parent,
+		}
+		err := s.loader.Initialize()
+		if err != nil {
+			s.loader = nil
+			return err
+		}
+
+		network, address := restrictnetwork.Restrict("tcp", s.TLSAddress)
+		ln, err := tls.Listen(network, address, &tls.Config{GetCertificate: s.loader.GetCertificate()})
+		if err != nil {
+			return err
+		}
+		s.lns = append(s.lns, ln)
+	}
+
+	return nil
+}
+
+func (s *Server) closeListeners() {
+	for _, ln := range s.lns {
+		ln.Close()
+	}
+	if s.udpLn != nil {
+		s.udpLn.Close()
+	}
+	if s.loader != nil {
+		s.loader.Close()
+	}
+}
+
+// Log implements logger.Writer.
+func (s *Server) Log(level logger.Level, format string, args ...interface{}) {
+	s.Parent.Log(level, "[syslog] "+format, args...)
+}
+
+// Close closes the server.
+func (s *Server) Close() {
+	s.Log(logger.Info, "listener is closing")
+
+	s.ctxCancel()
+	s.closeListeners()
+	s.wg.Wait()
+}
+
+func (s *Server) runTCP(ln net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-s.ctx.Done():
+			default:
+				s.Log(logger.Error, "accept error: %v", err)
+			}
+			return
+		}
+
+		s.wg.Add(1)
+		go s.runConn(conn)
+	}
+}
+
+func (s *Server) runConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	fromIP := remoteIP(conn.RemoteAddr())
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+
+	for {
+		buf, err := readFrame(r)
+		if err != nil {
+			if err != io.EOF {
+				s.Log(logger.Debug, "[%s] %v", fromIP, err)
+			}
+			return
+		}
+
+		s.handleMessage(buf, fromIP)
+	}
+}
+
+// readFrame reads a message from a stream.
+// Messages are framed with octet counting or are terminated by LF or NUL (RFC 6587).
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		// skip empty lines between messages
+		if b[0] == '\n' || b[0] == '\r' || b[0] == 0 {
+			r.ReadByte() //nolint:errcheck
+			continue
+		}
+
+		if b[0] >= '1' && b[0] <= '9' {
+			sizeStr, err := r.ReadString(' ')
+			if err != nil {
+				return nil, err
+			}
+
+			size, err := strconv.Atoi(sizeStr[:len(sizeStr)-1])
+			if err != nil || size > maxMessageSize {
+				return nil, fmt.Errorf("invalid message length '%s'", sizeStr)
+			}
+
+			buf := make([]byte, size)
+			_, err = io.ReadFull(r, buf)
+			return buf, err
+		}
+
+		var buf []byte
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == '\n' || c == 0 {
+				return buf, nil
+			}
+			if len(buf) >= maxMessageSize {
+				return nil, fmt.Errorf("message is too big")
+			}
+			buf = append(buf, c)
+		}
+	}
+}
+
+func (s *Server) runUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxMessageSize)
+
+	for {
+		n, addr, err := s.udpLn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.ctx.Done():
+			default:
+				s.Log(logger.Error, "read error: %v", err)
+			}
+			return
+		}
+
+		s.handleMessage(bytes.Clone(buf[:n]), remoteIP(addr))
+	}
+}
+
+func (s *Server) handleMessage(buf []byte, fromIP string) {
+	var m Message
+	err := m.Unmarshal(buf, time.Now())
+	if err != nil {
+		s.Log(logger.Warn, "[%s] invalid message: %v", fromIP, err)
+		return
+	}
+	m.FromIP = fromIP
+
+	s.Log(logger.Debug, "[%s] %s message from %s: %s", fromIP, m.Format, m.AppName, m.Content)
+
+	select {
+	case (*s.ChMessage) <- m:
+	default:
+		s.Log(logger.Warn, "Channel is full, dropping message")
+	}
+}
+
+func remoteIP(addr net.Addr) string {
+	if addr == nil {
+		return "unknown"
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
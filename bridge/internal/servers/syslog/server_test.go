@@ -0,0 +1,58 @@
+package syslog
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kaonmir/mini-chekt/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+func TestServer(t *testing.T) {
+	chMessage := make(chan Message, 10)
+
+	s := &Server{
+		Address:   "127.0.0.1:5514",
+		Parent:    nilLogger{},
+		ChMessage: &chMessage,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	receive := func() Message {
+		select {
+		case m := <-chMessage:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for message")
+			return Message{}
+		}
+	}
+
+	uconn, err := net.Dial("udp", "127.0.0.1:5514")
+	require.NoError(t, err)
+	defer uconn.Close()
+
+	_, err = uconn.Write([]byte("<11>Mar 10 11:59:58 cam app: udp message"))
+	require.NoError(t, err)
+
+	m := receive()
+	require.Equal(t, "udp message", m.Content)
+	require.Equal(t, "127.0.0.1", m.FromIP)
+
+	tconn, err := net.Dial("tcp", "127.0.0.1:5514")
+	require.NoError(t, err)
+	defer tconn.Close()
+
+	_, err = tconn.Write([]byte("<11>1 - cam app - - - first\n28 <11>1 - cam app - - - second"))
+	require.NoError(t, err)
+
+	require.Equal(t, "first", receive().Content)
+	require.Equal(t, "second", receive().Content)
+}
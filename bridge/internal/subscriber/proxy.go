@@ -0,0 +1,206 @@
+package subscriber
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+)
+
+const (
+	// requests with paths that start with this prefix are forwarded to the API.
+	proxyPrefix = "/v3/"
+	// maximum size of bodies of API responses.
+	proxyMaxResponseSize = 1024 * 1024
+)
+
+// paths of the API that change the configuration or the identity of the bridge.
+var proxyAdminPrefixes = []string{
+	"/v3/auth/",
+	"/v3/bridge/",
+	"/v3/config/",
+}
+
+var roleRanks = map[string]int{
+	controlplane.RoleViewer:   1,
+	controlplane.RoleOperator: 2,
+	controlplane.RoleAdmin:    3,
+}
+
+// roleAllows returns whether a role grants the permissions of another one.
+func roleAllows(role string, needed string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[needed]
+}
+
+// proxyPath parses the path of a request to the API.
+// Roles are checked on the same path that is routed, therefore paths must be in canonical
+// form and must not contain escaped characters, that would be decoded before routing.
+func proxyPath(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+
+	if u.Scheme != "" || u.Host != "" || u.RawPath != "" || strings.Contains(u.Path, "%") ||
+		u.Path != path.Clean(u.Path) || !strings.HasPrefix(u.Path, proxyPrefix) {
+		return nil, fmt.Errorf("invalid path")
+	}
+
+	return u, nil
+}
+
+// proxyRole returns the role needed by a request to the API.
+// Viewers can read, operators can act on sessions, recordings and walk tests,
+// and admins can change the configuration.
+func proxyRole(req *rpcRequest) string {
+	u, err := proxyPath(req.Path)
+	if err != nil {
+		// the request is rejected by proxyRequest anyway
+		return controlplane.RoleAdmin
+	}
+
+	if req.Method == http.MethodGet {
+		return controlplane.RoleViewer
+	}
+
+	for _, prefix := range proxyAdminPrefixes {
+		if strings.HasPrefix(u.Path, prefix) {
+			return controlplane.RoleAdmin
+		}
+	}
+
+	return controlplane.RoleOperator
+}
+
+type subscriberAPI interface {
+	ServeProxied(w http.ResponseWriter, r *http.Request)
+}
+
+// proxyResponseWriter stores the response of the API.
+type proxyResponseWriter struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *proxyResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *proxyResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *proxyResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+
+	if w.body.Len()+len(p) > proxyMaxResponseSize {
+		w.overflow = true
+		return 0, fmt.Errorf("response too large")
+	}
+
+	return w.body.Write(p)
+}
+
+// proxyErrorCode returns the error code of a status code of the API.
+func proxyErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return rpcCodeBadRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return rpcCodeForbidden
+	case http.StatusNotFound:
+		return rpcCodeNotFound
+	case http.StatusConflict:
+		return rpcCodeConflict
+	case http.StatusServiceUnavailable:
+		return rpcCodeUnavailable
+	default:
+		return rpcCodeInternal
+	}
+}
+
+// proxyRequest builds the HTTP request of a request to the API.
+func proxyRequest(ctx context.Context, req *rpcRequest) (*http.Request, error) {
+	u, err := proxyPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	if req.Body != nil {
+		err = json.NewEncoder(&body).Encode(req.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hreq, err := http.NewRequestWithContext(ctx, req.Method, u.RequestURI(), &body)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Body != nil {
+		hreq.Header.Set("Content-Type", "application/json")
+	}
+
+	return hreq, nil
+}
+
+// proxy forwards a request to the API and relays its response.
+func proxy(ctx context.Context, api subscriberAPI, req *rpcRequest) (interface{}, error) {
+	if api == nil {
+		return nil, newRPCError(rpcCodeUnavailable, fmt.Errorf("API is disabled"))
+	}
+
+	hreq, err := proxyRequest(ctx, req)
+	if err != nil {
+		return nil, newRPCError(rpcCodeBadRequest, err)
+	}
+
+	w := &proxyResponseWriter{header: make(http.Header)}
+	api.ServeProxied(w, hreq)
+
+	if w.overflow {
+		return nil, newRPCError(rpcCodeInternal, fmt.Errorf("response too large"))
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	var data interface{}
+	if w.body.Len() != 0 {
+		if strings.HasPrefix(w.header.Get("Content-Type"), "application/json") {
+			err = json.Unmarshal(w.body.Bytes(), &data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid response: %w", err)
+			}
+		} else {
+			data = w.body.String()
+		}
+	}
+
+	if w.status < 200 || w.status >= 300 {
+		message := http.StatusText(w.status)
+		if m, ok := data.(map[string]interface{}); ok {
+			if v, ok := m["error"].(string); ok {
+				message = v
+			}
+		}
+
+		return nil, &rpcError{Code: proxyErrorCode(w.status), Message: message, Status: w.status}
+	}
+
+	return &rpcResult{Status: w.status, Data: data}, nil
+}
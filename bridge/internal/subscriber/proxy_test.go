@@ -0,0 +1,163 @@
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+)
+
+type testAPI struct {
+	requests []*http.Request
+	bodies   []string
+	handler  func(w http.ResponseWriter, r *http.Request)
+}
+
+func (a *testAPI) ServeProxied(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	a.requests = append(a.requests, r)
+	a.bodies = append(a.bodies, string(body))
+	a.handler(w, r)
+}
+
+func TestRoleAllows(t *testing.T) {
+	require.True(t, roleAllows(controlplane.RoleAdmin, controlplane.RoleOperator))
+	require.True(t, roleAllows(controlplane.RoleViewer, controlplane.RoleViewer))
+	require.False(t, roleAllows(controlplane.RoleViewer, controlplane.RoleOperator))
+	require.False(t, roleAllows("", controlplane.RoleViewer))
+	require.False(t, roleAllows("owner", controlplane.RoleViewer))
+}
+
+func TestProxyRole(t *testing.T) {
+	for _, ca := range []struct {
+		method string
+		path   string
+		role   string
+	}{
+		{http.MethodGet, "/v3/paths/list", controlplane.RoleViewer},
+		{http.MethodGet, "/v3/config/global/get", controlplane.RoleViewer},
+		{http.MethodPost, "/v3/rtspsessions/kick/123", controlplane.RoleOperator},
+		{http.MethodDelete, "/v3/recordings/deletesegment?path=cam1", controlplane.RoleOperator},
+		{http.MethodPatch, "/v3/config/global/patch", controlplane.RoleAdmin},
+		{http.MethodPost, "/v3/bridge/pairing/unpair", controlplane.RoleAdmin},
+		{http.MethodPost, "/v3/auth/jwks/refresh", controlplane.RoleAdmin},
+		{http.MethodPatch, "/v3/config%2Fglobal/patch", controlplane.RoleAdmin},
+		{http.MethodPost, "/v3/bridge%2Fpairing/unpair", controlplane.RoleAdmin},
+	} {
+		t.Run(ca.method+" "+ca.path, func(t *testing.T) {
+			require.Equal(t, ca.role, proxyRole(&rpcRequest{Method: ca.method, Path: ca.path}))
+		})
+	}
+}
+
+func TestProxyRoleEncodedPath(t *testing.T) {
+	// admin paths can't be hidden from the role check by escaping their slashes
+	for _, p := range []string{"/v3/config%2Fglobal/patch", "/v3/bridge%2Fpairing/unpair"} {
+		req := &rpcRequest{Method: http.MethodPost, Path: p}
+		require.False(t, roleAllows(controlplane.RoleOperator, proxyRole(req)))
+
+		_, err := proxyRequest(context.Background(), req)
+		require.EqualError(t, err, "invalid path")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	api := &testAPI{
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+			switch r.URL.Path {
+			case "/v3/paths/list":
+				w.Write([]byte(`{"itemCount":1,"items":[{"name":"cam1"}]}`)) //nolint:errcheck
+
+			case "/v3/config/global/patch":
+				w.WriteHeader(http.StatusOK)
+
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"session not found"}`)) //nolint:errcheck
+			}
+		},
+	}
+
+	data, err := proxy(context.Background(), api, &rpcRequest{
+		Method: http.MethodGet,
+		Path:   "/v3/paths/list?itemsPerPage=10",
+	})
+	require.NoError(t, err)
+	require.Equal(t, &rpcResult{
+		Status: http.StatusOK,
+		Data: map[string]interface{}{
+			"itemCount": float64(1),
+			"items":     []interface{}{map[string]interface{}{"name": "cam1"}},
+		},
+	}, data)
+	require.Equal(t, "10", api.requests[0].URL.Query().Get("itemsPerPage"))
+
+	data, err = proxy(context.Background(), api, &rpcRequest{
+		Method: http.MethodPatch,
+		Path:   "/v3/config/global/patch",
+		Body:   map[string]interface{}{"logLevel": "debug"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &rpcResult{Status: http.StatusOK}, data)
+	require.Equal(t, http.MethodPatch, api.requests[1].Method)
+	require.Equal(t, "application/json", api.requests[1].Header.Get("Content-Type"))
+
+	var body map[string]interface{}
+	err = json.Unmarshal([]byte(api.bodies[1]), &body)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"logLevel": "debug"}, body)
+
+	_, err = proxy(context.Background(), api, &rpcRequest{
+		Method: http.MethodPost,
+		Path:   "/v3/rtspsessions/kick/123",
+	})
+	require.Equal(t, &rpcError{
+		Code:    rpcCodeNotFound,
+		Message: "session not found",
+		Status:  http.StatusNotFound,
+	}, err)
+
+	require.Equal(t, &rpcResponse{
+		Status: http.StatusNotFound,
+		Error:  "session not found",
+		Code:   rpcCodeNotFound,
+	}, rpcErrorResponse(err))
+}
+
+func TestProxyInvalid(t *testing.T) {
+	api := &testAPI{
+		handler: func(w http.ResponseWriter, _ *http.Request) {
+			w.Write(make([]byte, proxyMaxResponseSize+1)) //nolint:errcheck
+		},
+	}
+
+	for _, path := range []string{
+		"/v3/../api/v1/cameras",
+		"/v3//config/global/patch",
+		"http://localhost:9997/v3/paths/list",
+		"/v2/paths/list",
+		"/v3/config%2Fglobal/patch",
+		"/v3/bridge%2Fpairing/unpair",
+		"/v3/paths/get/cam%31",
+	} {
+		t.Run(path, func(t *testing.T) {
+			_, err := proxy(context.Background(), api, &rpcRequest{Method: http.MethodGet, Path: path})
+			require.Equal(t, rpcCodeBadRequest, err.(*rpcError).Code) //nolint:errorlint
+		})
+	}
+
+	require.Empty(t, api.requests)
+
+	_, err := proxy(context.Background(), api, &rpcRequest{Method: http.MethodGet, Path: "/v3/paths/list"})
+	require.EqualError(t, err, "response too large")
+
+	_, err = proxy(context.Background(), nil, &rpcRequest{Method: http.MethodGet, Path: "/v3/paths/list"})
+	require.Equal(t, rpcCodeUnavailable, err.(*rpcError).Code) //nolint:errorlint
+}
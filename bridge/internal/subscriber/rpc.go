@@ -0,0 +1,418 @@
+package subscriber
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+const (
+	// requests are answered with an error after this duration.
+	// It is shorter than the timeout of the web app, in order for the error to reach it.
+	rpcTimeout = 20 * time.Second
+	// maximum number of requests handled at the same time. Further requests are rejected.
+	rpcMaxConcurrent = 8
+	// maximum length of request IDs.
+	rpcMaxRequestIDLength = 128
+)
+
+// error codes of responses.
+const (
+	rpcCodeBadRequest  = "bad_request"
+	rpcCodeForbidden   = "forbidden"
+	rpcCodeNotFound    = "not_found"
+	rpcCodeConflict    = "conflict"
+	rpcCodeUnavailable = "unavailable"
+	rpcCodeBusy        = "busy"
+	rpcCodeTimeout     = "timeout"
+	rpcCodeInternal    = "internal"
+)
+
+// rpcError is an error returned to the requester.
+type rpcError struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+func newRPCError(code string, err error) error {
+	return &rpcError{Code: code, Message: err.Error()}
+}
+
+// rpcResult is returned by handlers that set the status code of responses.
+type rpcResult struct {
+	Status int
+	Data   interface{}
+}
+
+// rpcResponse is the envelope of responses.
+// Status is the status code of responses of the API.
+type rpcResponse struct {
+	Success bool        `json:"success"`
+	Status  int         `json:"status,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+}
+
+func rpcDataResponse(data interface{}) *rpcResponse {
+	if r, ok := data.(*rpcResult); ok {
+		return &rpcResponse{Success: true, Status: r.Status, Data: r.Data}
+	}
+	return &rpcResponse{Success: true, Data: data}
+}
+
+func rpcErrorResponse(err error) *rpcResponse {
+	var rerr *rpcError
+	if errors.As(err, &rerr) {
+		return &rpcResponse{Status: rerr.Status, Error: rerr.Message, Code: rerr.Code}
+	}
+	return &rpcResponse{Error: err.Error(), Code: rpcCodeInternal}
+}
+
+// rpcRequest is a request sent through the command channel.
+type rpcRequest struct {
+	ID          string
+	RequesterID string
+	Method      string
+	Path        string
+	Body        interface{}
+}
+
+// parseRequest extracts a request from a command.
+// Commands sent by the web app have the payload
+// {"request_id": "...", "requester_id": "<uuid>", "method": "GET", "path": "/v3/...", "body": {...}}.
+func parseRequest(cmd *controlplane.Command) (*rpcRequest, error) {
+	if cmd.Payload == nil {
+		return nil, fmt.Errorf("missing payload")
+	}
+
+	id, ok := cmd.Payload["request_id"].(string)
+	if !ok || id == "" {
+		return nil, fmt.Errorf("missing request_id")
+	}
+	if len(id) > rpcMaxRequestIDLength {
+		return nil, fmt.Errorf("request_id is too long")
+	}
+
+	requesterID, ok := cmd.Payload["requester_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing requester_id")
+	}
+	if _, err := uuid.Parse(requesterID); err != nil {
+		return nil, fmt.Errorf("invalid requester_id: %w", err)
+	}
+
+	path := cmd.Event
+	if v, ok := cmd.Payload["path"]; ok {
+		path, ok = v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid path")
+		}
+	}
+
+	method := http.MethodGet
+	if v, ok := cmd.Payload["method"]; ok {
+		method, ok = v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid method")
+		}
+
+		switch method {
+		case http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete:
+		default:
+			return nil, fmt.Errorf("invalid method")
+		}
+	}
+
+	return &rpcRequest{
+		ID:          id,
+		RequesterID: requesterID,
+		Method:      method,
+		Path:        path,
+		Body:        cmd.Payload["body"],
+	}, nil
+}
+
+// bodyDigest returns the digest of the body of a request, that its sender records
+// together with the request: the hex SHA-256 of the body encoded into JSON, with sorted
+// keys and without spaces, or of null when there's no body.
+func bodyDigest(body interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(body)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// decodeBody decodes the body of a request. Unknown fields are rejected.
+func decodeBody(body interface{}, dest interface{}) error {
+	enc, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(enc))
+	d.DisallowUnknownFields()
+	return d.Decode(dest)
+}
+
+type rpcHandlerFunc func(ctx context.Context, req *rpcRequest) (interface{}, error)
+
+// rpcEmpty is the body of requests without parameters.
+type rpcEmpty struct{}
+
+// rpcRoute is a handler and the role that requests need in order to call it.
+type rpcRoute struct {
+	role    func(req *rpcRequest) string
+	handler rpcHandlerFunc
+}
+
+type rpcPrefixRoute struct {
+	prefix string
+	route  *rpcRoute
+}
+
+// rpcRouter dispatches requests to their handler, by path.
+type rpcRouter struct {
+	routes   map[string]*rpcRoute
+	prefixes []rpcPrefixRoute
+}
+
+func newRPCRouter() *rpcRouter {
+	return &rpcRouter{routes: make(map[string]*rpcRoute)}
+}
+
+// handlePrefix registers the handler of paths that start with a prefix.
+// Paths registered with handle() take precedence.
+func (r *rpcRouter) handlePrefix(prefix string, role func(req *rpcRequest) string, h rpcHandlerFunc) {
+	r.prefixes = append(r.prefixes, rpcPrefixRoute{
+		prefix: prefix,
+		route:  &rpcRoute{role: role, handler: h},
+	})
+}
+
+func (r *rpcRouter) route(req *rpcRequest) (*rpcRoute, bool) {
+	if route, ok := r.routes[req.Path]; ok {
+		return route, true
+	}
+
+	for _, p := range r.prefixes {
+		if strings.HasPrefix(req.Path, p.prefix) {
+			return p.route, true
+		}
+	}
+
+	return nil, false
+}
+
+// handle registers the handler of a path, that can be called by users with the given role.
+// The body of requests is decoded into Req.
+func handle[Req any, Res any](
+	r *rpcRouter, path string, role string, h func(ctx context.Context, req *Req) (*Res, error),
+) {
+	r.routes[path] = &rpcRoute{
+		role: func(*rpcRequest) string { return role },
+		handler: func(ctx context.Context, rreq *rpcRequest) (interface{}, error) {
+			var req Req
+			if rreq.Body != nil {
+				err := decodeBody(rreq.Body, &req)
+				if err != nil {
+					return nil, newRPCError(rpcCodeBadRequest, fmt.Errorf("invalid body: %w", err))
+				}
+			}
+
+			return h(ctx, &req)
+		},
+	}
+}
+
+type rpcServerParent interface {
+	logger.Writer
+}
+
+// rpcServer handles requests received through the command channel and sends back responses.
+// Authorize is called before handlers, with the role needed by the request.
+type rpcServer struct {
+	Router        *rpcRouter
+	Authorize     func(req *rpcRequest, role string) error
+	Respond       func(res *controlplane.CommandResponse) error
+	Timeout       time.Duration
+	MaxConcurrent int
+	Parent        rpcServerParent
+
+	ctx       context.Context
+	ctxCancel func()
+	sem       chan struct{}
+	wg        sync.WaitGroup
+	mutex     sync.Mutex
+	inFlight  map[string]struct{}
+}
+
+func (s *rpcServer) initialize() {
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	s.sem = make(chan struct{}, s.MaxConcurrent)
+	s.inFlight = make(map[string]struct{})
+}
+
+// close stops handling requests. Pending requests are not answered.
+func (s *rpcServer) close() {
+	s.mutex.Lock()
+	s.ctxCancel()
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *rpcServer) Log(level logger.Level, format string, args ...interface{}) {
+	s.Parent.Log(level, format, args...)
+}
+
+// onCommand is called by the command channel. It doesn't block.
+func (s *rpcServer) onCommand(cmd *controlplane.Command) {
+	req, err := parseRequest(cmd)
+	if err != nil {
+		s.Log(logger.Warn, "discarding command '%s': %v", cmd.Event, err)
+		return
+	}
+
+	s.mutex.Lock()
+
+	if s.ctx.Err() != nil {
+		s.mutex.Unlock()
+		return
+	}
+
+	// commands may be delivered twice after a reconnection
+	if _, ok := s.inFlight[req.ID]; ok {
+		s.mutex.Unlock()
+		return
+	}
+	s.inFlight[req.ID] = struct{}{}
+	s.wg.Add(1)
+	s.mutex.Unlock()
+
+	route, ok := s.Router.route(req)
+	if !ok {
+		go s.finish(req, rpcErrorResponse(
+			newRPCError(rpcCodeNotFound, fmt.Errorf("path '%s' not found", req.Path))))
+		return
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		go s.finish(req, rpcErrorResponse(
+			newRPCError(rpcCodeBusy, fmt.Errorf("too many requests, retry later"))))
+		return
+	}
+
+	go s.run(req, route)
+}
+
+func (s *rpcServer) run(req *rpcRequest, route *rpcRoute) {
+	ctx, ctxCancel := context.WithTimeout(s.ctx, s.Timeout)
+	defer ctxCancel()
+
+	type result struct {
+		data interface{}
+		err  error
+	}
+	done := make(chan result, 1)
+
+	// the slot is released when the handler returns, even after a timeout
+	go func() {
+		defer func() { <-s.sem }()
+		data, err := s.call(ctx, req, route)
+		done <- result{data, err}
+	}()
+
+	var res *rpcResponse
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			res = rpcErrorResponse(r.err)
+		} else {
+			res = rpcDataResponse(r.data)
+		}
+
+	case <-ctx.Done():
+		res = rpcErrorResponse(newRPCError(rpcCodeTimeout, fmt.Errorf("request timed out")))
+	}
+
+	s.finish(req, res)
+}
+
+// call checks permissions of the requester and calls a handler.
+// Panics are recovered, in order not to crash the bridge.
+func (s *rpcServer) call(ctx context.Context, req *rpcRequest, route *rpcRoute) (data interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.Log(logger.Error, "request %s (%s) panicked: %v", req.ID, req.Path, r)
+			err = newRPCError(rpcCodeInternal, fmt.Errorf("internal error"))
+		}
+	}()
+
+	if s.Authorize != nil {
+		err = s.Authorize(req, route.role(req))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return route.handler(ctx, req)
+}
+
+func (s *rpcServer) finish(req *rpcRequest, res *rpcResponse) {
+	defer s.wg.Done()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.inFlight, req.ID)
+		s.mutex.Unlock()
+	}()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	if res.Success {
+		s.Log(logger.Debug, "request %s (%s %s) succeeded", req.ID, req.Method, req.Path)
+	} else {
+		s.Log(logger.Debug, "request %s (%s %s) failed: %s", req.ID, req.Method, req.Path, res.Error)
+	}
+
+	err := s.Respond(&controlplane.CommandResponse{
+		RequestID:   req.ID,
+		RequesterID: req.RequesterID,
+		Path:        req.Path,
+		Body:        res,
+	})
+	if err != nil {
+		s.Log(logger.Warn, "unable to send response to request %s (%s): %v", req.ID, req.Path, err)
+	}
+}
@@ -0,0 +1,296 @@
+package subscriber
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+	"github.com/kaonmir/mini-chekt/internal/logger"
+)
+
+const testRequesterID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+type testEchoReq struct {
+	Value string `json:"value"`
+}
+
+type testEchoRes struct {
+	Value string `json:"value"`
+}
+
+func newTestServer(t *testing.T, timeout time.Duration, maxConcurrent int) (*rpcServer, chan *controlplane.CommandResponse, chan struct{}) {
+	release := make(chan struct{})
+
+	router := newRPCRouter()
+	handle(router, "/echo", controlplane.RoleViewer, func(_ context.Context, req *testEchoReq) (*testEchoRes, error) {
+		return &testEchoRes{Value: req.Value}, nil
+	})
+	handle(router, "/fail", controlplane.RoleViewer, func(_ context.Context, _ *rpcEmpty) (*rpcEmpty, error) {
+		return nil, newRPCError(rpcCodeConflict, fmt.Errorf("already running"))
+	})
+	handle(router, "/panic", controlplane.RoleViewer, func(_ context.Context, _ *rpcEmpty) (*rpcEmpty, error) {
+		panic("boom")
+	})
+	handle(router, "/block", controlplane.RoleViewer, func(ctx context.Context, _ *rpcEmpty) (*rpcEmpty, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return &rpcEmpty{}, nil
+	})
+
+	responses := make(chan *controlplane.CommandResponse, 16)
+
+	s := &rpcServer{
+		Router: router,
+		Respond: func(res *controlplane.CommandResponse) error {
+			responses <- res
+			return nil
+		},
+		Timeout:       timeout,
+		MaxConcurrent: maxConcurrent,
+		Parent:        nilLogger{},
+	}
+	s.initialize()
+	t.Cleanup(s.close)
+
+	return s, responses, release
+}
+
+func testCommand(id string, path string, body interface{}) *controlplane.Command {
+	return &controlplane.Command{
+		Event: "path",
+		Payload: map[string]interface{}{
+			"request_id":   id,
+			"requester_id": testRequesterID,
+			"path":         path,
+			"body":         body,
+		},
+	}
+}
+
+func waitResponse(t *testing.T, ch chan *controlplane.CommandResponse) *controlplane.CommandResponse {
+	select {
+	case res := <-ch:
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatal("response not received")
+		return nil
+	}
+}
+
+func TestRPCServer(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		path string
+		body interface{}
+		res  *rpcResponse
+	}{
+		{
+			"success",
+			"/echo",
+			map[string]interface{}{"value": "test"},
+			&rpcResponse{Success: true, Data: &testEchoRes{Value: "test"}},
+		},
+		{
+			"empty body",
+			"/echo",
+			nil,
+			&rpcResponse{Success: true, Data: &testEchoRes{}},
+		},
+		{
+			"unknown field",
+			"/echo",
+			map[string]interface{}{"other": "test"},
+			&rpcResponse{
+				Error: "invalid body: json: unknown field \"other\"",
+				Code:  rpcCodeBadRequest,
+			},
+		},
+		{
+			"invalid type",
+			"/echo",
+			map[string]interface{}{"value": 1},
+			&rpcResponse{
+				Error: "invalid body: json: cannot unmarshal number into Go struct field testEchoReq.value of type string",
+				Code:  rpcCodeBadRequest,
+			},
+		},
+		{
+			"handler error",
+			"/fail",
+			nil,
+			&rpcResponse{Error: "already running", Code: rpcCodeConflict},
+		},
+		{
+			"panic",
+			"/panic",
+			nil,
+			&rpcResponse{Error: "internal error", Code: rpcCodeInternal},
+		},
+		{
+			"not found",
+			"/missing",
+			nil,
+			&rpcResponse{Error: "path '/missing' not found", Code: rpcCodeNotFound},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			s, responses, _ := newTestServer(t, 5*time.Second, 1)
+
+			s.onCommand(testCommand("req1", ca.path, ca.body))
+
+			res := waitResponse(t, responses)
+			require.Equal(t, &controlplane.CommandResponse{
+				RequestID:   "req1",
+				RequesterID: testRequesterID,
+				Path:        ca.path,
+				Body:        ca.res,
+			}, res)
+		})
+	}
+}
+
+func TestRPCServerMalformed(t *testing.T) {
+	s, responses, _ := newTestServer(t, 5*time.Second, 1)
+
+	for _, payload := range []map[string]interface{}{
+		nil,
+		{},
+		{"request_id": 1, "requester_id": testRequesterID},
+		{"request_id": "req1"},
+		{"request_id": "req1", "requester_id": "not-an-uuid"},
+		{"request_id": "req1", "requester_id": testRequesterID, "path": []interface{}{}},
+		{"request_id": "req1", "requester_id": testRequesterID, "method": "TRACE"},
+		{"request_id": string(make([]byte, 200)), "requester_id": testRequesterID},
+	} {
+		s.onCommand(&controlplane.Command{Event: "path", Payload: payload})
+	}
+
+	// malformed commands are discarded without a response
+	s.onCommand(testCommand("req2", "/echo", map[string]interface{}{"value": "test"}))
+
+	res := waitResponse(t, responses)
+	require.Equal(t, "req2", res.RequestID)
+}
+
+func TestRPCServerBusy(t *testing.T) {
+	s, responses, release := newTestServer(t, 5*time.Second, 1)
+
+	s.onCommand(testCommand("req1", "/block", nil))
+
+	// wait for the handler to take the slot
+	require.Eventually(t, func() bool { return len(s.sem) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// duplicate commands are ignored
+	s.onCommand(testCommand("req1", "/block", nil))
+
+	s.onCommand(testCommand("req2", "/block", nil))
+
+	res := waitResponse(t, responses)
+	require.Equal(t, "req2", res.RequestID)
+	require.Equal(t, rpcCodeBusy, res.Body.(*rpcResponse).Code)
+
+	close(release)
+
+	res = waitResponse(t, responses)
+	require.Equal(t, "req1", res.RequestID)
+	require.Equal(t, true, res.Body.(*rpcResponse).Success)
+
+	select {
+	case res = <-responses:
+		t.Fatalf("unexpected response: %v", res)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRPCServerTimeout(t *testing.T) {
+	s, responses, _ := newTestServer(t, 100*time.Millisecond, 1)
+
+	s.onCommand(testCommand("req1", "/block", nil))
+
+	res := waitResponse(t, responses)
+	require.Equal(t, &rpcResponse{Error: "request timed out", Code: rpcCodeTimeout}, res.Body)
+}
+
+func TestRPCServerClose(t *testing.T) {
+	var mutex sync.Mutex
+	responded := false
+
+	router := newRPCRouter()
+	handle(router, "/block", controlplane.RoleViewer, func(ctx context.Context, _ *rpcEmpty) (*rpcEmpty, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	s := &rpcServer{
+		Router: router,
+		Respond: func(_ *controlplane.CommandResponse) error {
+			mutex.Lock()
+			responded = true
+			mutex.Unlock()
+			return nil
+		},
+		Timeout:       5 * time.Second,
+		MaxConcurrent: 1,
+		Parent:        nilLogger{},
+	}
+	s.initialize()
+
+	s.onCommand(testCommand("req1", "/block", nil))
+	s.close()
+
+	// commands received after closing are discarded
+	s.onCommand(testCommand("req2", "/block", nil))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.False(t, responded)
+}
+
+func TestRPCServerAuthorize(t *testing.T) {
+	s, responses, _ := newTestServer(t, 5*time.Second, 1)
+
+	var roles []string
+	s.Authorize = func(req *rpcRequest, role string) error {
+		roles = append(roles, role)
+		if req.ID == "req2" {
+			return newRPCError(rpcCodeForbidden, fmt.Errorf("role '%s' is needed", role))
+		}
+		return nil
+	}
+
+	s.onCommand(testCommand("req1", "/echo", nil))
+	res := waitResponse(t, responses)
+	require.Equal(t, true, res.Body.(*rpcResponse).Success)
+
+	s.onCommand(testCommand("req2", "/echo", nil))
+	res = waitResponse(t, responses)
+	require.Equal(t, &rpcResponse{Error: "role 'viewer' is needed", Code: rpcCodeForbidden}, res.Body)
+
+	require.Equal(t, []string{controlplane.RoleViewer, controlplane.RoleViewer}, roles)
+}
+
+func TestBodyDigest(t *testing.T) {
+	digest, err := bodyDigest(nil)
+	require.NoError(t, err)
+	require.Equal(t, "74234e98afe7498fb5daf1f36ac2d78acc339464f950703b8c019892f982b90b", digest)
+
+	// keys are sorted and characters are not escaped, as in the web app
+	digest, err = bodyDigest(map[string]interface{}{
+		"c": []interface{}{float64(1), map[string]interface{}{"e": true, "d": nil}},
+		"b": 1.5,
+		"a": "<x>",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "d40228e0308ab12ded27d47afcde7c6e78a319275012bd557be320e126a3633c", digest)
+}
@@ -1,14 +1,17 @@
 package subscriber
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 
-	"github.com/kaonmir/mini-chekt/internal/conf"
+	"github.com/kaonmir/mini-chekt/internal/alarm"
 	"github.com/kaonmir/mini-chekt/internal/confdb"
+	"github.com/kaonmir/mini-chekt/internal/controlplane"
+	"github.com/kaonmir/mini-chekt/internal/defs"
 	"github.com/kaonmir/mini-chekt/internal/logger"
 	"github.com/kaonmir/mini-chekt/internal/onvif/discovery"
-	realtimego "github.com/kaonmir/mini-chekt/pkg/realtime-go"
 )
 
 type SubscriberParent interface {
@@ -16,91 +19,176 @@ type SubscriberParent interface {
 }
 
 type Subscriber struct {
-	Conf   *conf.Conf
-	ConfDB *confdb.ConfDB
+	ControlPlane controlplane.CommandChannel
+	ConfDB       *confdb.ConfDB
+	AlarmManager defs.APIAlarmManager
+	API          subscriberAPI
 
-	ch *realtimego.Channel
+	sub controlplane.Subscription
+	rpc *rpcServer
 
 	Parent SubscriberParent
 	mutex  sync.RWMutex
 }
 
 func (s *Subscriber) Initialize() error {
-	client, err := realtimego.NewClient(s.Conf.SupabaseURL, s.Conf.SupabaseKey)
-	if err != nil {
-		return fmt.Errorf("failed to create supabase realtime client: %w", err)
+	router := newRPCRouter()
+	handle(router, "/api/v1/cameras", controlplane.RoleViewer, s.onCameras)
+	handle(router, "/v3/walktest/get", controlplane.RoleViewer, s.onWalkTestGet)
+	handle(router, "/v3/walktest/start", controlplane.RoleOperator, s.onWalkTestStart)
+	handle(router, "/v3/walktest/stop", controlplane.RoleOperator, s.onWalkTestStop)
+	handle(router, "/v3/camerasync/get", controlplane.RoleViewer, s.onCameraSyncGet)
+	router.handlePrefix(proxyPrefix, proxyRole, s.onProxy)
+
+	s.rpc = &rpcServer{
+		Router:    router,
+		Authorize: s.authorize,
+		Respond: func(res *controlplane.CommandResponse) error {
+			return s.ControlPlane.RespondCommand(s.ConfDB.BridgeID(), res)
+		},
+		Timeout:       rpcTimeout,
+		MaxConcurrent: rpcMaxConcurrent,
+		Parent:        s,
 	}
+	s.rpc.initialize()
 
-	err = client.Connect()
+	sub, err := s.ControlPlane.ListenCommands(s.ConfDB.BridgeID(), s.rpc.onCommand)
 	if err != nil {
-		return fmt.Errorf("failed to connect to supabase realtime server: %w", err)
+		s.rpc.close()
+		return err
 	}
 
-	ch, err := client.Channel(
-		realtimego.WithBroadcast(fmt.Sprintf("bridge-%d", s.ConfDB.BridgeId)),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create realtime channel: %w", err)
+	s.Log(logger.Info, "listening to commands of bridge %d", s.ConfDB.BridgeID())
+	s.sub = sub
+
+	return nil
+}
+
+func (s *Subscriber) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.sub != nil {
+		s.sub.Close()
 	}
 
-	ch.OnBroadcast = func(m realtimego.Message) {
-		event := m.Payload.(map[string]interface{})["event"].(string)
-		payload := m.Payload.(map[string]interface{})["payload"].(map[string]interface{})
+	if s.rpc != nil {
+		s.rpc.close()
+	}
+}
 
-		switch event {
-		// ! Test
-		case "Test message":
-			s.onCameras(payload) // ? Test
-		case "/api/v1/cameras":
-			s.onCameras(payload)
+// Log implements logger.Writer.
+func (s *Subscriber) Log(level logger.Level, format string, args ...interface{}) {
+	s.Parent.Log(level, "[Subscriber] "+format, args...)
+}
 
-		}
+// authorize checks that the sender of a request has the given role in the site of the bridge.
+func (s *Subscriber) authorize(req *rpcRequest, role string) error {
+	digest, err := bodyDigest(req.Body)
+	if err != nil {
+		return newRPCError(rpcCodeBadRequest, fmt.Errorf("invalid body: %w", err))
 	}
 
-	if err = ch.Subscribe(); err != nil {
-		return fmt.Errorf("failed to subscribe to channel: %w", err)
+	have, err := s.ControlPlane.RequesterRole(s.ConfDB.BridgeID(), req.ID, req.Method, req.Path, digest)
+	if err != nil {
+		return newRPCError(rpcCodeUnavailable, fmt.Errorf("unable to check permissions: %w", err))
 	}
 
-	s.Log(logger.Info, "subscribed to channel %s", ch.Topic)
-	s.ch = ch
+	if !roleAllows(have, role) {
+		return newRPCError(rpcCodeForbidden, fmt.Errorf("role '%s' is needed", role))
+	}
 
 	return nil
 }
 
-// func (s *session) respond(payload map[string]interface{}) {
-// 	responseData := map[string]interface{}{
-// 		"bridge_id":     s.bridge_id,
-// 		"request_id":    s.request_id,
-// 		"requester_id":  s.requester_id,
-// 		"request_path":  s.event,
-// 		"response_body": payload,
-// 	}
-// 	_, _, err := s.db.From("response").Insert(responseData, false, "", "", "").Execute()
-// 	if err != nil {
-// 		s.log.Log(logger.Error, "[%s] [%s] Failed to insert response: %v", s.event, s.request_id, err)
-// 	}
-// }
+func (s *Subscriber) onProxy(ctx context.Context, req *rpcRequest) (interface{}, error) {
+	return proxy(ctx, s.API, req)
+}
 
-func (s *Subscriber) Close() {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+func (s *Subscriber) onCameras(_ context.Context, _ *rpcEmpty) (*defs.APIDiscoveredDeviceList, error) {
+	devices, err := discovery.DiscoverDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover devices: %w", err)
+	}
+
+	s.Log(logger.Info, "found %d devices", len(devices))
+
+	data := &defs.APIDiscoveredDeviceList{
+		Items: make([]*defs.APIDiscoveredDevice, len(devices)),
+	}
+	for i, dev := range devices {
+		data.Items[i] = &defs.APIDiscoveredDevice{
+			Address: dev.Xaddr,
+			UUID:    dev.Uuid,
+		}
+	}
+	data.ItemCount = len(data.Items)
+
+	return data, nil
+}
 
-	if s.ch != nil {
-		s.ch.Unsubscribe()
+func (s *Subscriber) alarmManager() (defs.APIAlarmManager, error) {
+	if s.AlarmManager == nil {
+		return nil, newRPCError(rpcCodeUnavailable, fmt.Errorf("alarm manager is disabled"))
 	}
+	return s.AlarmManager, nil
 }
 
-// Log implements logger.Writer.
-func (s *Subscriber) Log(level logger.Level, format string, args ...interface{}) {
-	s.Parent.Log(level, "[Subscriber] "+format, args...)
+func (s *Subscriber) onWalkTestGet(_ context.Context, _ *rpcEmpty) (*defs.APIWalkTest, error) {
+	am, err := s.alarmManager()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := am.APIWalkTestGet()
+	if err != nil {
+		if errors.Is(err, alarm.ErrWalkTestNotFound) {
+			return nil, newRPCError(rpcCodeNotFound, err)
+		}
+		return nil, err
+	}
+
+	return data, nil
 }
 
-func (s *Subscriber) onCameras(payload map[string]interface{}) {
-	devices, err := discovery.DiscoverDevices()
+func (s *Subscriber) onWalkTestStart(_ context.Context, req *defs.APIWalkTestStartReq) (*defs.APIWalkTest, error) {
+	am, err := s.alarmManager()
 	if err != nil {
-		s.Log(logger.Error, "failed to discover devices: %v", err)
-		return
+		return nil, err
 	}
 
-	s.Log(logger.Info, "found %d devices", len(devices))
+	data, err := am.APIWalkTestStart(req)
+	if err != nil {
+		if errors.Is(err, alarm.ErrWalkTestRunning) {
+			return nil, newRPCError(rpcCodeConflict, err)
+		}
+		return nil, newRPCError(rpcCodeBadRequest, err)
+	}
+
+	s.Log(logger.Info, "walk test %d started", data.ID)
+
+	return data, nil
+}
+
+func (s *Subscriber) onWalkTestStop(_ context.Context, _ *rpcEmpty) (*defs.APIWalkTest, error) {
+	am, err := s.alarmManager()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := am.APIWalkTestStop()
+	if err != nil {
+		if errors.Is(err, alarm.ErrWalkTestNotFound) {
+			return nil, newRPCError(rpcCodeNotFound, err)
+		}
+		return nil, err
+	}
+
+	s.Log(logger.Info, "walk test %d stopped", data.ID)
+
+	return data, nil
+}
+
+func (s *Subscriber) onCameraSyncGet(_ context.Context, _ *rpcEmpty) (*defs.APICameraSync, error) {
+	return s.ConfDB.APICameraSyncGet()
 }
@@ -140,6 +140,34 @@ func TestAPIDocs(t *testing.T) {
 			"WebRTCSessionList",
 			defs.APIWebRTCSessionList{},
 		},
+		{
+			"WalkTest",
+			defs.APIWalkTest{},
+		},
+		{
+			"WalkTestCamera",
+			defs.APIWalkTestCamera{},
+		},
+		{
+			"WalkTestStartReq",
+			defs.APIWalkTestStartReq{},
+		},
+		{
+			"ClockSkew",
+			defs.APIClockSkew{},
+		},
+		{
+			"ClockSkewList",
+			defs.APIClockSkewList{},
+		},
+		{
+			"CameraSync",
+			defs.APICameraSync{},
+		},
+		{
+			"BridgePairing",
+			defs.APIBridgePairing{},
+		},
 	} {
 		t.Run(ca.openAPIKey, func(t *testing.T) {
 			content1 := doc.Components.Schemas[ca.openAPIKey]
@@ -1,19 +1,45 @@
 package realtimego
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 )
 
+// ErrNotJoined is returned when sending messages to a channel that has not been joined.
+var ErrNotJoined = errors.New("channel not joined")
+
 // ChannelOption represents the channel configuration options.
 // i.e. WithTable
 type ChannelOption func(ch *Channel)
 
+// postgresBinding is a subscription of a channel to the changes of a table.
+type postgresBinding struct {
+	event  Event
+	schema string
+	table  string
+	filter string
+	// handler is called with the changes. When nil, changes are routed to OnInsert, OnUpdate and OnDelete.
+	handler func(Message)
+
+	// id is assigned by the server when joining, and is put into changes.
+	id    float64
+	hasID bool
+}
+
 // Channel manages a subscription to a realtime socket.
 type Channel struct {
 	client *Client
 
 	Topic Topic
 
+	// configuration sent when joining the topic.
+	broadcastSelf   bool
+	broadcastAck    bool
+	presenceKey     string
+	postgresChanges []*postgresBinding
+
 	// OnInsert is a message handler for INSERT event messages
 	OnInsert func(Message)
 	// OnUpdate is a message handler for UPDATE event messages
@@ -22,12 +48,23 @@ type Channel struct {
 	OnDelete func(Message)
 	// OnBroadcast is a message handler for BROADCAST event messages
 	OnBroadcast func(Message)
+	// OnPresenceDiff is called when clients join or leave the presence of the channel.
+	OnPresenceDiff func(joins PresenceState, leaves PresenceState)
+	// OnError is called when the channel fails after it has been subscribed,
+	// i.e. when the server refuses to join it again or reports an error of an extension.
+	OnError func(err error)
+
+	mu       sync.Mutex
+	state    ChannelState
+	tracked  map[string]interface{}
+	presence presence
 }
 
 // newChannel returns a channel used to subscribe and unsubscribe to topics.
 func newChannel(c *Client, options ...ChannelOption) (*Channel, error) {
 	ch := &Channel{
 		client: c,
+		state:  CHANNEL_CLOSED,
 	}
 
 	// set default message handlers as needed
@@ -45,32 +82,444 @@ func newChannel(c *Client, options ...ChannelOption) (*Channel, error) {
 	return ch, nil
 }
 
-// Subscribe requests to receive messages for a topic from the realtime server.
+// State returns the state of the channel.
+func (ch *Channel) State() ChannelState {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.state
+}
+
+func (ch *Channel) setState(state ChannelState) {
+	ch.mu.Lock()
+	ch.state = state
+	ch.mu.Unlock()
+}
+
+// OnPostgresChanges subscribes the channel to the changes of a table, and routes them to handler.
+// event is EVENT_MESSAGE_INSERT, EVENT_MESSAGE_UPDATE, EVENT_MESSAGE_DELETE or EVENT_ALL.
+// filter restricts changes to the rows that match it, i.e. "bridge_id=eq.1".
+// It must be called before Subscribe.
+func (ch *Channel) OnPostgresChanges(event Event, schema string, table string, filter string, handler func(Message)) {
+	ch.postgresChanges = append(ch.postgresChanges, &postgresBinding{
+		event:   event,
+		schema:  schema,
+		table:   table,
+		filter:  filter,
+		handler: handler,
+	})
+}
+
+// Subscribe requests to receive messages for a topic from the realtime server,
+// and waits until the server accepts or refuses the request.
+// The channel is joined again when the client reconnects.
 func (ch *Channel) Subscribe() error {
 	// add to router
 	ch.client.router.AddChannel(ch)
 
-	msg := Message{
+	done := make(chan error, 1)
+
+	err := ch.join(func(err error) {
+		done <- err
+	})
+	if err == nil {
+		err = <-done
+	}
+
+	if err != nil {
+		ch.client.router.DelChannel(ch)
+		ch.setState(CHANNEL_CLOSED)
+		return fmt.Errorf("failed to join '%s': %w", ch.Topic, err)
+	}
+
+	return nil
+}
+
+// joinConfig returns the configuration sent when joining the topic.
+func (ch *Channel) joinConfig() map[string]interface{} {
+	changes := make([]interface{}, len(ch.postgresChanges))
+	for i, b := range ch.postgresChanges {
+		change := map[string]interface{}{
+			"event":  b.event,
+			"schema": b.schema,
+			"table":  b.table,
+		}
+		if b.filter != "" {
+			change["filter"] = b.filter
+		}
+		changes[i] = change
+	}
+
+	return map[string]interface{}{
+		"broadcast": map[string]interface{}{
+			"self": ch.broadcastSelf,
+			"ack":  ch.broadcastAck,
+		},
+		"presence": map[string]interface{}{
+			"key": ch.presenceKey,
+		},
+		"postgres_changes": changes,
+	}
+}
+
+// join sends a join request, with the latest parameters of the client.
+// onJoin is called with the outcome of the request, if it has been sent.
+func (ch *Channel) join(onJoin func(err error)) error {
+	payload := ch.client.joinParams()
+	payload["config"] = ch.joinConfig()
+
+	ch.setState(CHANNEL_JOINING)
+
+	p, err := ch.client.socket.request(Message{
 		Topic:   ch.Topic,
 		Event:   EVENT_JOIN,
-		Payload: ch.client.params,
+		Payload: payload,
+	}, ch.client.timeout)
+	if err != nil {
+		ch.setState(CHANNEL_ERRORED)
+		return err
+	}
+
+	p.Receive(STATUS_OK, func(response interface{}) {
+		onJoin(ch.onJoined(response))
+	})
+	p.Receive(STATUS_ERROR, func(response interface{}) {
+		ch.setState(CHANNEL_ERRORED)
+		onJoin(&ReplyError{Response: response})
+	})
+	p.Receive(STATUS_TIMEOUT, func(interface{}) {
+		ch.setState(CHANNEL_ERRORED)
+		onJoin(ErrTimeout)
+	})
+
+	return nil
+}
+
+// rejoin joins the channel again, reporting failures to OnError.
+func (ch *Channel) rejoin() {
+	err := ch.join(ch.reportError)
+	if err != nil {
+		ch.reportError(err)
+	}
+}
+
+func (ch *Channel) reportError(err error) {
+	if err != nil && ch.OnError != nil {
+		ch.OnError(fmt.Errorf("channel '%s': %w", ch.Topic, err))
+	}
+}
+
+// onJoined is called when the server accepts to join the channel.
+func (ch *Channel) onJoined(response interface{}) error {
+	res, _ := response.(map[string]interface{})
+	changes, _ := res["postgres_changes"].([]interface{})
+
+	ch.mu.Lock()
+
+	// the server assigns an ID to every subscription to changes, in the same order of the request
+	err := bindPostgresChanges(ch.postgresChanges, changes)
+	if err != nil {
+		ch.state = CHANNEL_ERRORED
+		ch.mu.Unlock()
+		return err
+	}
+
+	ch.state = CHANNEL_JOINED
+	tracked := ch.tracked
+	ch.mu.Unlock()
+
+	// presence is tracked again, since it has been lost with the previous join
+	if tracked != nil {
+		_, err = ch.pushPresence("track", tracked)
+		ch.reportError(err)
 	}
 
-	return ch.client.socket.push(msg)
+	return nil
 }
 
-// Unsubscribe requests to stop receiving messages for a topic from the realtime server.
+func bindPostgresChanges(bindings []*postgresBinding, changes []interface{}) error {
+	if len(changes) != len(bindings) {
+		return fmt.Errorf("mismatch between server and client bindings for postgres changes")
+	}
+
+	for i, b := range bindings {
+		change, _ := changes[i].(map[string]interface{})
+		event, _ := change["event"].(string)
+		schema, _ := change["schema"].(string)
+		table, _ := change["table"].(string)
+		filter, _ := change["filter"].(string)
+		id, ok := change["id"].(float64)
+
+		if !ok || Event(event) != b.event || schema != b.schema || table != b.table || filter != b.filter {
+			return fmt.Errorf("mismatch between server and client bindings for postgres changes")
+		}
+
+		b.id = id
+		b.hasID = true
+	}
+
+	return nil
+}
+
+// Unsubscribe requests to stop receiving messages for a topic from the realtime server,
+// and waits until the server replies.
 func (ch *Channel) Unsubscribe() error {
 	// remove from router
 	ch.client.router.DelChannel(ch)
 
-	msg := Message{
+	ch.mu.Lock()
+	ch.state = CHANNEL_LEAVING
+	ch.presence = presence{}
+	ch.mu.Unlock()
+
+	defer ch.setState(CHANNEL_CLOSED)
+
+	p, err := ch.client.socket.request(Message{
 		Topic:   ch.Topic,
 		Event:   EVENT_LEAVE,
-		Payload: ch.client.params,
+		Payload: map[string]interface{}{},
+	}, ch.client.timeout)
+	if err != nil {
+		return err
 	}
 
-	return ch.client.socket.push(msg)
+	_, err = p.Wait()
+	return err
+}
+
+// Push sends an event to the channel, and returns the push that tracks its reply.
+// The channel must be joined.
+func (ch *Channel) Push(event Event, payload interface{}) (*Push, error) {
+	if ch.State() != CHANNEL_JOINED {
+		return nil, ErrNotJoined
+	}
+
+	return ch.client.socket.request(Message{
+		Topic:   ch.Topic,
+		Event:   event,
+		Payload: payload,
+	}, ch.client.timeout)
+}
+
+// Send broadcasts a message to the clients that joined the channel.
+// When the channel has been created with WithBroadcastAck, it waits until the server receives the message.
+func (ch *Channel) Send(event string, payload interface{}) error {
+	msg := map[string]interface{}{
+		"event":   event,
+		"payload": payload,
+		"type":    "broadcast",
+	}
+
+	// the server replies only when acknowledgements are enabled
+	if !ch.broadcastAck {
+		if ch.State() != CHANNEL_JOINED {
+			return ErrNotJoined
+		}
+
+		return ch.client.socket.push(Message{
+			Topic:   ch.Topic,
+			Event:   EVENT_BROADCAST,
+			Payload: msg,
+		})
+	}
+
+	p, err := ch.Push(EVENT_BROADCAST, msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Wait()
+	return err
+}
+
+// Track publishes the metadata of the client on the presence of the channel, replacing the previous one,
+// and waits until the server receives it. The metadata is published again when the channel is joined again;
+// when the channel has not been joined yet, it is published once joined.
+func (ch *Channel) Track(meta map[string]interface{}) error {
+	ch.mu.Lock()
+	ch.tracked = meta
+	joined := ch.state == CHANNEL_JOINED
+	ch.mu.Unlock()
+
+	if !joined {
+		return nil
+	}
+
+	p, err := ch.pushPresence("track", meta)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Wait()
+	return err
+}
+
+// Untrack removes the client from the presence of the channel, and waits until the server receives it.
+func (ch *Channel) Untrack() error {
+	ch.mu.Lock()
+	ch.tracked = nil
+	joined := ch.state == CHANNEL_JOINED
+	ch.mu.Unlock()
+
+	if !joined {
+		return nil
+	}
+
+	p, err := ch.pushPresence("untrack", nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Wait()
+	return err
+}
+
+func (ch *Channel) pushPresence(event string, meta map[string]interface{}) (*Push, error) {
+	payload := map[string]interface{}{
+		"type":  "presence",
+		"event": event,
+	}
+	if meta != nil {
+		payload["payload"] = meta
+	}
+
+	return ch.client.socket.request(Message{
+		Topic:   ch.Topic,
+		Event:   EVENT_PRESENCE,
+		Payload: payload,
+	}, ch.client.timeout)
+}
+
+// Presence returns the clients that are present on the channel.
+func (ch *Channel) Presence() PresenceState {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.presence.state.copy()
+}
+
+func (ch *Channel) onPresenceState(msg *Message) {
+	ch.mu.Lock()
+	joins, leaves := ch.presence.syncState(decodePresenceState(msg.Payload))
+	ch.mu.Unlock()
+
+	if (len(joins) != 0 || len(leaves) != 0) && ch.OnPresenceDiff != nil {
+		ch.OnPresenceDiff(joins, leaves)
+	}
+}
+
+func (ch *Channel) onPresenceDiff(msg *Message) {
+	payload, ok := msg.Payload.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	joins := decodePresenceState(payload["joins"])
+	leaves := decodePresenceState(payload["leaves"])
+
+	ch.mu.Lock()
+	ch.presence.syncDiff(joins, leaves)
+	ch.mu.Unlock()
+
+	if ch.OnPresenceDiff != nil {
+		ch.OnPresenceDiff(joins, leaves)
+	}
+}
+
+// onPostgresChange routes a change to the handlers of the subscriptions whose IDs are in payload.ids.
+// Changes without IDs, sent by older servers, are routed by their type, that is in payload.data.type.
+func (ch *Channel) onPostgresChange(msg *Message) {
+	payload, ok := msg.Payload.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	ids, hasIDs := payload["ids"].([]interface{})
+
+	fallback := !hasIDs
+	var handlers []func(Message)
+
+	if hasIDs {
+		ch.mu.Lock()
+		for _, b := range ch.postgresChanges {
+			if !b.hasID || !containsID(ids, b.id) {
+				continue
+			}
+
+			if b.handler != nil {
+				handlers = append(handlers, b.handler)
+			} else {
+				fallback = true
+			}
+		}
+		ch.mu.Unlock()
+	}
+
+	for _, h := range handlers {
+		h(*msg)
+	}
+
+	if !fallback {
+		return
+	}
+
+	data, ok := payload["data"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	typ, _ := data["type"].(string)
+
+	switch Event(typ) {
+	case EVENT_MESSAGE_INSERT:
+		ch.OnInsert(*msg)
+	case EVENT_MESSAGE_UPDATE:
+		ch.OnUpdate(*msg)
+	case EVENT_MESSAGE_DELETE:
+		ch.OnDelete(*msg)
+	}
+}
+
+func containsID(ids []interface{}, id float64) bool {
+	for _, v := range ids {
+		if v, ok := v.(float64); ok && v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// onSystem handles status reports of the extensions of the server.
+func (ch *Channel) onSystem(msg *Message) {
+	payload, ok := msg.Payload.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if status, _ := payload["status"].(string); status != string(STATUS_ERROR) {
+		return
+	}
+
+	extension, _ := payload["extension"].(string)
+	message, _ := payload["message"].(string)
+	ch.reportError(fmt.Errorf("%s: %s", extension, message))
+}
+
+// onServerError is called when the channel crashes on the server. The channel is joined again after a delay.
+func (ch *Channel) onServerError() {
+	ch.mu.Lock()
+	if ch.state != CHANNEL_JOINED {
+		ch.mu.Unlock()
+		return
+	}
+	ch.state = CHANNEL_ERRORED
+	ch.mu.Unlock()
+
+	ch.reportError(fmt.Errorf("channel crashed on server"))
+
+	time.AfterFunc(ch.client.socket.backoffMin, func() {
+		if ch.State() == CHANNEL_ERRORED && ch.client.router.HasChannel(ch) {
+			ch.rejoin()
+		}
+	})
 }
 
 // WithTable option sets the database/schema/table for a channel.
@@ -113,6 +562,38 @@ func WithBroadcast(channelName string) ChannelOption {
 	}
 }
 
+// WithBroadcastSelf option makes the channel receive the messages that it broadcasts.
+func WithBroadcastSelf() ChannelOption {
+	return func(ch *Channel) {
+		ch.broadcastSelf = true
+	}
+}
+
+// WithBroadcastAck option makes the server acknowledge broadcast messages, and Send wait for it.
+func WithBroadcastAck() ChannelOption {
+	return func(ch *Channel) {
+		ch.broadcastAck = true
+	}
+}
+
+// WithPresence option sets the key that identifies the client on the presence of the channel.
+// When empty, the server generates one.
+func WithPresence(key string) ChannelOption {
+	return func(ch *Channel) {
+		ch.presenceKey = key
+	}
+}
+
+// WithPostgresChanges option subscribes a channel to the changes of a table.
+// Changes are routed to OnInsert, OnUpdate and OnDelete.
+// filter restricts changes to the rows that match it, i.e. "bridge_id=eq.1".
+func WithPostgresChanges(channelName string, schema string, table string, filter string) ChannelOption {
+	return func(ch *Channel) {
+		ch.Topic = Topic(fmt.Sprintf("realtime:%s", channelName))
+		ch.OnPostgresChanges(EVENT_ALL, schema, table, filter, nil)
+	}
+}
+
 func (ch *Channel) setDefaultMessageHandlers() {
 	if ch.OnInsert == nil {
 		ch.OnInsert = func(m Message) {
@@ -138,17 +619,3 @@ func (ch *Channel) setDefaultMessageHandlers() {
 		}
 	}
 }
-
-func (ch *Channel) Send(event string, payload interface{}) error {
-	msg := Message{
-		Topic: ch.Topic,
-		Event: EVENT_BROADCAST,
-		Payload: map[string]interface{}{
-			"event":   event,
-			"payload": payload,
-			"type":    "broadcast",
-		},
-	}
-
-	return ch.client.socket.push(msg)
-}
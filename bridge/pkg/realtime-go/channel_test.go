@@ -0,0 +1,297 @@
+package realtimego
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, s *testServer, options ...ClientOption) *Client {
+	options = append([]ClientOption{WithReconnectBackoff(10*time.Millisecond, 50*time.Millisecond)}, options...)
+
+	c, err := NewClient(s.URL, "key", options...)
+	require.NoError(t, err)
+
+	err = c.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Disconnect() }) //nolint:errcheck
+
+	return c
+}
+
+// waitEvent returns the next message with the given event received by the server.
+func (s *testServer) waitEvent(t *testing.T, event Event) Message {
+	for {
+		msg := s.waitMessage(t)
+		if msg.Event == event {
+			return msg
+		}
+	}
+}
+
+func TestChannelJoinRefused(t *testing.T) {
+	s := newTestServer(t)
+	s.onJoin = func(Message) (ReplyStatus, interface{}) {
+		return STATUS_ERROR, map[string]interface{}{"reason": "unauthorized"}
+	}
+
+	c := newTestClient(t, s)
+
+	ch, err := c.Channel(WithBroadcast("test"))
+	require.NoError(t, err)
+
+	err = ch.Subscribe()
+	require.EqualError(t, err, "failed to join 'realtime:test': unauthorized")
+
+	var replyErr *ReplyError
+	require.ErrorAs(t, err, &replyErr)
+	require.Equal(t, CHANNEL_CLOSED, ch.State())
+	require.Empty(t, c.router.Channels())
+}
+
+func TestChannelJoinTimeout(t *testing.T) {
+	s := newTestServer(t)
+	s.onJoin = func(Message) (ReplyStatus, interface{}) {
+		time.Sleep(200 * time.Millisecond)
+		return STATUS_OK, map[string]interface{}{}
+	}
+
+	c := newTestClient(t, s, WithTimeout(50*time.Millisecond))
+
+	ch, err := c.Channel(WithBroadcast("test"))
+	require.NoError(t, err)
+
+	err = ch.Subscribe()
+	require.ErrorIs(t, err, ErrTimeout)
+}
+
+func TestChannelSend(t *testing.T) {
+	for _, ca := range []string{"fire and forget", "ack"} {
+		t.Run(ca, func(t *testing.T) {
+			s := newTestServer(t)
+			c := newTestClient(t, s)
+
+			opts := []ChannelOption{WithBroadcast("test")}
+			if ca == "ack" {
+				opts = append(opts, WithBroadcastAck())
+			}
+
+			ch, err := c.Channel(opts...)
+			require.NoError(t, err)
+
+			err = ch.Send("ping", map[string]interface{}{"value": 1})
+			require.ErrorIs(t, err, ErrNotJoined)
+
+			err = ch.Subscribe()
+			require.NoError(t, err)
+			require.Equal(t, CHANNEL_JOINED, ch.State())
+
+			msg := s.waitEvent(t, EVENT_JOIN)
+			require.Equal(t, map[string]interface{}{
+				"self": false,
+				"ack":  ca == "ack",
+			}, msg.Payload.(map[string]interface{})["config"].(map[string]interface{})["broadcast"])
+
+			err = ch.Send("ping", map[string]interface{}{"value": 1})
+			require.NoError(t, err)
+
+			msg = s.waitEvent(t, EVENT_BROADCAST)
+			require.Equal(t, Topic("realtime:test"), msg.Topic)
+			require.Equal(t, map[string]interface{}{
+				"type":    "broadcast",
+				"event":   "ping",
+				"payload": map[string]interface{}{"value": float64(1)},
+			}, msg.Payload)
+			require.Equal(t, ca == "ack", msg.Ref != "")
+		})
+	}
+}
+
+func TestChannelPresence(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s)
+
+	ch, err := c.Channel(WithBroadcast("test"), WithPresence("bridge"))
+	require.NoError(t, err)
+
+	diffs := make(chan [2]PresenceState, 10)
+	ch.OnPresenceDiff = func(joins PresenceState, leaves PresenceState) {
+		diffs <- [2]PresenceState{joins, leaves}
+	}
+
+	waitDiff := func() [2]PresenceState {
+		select {
+		case d := <-diffs:
+			return d
+		case <-time.After(5 * time.Second):
+			t.Fatal("presence diff not received")
+			return [2]PresenceState{}
+		}
+	}
+
+	// metadata tracked before joining is published once joined
+	err = ch.Track(map[string]interface{}{"status": "starting"})
+	require.NoError(t, err)
+
+	err = ch.Subscribe()
+	require.NoError(t, err)
+
+	msg := s.waitEvent(t, EVENT_JOIN)
+	require.Equal(t, map[string]interface{}{"key": "bridge"},
+		msg.Payload.(map[string]interface{})["config"].(map[string]interface{})["presence"])
+
+	msg = s.waitEvent(t, EVENT_PRESENCE)
+	require.Equal(t, map[string]interface{}{
+		"type":    "presence",
+		"event":   "track",
+		"payload": map[string]interface{}{"status": "starting"},
+	}, msg.Payload)
+
+	d := waitDiff()
+	require.Equal(t, PresenceState{"bridge": {{"phx_ref": "ref1", "status": "starting"}}}, d[0])
+	require.Empty(t, d[1])
+
+	err = ch.Track(map[string]interface{}{"status": "ready"})
+	require.NoError(t, err)
+	s.waitEvent(t, EVENT_PRESENCE)
+
+	d = waitDiff()
+	require.Equal(t, PresenceState{"bridge": {{"phx_ref": "ref2", "status": "ready"}}}, d[0])
+	require.Equal(t, PresenceState{"bridge": {{"phx_ref": "ref1", "status": "starting"}}}, d[1])
+	require.Equal(t, PresenceState{"bridge": {{"phx_ref": "ref2", "status": "ready"}}}, ch.Presence())
+
+	// presence is tracked again after a reconnection
+	s.drop()
+
+	s.waitEvent(t, EVENT_JOIN)
+	msg = s.waitEvent(t, EVENT_PRESENCE)
+	require.Equal(t, map[string]interface{}{"status": "ready"}, msg.Payload.(map[string]interface{})["payload"])
+
+	// the state sent after joining again replaces the previous one
+	d = waitDiff()
+	require.Empty(t, d[0])
+	require.Equal(t, PresenceState{"bridge": {{"phx_ref": "ref2", "status": "ready"}}}, d[1])
+
+	d = waitDiff()
+	require.Equal(t, PresenceState{"bridge": {{"phx_ref": "ref1", "status": "ready"}}}, d[0])
+
+	err = ch.Untrack()
+	require.NoError(t, err)
+
+	d = waitDiff()
+	require.Empty(t, d[0])
+	require.Equal(t, PresenceState{"bridge": {{"phx_ref": "ref1", "status": "ready"}}}, d[1])
+	require.Empty(t, ch.Presence())
+
+	err = ch.Unsubscribe()
+	require.NoError(t, err)
+	require.Equal(t, CHANNEL_CLOSED, ch.State())
+}
+
+func TestChannelPostgresChanges(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s)
+
+	ch, err := c.Channel(WithPostgresChanges("test", "public", "camera", "bridge_id=eq.1"))
+	require.NoError(t, err)
+
+	inserts := make(chan Message, 10)
+	ch.OnInsert = func(m Message) {
+		inserts <- m
+	}
+
+	deletes := make(chan Message, 10)
+	ch.OnPostgresChanges(EVENT_MESSAGE_DELETE, "public", "alarm", "", func(m Message) {
+		deletes <- m
+	})
+
+	err = ch.Subscribe()
+	require.NoError(t, err)
+
+	msg := s.waitEvent(t, EVENT_JOIN)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"event": "*", "schema": "public", "table": "camera", "filter": "bridge_id=eq.1"},
+		map[string]interface{}{"event": "DELETE", "schema": "public", "table": "alarm"},
+	}, msg.Payload.(map[string]interface{})["config"].(map[string]interface{})["postgres_changes"])
+
+	s.broadcast(Message{
+		Topic: "realtime:test",
+		Event: EVENT_POSTGRES,
+		Payload: map[string]interface{}{
+			"ids":  []interface{}{101},
+			"data": map[string]interface{}{"type": "DELETE", "table": "alarm"},
+		},
+	})
+
+	s.broadcast(Message{
+		Topic: "realtime:test",
+		Event: EVENT_POSTGRES,
+		Payload: map[string]interface{}{
+			"ids":  []interface{}{100},
+			"data": map[string]interface{}{"type": "INSERT", "table": "camera"},
+		},
+	})
+
+	for _, received := range []chan Message{deletes, inserts} {
+		select {
+		case m := <-received:
+			require.Equal(t, EVENT_POSTGRES, m.Event)
+		case <-time.After(5 * time.Second):
+			t.Fatal("change not received")
+		}
+	}
+
+	require.Empty(t, deletes)
+	require.Empty(t, inserts)
+}
+
+func TestChannelPostgresChangesMismatch(t *testing.T) {
+	s := newTestServer(t)
+	s.onJoin = func(Message) (ReplyStatus, interface{}) {
+		return STATUS_OK, map[string]interface{}{"postgres_changes": []interface{}{
+			map[string]interface{}{"id": 100, "event": "*", "schema": "public", "table": "other"},
+		}}
+	}
+
+	c := newTestClient(t, s)
+
+	ch, err := c.Channel(WithPostgresChanges("test", "public", "camera", ""))
+	require.NoError(t, err)
+
+	err = ch.Subscribe()
+	require.EqualError(t, err, "failed to join 'realtime:test': mismatch between server and client bindings for postgres changes")
+}
+
+func TestChannelSystemError(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s)
+
+	ch, err := c.Channel(WithPostgresChanges("test", "public", "camera", ""))
+	require.NoError(t, err)
+
+	errs := make(chan error, 1)
+	ch.OnError = func(err error) {
+		errs <- err
+	}
+
+	err = ch.Subscribe()
+	require.NoError(t, err)
+
+	s.broadcast(Message{
+		Topic: "realtime:test",
+		Event: EVENT_SYSTEM,
+		Payload: map[string]interface{}{
+			"status":    "error",
+			"extension": "postgres_changes",
+			"message":   "invalid filter",
+		},
+	})
+
+	select {
+	case err := <-errs:
+		require.EqualError(t, err, "channel 'realtime:test': postgres_changes: invalid filter")
+	case <-time.After(5 * time.Second):
+		t.Fatal("error not received")
+	}
+}
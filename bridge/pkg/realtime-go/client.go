@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ClientOption represents the client configuration options.
@@ -15,11 +17,16 @@ type ClientOption func(c *Client)
 type Client struct {
 	addr, apiKey string
 	params       map[string]interface{}
+	paramsMu     sync.Mutex
 
 	socket *socket
 	router *router
 
 	heartbeatInterval uint
+	timeout           time.Duration
+	backoffMin        time.Duration
+	backoffMax        time.Duration
+	onState           func(state ConnState, err error)
 }
 
 // NewClient returns a realtime client.
@@ -36,9 +43,10 @@ func NewClient(addr, apiKey string, options ...ClientOption) (*Client, error) {
 	}
 
 	c := &Client{
-		addr:   addr,
-		apiKey: apiKey,
-		params: map[string]interface{}{},
+		addr:    addr,
+		apiKey:  apiKey,
+		params:  map[string]interface{}{},
+		timeout: 10 * time.Second,
 	}
 
 	// set options
@@ -51,14 +59,18 @@ func NewClient(addr, apiKey string, options ...ClientOption) (*Client, error) {
 	c.router = router
 
 	// create socket
-	socket := newSocket(c.heartbeatInterval)
+	socket := newSocket(c.heartbeatInterval, c.backoffMin, c.backoffMax)
 	c.socket = socket
 	c.socket.router = router
+	c.socket.onReconnect = c.rejoin
+	c.socket.onState = c.onState
 
 	return c, nil
 }
 
 // Connect creates a connection to the server.
+// When the connection fails, it is created again and channels are joined again,
+// until Disconnect is called.
 func (c *Client) Connect() error {
 	return c.socket.connect(context.Background(), c.addr)
 }
@@ -68,14 +80,50 @@ func (c *Client) Disconnect() error {
 	return c.socket.disconnect()
 }
 
+// State returns the state of the connection.
+func (c *Client) State() ConnState {
+	return c.socket.getState()
+}
+
+// rejoin joins again channels after a reconnection, with the latest parameters.
+func (c *Client) rejoin() {
+	for _, ch := range c.router.Channels() {
+		ch.rejoin()
+	}
+}
+
 // Channel creates a new subscription channel to the realtime server.
 func (c *Client) Channel(options ...ChannelOption) (*Channel, error) {
 	return newChannel(c, options...)
 }
 
 // SetAuth updates the client and channels with the latest token.
+// The token is sent when joining channels, and to channels that have already been joined.
 func (c *Client) SetAuth(token string) {
-	c.params[PARAM_USER_TOKEN] = token
+	c.paramsMu.Lock()
+	c.params[PARAM_ACCESS_TOKEN] = token
+	c.paramsMu.Unlock()
+
+	for _, ch := range c.router.Channels() {
+		msg := Message{
+			Topic:   ch.Topic,
+			Event:   EVENT_ACCESS_TOKEN,
+			Payload: map[string]interface{}{PARAM_ACCESS_TOKEN: token},
+		}
+		c.socket.push(msg) //nolint:errcheck
+	}
+}
+
+// joinParams returns a copy of the parameters sent when joining a channel.
+func (c *Client) joinParams() map[string]interface{} {
+	c.paramsMu.Lock()
+	defer c.paramsMu.Unlock()
+
+	ret := make(map[string]interface{}, len(c.params))
+	for k, v := range c.params {
+		ret[k] = v
+	}
+	return ret
 }
 
 // WithHeartbeatInterval option sets the heartbeat interval () on the socket connection.
@@ -85,6 +133,31 @@ func WithHeartbeatInterval(interval uint) func(*Client) {
 	}
 }
 
+// WithTimeout option sets how long to wait for replies of the server, i.e. to join channels.
+// The default is 10 seconds.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithReconnectBackoff option sets the delays between reconnection attempts.
+// The delay starts from minDelay and doubles after every failed attempt, up to maxDelay.
+func WithReconnectBackoff(minDelay time.Duration, maxDelay time.Duration) ClientOption {
+	return func(c *Client) {
+		c.backoffMin = minDelay
+		c.backoffMax = maxDelay
+	}
+}
+
+// WithStateHandler option sets a function that is called when the state of the connection changes,
+// and when reconnection attempts fail. err is the reason of the change, if any.
+func WithStateHandler(onState func(state ConnState, err error)) ClientOption {
+	return func(c *Client) {
+		c.onState = onState
+	}
+}
+
 // WithUserToken option sets the user_token parameter for user auth when communicating with the server.
 // i.e. authenticating the user for an RLS protected table.
 func WithUserToken(token string) ClientOption {
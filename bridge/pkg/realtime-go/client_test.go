@@ -0,0 +1,334 @@
+package realtimego
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer is a minimal realtime server, that replies to joins, broadcasts and presence
+// like the Supabase Realtime server.
+type testServer struct {
+	*httptest.Server
+
+	// replyHeartbeats sets whether heartbeats are replied.
+	replyHeartbeats bool
+	// onJoin, when set, returns the reply to a join.
+	onJoin func(msg Message) (ReplyStatus, interface{})
+
+	mutex    sync.Mutex
+	conns    []*websocket.Conn
+	received chan Message
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{
+		replyHeartbeats: true,
+		received:        make(chan Message, 100),
+	}
+
+	upgrader := websocket.Upgrader{}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		s.conns = append(s.conns, conn)
+		s.mutex.Unlock()
+
+		s.serve(conn)
+	}))
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testServer) reply(conn *websocket.Conn, msg Message, status ReplyStatus, response interface{}) {
+	s.write(conn, Message{
+		Topic:   msg.Topic,
+		Event:   EVENT_REPLY,
+		Payload: map[string]interface{}{"status": status, "response": response},
+		Ref:     msg.Ref,
+	})
+}
+
+func (s *testServer) serve(conn *websocket.Conn) {
+	// presence keys and metadata of the joined topics
+	presenceKeys := make(map[Topic]string)
+	tracked := make(map[Topic]map[string]interface{})
+	presenceRefs := 0
+
+	for {
+		var msg Message
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			return
+		}
+
+		if msg.Event == EVENT_HEARTBEAT {
+			s.mutex.Lock()
+			replyHeartbeats := s.replyHeartbeats
+			s.mutex.Unlock()
+
+			if replyHeartbeats {
+				s.reply(conn, msg, STATUS_OK, map[string]interface{}{})
+			}
+			continue
+		}
+
+		s.received <- msg
+
+		payload, _ := msg.Payload.(map[string]interface{})
+
+		switch msg.Event {
+		case EVENT_JOIN:
+			if s.onJoin != nil {
+				status, response := s.onJoin(msg)
+				s.reply(conn, msg, status, response)
+				continue
+			}
+
+			config, _ := payload["config"].(map[string]interface{})
+
+			// subscriptions to changes get IDs in the same order
+			var changes []interface{}
+			requested, _ := config["postgres_changes"].([]interface{})
+			for i, change := range requested {
+				c := map[string]interface{}{"id": 100 + i}
+				for k, v := range change.(map[string]interface{}) {
+					c[k] = v
+				}
+				changes = append(changes, c)
+			}
+
+			presence, _ := config["presence"].(map[string]interface{})
+			presenceKeys[msg.Topic], _ = presence["key"].(string)
+			delete(tracked, msg.Topic)
+
+			s.reply(conn, msg, STATUS_OK, map[string]interface{}{"postgres_changes": changes})
+			s.write(conn, Message{Topic: msg.Topic, Event: EVENT_PRESENCE_STATE, Payload: map[string]interface{}{}})
+
+		case EVENT_LEAVE:
+			s.reply(conn, msg, STATUS_OK, map[string]interface{}{})
+			s.write(conn, Message{Topic: msg.Topic, Event: EVENT_CLOSE, Payload: map[string]interface{}{}})
+
+		case EVENT_BROADCAST:
+			// only broadcasts that need an acknowledgement have a ref
+			if msg.Ref != "" {
+				s.reply(conn, msg, STATUS_OK, map[string]interface{}{})
+			}
+
+		case EVENT_PRESENCE:
+			key := presenceKeys[msg.Topic]
+			diff := map[string]interface{}{
+				"joins":  map[string]interface{}{},
+				"leaves": map[string]interface{}{},
+			}
+
+			if old, ok := tracked[msg.Topic]; ok {
+				diff["leaves"] = map[string]interface{}{key: map[string]interface{}{"metas": []interface{}{old}}}
+				delete(tracked, msg.Topic)
+			}
+
+			if payload["event"] == "track" {
+				presenceRefs++
+				meta := map[string]interface{}{"phx_ref": fmt.Sprintf("ref%d", presenceRefs)}
+				for k, v := range payload["payload"].(map[string]interface{}) {
+					meta[k] = v
+				}
+				tracked[msg.Topic] = meta
+				diff["joins"] = map[string]interface{}{key: map[string]interface{}{"metas": []interface{}{meta}}}
+			}
+
+			s.reply(conn, msg, STATUS_OK, map[string]interface{}{})
+			s.write(conn, Message{Topic: msg.Topic, Event: EVENT_PRESENCE_DIFF, Payload: diff})
+		}
+	}
+}
+
+func (s *testServer) write(conn *websocket.Conn, msg Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	conn.WriteJSON(msg) //nolint:errcheck
+}
+
+// broadcast sends a message to the last connection.
+func (s *testServer) broadcast(msg Message) {
+	s.mutex.Lock()
+	conn := s.conns[len(s.conns)-1]
+	s.mutex.Unlock()
+
+	s.write(conn, msg)
+}
+
+// drop closes all connections.
+func (s *testServer) drop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *testServer) connCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.conns)
+}
+
+func (s *testServer) waitMessage(t *testing.T) Message {
+	select {
+	case msg := <-s.received:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+		return Message{}
+	}
+}
+
+type stateRecorder struct {
+	mutex  sync.Mutex
+	states []ConnState
+}
+
+func (r *stateRecorder) onState(state ConnState, _ error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.states = append(r.states, state)
+}
+
+func (r *stateRecorder) get() []ConnState {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]ConnState(nil), r.states...)
+}
+
+func TestClientReconnect(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := &stateRecorder{}
+
+	c, err := NewClient(s.URL, "key",
+		WithReconnectBackoff(10*time.Millisecond, 50*time.Millisecond),
+		WithStateHandler(rec.onState))
+	require.NoError(t, err)
+
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Disconnect() //nolint:errcheck
+
+	require.Equal(t, STATE_CONNECTED, c.State())
+
+	c.SetAuth("token1")
+
+	ch, err := c.Channel(WithBroadcast("test"))
+	require.NoError(t, err)
+
+	received := make(chan Message, 10)
+	ch.OnBroadcast = func(m Message) {
+		received <- m
+	}
+
+	err = ch.Subscribe()
+	require.NoError(t, err)
+
+	msg := s.waitMessage(t)
+	require.Equal(t, EVENT_JOIN, msg.Event)
+	require.Equal(t, Topic("realtime:test"), msg.Topic)
+	require.Equal(t, "token1", msg.Payload.(map[string]interface{})[PARAM_ACCESS_TOKEN])
+
+	// the token is changed while the connection is down
+	s.drop()
+	require.Eventually(t, func() bool { return c.State() == STATE_RECONNECTING }, 5*time.Second, 5*time.Millisecond)
+	c.SetAuth("token2")
+
+	// channels are joined again with the latest token
+	for {
+		msg = s.waitMessage(t)
+		if msg.Event == EVENT_JOIN {
+			break
+		}
+	}
+	require.Equal(t, Topic("realtime:test"), msg.Topic)
+	require.Equal(t, "token2", msg.Payload.(map[string]interface{})[PARAM_ACCESS_TOKEN])
+
+	require.Eventually(t, func() bool { return c.State() == STATE_CONNECTED }, 5*time.Second, 5*time.Millisecond)
+
+	s.broadcast(Message{
+		Topic:   "realtime:test",
+		Event:   EVENT_BROADCAST,
+		Payload: map[string]interface{}{"event": "test"},
+	})
+
+	select {
+	case m := <-received:
+		require.Equal(t, map[string]interface{}{"event": "test"}, m.Payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcast not received")
+	}
+
+	err = c.Disconnect()
+	require.NoError(t, err)
+	require.Equal(t, STATE_CLOSED, c.State())
+
+	require.Equal(t, []ConnState{
+		STATE_CONNECTING,
+		STATE_CONNECTED,
+		STATE_RECONNECTING,
+		STATE_CONNECTED,
+		STATE_CLOSED,
+	}, rec.get())
+
+	// the client doesn't reconnect after Disconnect
+	n := s.connCount()
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, n, s.connCount())
+}
+
+func TestClientHeartbeatTimeout(t *testing.T) {
+	s := newTestServer(t)
+	s.replyHeartbeats = false
+
+	c, err := NewClient(s.URL, "key",
+		WithHeartbeatInterval(1),
+		WithReconnectBackoff(10*time.Millisecond, 50*time.Millisecond))
+	require.NoError(t, err)
+
+	err = c.Connect()
+	require.NoError(t, err)
+	defer c.Disconnect() //nolint:errcheck
+
+	// the connection is replaced when a heartbeat is not replied
+	require.Eventually(t, func() bool { return s.connCount() >= 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestClientConnectError(t *testing.T) {
+	s := newTestServer(t)
+	s.Close()
+
+	rec := &stateRecorder{}
+
+	c, err := NewClient(s.URL, "key", WithStateHandler(rec.onState))
+	require.NoError(t, err)
+
+	err = c.Connect()
+	require.Error(t, err)
+	require.Equal(t, STATE_DISCONNECTED, c.State())
+
+	ch, err := c.Channel(WithBroadcast("test"))
+	require.NoError(t, err)
+	require.ErrorIs(t, ch.Subscribe(), ErrNotConnected)
+
+	require.Equal(t, []ConnState{STATE_CONNECTING, STATE_DISCONNECTED}, rec.get())
+}
@@ -1,5 +1,6 @@
 package realtimego
 
 const (
-	PARAM_USER_TOKEN = "user_token"
+	PARAM_USER_TOKEN   = "user_token"
+	PARAM_ACCESS_TOKEN = "access_token"
 )
@@ -7,11 +7,20 @@ const (
 	EVENT_JOIN           Event = "phx_join"
 	EVENT_LEAVE          Event = "phx_leave"
 	EVENT_REPLY          Event = "phx_reply"
+	EVENT_ERROR          Event = "phx_error"
+	EVENT_CLOSE          Event = "phx_close"
 	EVENT_HEARTBEAT      Event = "heartbeat"
 	EVENT_MESSAGE_INSERT Event = "INSERT"
 	EVENT_MESSAGE_UPDATE Event = "UPDATE"
 	EVENT_MESSAGE_DELETE Event = "DELETE"
+	EVENT_ALL            Event = "*"
 	EVENT_BROADCAST      Event = "broadcast"
+	EVENT_POSTGRES       Event = "postgres_changes"
+	EVENT_PRESENCE       Event = "presence"
+	EVENT_PRESENCE_STATE Event = "presence_state"
+	EVENT_PRESENCE_DIFF  Event = "presence_diff"
+	EVENT_SYSTEM         Event = "system"
+	EVENT_ACCESS_TOKEN   Event = "access_token"
 )
 
 type Topic string
@@ -19,3 +28,36 @@ type Topic string
 const (
 	PHOENIX_TOPIC Topic = "phoenix"
 )
+
+// ConnState is the state of the connection to the server.
+type ConnState string
+
+const (
+	STATE_DISCONNECTED ConnState = "disconnected"
+	STATE_CONNECTING   ConnState = "connecting"
+	STATE_CONNECTED    ConnState = "connected"
+	STATE_RECONNECTING ConnState = "reconnecting"
+	STATE_CLOSED       ConnState = "closed"
+)
+
+// ChannelState is the state of a channel.
+type ChannelState string
+
+const (
+	CHANNEL_CLOSED  ChannelState = "closed"
+	CHANNEL_JOINING ChannelState = "joining"
+	CHANNEL_JOINED  ChannelState = "joined"
+	CHANNEL_ERRORED ChannelState = "errored"
+	CHANNEL_LEAVING ChannelState = "leaving"
+)
+
+// ReplyStatus is the status of a reply to a push.
+type ReplyStatus string
+
+const (
+	STATUS_OK    ReplyStatus = "ok"
+	STATUS_ERROR ReplyStatus = "error"
+	// STATUS_TIMEOUT is not sent by the server: pushes get it when they are not
+	// replied in time, or when the connection fails before.
+	STATUS_TIMEOUT ReplyStatus = "timeout"
+)
@@ -1,8 +1,48 @@
 package realtimego
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
 type Message struct {
 	Topic   Topic       `json:"topic"`
 	Event   Event       `json:"event"`
 	Payload interface{} `json:"payload"`
-	Ref     int64       `json:"ref"`
+	Ref     Ref         `json:"ref"`
+}
+
+// Ref is the reference of a message, that the server puts into replies.
+// Messages that don't expect a reply have an empty reference.
+type Ref string
+
+// MarshalJSON implements json.Marshaler.
+func (r Ref) MarshalJSON() ([]byte, error) {
+	if r == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(string(r))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// References are strings, but some servers send numbers.
+func (r *Ref) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		*r = ""
+	case string:
+		*r = Ref(v)
+	case float64:
+		*r = Ref(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("invalid ref: %s", b)
+	}
+
+	return nil
 }
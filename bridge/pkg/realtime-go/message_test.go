@@ -0,0 +1,35 @@
+package realtimego
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageRef(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		json string
+		ref  Ref
+	}{
+		{"string", `{"ref":"12"}`, "12"},
+		{"number", `{"ref":12}`, "12"},
+		{"null", `{"ref":null}`, ""},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			var msg Message
+			err := json.Unmarshal([]byte(ca.json), &msg)
+			require.NoError(t, err)
+			require.Equal(t, ca.ref, msg.Ref)
+		})
+	}
+
+	var msg Message
+	err := json.Unmarshal([]byte(`{"ref":{}}`), &msg)
+	require.Error(t, err)
+
+	buf, err := json.Marshal(Message{Topic: "t", Event: EVENT_BROADCAST})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"topic":"t","event":"broadcast","payload":null,"ref":null}`, string(buf))
+}
@@ -0,0 +1,116 @@
+package realtimego
+
+// PresenceState maps the keys of the clients that are present on a channel to their metadata.
+// A key has multiple metadata when the same client is connected multiple times.
+// Every metadata contains the "phx_ref" field, that identifies the connection.
+type PresenceState map[string][]map[string]interface{}
+
+// copy returns a copy of the state, that shares the metadata.
+func (s PresenceState) copy() PresenceState {
+	ret := make(PresenceState, len(s))
+	for key, metas := range s {
+		ret[key] = append([]map[string]interface{}(nil), metas...)
+	}
+	return ret
+}
+
+func presenceRef(meta map[string]interface{}) string {
+	ref, _ := meta["phx_ref"].(string)
+	return ref
+}
+
+func containsPresenceRef(metas []map[string]interface{}, ref string) bool {
+	for _, meta := range metas {
+		if presenceRef(meta) == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// decodePresenceState decodes a state in the format of the server,
+// that is { key: { "metas": [ meta, ... ] } }.
+func decodePresenceState(v interface{}) PresenceState {
+	ret := make(PresenceState)
+
+	entries, _ := v.(map[string]interface{})
+	for key, entry := range entries {
+		e, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		metas, _ := e["metas"].([]interface{})
+		for _, meta := range metas {
+			if m, ok := meta.(map[string]interface{}); ok {
+				ret[key] = append(ret[key], m)
+			}
+		}
+	}
+
+	return ret
+}
+
+// presence stores the clients that are present on a channel.
+type presence struct {
+	state PresenceState
+}
+
+// syncState replaces the state with the one sent by the server after joining,
+// and returns the difference with the previous one.
+func (p *presence) syncState(state PresenceState) (PresenceState, PresenceState) {
+	joins := make(PresenceState)
+	leaves := make(PresenceState)
+
+	for key, metas := range p.state {
+		for _, meta := range metas {
+			if !containsPresenceRef(state[key], presenceRef(meta)) {
+				leaves[key] = append(leaves[key], meta)
+			}
+		}
+	}
+
+	for key, metas := range state {
+		for _, meta := range metas {
+			if !containsPresenceRef(p.state[key], presenceRef(meta)) {
+				joins[key] = append(joins[key], meta)
+			}
+		}
+	}
+
+	p.state = state
+
+	return joins, leaves
+}
+
+// syncDiff applies a difference sent by the server.
+func (p *presence) syncDiff(joins PresenceState, leaves PresenceState) {
+	if p.state == nil {
+		p.state = make(PresenceState)
+	}
+
+	for key, metas := range joins {
+		var kept []map[string]interface{}
+		for _, meta := range p.state[key] {
+			if !containsPresenceRef(metas, presenceRef(meta)) {
+				kept = append(kept, meta)
+			}
+		}
+		p.state[key] = append(kept, metas...)
+	}
+
+	for key, metas := range leaves {
+		var kept []map[string]interface{}
+		for _, meta := range p.state[key] {
+			if !containsPresenceRef(metas, presenceRef(meta)) {
+				kept = append(kept, meta)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(p.state, key)
+		} else {
+			p.state[key] = kept
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package realtimego
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresenceSync(t *testing.T) {
+	p := presence{}
+
+	joins, leaves := p.syncState(decodePresenceState(map[string]interface{}{
+		"a": map[string]interface{}{"metas": []interface{}{
+			map[string]interface{}{"phx_ref": "1", "status": "ready"},
+		}},
+		"b": map[string]interface{}{"metas": []interface{}{
+			map[string]interface{}{"phx_ref": "2"},
+			map[string]interface{}{"phx_ref": "3"},
+		}},
+	}))
+	require.Equal(t, PresenceState{
+		"a": {{"phx_ref": "1", "status": "ready"}},
+		"b": {{"phx_ref": "2"}, {"phx_ref": "3"}},
+	}, joins)
+	require.Empty(t, leaves)
+
+	// metadata is replaced, and keys without metadata are removed
+	p.syncDiff(
+		PresenceState{"a": {{"phx_ref": "4", "status": "busy"}}},
+		PresenceState{"a": {{"phx_ref": "1"}}, "b": {{"phx_ref": "2"}}},
+	)
+	require.Equal(t, PresenceState{
+		"a": {{"phx_ref": "4", "status": "busy"}},
+		"b": {{"phx_ref": "3"}},
+	}, p.state)
+
+	p.syncDiff(nil, PresenceState{"b": {{"phx_ref": "3"}}})
+	require.Equal(t, PresenceState{"a": {{"phx_ref": "4", "status": "busy"}}}, p.state)
+
+	joins, leaves = p.syncState(PresenceState{"c": {{"phx_ref": "5"}}})
+	require.Equal(t, PresenceState{"c": {{"phx_ref": "5"}}}, joins)
+	require.Equal(t, PresenceState{"a": {{"phx_ref": "4", "status": "busy"}}}, leaves)
+}
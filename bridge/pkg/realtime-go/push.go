@@ -0,0 +1,110 @@
+package realtimego
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned when a push is not replied in time.
+var ErrTimeout = errors.New("timed out")
+
+// ReplyError is returned when the server replies to a push with an error.
+type ReplyError struct {
+	Response interface{}
+}
+
+// Error implements the error interface.
+func (e *ReplyError) Error() string {
+	if res, ok := e.Response.(map[string]interface{}); ok {
+		if reason, ok := res["reason"].(string); ok {
+			return reason
+		}
+	}
+	return fmt.Sprintf("server replied with error: %v", e.Response)
+}
+
+type pushReceiver struct {
+	status ReplyStatus
+	cb     func(response interface{})
+}
+
+// Push is a message sent to the server, whose reply is tracked through its reference.
+type Push struct {
+	timer *time.Timer
+	done  chan struct{}
+
+	mu        sync.Mutex
+	status    ReplyStatus
+	response  interface{}
+	receivers []pushReceiver
+}
+
+func newPush() *Push {
+	return &Push{
+		done: make(chan struct{}),
+	}
+}
+
+// Receive sets a function that is called when the push gets a reply with the given status.
+// If the reply has already been received, the function is called immediately.
+// Functions are called by the goroutine that reads messages, therefore they must not wait for other replies.
+func (p *Push) Receive(status ReplyStatus, cb func(response interface{})) *Push {
+	p.mu.Lock()
+
+	if p.status == "" {
+		p.receivers = append(p.receivers, pushReceiver{status: status, cb: cb})
+		p.mu.Unlock()
+		return p
+	}
+
+	replied, response := p.status, p.response
+	p.mu.Unlock()
+
+	if replied == status {
+		cb(response)
+	}
+
+	return p
+}
+
+// Wait blocks until the push gets a reply, and returns its response.
+func (p *Push) Wait() (interface{}, error) {
+	<-p.done
+
+	switch p.status {
+	case STATUS_OK:
+		return p.response, nil
+
+	case STATUS_ERROR:
+		return nil, &ReplyError{Response: p.response}
+
+	default:
+		return nil, ErrTimeout
+	}
+}
+
+// resolve stores the reply and calls receivers. Replies after the first one are discarded.
+func (p *Push) resolve(status ReplyStatus, response interface{}) {
+	p.mu.Lock()
+
+	if p.status != "" {
+		p.mu.Unlock()
+		return
+	}
+
+	p.status = status
+	p.response = response
+	receivers := p.receivers
+	p.receivers = nil
+	close(p.done)
+
+	p.mu.Unlock()
+
+	for _, r := range receivers {
+		if r.status == status {
+			r.cb(response)
+		}
+	}
+}
@@ -29,6 +29,25 @@ func (r *router) DelChannel(ch *Channel) {
 	delete(r.channels, ch.Topic)
 }
 
+func (r *router) Channels() []*Channel {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ret := make([]*Channel, 0, len(r.channels))
+	for _, ch := range r.channels {
+		ret = append(ret, ch)
+	}
+	return ret
+}
+
+// HasChannel returns whether a channel is subscribed.
+func (r *router) HasChannel(ch *Channel) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.channels[ch.Topic] == ch
+}
+
 func (r *router) RouteMessage(msg *Message) {
 	r.mu.RLock()
 
@@ -50,6 +69,15 @@ func (r *router) RouteMessage(msg *Message) {
 		ch.OnDelete(*msg)
 	case EVENT_BROADCAST:
 		ch.OnBroadcast(*msg)
+	case EVENT_POSTGRES:
+		ch.onPostgresChange(msg)
+	case EVENT_PRESENCE_STATE:
+		ch.onPresenceState(msg)
+	case EVENT_PRESENCE_DIFF:
+		ch.onPresenceDiff(msg)
+	case EVENT_SYSTEM:
+		ch.onSystem(msg)
+	case EVENT_ERROR:
+		ch.onServerError()
 	}
-
 }
@@ -2,57 +2,157 @@ package realtimego
 
 import (
 	"context"
-	"log"
-	"net/http"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// ErrNotConnected is returned when sending messages while the connection is down.
+var ErrNotConnected = errors.New("not connected")
+
+// errHeartbeatTimeout is returned when the server doesn't reply to a heartbeat.
+var errHeartbeatTimeout = errors.New("heartbeat timed out")
+
 // socket manages a connection to the phoenix server via websockets.
+// When the connection fails, it is replaced with a new one, after a delay that grows exponentially.
 type socket struct {
-	socket *websocket.Conn
 	router *router
-	cancel context.CancelFunc
-	mu     sync.Mutex
 
 	// heartbeatInterval is the delay in seconds between heartbeat notifications to the server.
+	// The connection is considered failed when a heartbeat is not replied before the next one.
 	heartbeatInterval uint
+	// delays between reconnection attempts.
+	backoffMin time.Duration
+	backoffMax time.Duration
+
+	// onReconnect is called when the connection has been replaced, before the state changes.
+	onReconnect func()
+	// onState is called when the state of the connection changes.
+	onState func(state ConnState, err error)
+
+	addr      string
+	ctx       context.Context
+	ctxCancel func()
+	done      chan struct{}
+	ref       int64
+
+	// heartbeatRef is the ref of the last heartbeat that has not been replied.
+	heartbeatRef int64
+
+	mu      sync.Mutex // protects conn and pending, and serializes writes
+	conn    *websocket.Conn
+	state   ConnState
+	pending map[Ref]*Push
 }
 
-func newSocket(heartbeatInterval uint) *socket {
+func newSocket(heartbeatInterval uint, backoffMin time.Duration, backoffMax time.Duration) *socket {
 	// default interval
 	if heartbeatInterval == 0 {
 		heartbeatInterval = 10
 	}
 
-	return &socket{heartbeatInterval: heartbeatInterval}
+	// default backoff
+	if backoffMin == 0 {
+		backoffMin = time.Second
+	}
+	if backoffMax < backoffMin {
+		backoffMax = 30 * time.Second
+		if backoffMax < backoffMin {
+			backoffMax = backoffMin
+		}
+	}
+
+	return &socket{
+		heartbeatInterval: heartbeatInterval,
+		backoffMin:        backoffMin,
+		backoffMax:        backoffMax,
+		state:             STATE_DISCONNECTED,
+		pending:           make(map[Ref]*Push),
+	}
 }
 
 // connect creates a connection to the server.
+// Once connected, the connection is supervised until disconnect() is called.
 func (s *socket) connect(ctx context.Context, addr string) error {
-	// socket context
-	ctx, cancel := context.WithCancel(ctx)
-	s.cancel = cancel
+	s.addr = addr
+	s.ctx, s.ctxCancel = context.WithCancel(ctx)
+
+	s.setState(STATE_CONNECTING, nil)
 
-	conn, _, err := websocket.DefaultDialer.Dial(addr, http.Header{})
+	conn, err := s.dial()
 	if err != nil {
+		s.ctxCancel()
+		s.setState(STATE_DISCONNECTED, err)
 		return err
 	}
-	s.socket = conn
 
-	// run heartbeat and listen routines
-	go s.heartbeat(ctx, time.Duration(s.heartbeatInterval*uint(time.Second)))
-	go s.listen(ctx)
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+
+	s.setState(STATE_CONNECTED, nil)
+
+	s.done = make(chan struct{})
+	go s.run(conn)
 
 	return nil
 }
 
-// disconnect closes and cleans up the connection.
+// disconnect closes the connection and stops reconnecting.
 func (s *socket) disconnect() error {
-	defer s.cancel()
-	return s.socket.Close()
+	if s.ctxCancel == nil {
+		return ErrNotConnected
+	}
+
+	s.ctxCancel()
+
+	var err error
+	s.mu.Lock()
+	if s.conn != nil {
+		err = s.conn.Close()
+		s.conn = nil
+	}
+	s.mu.Unlock()
+
+	if s.done != nil {
+		<-s.done
+	}
+
+	s.failPending()
+
+	s.setState(STATE_CLOSED, nil)
+
+	return err
+}
+
+// getState returns the state of the connection.
+func (s *socket) getState() ConnState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+func (s *socket) setState(state ConnState, err error) {
+	s.mu.Lock()
+	changed := state != s.state
+	s.state = state
+	s.mu.Unlock()
+
+	// failed reconnection attempts are notified too, with their error
+	if (changed || err != nil) && s.onState != nil {
+		s.onState(state, err)
+	}
+}
+
+// nextRef returns a reference for a message.
+func (s *socket) nextRef() Ref {
+	return Ref(strconv.FormatInt(atomic.AddInt64(&s.ref, 1), 10))
 }
 
 // push sends data on the connection.
@@ -60,49 +160,249 @@ func (s *socket) push(data interface{}) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return s.socket.WriteJSON(data)
+	if s.conn == nil || s.state != STATE_CONNECTED {
+		return ErrNotConnected
+	}
+
+	return s.conn.WriteJSON(data)
 }
 
-// heartbeat is a routine informing the connection the client is still alive.
-func (s *socket) heartbeat(ctx context.Context, interval time.Duration) {
+// request sends a message with a new reference, and returns the push that tracks its reply.
+// The push gets STATUS_TIMEOUT when the reply is not received before timeout.
+func (s *socket) request(msg Message, timeout time.Duration) (*Push, error) {
+	p := newPush()
+	msg.Ref = s.nextRef()
+
+	p.timer = time.AfterFunc(timeout, func() {
+		s.mu.Lock()
+		if s.pending[msg.Ref] == p {
+			delete(s.pending, msg.Ref)
+		}
+		s.mu.Unlock()
+
+		p.resolve(STATUS_TIMEOUT, nil)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil || s.state != STATE_CONNECTED {
+		p.timer.Stop()
+		return nil, ErrNotConnected
+	}
+
+	err := s.conn.WriteJSON(msg)
+	if err != nil {
+		p.timer.Stop()
+		return nil, err
+	}
+
+	s.pending[msg.Ref] = p
+
+	return p, nil
+}
+
+// reply resolves the push a reply refers to. It returns false if there's no such push.
+func (s *socket) reply(msg *Message) bool {
+	s.mu.Lock()
+	p, ok := s.pending[msg.Ref]
+	delete(s.pending, msg.Ref)
+	s.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	p.timer.Stop()
+
+	var status ReplyStatus
+	var response interface{}
+	if payload, ok := msg.Payload.(map[string]interface{}); ok {
+		str, _ := payload["status"].(string)
+		status = ReplyStatus(str)
+		response = payload["response"]
+	}
+
+	if status != STATUS_OK {
+		status = STATUS_ERROR
+	}
+
+	p.resolve(status, response)
+	return true
+}
+
+// failPending resolves pushes that are waiting for a reply, since replies
+// are never received once their connection has been closed.
+func (s *socket) failPending() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[Ref]*Push)
+	s.mu.Unlock()
+
+	for _, p := range pending {
+		p.timer.Stop()
+		p.resolve(STATUS_TIMEOUT, nil)
+	}
+}
+
+func (s *socket) dial() (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(s.ctx, s.addr, nil)
+	return conn, err
+}
+
+// run supervises connections, and replaces them when they fail.
+func (s *socket) run(conn *websocket.Conn) {
+	defer close(s.done)
+
 	for {
-		select {
-		case <-ctx.Done():
+		err := s.runConn(conn)
+
+		s.mu.Lock()
+		if s.conn == conn {
+			s.conn = nil
+		}
+		s.mu.Unlock()
+
+		s.failPending()
+
+		if s.ctx.Err() != nil {
 			return
-		default:
-			msg := Message{
-				Topic: PHOENIX_TOPIC,
-				Event: EVENT_HEARTBEAT,
+		}
+
+		s.setState(STATE_RECONNECTING, err)
+
+		conn = s.redial()
+		if conn == nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conn = conn
+		s.state = STATE_CONNECTED
+		s.mu.Unlock()
+
+		// channels are joined again before callers are notified,
+		// in order for them to be able to fetch what they missed.
+		if s.onReconnect != nil {
+			s.onReconnect()
+		}
+
+		if s.onState != nil {
+			s.onState(STATE_CONNECTED, nil)
+		}
+	}
+}
+
+// redial connects again, waiting between attempts. It returns nil when the socket is closed.
+func (s *socket) redial() *websocket.Conn {
+	delay := s.backoffMin
+
+	for {
+		// jitter avoids reconnecting all clients at once after an outage of the server
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+		select {
+		case <-time.After(wait):
+		case <-s.ctx.Done():
+			return nil
+		}
+
+		conn, err := s.dial()
+		if err == nil {
+			return conn
+		}
+
+		if s.ctx.Err() != nil {
+			return nil
+		}
+
+		s.setState(STATE_RECONNECTING, err)
+
+		delay *= 2
+		if delay > s.backoffMax {
+			delay = s.backoffMax
+		}
+	}
+}
+
+// runConn sends heartbeats and receives messages until the connection fails.
+func (s *socket) runConn(conn *websocket.Conn) error {
+	atomic.StoreInt64(&s.heartbeatRef, 0)
+
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- s.listen(conn)
+	}()
+
+	ticker := time.NewTicker(time.Duration(s.heartbeatInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-readErr:
+			conn.Close()
+			return err
+
+		case <-ticker.C:
+			if atomic.LoadInt64(&s.heartbeatRef) != 0 {
+				conn.Close()
+				<-readErr
+				return errHeartbeatTimeout
 			}
-			err := s.push(msg)
+
+			ref := atomic.AddInt64(&s.ref, 1)
+			atomic.StoreInt64(&s.heartbeatRef, ref)
+
+			err := s.push(Message{
+				Topic:   PHOENIX_TOPIC,
+				Event:   EVENT_HEARTBEAT,
+				Payload: map[string]interface{}{},
+				Ref:     Ref(strconv.FormatInt(ref, 10)),
+			})
 			if err != nil {
-				log.Println("heartbeat error:", err)
+				conn.Close()
+				<-readErr
+				return err
 			}
-			time.Sleep(interval)
+
+		case <-s.ctx.Done():
+			conn.Close()
+			<-readErr
+			return s.ctx.Err()
 		}
 	}
 }
 
-// listen is a routine receiving messages from the connection.
-func (s *socket) listen(ctx context.Context) {
+// listen receives messages from a connection, until it fails.
+func (s *socket) listen(conn *websocket.Conn) error {
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			var message Message
-			if err := s.socket.ReadJSON(&message); err != nil {
-				log.Println("message read error:", err)
+		_, buf, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		// malformed messages are discarded
+		var message Message
+		if err := json.Unmarshal(buf, &message); err != nil {
+			continue
+		}
+
+		// handle events and route messages
+		switch message.Event {
+		case EVENT_REPLY:
+			if s.reply(&message) {
+				continue
 			}
 
-			// handle events and route messages
-			switch message.Event {
-			case EVENT_REPLY:
-			case EVENT_JOIN:
-			case EVENT_MESSAGE:
-			default:
-				s.router.RouteMessage(&message)
+			if message.Topic == PHOENIX_TOPIC {
+				if ref, err := strconv.ParseInt(string(message.Ref), 10, 64); err == nil {
+					atomic.CompareAndSwapInt64(&s.heartbeatRef, ref, 0)
+				}
 			}
+		case EVENT_JOIN:
+		case EVENT_MESSAGE:
+		default:
+			s.router.RouteMessage(&message)
 		}
 	}
 }
@@ -0,0 +1,280 @@
+-- pair_bridge pairs the bridge that displays the given pairing token with a site.
+-- Only admins of the site can pair bridges with it.
+-- Tokens are compared ignoring case and separators, and expired tokens are rejected.
+-- Users can't fail more than 5 times in 10 minutes, in order to prevent tokens
+-- from being guessed.
+CREATE OR REPLACE FUNCTION pair_bridge(p_access_token text, p_site_id bigint)
+RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_bridge_id bigint;
+  v_failures integer;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'authentication required';
+  END IF;
+
+  IF site_role(p_site_id) IS DISTINCT FROM 'admin' THEN
+    RAISE EXCEPTION 'permission denied' USING ERRCODE = '42501';
+  END IF;
+
+  SELECT count(*) INTO v_failures
+  FROM pairing_attempt
+  WHERE user_id = auth.uid()
+    AND NOT succeeded
+    AND created_at > now() - interval '10 minutes';
+
+  IF v_failures >= 5 THEN
+    RAISE EXCEPTION 'too many pairing attempts, retry later';
+  END IF;
+
+  UPDATE bridge
+  SET site_id = p_site_id,
+    access_token = NULL,
+    access_token_expires_at = NULL,
+    updated_at = now()
+  WHERE site_id IS NULL
+    AND access_token IS NOT NULL
+    AND access_token_expires_at > now()
+    AND replace(access_token, '-', '') = upper(regexp_replace(p_access_token, '[^A-Za-z0-9]', '', 'g'))
+  RETURNING id INTO v_bridge_id;
+
+  INSERT INTO pairing_attempt (user_id, succeeded)
+  VALUES (auth.uid(), v_bridge_id IS NOT NULL);
+
+  RETURN v_bridge_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION pair_bridge(text, bigint) FROM public;
+GRANT EXECUTE ON FUNCTION pair_bridge(text, bigint) TO authenticated;
+
+
+-- current_bridge_id returns the bridge authenticated by the access token of the request,
+-- or NULL if the request doesn't come from a bridge.
+-- Tokens issued before the credentials of the bridge have been revoked are rejected.
+CREATE OR REPLACE FUNCTION current_bridge_id()
+RETURNS bigint
+LANGUAGE plpgsql
+STABLE
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_bridge_id bigint;
+  v_revoked_at timestamp with time zone;
+BEGIN
+  IF auth.jwt()->>'role' IS DISTINCT FROM 'bridge' THEN
+    RETURN NULL;
+  END IF;
+
+  SELECT id, credentials_revoked_at INTO v_bridge_id, v_revoked_at
+  FROM bridge
+  WHERE id = (auth.jwt()->>'bridge_id')::bigint;
+
+  IF v_revoked_at IS NOT NULL AND to_timestamp((auth.jwt()->>'iat')::bigint) < v_revoked_at THEN
+    RAISE EXCEPTION 'bridge credentials revoked' USING ERRCODE = '28000';
+  END IF;
+
+  RETURN v_bridge_id;
+END;
+$$;
+
+-- current_site_id returns the site of the bridge authenticated by the request.
+-- It is read at every request, therefore unpairing takes effect immediately.
+CREATE OR REPLACE FUNCTION current_site_id()
+RETURNS bigint
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = public
+AS $$
+  SELECT site_id FROM bridge WHERE id = current_bridge_id();
+$$;
+
+-- revoke_bridge revokes the credentials of a bridge and unpairs it.
+-- Only admins of the site of the bridge can revoke it.
+-- Access tokens of the bridge are rejected immediately, and no secret can be enrolled
+-- until the bridge is re-provisioned. The revoked secret can't be enrolled again.
+CREATE OR REPLACE FUNCTION revoke_bridge(p_bridge_id bigint)
+RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_site_id bigint;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'authentication required';
+  END IF;
+
+  SELECT site_id INTO v_site_id FROM bridge WHERE id = p_bridge_id;
+
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'bridge not found';
+  END IF;
+
+  IF site_role(v_site_id) IS DISTINCT FROM 'admin' THEN
+    RAISE EXCEPTION 'permission denied' USING ERRCODE = '42501';
+  END IF;
+
+  UPDATE bridge
+  SET site_id = NULL,
+    access_token = NULL,
+    access_token_expires_at = NULL,
+    revoked_secret_hash = secret_hash,
+    secret_hash = NULL,
+    revoked_site_id = v_site_id,
+    credentials_revoked_at = now(),
+    updated_at = now()
+  WHERE id = p_bridge_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION revoke_bridge(bigint) FROM public;
+GRANT EXECUTE ON FUNCTION revoke_bridge(bigint) TO authenticated;
+
+-- reprovision_bridge allows a revoked bridge to enroll a new secret.
+-- Only admins of the site the bridge has been revoked from can re-provision it,
+-- once the bridge is back in their hands. The bridge must be paired again.
+CREATE OR REPLACE FUNCTION reprovision_bridge(p_bridge_id bigint)
+RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_site_id bigint;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'authentication required';
+  END IF;
+
+  SELECT revoked_site_id INTO v_site_id FROM bridge WHERE id = p_bridge_id;
+
+  IF v_site_id IS NULL THEN
+    RAISE EXCEPTION 'bridge not found or not revoked';
+  END IF;
+
+  IF site_role(v_site_id) IS DISTINCT FROM 'admin' THEN
+    RAISE EXCEPTION 'permission denied' USING ERRCODE = '42501';
+  END IF;
+
+  UPDATE bridge
+  SET revoked_site_id = NULL,
+    updated_at = now()
+  WHERE id = p_bridge_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION reprovision_bridge(bigint) FROM public;
+GRANT EXECUTE ON FUNCTION reprovision_bridge(bigint) TO authenticated;
+
+-- unpair_bridge removes a bridge from its site, keeping its credentials.
+-- Only admins of the site of the bridge can unpair it. The bridge then displays
+-- a new pairing token.
+CREATE OR REPLACE FUNCTION unpair_bridge(p_bridge_id bigint)
+RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+DECLARE
+  v_site_id bigint;
+BEGIN
+  IF auth.uid() IS NULL THEN
+    RAISE EXCEPTION 'authentication required';
+  END IF;
+
+  SELECT site_id INTO v_site_id FROM bridge WHERE id = p_bridge_id;
+
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'bridge not found';
+  END IF;
+
+  IF site_role(v_site_id) IS DISTINCT FROM 'admin' THEN
+    RAISE EXCEPTION 'permission denied' USING ERRCODE = '42501';
+  END IF;
+
+  UPDATE bridge
+  SET site_id = NULL,
+    access_token = NULL,
+    access_token_expires_at = NULL,
+    updated_at = now()
+  WHERE id = p_bridge_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION unpair_bridge(bigint) FROM public;
+GRANT EXECUTE ON FUNCTION unpair_bridge(bigint) TO authenticated;
+
+
+-- site_role returns the role of the current user in a site, or NULL if the user is not a member.
+CREATE OR REPLACE FUNCTION site_role(p_site_id bigint)
+RETURNS text
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = public
+AS $$
+  SELECT role FROM site_member WHERE site_id = p_site_id AND user_id = auth.uid();
+$$;
+
+REVOKE ALL ON FUNCTION site_role(bigint) FROM public;
+GRANT EXECUTE ON FUNCTION site_role(bigint) TO authenticated;
+
+-- add_site_creator makes the user that creates a site its admin.
+CREATE OR REPLACE FUNCTION add_site_creator()
+RETURNS trigger
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public
+AS $$
+BEGIN
+  IF auth.uid() IS NOT NULL THEN
+    INSERT INTO site_member (site_id, user_id, role)
+    VALUES (NEW.id, auth.uid(), 'admin');
+  END IF;
+
+  RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS add_site_creator ON site;
+CREATE TRIGGER add_site_creator
+  AFTER INSERT ON site
+  FOR EACH ROW
+  EXECUTE FUNCTION add_site_creator();
+
+-- request_role returns the role, in the site of the current bridge, of the user that sent a request,
+-- and deletes the request, in order for it not to be replayed.
+-- It returns NULL if the request has been sent to another bridge, doesn't match the given method,
+-- path and body digest, is older than a minute, or if its sender is not a member of the site.
+DROP FUNCTION IF EXISTS request_role(text, text, text);
+CREATE OR REPLACE FUNCTION request_role(p_request_id text, p_method text, p_path text, p_body_hash text)
+RETURNS text
+LANGUAGE sql
+VOLATILE
+SECURITY DEFINER
+SET search_path = public
+AS $$
+  DELETE FROM bridge_request r
+  USING bridge b, site_member m
+  WHERE b.id = r.bridge_id
+    AND m.site_id = b.site_id
+    AND m.user_id = r.user_id
+    AND r.request_id = p_request_id
+    AND r.bridge_id = current_bridge_id()
+    AND r.request_method = p_method
+    AND r.request_path = p_path
+    AND r.request_body_hash = p_body_hash
+    AND r.created_at > now() - interval '1 minute'
+  RETURNING m.role;
+$$;
+
+REVOKE ALL ON FUNCTION request_role(text, text, text, text) FROM public;
+GRANT EXECUTE ON FUNCTION request_role(text, text, text, text) TO bridge;
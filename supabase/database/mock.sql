@@ -38,13 +38,13 @@ INSERT INTO camera (bridge_id, camera_name, ip_address, source, is_registered, h
 
 -- Mock data for alarm table
 INSERT INTO alarm (site_id, bridge_id, camera_id, alarm_name, alarm_type, last_alarm_at, is_read, read_at, snapshot_url, video_url) VALUES
-(2, 4, 6, 'Camera Connection Lost', 'connection_error', '2024-01-15 09:45:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(5, 10, 15, 'Server Room Access Detected', 'unauthorized_access', '2024-01-15 08:30:00+09', true, '2024-01-15 08:35:00+09', 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(1, 1, 1, 'Motion Detected at Main Entrance', 'motion_detection', '2024-01-15 10:30:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(3, 5, 7, 'Security Checkpoint Alert', 'security_alert', '2024-01-15 07:20:00+09', true, '2024-01-15 07:25:00+09', 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(4, 7, 10, 'Factory Equipment Malfunction', 'equipment_error', '2024-01-15 09:00:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(2, 3, 4, 'Harbor Dock Activity', 'motion_detection', '2024-01-15 09:45:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(1, 2, 3, 'Backup System Activated', 'system_alert', '2024-01-15 10:30:00+09', true, '2024-01-15 10:32:00+09', 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(3, 6, 9, 'Terminal Gate Opened', 'access_control', '2024-01-15 07:20:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(5, 9, 13, 'Research Lab Door Opened', 'access_control', '2024-01-15 08:30:00+09', true, '2024-01-15 08:33:00+09', 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
-(4, 8, 12, 'Warehouse Inventory Check', 'scheduled_event', '2024-01-15 09:00:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300');
\ No newline at end of file
+(2, 4, 6, 'Camera Connection Lost', 'video-loss', '2024-01-15 09:45:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(5, 10, 15, 'Server Room Access Detected', 'intrusion', '2024-01-15 08:30:00+09', true, '2024-01-15 08:35:00+09', 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(1, 1, 1, 'Motion Detected at Main Entrance', 'motion', '2024-01-15 10:30:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(3, 5, 7, 'Security Checkpoint Alert', 'intrusion', '2024-01-15 07:20:00+09', true, '2024-01-15 07:25:00+09', 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(4, 7, 10, 'Factory Equipment Malfunction', 'system', '2024-01-15 09:00:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(2, 3, 4, 'Harbor Dock Activity', 'motion', '2024-01-15 09:45:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(1, 2, 3, 'Backup System Activated', 'system', '2024-01-15 10:30:00+09', true, '2024-01-15 10:32:00+09', 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(3, 6, 9, 'Terminal Gate Opened', 'io-input', '2024-01-15 07:20:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(5, 9, 13, 'Research Lab Door Opened', 'io-input', '2024-01-15 08:30:00+09', true, '2024-01-15 08:33:00+09', 'https://picsum.photos/200/300', 'https://picsum.photos/200/300'),
+(4, 8, 12, 'Warehouse Inventory Check', 'system', '2024-01-15 09:00:00+09', false, NULL, 'https://picsum.photos/200/300', 'https://picsum.photos/200/300');
\ No newline at end of file
@@ -10,9 +10,17 @@ CREATE POLICY "Allow only specific email domain"
 -- Enable RLS on all tables
 ALTER TABLE site ENABLE ROW LEVEL SECURITY;
 ALTER TABLE bridge ENABLE ROW LEVEL SECURITY;
+ALTER TABLE bridge_config ENABLE ROW LEVEL SECURITY;
 ALTER TABLE camera ENABLE ROW LEVEL SECURITY;
 ALTER TABLE alarm ENABLE ROW LEVEL SECURITY;
 ALTER TABLE response ENABLE ROW LEVEL SECURITY;
+ALTER TABLE incident ENABLE ROW LEVEL SECURITY;
+ALTER TABLE panel ENABLE ROW LEVEL SECURITY;
+ALTER TABLE panel_zone ENABLE ROW LEVEL SECURITY;
+ALTER TABLE walk_test ENABLE ROW LEVEL SECURITY;
+ALTER TABLE site_member ENABLE ROW LEVEL SECURITY;
+ALTER TABLE bridge_request ENABLE ROW LEVEL SECURITY;
+ALTER TABLE pairing_attempt ENABLE ROW LEVEL SECURITY; -- only accessed by pair_bridge
 
 -- Site table policies
 DROP POLICY IF EXISTS "Allow authenticated users to view sites" ON site;
@@ -44,7 +52,63 @@ CREATE POLICY "Allow authenticated users to delete sites"
   TO authenticated
   USING (true);
 
+-- Site member table policies
+DROP POLICY IF EXISTS "Allow users to view members of their sites" ON site_member;
+CREATE POLICY "Allow users to view members of their sites"
+  ON site_member
+  FOR SELECT
+  TO authenticated
+  USING (site_role(site_id) IS NOT NULL);
+
+DROP POLICY IF EXISTS "Allow site admins to insert members" ON site_member;
+CREATE POLICY "Allow site admins to insert members"
+  ON site_member
+  FOR INSERT
+  TO authenticated
+  WITH CHECK (site_role(site_id) = 'admin');
+
+DROP POLICY IF EXISTS "Allow site admins to update members" ON site_member;
+CREATE POLICY "Allow site admins to update members"
+  ON site_member
+  FOR UPDATE
+  TO authenticated
+  USING (site_role(site_id) = 'admin')
+  WITH CHECK (site_role(site_id) = 'admin');
+
+DROP POLICY IF EXISTS "Allow site admins to delete members" ON site_member;
+CREATE POLICY "Allow site admins to delete members"
+  ON site_member
+  FOR DELETE
+  TO authenticated
+  USING (site_role(site_id) = 'admin');
+
+-- Bridge request table policies
+DROP POLICY IF EXISTS "Allow users to view their bridge requests" ON bridge_request;
+CREATE POLICY "Allow users to view their bridge requests"
+  ON bridge_request
+  FOR SELECT
+  TO authenticated
+  USING (user_id = auth.uid());
+
+DROP POLICY IF EXISTS "Allow users to insert their bridge requests" ON bridge_request;
+CREATE POLICY "Allow users to insert their bridge requests"
+  ON bridge_request
+  FOR INSERT
+  TO authenticated
+  WITH CHECK (user_id = auth.uid());
+
 -- Bridge table policies
+-- Bridges are created by the bridge-auth function. Credentials, pairing tokens and the site of
+-- bridges are changed only through pair_bridge, unpair_bridge, revoke_bridge and
+-- reprovision_bridge, therefore users can't read credentials and pairing tokens, and can only
+-- rename bridges. Pairing tokens are checked by pair_bridge, that limits attempts.
+REVOKE ALL ON bridge FROM anon, authenticated;
+GRANT SELECT (id, bridge_uuid, site_id, bridge_name, healthy, version, status, credentials_revoked_at,
+  last_checked_at, created_at, updated_at)
+  ON bridge TO authenticated;
+GRANT UPDATE (bridge_name, updated_at) ON bridge TO authenticated;
+GRANT DELETE ON bridge TO authenticated;
+
 DROP POLICY IF EXISTS "Allow authenticated users to view bridges" ON bridge;
 CREATE POLICY "Allow authenticated users to view bridges"
   ON bridge
@@ -53,28 +117,62 @@ CREATE POLICY "Allow authenticated users to view bridges"
   USING (true);
 
 DROP POLICY IF EXISTS "Allow authenticated users to insert bridges" ON bridge;
-CREATE POLICY "Allow authenticated users to insert bridges"
+
+DROP POLICY IF EXISTS "Allow authenticated users to update bridges" ON bridge;
+DROP POLICY IF EXISTS "Allow site admins to update bridges" ON bridge;
+CREATE POLICY "Allow site admins to update bridges"
   ON bridge
+  FOR UPDATE
+  TO authenticated
+  USING (site_role(site_id) = 'admin')
+  WITH CHECK (site_role(site_id) = 'admin');
+
+DROP POLICY IF EXISTS "Allow authenticated users to delete bridges" ON bridge;
+DROP POLICY IF EXISTS "Allow site admins to delete bridges" ON bridge;
+CREATE POLICY "Allow site admins to delete bridges"
+  ON bridge
+  FOR DELETE
+  TO authenticated
+  USING (site_role(site_id) = 'admin');
+
+-- Bridge config table policies
+DROP POLICY IF EXISTS "Allow authenticated users to view bridge configs" ON bridge_config;
+CREATE POLICY "Allow authenticated users to view bridge configs"
+  ON bridge_config
+  FOR SELECT
+  TO authenticated
+  USING (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to insert bridge configs" ON bridge_config;
+CREATE POLICY "Allow authenticated users to insert bridge configs"
+  ON bridge_config
   FOR INSERT
   TO authenticated
   WITH CHECK (true);
 
-DROP POLICY IF EXISTS "Allow authenticated users to update bridges" ON bridge;
-CREATE POLICY "Allow authenticated users to update bridges"
-  ON bridge
+DROP POLICY IF EXISTS "Allow authenticated users to update bridge configs" ON bridge_config;
+CREATE POLICY "Allow authenticated users to update bridge configs"
+  ON bridge_config
   FOR UPDATE
   TO authenticated
   USING (true)
   WITH CHECK (true);
 
-DROP POLICY IF EXISTS "Allow authenticated users to delete bridges" ON bridge;
-CREATE POLICY "Allow authenticated users to delete bridges"
-  ON bridge
+DROP POLICY IF EXISTS "Allow authenticated users to delete bridge configs" ON bridge_config;
+CREATE POLICY "Allow authenticated users to delete bridge configs"
+  ON bridge_config
   FOR DELETE
   TO authenticated
   USING (true);
 
 -- Camera table policies
+-- Credentials of cameras can be written by users, when registering cameras, but are readable
+-- only by bridges, that add them to sources.
+REVOKE SELECT ON camera FROM anon, authenticated;
+GRANT SELECT (id, bridge_id, camera_name, ip_address, source, is_registered, healthy, timezone, settings,
+  config_error, last_checked_at, created_at, updated_at)
+  ON camera TO authenticated;
+
 DROP POLICY IF EXISTS "Allow authenticated users to view cameras" ON camera;
 CREATE POLICY "Allow authenticated users to view cameras"
   ON camera
@@ -162,4 +260,260 @@ CREATE POLICY "Allow authenticated users to delete responses"
   ON response
   FOR DELETE
   TO authenticated
-  USING (true);
\ No newline at end of file
+  USING (true);
+
+-- Incident table policies
+DROP POLICY IF EXISTS "Allow authenticated users to view incidents" ON incident;
+CREATE POLICY "Allow authenticated users to view incidents"
+  ON incident
+  FOR SELECT
+  TO authenticated
+  USING (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to insert incidents" ON incident;
+CREATE POLICY "Allow authenticated users to insert incidents"
+  ON incident
+  FOR INSERT
+  TO authenticated
+  WITH CHECK (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to update incidents" ON incident;
+CREATE POLICY "Allow authenticated users to update incidents"
+  ON incident
+  FOR UPDATE
+  TO authenticated
+  USING (true)
+  WITH CHECK (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to delete incidents" ON incident;
+CREATE POLICY "Allow authenticated users to delete incidents"
+  ON incident
+  FOR DELETE
+  TO authenticated
+  USING (true);
+
+-- Panel table policies
+DROP POLICY IF EXISTS "Allow authenticated users to view panels" ON panel;
+CREATE POLICY "Allow authenticated users to view panels"
+  ON panel
+  FOR SELECT
+  TO authenticated
+  USING (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to insert panels" ON panel;
+CREATE POLICY "Allow authenticated users to insert panels"
+  ON panel
+  FOR INSERT
+  TO authenticated
+  WITH CHECK (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to update panels" ON panel;
+CREATE POLICY "Allow authenticated users to update panels"
+  ON panel
+  FOR UPDATE
+  TO authenticated
+  USING (true)
+  WITH CHECK (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to delete panels" ON panel;
+CREATE POLICY "Allow authenticated users to delete panels"
+  ON panel
+  FOR DELETE
+  TO authenticated
+  USING (true);
+
+-- Panel zone table policies
+DROP POLICY IF EXISTS "Allow authenticated users to view panel zones" ON panel_zone;
+CREATE POLICY "Allow authenticated users to view panel zones"
+  ON panel_zone
+  FOR SELECT
+  TO authenticated
+  USING (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to insert panel zones" ON panel_zone;
+CREATE POLICY "Allow authenticated users to insert panel zones"
+  ON panel_zone
+  FOR INSERT
+  TO authenticated
+  WITH CHECK (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to update panel zones" ON panel_zone;
+CREATE POLICY "Allow authenticated users to update panel zones"
+  ON panel_zone
+  FOR UPDATE
+  TO authenticated
+  USING (true)
+  WITH CHECK (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to delete panel zones" ON panel_zone;
+CREATE POLICY "Allow authenticated users to delete panel zones"
+  ON panel_zone
+  FOR DELETE
+  TO authenticated
+  USING (true);
+
+-- Walk test table policies
+DROP POLICY IF EXISTS "Allow authenticated users to view walk tests" ON walk_test;
+CREATE POLICY "Allow authenticated users to view walk tests"
+  ON walk_test
+  FOR SELECT
+  TO authenticated
+  USING (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to insert walk tests" ON walk_test;
+CREATE POLICY "Allow authenticated users to insert walk tests"
+  ON walk_test
+  FOR INSERT
+  TO authenticated
+  WITH CHECK (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to update walk tests" ON walk_test;
+CREATE POLICY "Allow authenticated users to update walk tests"
+  ON walk_test
+  FOR UPDATE
+  TO authenticated
+  USING (true)
+  WITH CHECK (true);
+
+DROP POLICY IF EXISTS "Allow authenticated users to delete walk tests" ON walk_test;
+CREATE POLICY "Allow authenticated users to delete walk tests"
+  ON walk_test
+  FOR DELETE
+  TO authenticated
+  USING (true);
+
+
+-- Bridge policies
+-- Bridges authenticate with access tokens carrying the "bridge" role, issued by the bridge-auth
+-- function. They can only access their own records and the ones of their site.
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'bridge') THEN
+    CREATE ROLE bridge NOLOGIN NOINHERIT;
+  END IF;
+END
+$$;
+
+GRANT bridge TO authenticator;
+GRANT USAGE ON SCHEMA public TO bridge;
+
+GRANT SELECT ON bridge, bridge_config, camera, panel, panel_zone, site TO bridge;
+GRANT SELECT, INSERT, UPDATE ON alarm, incident, walk_test TO bridge;
+GRANT UPDATE (site_id, access_token, access_token_expires_at, healthy, version, status, last_checked_at, updated_at)
+  ON bridge TO bridge;
+GRANT UPDATE (healthy, last_checked_at, config_error) ON camera TO bridge;
+GRANT UPDATE (arm_status, arm_status_changed_at) ON site TO bridge;
+GRANT UPDATE (status, error, applied_at) ON bridge_config TO bridge;
+GRANT INSERT ON response TO bridge;
+
+GRANT USAGE ON SCHEMA storage TO bridge;
+GRANT SELECT ON storage.buckets TO bridge;
+GRANT SELECT, INSERT ON storage.objects TO bridge;
+
+DROP POLICY IF EXISTS "Allow bridges to view themselves" ON bridge;
+CREATE POLICY "Allow bridges to view themselves"
+  ON bridge
+  FOR SELECT
+  TO bridge
+  USING (id = current_bridge_id());
+
+-- bridges can unpair themselves, but can't pair themselves with another site
+DROP POLICY IF EXISTS "Allow bridges to update themselves" ON bridge;
+CREATE POLICY "Allow bridges to update themselves"
+  ON bridge
+  FOR UPDATE
+  TO bridge
+  USING (id = current_bridge_id())
+  WITH CHECK (site_id IS NULL OR site_id = current_site_id());
+
+DROP POLICY IF EXISTS "Allow bridges to view their config" ON bridge_config;
+CREATE POLICY "Allow bridges to view their config"
+  ON bridge_config
+  FOR SELECT
+  TO bridge
+  USING (bridge_id = current_bridge_id());
+
+-- bridges can only report the status of their config
+DROP POLICY IF EXISTS "Allow bridges to report the status of their config" ON bridge_config;
+CREATE POLICY "Allow bridges to report the status of their config"
+  ON bridge_config
+  FOR UPDATE
+  TO bridge
+  USING (bridge_id = current_bridge_id())
+  WITH CHECK (bridge_id = current_bridge_id());
+
+-- bridges answer commands without reading responses
+DROP POLICY IF EXISTS "Allow bridges to insert their responses" ON response;
+CREATE POLICY "Allow bridges to insert their responses"
+  ON response
+  FOR INSERT
+  TO bridge
+  WITH CHECK (bridge_id = current_bridge_id());
+
+DROP POLICY IF EXISTS "Allow bridges to view their site" ON site;
+CREATE POLICY "Allow bridges to view their site"
+  ON site
+  FOR SELECT
+  TO bridge
+  USING (id = current_site_id());
+
+DROP POLICY IF EXISTS "Allow bridges to update their site" ON site;
+CREATE POLICY "Allow bridges to update their site"
+  ON site
+  FOR UPDATE
+  TO bridge
+  USING (id = current_site_id())
+  WITH CHECK (id = current_site_id());
+
+DROP POLICY IF EXISTS "Allow bridges to view their cameras" ON camera;
+CREATE POLICY "Allow bridges to view their cameras"
+  ON camera
+  FOR SELECT
+  TO bridge
+  USING (bridge_id = current_bridge_id());
+
+DROP POLICY IF EXISTS "Allow bridges to update their cameras" ON camera;
+CREATE POLICY "Allow bridges to update their cameras"
+  ON camera
+  FOR UPDATE
+  TO bridge
+  USING (bridge_id = current_bridge_id())
+  WITH CHECK (bridge_id = current_bridge_id());
+
+DROP POLICY IF EXISTS "Allow bridges to view their panels" ON panel;
+CREATE POLICY "Allow bridges to view their panels"
+  ON panel
+  FOR SELECT
+  TO bridge
+  USING (bridge_id = current_bridge_id() AND site_id = current_site_id());
+
+DROP POLICY IF EXISTS "Allow bridges to view their panel zones" ON panel_zone;
+CREATE POLICY "Allow bridges to view their panel zones"
+  ON panel_zone
+  FOR SELECT
+  TO bridge
+  USING (panel_id IN (SELECT id FROM panel WHERE bridge_id = current_bridge_id()));
+
+DROP POLICY IF EXISTS "Allow bridges to manage their alarms" ON alarm;
+CREATE POLICY "Allow bridges to manage their alarms"
+  ON alarm
+  FOR ALL
+  TO bridge
+  USING (bridge_id = current_bridge_id() AND site_id = current_site_id())
+  WITH CHECK (bridge_id = current_bridge_id() AND site_id = current_site_id());
+
+DROP POLICY IF EXISTS "Allow bridges to manage their incidents" ON incident;
+CREATE POLICY "Allow bridges to manage their incidents"
+  ON incident
+  FOR ALL
+  TO bridge
+  USING (bridge_id = current_bridge_id() AND site_id = current_site_id())
+  WITH CHECK (bridge_id = current_bridge_id() AND site_id = current_site_id());
+
+DROP POLICY IF EXISTS "Allow bridges to manage their walk tests" ON walk_test;
+CREATE POLICY "Allow bridges to manage their walk tests"
+  ON walk_test
+  FOR ALL
+  TO bridge
+  USING (bridge_id = current_bridge_id() AND site_id = current_site_id())
+  WITH CHECK (bridge_id = current_bridge_id() AND site_id = current_site_id());
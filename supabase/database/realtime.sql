@@ -4,11 +4,26 @@ ALTER PUBLICATION supabase_realtime ADD TABLE site;
 -- bridge 테이블을 Realtime에 추가
 ALTER PUBLICATION supabase_realtime ADD TABLE bridge;
 
+-- bridge_config 테이블을 Realtime에 추가
+ALTER PUBLICATION supabase_realtime ADD TABLE bridge_config;
+
 -- camera 테이블을 Realtime에 추가  
 ALTER PUBLICATION supabase_realtime ADD TABLE camera;
 
+-- panel 테이블을 Realtime에 추가
+ALTER PUBLICATION supabase_realtime ADD TABLE panel;
+
+-- panel_zone 테이블을 Realtime에 추가
+ALTER PUBLICATION supabase_realtime ADD TABLE panel_zone;
+
 -- alarm 테이블을 Realtime에 추가
 ALTER PUBLICATION supabase_realtime ADD TABLE alarm;
 
+-- incident 테이블을 Realtime에 추가
+ALTER PUBLICATION supabase_realtime ADD TABLE incident;
+
+-- walk_test 테이블을 Realtime에 추가
+ALTER PUBLICATION supabase_realtime ADD TABLE walk_test;
+
 -- response 테이블을 Realtime에 추가
 ALTER PUBLICATION supabase_realtime ADD TABLE response;
\ No newline at end of file
@@ -14,14 +14,34 @@ CREATE TABLE IF NOT EXISTS site (
 );
 
 
+DROP TABLE IF EXISTS site_member CASCADE;
+CREATE TABLE IF NOT EXISTS site_member (
+  site_id bigint NOT NULL,
+  user_id uuid NOT NULL,
+  role text NOT NULL DEFAULT 'viewer', -- viewer, operator, admin. Checked by bridges when answering requests
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  updated_at timestamp with time zone NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (site_id, user_id),
+  FOREIGN KEY (site_id) REFERENCES site(id) ON DELETE CASCADE
+);
+
+
 DROP TABLE IF EXISTS bridge CASCADE;
 CREATE TABLE IF NOT EXISTS bridge (
   id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
   bridge_uuid uuid UNIQUE NOT NULL,
   site_id bigint,
   bridge_name text NOT NULL,
-  access_token text,
+  access_token text, -- pairing token, i.e. ABCDE-FGHJK. Set by the bridge while it is not paired
+  access_token_expires_at timestamp with time zone,
   healthy boolean NOT NULL DEFAULT true,
+  version text, -- version of the bridge software, reported by heartbeats
+  status jsonb, -- {version, uptime, disk, streams}, reported by heartbeats
+  secret_hash text, -- SHA-256 of the secret of the bridge, exchanged for access tokens. NULL: enrolled by the next bridge that authenticates
+  credentials_revoked_at timestamp with time zone, -- access tokens issued before are rejected
+  revoked_site_id bigint, -- site the bridge was paired with when its credentials were revoked. Not NULL: secrets can't be enrolled until an admin of the site re-provisions the bridge
+  revoked_secret_hash text, -- SHA-256 of the revoked secret, that can't be enrolled again
   last_checked_at timestamp with time zone NOT NULL DEFAULT now(),
   created_at timestamp with time zone NOT NULL DEFAULT now(),
   updated_at timestamp with time zone NOT NULL DEFAULT now(),
@@ -29,6 +49,19 @@ CREATE TABLE IF NOT EXISTS bridge (
   FOREIGN KEY (site_id) REFERENCES site(id)
 );
 
+DROP TABLE IF EXISTS bridge_config CASCADE;
+CREATE TABLE IF NOT EXISTS bridge_config (
+  bridge_id bigint PRIMARY KEY,
+  config jsonb NOT NULL DEFAULT '{}', -- global settings merged over the configuration file of the bridge, i.e. {"logLevel": "debug", "pathDefaults": {"recordDeleteAfter": "72h"}}
+  status text NOT NULL DEFAULT 'pending', -- pending, applied, invalid, rolled_back. Reported by the bridge
+  error text, -- why the configuration has not been applied, reported by the bridge
+  applied_at timestamp with time zone, -- reported by the bridge
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  updated_at timestamp with time zone NOT NULL DEFAULT now(),
+
+  FOREIGN KEY (bridge_id) REFERENCES bridge(id) ON DELETE CASCADE
+);
+
 DROP TABLE IF EXISTS camera CASCADE;
 CREATE TABLE IF NOT EXISTS camera (
   id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
@@ -37,7 +70,12 @@ CREATE TABLE IF NOT EXISTS camera (
   ip_address text NOT NULL,
   source text NOT NULL,
   is_registered boolean NOT NULL DEFAULT false,
+  username text, -- credentials of the camera, added to source. Set when registering the camera
+  password text,
   healthy boolean NOT NULL DEFAULT true,
+  timezone text, -- IANA timezone of the camera clock, i.e. Asia/Seoul. NULL: alarmCameraTimezone of the bridge
+  settings jsonb NOT NULL DEFAULT '{}', -- path settings of the camera, i.e. {"record": true, "recordDeleteAfter": "72h"}
+  config_error text, -- error of the source or of settings, reported by the bridge. NULL: camera is streamed
   last_checked_at timestamp with time zone NOT NULL DEFAULT now(),
   created_at timestamp with time zone NOT NULL DEFAULT now(),
   updated_at timestamp with time zone NOT NULL DEFAULT now(),
@@ -46,6 +84,74 @@ CREATE TABLE IF NOT EXISTS camera (
   UNIQUE (bridge_id, ip_address)
 );
 
+DROP TABLE IF EXISTS panel CASCADE;
+CREATE TABLE IF NOT EXISTS panel (
+  id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  site_id bigint NOT NULL,
+  bridge_id bigint NOT NULL,
+  panel_name text NOT NULL,
+  account text NOT NULL, -- DC-09 account number
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  updated_at timestamp with time zone NOT NULL DEFAULT now(),
+
+  FOREIGN KEY (site_id) REFERENCES site(id),
+  FOREIGN KEY (bridge_id) REFERENCES bridge(id),
+  UNIQUE (bridge_id, account)
+);
+
+DROP TABLE IF EXISTS panel_zone CASCADE;
+CREATE TABLE IF NOT EXISTS panel_zone (
+  id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  panel_id bigint NOT NULL,
+  zone integer NOT NULL,
+  zone_name text,
+  camera_id bigint NOT NULL,
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  updated_at timestamp with time zone NOT NULL DEFAULT now(),
+
+  FOREIGN KEY (panel_id) REFERENCES panel(id) ON DELETE CASCADE,
+  FOREIGN KEY (camera_id) REFERENCES camera(id),
+  UNIQUE (panel_id, zone)
+);
+
+DROP TABLE IF EXISTS incident CASCADE;
+CREATE TABLE IF NOT EXISTS incident (
+  id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  site_id bigint NOT NULL,
+  bridge_id bigint NOT NULL,
+  status text NOT NULL DEFAULT 'open', -- open, closed
+  camera_ids bigint[] NOT NULL DEFAULT '{}', -- cameras involved
+  alarm_count integer NOT NULL DEFAULT 0,
+  timeline jsonb NOT NULL DEFAULT '[]', -- [{alarm_id, camera_id, alarm_name, alarm_type, at}]
+  clip_request jsonb, -- {camera_ids, start_at, end_at}
+  started_at timestamp with time zone NOT NULL DEFAULT now(),
+  last_alarm_at timestamp with time zone NOT NULL DEFAULT now(),
+  closed_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  updated_at timestamp with time zone NOT NULL DEFAULT now(),
+
+  FOREIGN KEY (site_id) REFERENCES site(id),
+  FOREIGN KEY (bridge_id) REFERENCES bridge(id)
+);
+
+DROP TABLE IF EXISTS walk_test CASCADE;
+CREATE TABLE IF NOT EXISTS walk_test (
+  id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  site_id bigint NOT NULL,
+  bridge_id bigint NOT NULL,
+  status text NOT NULL DEFAULT 'running', -- running, finished
+  camera_ids bigint[] NOT NULL DEFAULT '{}', -- cameras under test
+  alarms jsonb NOT NULL DEFAULT '[]', -- [{camera_id, alarm_name, alarm_type, at}]
+  report jsonb, -- [{id, name, fired, alarmCount, alarmTypes, lastAlarmAt}]
+  started_at timestamp with time zone NOT NULL DEFAULT now(),
+  expires_at timestamp with time zone NOT NULL,
+  finished_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+  updated_at timestamp with time zone NOT NULL DEFAULT now(),
+
+  FOREIGN KEY (site_id) REFERENCES site(id),
+  FOREIGN KEY (bridge_id) REFERENCES bridge(id)
+);
 
 DROP TABLE IF EXISTS alarm CASCADE;
 CREATE TABLE IF NOT EXISTS alarm (
@@ -54,7 +160,9 @@ CREATE TABLE IF NOT EXISTS alarm (
   bridge_id bigint NOT NULL,
   camera_id bigint NOT NULL,
   alarm_name text NOT NULL,
-  alarm_type text NOT NULL,
+  alarm_type text NOT NULL, -- motion, line-crossing, intrusion, tamper, video-loss, io-input, face, vehicle, system
+  priority integer NOT NULL DEFAULT 2, -- 1 low, 2 medium, 3 high, 4 critical
+  vendor_event text, -- event name as reported by the device
   last_alarm_at timestamp with time zone NOT NULL DEFAULT now(),
   is_read boolean NOT NULL DEFAULT false,
   read_at timestamp with time zone,
@@ -64,9 +172,16 @@ CREATE TABLE IF NOT EXISTS alarm (
   snapshot_url text,
   video_url text,
 
+  incident_id bigint,
+
+  is_test boolean NOT NULL DEFAULT false, -- fired during a walk test, not notified
+  walk_test_id bigint,
+
   FOREIGN KEY (site_id) REFERENCES site(id),
   FOREIGN KEY (bridge_id) REFERENCES bridge(id),
-  FOREIGN KEY (camera_id) REFERENCES camera(id)
+  FOREIGN KEY (camera_id) REFERENCES camera(id),
+  FOREIGN KEY (incident_id) REFERENCES incident(id),
+  FOREIGN KEY (walk_test_id) REFERENCES walk_test(id)
 );
 
 DROP TABLE IF EXISTS response CASCADE;
@@ -85,3 +200,29 @@ CREATE TABLE IF NOT EXISTS response (
 
   FOREIGN KEY (bridge_id) REFERENCES bridge(id)
 );
+
+-- requests sent to bridges through realtime, recorded by their sender in order for
+-- bridges to check who sent them
+DROP TABLE IF EXISTS bridge_request CASCADE;
+CREATE TABLE IF NOT EXISTS bridge_request (
+  request_id text PRIMARY KEY,
+  bridge_id bigint NOT NULL,
+  user_id uuid NOT NULL DEFAULT auth.uid(),
+  request_method text NOT NULL, -- GET, POST, PATCH, DELETE
+  request_path text NOT NULL, -- i.e. /v3/paths/list
+  request_body_hash text NOT NULL, -- hex SHA-256 of the body encoded into JSON with sorted keys
+
+  created_at timestamp with time zone NOT NULL DEFAULT now(),
+
+  FOREIGN KEY (bridge_id) REFERENCES bridge(id) ON DELETE CASCADE
+);
+
+DROP TABLE IF EXISTS pairing_attempt CASCADE;
+CREATE TABLE IF NOT EXISTS pairing_attempt (
+  id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+
+  user_id uuid NOT NULL,
+  succeeded boolean NOT NULL,
+
+  created_at timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,154 @@
+// bridge-auth exchanges the identity of a bridge for a short-lived access token.
+// The bridge sends its UUID and its secret. The secret is enrolled by the first bridge that
+// authenticates. After the credentials of the bridge have been revoked, no secret is enrolled
+// until an admin re-provisions the bridge, and the revoked secret is never enrolled again:
+// the bridge is answered with 403, and replaces its secret.
+// Tokens carry the "bridge" role, which row-level security limits to the bridge and its site.
+//
+// Secrets: BRIDGE_JWT_SECRET, the JWT secret of the project.
+import { createClient } from "npm:@supabase/supabase-js@2";
+import { create, getNumericDate } from "https://deno.land/x/djwt@v3.0.2/mod.ts";
+
+// lifetime of access tokens, in seconds. Bridges refresh them before they expire.
+const TOKEN_LIFETIME = 10 * 60;
+
+const UUID_REGEXP =
+  /^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$/i;
+
+function reply(status: number, body: Record<string, unknown>) {
+  return new Response(JSON.stringify(body), {
+    status,
+    headers: { "Content-Type": "application/json" },
+  });
+}
+
+async function sha256(value: string) {
+  const digest = await crypto.subtle.digest(
+    "SHA-256",
+    new TextEncoder().encode(value),
+  );
+  return Array.from(new Uint8Array(digest))
+    .map((b) => b.toString(16).padStart(2, "0"))
+    .join("");
+}
+
+async function signToken(bridgeId: number) {
+  const key = await crypto.subtle.importKey(
+    "raw",
+    new TextEncoder().encode(Deno.env.get("BRIDGE_JWT_SECRET")!),
+    { name: "HMAC", hash: "SHA-256" },
+    false,
+    ["sign"],
+  );
+
+  const issuedAt = getNumericDate(0);
+  const expiresAt = issuedAt + TOKEN_LIFETIME;
+
+  const token = await create(
+    { alg: "HS256", typ: "JWT" },
+    {
+      role: "bridge",
+      sub: `bridge:${bridgeId}`,
+      bridge_id: bridgeId,
+      iat: issuedAt,
+      exp: expiresAt,
+    },
+    key,
+  );
+
+  return { token, expiresAt };
+}
+
+Deno.serve(async (req) => {
+  if (req.method !== "POST") {
+    return reply(405, { error: "method not allowed" });
+  }
+
+  let body: { bridge_uuid?: unknown; secret?: unknown };
+  try {
+    body = await req.json();
+  } catch {
+    return reply(400, { error: "invalid request" });
+  }
+
+  const { bridge_uuid: bridgeUUID, secret } = body;
+  if (
+    typeof bridgeUUID !== "string" || !UUID_REGEXP.test(bridgeUUID) ||
+    typeof secret !== "string" || secret.length < 32
+  ) {
+    return reply(400, { error: "invalid request" });
+  }
+
+  const supabase = createClient(
+    Deno.env.get("SUPABASE_URL")!,
+    Deno.env.get("SUPABASE_SERVICE_ROLE_KEY")!,
+  );
+
+  const secretHash = await sha256(secret);
+
+  const { data: bridge, error } = await supabase
+    .from("bridge")
+    .select("id, secret_hash, revoked_site_id, revoked_secret_hash")
+    .eq("bridge_uuid", bridgeUUID)
+    .maybeSingle();
+  if (error) {
+    return reply(500, { error: error.message });
+  }
+
+  let bridgeId: number;
+
+  if (bridge === null) {
+    // register the bridge
+    const { data: created, error } = await supabase
+      .from("bridge")
+      .insert({
+        bridge_uuid: bridgeUUID,
+        bridge_name: "Bridge-" + bridgeUUID.slice(0, 8),
+        healthy: true,
+        secret_hash: secretHash,
+      })
+      .select("id")
+      .single();
+    if (error) {
+      return reply(500, { error: error.message });
+    }
+    bridgeId = created.id;
+  } else if (bridge.secret_hash === null) {
+    if (
+      bridge.revoked_site_id !== null ||
+      bridge.revoked_secret_hash === secretHash
+    ) {
+      return reply(403, { error: "credentials revoked" });
+    }
+
+    // enroll the secret, only if another bridge didn't do it in the meanwhile
+    // and the bridge hasn't been revoked again
+    const { data: enrolled, error } = await supabase
+      .from("bridge")
+      .update({ secret_hash: secretHash })
+      .eq("id", bridge.id)
+      .is("secret_hash", null)
+      .is("revoked_site_id", null)
+      .select("id");
+    if (error) {
+      return reply(500, { error: error.message });
+    }
+    if (enrolled.length === 0) {
+      return reply(401, { error: "invalid credentials" });
+    }
+    bridgeId = bridge.id;
+  } else if (bridge.secret_hash === secretHash) {
+    bridgeId = bridge.id;
+  } else {
+    return reply(401, { error: "invalid credentials" });
+  }
+
+  const { token, expiresAt } = await signToken(bridgeId);
+
+  return reply(200, {
+    access_token: token,
+    expires_at: expiresAt,
+    expires_in: TOKEN_LIFETIME,
+    bridge_id: bridgeId,
+  });
+});
@@ -1,2 +1,4 @@
 // 10초에 한 번씩 bridge와 camera의 healthy 상태를 확인
 // last_checked 가 60초 이상 지난 경우 healthy 상태를 false로 변경
+// bridge의 온라인 여부는 bridge-<id> 채널의 presence("bridge" 키)로 수 초 안에 확인할 수 있음
+// healthy는 presence를 구독하지 않는 클라이언트를 위한 보조 지표
@@ -1,5 +1,6 @@
 import { NextRequest, NextResponse } from "next/server";
 import { createClient } from "@/lib/supabase/server";
+import { CAMERA_COLUMNS } from "@/lib/database";
 export async function POST(
   request: NextRequest,
   context: { params: Promise<{ id: string }> } // params is Promise now
@@ -26,7 +27,7 @@ export async function POST(
         is_registered: true,
       })
       .eq("id", parseInt(id))
-      .select()
+      .select(CAMERA_COLUMNS)
       .single();
 
     if (error) {
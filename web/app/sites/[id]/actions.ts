@@ -2,13 +2,27 @@
 
 import { createClient } from "@/lib/supabase/server";
 
+// Removes a bridge from the site. The bridge keeps its credentials, and can be paired again.
 export async function disconnectBridgeFromSite(bridgeId: number) {
   const supabase = await createClient();
 
-  const { error } = await supabase
-    .from("bridge")
-    .update({ site_id: null })
-    .eq("id", bridgeId);
+  const { error } = await supabase.rpc("unpair_bridge", {
+    p_bridge_id: bridgeId,
+  });
+
+  if (error) {
+    throw new Error(error.message);
+  }
+}
+
+// Revokes the credentials of a bridge, i.e. when it has been stolen.
+// The bridge is removed from the site, and must be re-provisioned and paired again.
+export async function revokeBridgeCredentials(bridgeId: number) {
+  const supabase = await createClient();
+
+  const { error } = await supabase.rpc("revoke_bridge", {
+    p_bridge_id: bridgeId,
+  });
 
   if (error) {
     throw new Error(error.message);
@@ -32,13 +46,23 @@ export async function deleteSite(siteId: number) {
   const supabase = await createClient();
 
   // First, disconnect all bridges from this site
-  const { error: bridgeError } = await supabase
+  const { data: bridges, error: bridgesError } = await supabase
     .from("bridge")
-    .update({ site_id: null })
+    .select("id")
     .eq("site_id", siteId);
 
-  if (bridgeError) {
-    throw new Error(`Failed to disconnect bridges: ${bridgeError.message}`);
+  if (bridgesError) {
+    throw new Error(`Failed to disconnect bridges: ${bridgesError.message}`);
+  }
+
+  for (const bridge of bridges ?? []) {
+    const { error: bridgeError } = await supabase.rpc("unpair_bridge", {
+      p_bridge_id: bridge.id,
+    });
+
+    if (bridgeError) {
+      throw new Error(`Failed to disconnect bridges: ${bridgeError.message}`);
+    }
   }
 
   // Then delete the site
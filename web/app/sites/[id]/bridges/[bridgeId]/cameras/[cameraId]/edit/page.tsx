@@ -1,9 +1,19 @@
 import { createClient } from "@/lib/supabase/server";
+import { CAMERA_COLUMNS } from "@/lib/database";
 import { Button } from "@/components/ui/button";
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
-import { Video, ArrowLeft } from "lucide-react";
+import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert";
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from "@/components/ui/select";
+import { Json } from "@/lib/supabase/types";
+import { Video, ArrowLeft, AlertTriangle } from "lucide-react";
 import Link from "next/link";
 import { notFound, redirect } from "next/navigation";
 
@@ -19,7 +29,7 @@ async function getCamera(siteId: string, bridgeId: string, cameraId: string) {
   const supabase = await createClient();
   const { data, error } = await supabase
     .from("camera")
-    .select("*")
+    .select(CAMERA_COLUMNS)
     .eq("id", parseInt(cameraId))
     .eq("bridge_id", parseInt(bridgeId))
     .single();
@@ -43,13 +53,58 @@ async function getCamera(siteId: string, bridgeId: string, cameraId: string) {
   return data;
 }
 
-async function updateCamera(cameraId: string, cameraName: string) {
+// Path settings of the camera, applied by the bridge.
+// Keys must be allowed by the bridge, otherwise the camera is not streamed.
+interface CameraSettings {
+  record?: boolean;
+  recordFormat?: string;
+  recordDeleteAfter?: string;
+  sourceOnDemand?: boolean;
+  maxReaders?: number;
+  rtspTransport?: string;
+  [key: string]: Json | undefined;
+}
+
+function parseSettings(formData: FormData, current: CameraSettings) {
+  const settings: CameraSettings = { ...current };
+
+  settings.record = formData.get("record") === "on";
+  settings.sourceOnDemand = formData.get("sourceOnDemand") === "on";
+
+  for (const key of ["recordFormat", "recordDeleteAfter", "rtspTransport"]) {
+    const value = ((formData.get(key) as string) || "").trim();
+    if (value === "" || value === "default") {
+      delete settings[key];
+    } else {
+      settings[key] = value;
+    }
+  }
+
+  const maxReaders = ((formData.get("maxReaders") as string) || "").trim();
+  if (maxReaders === "") {
+    delete settings.maxReaders;
+  } else {
+    const value = parseInt(maxReaders);
+    if (isNaN(value) || value < 0) {
+      throw new Error("Max readers must be a positive number");
+    }
+    settings.maxReaders = value;
+  }
+
+  return settings;
+}
+
+async function updateCamera(
+  cameraId: string,
+  cameraName: string,
+  settings: CameraSettings
+) {
   "use server";
   
   const supabase = await createClient();
   const { error } = await supabase
     .from("camera")
-    .update({ camera_name: cameraName })
+    .update({ camera_name: cameraName, settings: settings as Json })
     .eq("id", parseInt(cameraId));
 
   if (error) {
@@ -65,6 +120,12 @@ export default async function EditCameraPage({ params }: PageProps) {
     notFound();
   }
 
+  const settings = (
+    camera.settings && typeof camera.settings === "object" && !Array.isArray(camera.settings)
+      ? camera.settings
+      : {}
+  ) as CameraSettings;
+
   async function handleSubmit(formData: FormData) {
     "use server";
     
@@ -74,7 +135,7 @@ export default async function EditCameraPage({ params }: PageProps) {
       throw new Error("Camera name is required");
     }
 
-    await updateCamera(cameraId, cameraName);
+    await updateCamera(cameraId, cameraName, parseSettings(formData, settings));
     redirect(`/sites/${id}`);
   }
 
@@ -100,6 +161,16 @@ export default async function EditCameraPage({ params }: PageProps) {
       <div className="flex-1 overflow-auto">
         <div className="p-6">
           <div className="max-w-2xl mx-auto">
+            {camera.config_error && (
+              <Alert variant="destructive" className="mb-6">
+                <AlertTriangle className="h-4 w-4" />
+                <AlertTitle>Invalid setting</AlertTitle>
+                <AlertDescription>
+                  The bridge is not streaming this camera: {camera.config_error}
+                </AlertDescription>
+              </Alert>
+            )}
+
             <Card>
               <CardHeader>
                 <CardTitle>Camera Information</CardTitle>
@@ -117,6 +188,99 @@ export default async function EditCameraPage({ params }: PageProps) {
                     />
                   </div>
 
+                  <div className="space-y-4">
+                    <h3 className="text-sm font-medium">Recording</h3>
+
+                    <div className="flex items-center gap-2">
+                      <input
+                        type="checkbox"
+                        id="record"
+                        name="record"
+                        defaultChecked={settings.record === true}
+                        className="h-4 w-4"
+                      />
+                      <Label htmlFor="record">Record continuously</Label>
+                    </div>
+
+                    <div className="grid grid-cols-2 gap-4">
+                      <div className="space-y-2">
+                        <Label htmlFor="recordFormat">Format</Label>
+                        <Select
+                          name="recordFormat"
+                          defaultValue={settings.recordFormat || "default"}
+                        >
+                          <SelectTrigger id="recordFormat">
+                            <SelectValue />
+                          </SelectTrigger>
+                          <SelectContent>
+                            <SelectItem value="default">Default</SelectItem>
+                            <SelectItem value="fmp4">fMP4</SelectItem>
+                            <SelectItem value="mpegts">MPEG-TS</SelectItem>
+                          </SelectContent>
+                        </Select>
+                      </div>
+
+                      <div className="space-y-2">
+                        <Label htmlFor="recordDeleteAfter">Retention</Label>
+                        <Input
+                          id="recordDeleteAfter"
+                          name="recordDeleteAfter"
+                          defaultValue={settings.recordDeleteAfter || ""}
+                          placeholder="e.g. 72h"
+                        />
+                      </div>
+                    </div>
+                  </div>
+
+                  <div className="space-y-4">
+                    <h3 className="text-sm font-medium">Streaming</h3>
+
+                    <div className="flex items-center gap-2">
+                      <input
+                        type="checkbox"
+                        id="sourceOnDemand"
+                        name="sourceOnDemand"
+                        defaultChecked={settings.sourceOnDemand === true}
+                        className="h-4 w-4"
+                      />
+                      <Label htmlFor="sourceOnDemand">
+                        Connect to the camera only when watched
+                      </Label>
+                    </div>
+
+                    <div className="grid grid-cols-2 gap-4">
+                      <div className="space-y-2">
+                        <Label htmlFor="maxReaders">Max Readers</Label>
+                        <Input
+                          id="maxReaders"
+                          name="maxReaders"
+                          type="number"
+                          min={0}
+                          defaultValue={settings.maxReaders ?? ""}
+                          placeholder="Unlimited"
+                        />
+                      </div>
+
+                      <div className="space-y-2">
+                        <Label htmlFor="rtspTransport">RTSP Transport</Label>
+                        <Select
+                          name="rtspTransport"
+                          defaultValue={settings.rtspTransport || "default"}
+                        >
+                          <SelectTrigger id="rtspTransport">
+                            <SelectValue />
+                          </SelectTrigger>
+                          <SelectContent>
+                            <SelectItem value="default">Automatic</SelectItem>
+                            <SelectItem value="udp">UDP</SelectItem>
+                            <SelectItem value="multicast">Multicast</SelectItem>
+                            <SelectItem value="tcp">TCP</SelectItem>
+                          </SelectContent>
+                        </Select>
+                      </div>
+                    </div>
+                  </div>
+
                   <div className="flex gap-3">
                     <Button type="submit">Save Changes</Button>
                     <Link href={`/sites/${id}`}>
@@ -1,11 +1,15 @@
 import { createClient } from "@/lib/supabase/server";
+import { BRIDGE_COLUMNS } from "@/lib/database";
 import { Button } from "@/components/ui/button";
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
-import { Wifi, ArrowLeft } from "lucide-react";
+import { Alert, AlertDescription, AlertTitle } from "@/components/ui/alert";
+import { Badge } from "@/components/ui/badge";
+import { Wifi, ArrowLeft, AlertTriangle } from "lucide-react";
 import Link from "next/link";
 import { notFound, redirect } from "next/navigation";
+import type { Json } from "@/lib/supabase/types";
 
 interface PageProps {
   params: Promise<{
@@ -18,7 +22,7 @@ async function getBridge(siteId: string, bridgeId: string) {
   const supabase = await createClient();
   const { data, error } = await supabase
     .from("bridge")
-    .select("*")
+    .select(BRIDGE_COLUMNS)
     .eq("id", parseInt(bridgeId))
     .eq("site_id", parseInt(siteId))
     .single();
@@ -30,6 +34,43 @@ async function getBridge(siteId: string, bridgeId: string) {
   return data;
 }
 
+async function getBridgeConfig(bridgeId: string) {
+  const supabase = await createClient();
+  const { data } = await supabase
+    .from("bridge_config")
+    .select("*")
+    .eq("bridge_id", parseInt(bridgeId))
+    .maybeSingle();
+
+  return data;
+}
+
+// The configuration is merged by the bridge into its mediamtx.yml, except for
+// keys pinned locally, and its status is reported back once it has been applied.
+async function updateBridgeConfig(bridgeId: string, config: unknown) {
+  "use server";
+
+  const supabase = await createClient();
+  const { error } = await supabase.from("bridge_config").upsert({
+    bridge_id: parseInt(bridgeId),
+    config: config as Json,
+    status: "pending",
+    error: null,
+    updated_at: new Date().toISOString(),
+  });
+
+  if (error) {
+    throw new Error(error.message);
+  }
+}
+
+const CONFIG_STATUS_LABELS: Record<string, string> = {
+  pending: "Pending",
+  applied: "Applied",
+  invalid: "Invalid",
+  rolled_back: "Rolled back",
+};
+
 async function updateBridge(bridgeId: string, bridgeName: string) {
   "use server";
   
@@ -52,6 +93,8 @@ export default async function EditBridgePage({ params }: PageProps) {
     notFound();
   }
 
+  const bridgeConfig = await getBridgeConfig(bridgeId);
+
   async function handleSubmit(formData: FormData) {
     "use server";
     
@@ -65,6 +108,28 @@ export default async function EditBridgePage({ params }: PageProps) {
     redirect(`/sites/${id}`);
   }
 
+  async function handleConfigSubmit(formData: FormData) {
+    "use server";
+
+    const text = (formData.get("config") as string).trim();
+
+    let config: unknown = {};
+    if (text) {
+      try {
+        config = JSON.parse(text);
+      } catch {
+        throw new Error("Configuration must be valid JSON");
+      }
+    }
+
+    if (typeof config !== "object" || config === null || Array.isArray(config)) {
+      throw new Error("Configuration must be a JSON object");
+    }
+
+    await updateBridgeConfig(bridgeId, config);
+    redirect(`/sites/${id}/bridges/${bridgeId}/edit`);
+  }
+
   return (
     <div className="flex-1 flex flex-col overflow-hidden">
       {/* Header */}
@@ -115,6 +180,76 @@ export default async function EditBridgePage({ params }: PageProps) {
                 </form>
               </CardContent>
             </Card>
+
+            <Card className="mt-6">
+              <CardHeader>
+                <div className="flex items-center justify-between">
+                  <CardTitle>Remote Configuration</CardTitle>
+                  {bridgeConfig && (
+                    <Badge
+                      variant={
+                        bridgeConfig.status === "applied"
+                          ? "default"
+                          : bridgeConfig.status === "pending"
+                            ? "secondary"
+                            : "destructive"
+                      }
+                    >
+                      {CONFIG_STATUS_LABELS[bridgeConfig.status] ??
+                        bridgeConfig.status}
+                    </Badge>
+                  )}
+                </div>
+              </CardHeader>
+              <CardContent>
+                {bridgeConfig?.error && (
+                  <Alert variant="destructive" className="mb-6">
+                    <AlertTriangle className="h-4 w-4" />
+                    <AlertTitle>
+                      {bridgeConfig.status === "rolled_back"
+                        ? "Configuration rolled back"
+                        : "Invalid configuration"}
+                    </AlertTitle>
+                    <AlertDescription>
+                      The bridge is using its previous configuration:{" "}
+                      {bridgeConfig.error}
+                    </AlertDescription>
+                  </Alert>
+                )}
+
+                <form action={handleConfigSubmit} className="space-y-6">
+                  <div className="space-y-2">
+                    <Label htmlFor="config">Global Settings (JSON)</Label>
+                    <textarea
+                      id="config"
+                      name="config"
+                      rows={12}
+                      defaultValue={JSON.stringify(
+                        bridgeConfig?.config ?? {},
+                        null,
+                        2,
+                      )}
+                      placeholder='{"logLevel": "debug", "pathDefaults": {"recordDeleteAfter": "72h"}}'
+                      className="w-full rounded-md border border-input bg-transparent px-3 py-2 font-mono text-sm shadow-sm focus-visible:outline-none focus-visible:ring-1 focus-visible:ring-ring"
+                    />
+                    <p className="text-sm text-muted-foreground">
+                      Settings of mediamtx.yml, applied over the local file of
+                      the bridge. Keys listed in remoteConfigPinned are kept
+                      from the local file.
+                    </p>
+                  </div>
+
+                  {bridgeConfig?.applied_at && (
+                    <p className="text-sm text-muted-foreground">
+                      Last applied at{" "}
+                      {new Date(bridgeConfig.applied_at).toLocaleString()}
+                    </p>
+                  )}
+
+                  <Button type="submit">Save Configuration</Button>
+                </form>
+              </CardContent>
+            </Card>
           </div>
         </div>
       </div>
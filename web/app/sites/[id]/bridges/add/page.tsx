@@ -8,7 +8,7 @@ import { Wifi, ArrowLeft, Key, AlertCircle } from "lucide-react";
 import Link from "next/link";
 import { useRouter } from "next/navigation";
 import { useState, useEffect } from "react";
-import { findBridgeByAccessToken, connectBridgeToSite } from "./actions";
+import { pairBridge } from "./actions";
 
 interface PageProps {
   params: Promise<{
@@ -40,17 +40,16 @@ export default function AddBridgePage({ params }: PageProps) {
         return;
       }
 
-      // Access token으로 bridge 찾기
-      const bridge = await findBridgeByAccessToken(accessToken);
+      // Access token으로 bridge를 찾아 현재 사이트에 연결
+      const bridgeId = await pairBridge(accessToken, id);
 
-      if (!bridge) {
-        setError("Valid bridge not found. Please check your access token.");
+      if (!bridgeId) {
+        setError(
+          "Valid bridge not found. Please check your access token, or renew it if it has expired."
+        );
         return;
       }
 
-      // Bridge를 현재 사이트에 연결
-      await connectBridgeToSite(bridge.id, id);
-
       router.push(`/sites/${id}`);
     } catch (err) {
       setError(err instanceof Error ? err.message : "An error occurred");
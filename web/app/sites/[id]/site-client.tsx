@@ -18,11 +18,18 @@ import {
   Video,
   ChevronLeft,
   Plus,
+  ShieldOff,
 } from "lucide-react";
 import Link from "next/link";
 import { useRouter } from "next/navigation";
 import { useState } from "react";
-import { disconnectBridgeFromSite, deleteCamera, deleteSite } from "./actions";
+import {
+  disconnectBridgeFromSite,
+  deleteCamera,
+  deleteSite,
+  revokeBridgeCredentials,
+} from "./actions";
+import { useBridgePresence } from "@/lib/hooks/use-bridge-presence";
 
 interface Site {
   id: number;
@@ -50,6 +57,7 @@ interface Camera {
   id: number;
   camera_name: string;
   healthy: boolean;
+  config_error: string | null;
 }
 
 interface Bridge {
@@ -74,8 +82,22 @@ export default function SiteClient({
 }: SiteClientProps) {
   const router = useRouter();
   const [deletingBridgeId, setDeletingBridgeId] = useState<number | null>(null);
+  const [revokingBridgeId, setRevokingBridgeId] = useState<number | null>(null);
   const [deletingCameraId, setDeletingCameraId] = useState<number | null>(null);
   const [deletingSite, setDeletingSite] = useState(false);
+  const presences = useBridgePresence(bridgesWithCameras.map((b) => b.id));
+
+  // presence is immediate; the healthy flag, updated by heartbeats, is used until it's received
+  const bridgeStatus = (bridge: Bridge) => {
+    const presence = presences[bridge.id];
+    if (presence === undefined) {
+      return bridge.healthy ? "Healthy" : "Unhealthy";
+    }
+    if (presence === null) {
+      return "Offline";
+    }
+    return `Online · ${presence.cameras_ready}/${presence.cameras} cameras ready · ${presence.cameras_recording} recording`;
+  };
 
   const handleDeleteBridge = async (bridgeId: number) => {
     if (confirm("Are you sure you want to remove this bridge from the site?")) {
@@ -92,6 +114,25 @@ export default function SiteClient({
     }
   };
 
+  const handleRevokeBridge = async (bridgeId: number) => {
+    if (
+      confirm(
+        "Are you sure you want to revoke the credentials of this bridge? It will be disconnected immediately, and must be re-provisioned and paired again."
+      )
+    ) {
+      setRevokingBridgeId(bridgeId);
+      try {
+        await revokeBridgeCredentials(bridgeId);
+        router.refresh();
+      } catch (error) {
+        console.error("Error revoking bridge:", error);
+        alert("Failed to revoke bridge credentials. Please try again.");
+      } finally {
+        setRevokingBridgeId(null);
+      }
+    }
+  };
+
   const handleDeleteCamera = async (cameraId: number) => {
     if (confirm("Are you sure you want to delete this camera?")) {
       setDeletingCameraId(cameraId);
@@ -128,9 +169,12 @@ export default function SiteClient({
 
   const getAlarmTypeColor = (alarmType: string) => {
     switch (alarmType) {
-      case "motion":
+      case "intrusion":
+      case "line-crossing":
+      case "tamper":
         return "destructive";
-      case "door":
+      case "motion":
+      case "io-input":
         return "secondary";
       case "system":
         return "default";
@@ -141,9 +185,11 @@ export default function SiteClient({
 
   const getAlarmTypeIcon = (alarmType: string) => {
     switch (alarmType) {
+      case "intrusion":
+      case "line-crossing":
+      case "tamper":
       case "motion":
-        return <AlertTriangle className="h-4 w-4" />;
-      case "door":
+      case "io-input":
         return <AlertTriangle className="h-4 w-4" />;
       case "system":
         return <Bell className="h-4 w-4" />;
@@ -385,8 +431,7 @@ export default function SiteClient({
                                   {bridge.bridge_name}
                                 </h3>
                                 <p className="text-sm text-muted-foreground">
-                                  Status:{" "}
-                                  {bridge.healthy ? "Healthy" : "Unhealthy"}
+                                  Status: {bridgeStatus(bridge)}
                                 </p>
                               </div>
                             </div>
@@ -398,6 +443,19 @@ export default function SiteClient({
                                   <Edit className="h-4 w-4" />
                                 </Button>
                               </Link>
+                              <Button
+                                variant="outline"
+                                size="sm"
+                                title="Revoke credentials"
+                                onClick={() => handleRevokeBridge(bridge.id)}
+                                disabled={revokingBridgeId === bridge.id}
+                              >
+                                {revokingBridgeId === bridge.id ? (
+                                  "Revoking..."
+                                ) : (
+                                  <ShieldOff className="h-4 w-4" />
+                                )}
+                              </Button>
                               <Button
                                 variant="outline"
                                 size="sm"
@@ -439,6 +497,15 @@ export default function SiteClient({
                                     >
                                       {camera.healthy ? "Online" : "Offline"}
                                     </Badge>
+                                    {camera.config_error && (
+                                      <Badge
+                                        variant="destructive"
+                                        className="text-xs"
+                                        title={camera.config_error}
+                                      >
+                                        Invalid setting
+                                      </Badge>
+                                    )}
                                   </div>
                                   <div className="flex items-center gap-1">
                                     <Link
@@ -1,4 +1,5 @@
 import { createClient } from "@/lib/supabase/server";
+import { BRIDGE_COLUMNS, CAMERA_COLUMNS } from "@/lib/database";
 
 export async function getSite(id: string) {
   const supabase = await createClient();
@@ -21,6 +22,7 @@ export async function getSiteAlarms(siteId: string) {
     .from("alarm")
     .select("*")
     .eq("site_id", parseInt(siteId))
+    .eq("is_test", false) // alarms of walk tests are not notified
     .order("last_alarm_at", { ascending: false })
     .limit(10);
 
@@ -39,21 +41,24 @@ export async function getSiteStats(siteId: string) {
   const { count: totalAlarms } = await supabase
     .from("alarm")
     .select("*", { count: "exact", head: true })
-    .eq("site_id", parseInt(siteId));
+    .eq("site_id", parseInt(siteId))
+    .eq("is_test", false);
 
   // Get unread alarms
   const { count: unreadAlarms } = await supabase
     .from("alarm")
     .select("*", { count: "exact", head: true })
     .eq("site_id", parseInt(siteId))
+    .eq("is_test", false)
     .eq("is_read", false);
 
-  // Get motion alarms (critical)
+  // Get high and critical priority alarms
   const { count: criticalAlarms } = await supabase
     .from("alarm")
     .select("*", { count: "exact", head: true })
     .eq("site_id", parseInt(siteId))
-    .eq("alarm_type", "motion");
+    .eq("is_test", false)
+    .gte("priority", 3);
 
   return {
     totalAlarms: totalAlarms || 0,
@@ -66,7 +71,7 @@ export async function getSiteBridges(siteId: string) {
   const supabase = await createClient();
   const { data, error } = await supabase
     .from("bridge")
-    .select("*")
+    .select(BRIDGE_COLUMNS)
     .eq("site_id", parseInt(siteId))
     .order("bridge_name");
 
@@ -82,7 +87,7 @@ export async function getBridgeCameras(bridgeId: number) {
   const supabase = await createClient();
   const { data, error } = await supabase
     .from("camera")
-    .select("*")
+    .select(CAMERA_COLUMNS)
     .eq("bridge_id", bridgeId)
     .eq("is_registered", true)
     .order("camera_name");
@@ -99,7 +104,7 @@ export async function getAvailableCameras(bridgeId: number) {
   const supabase = await createClient();
   const { data, error } = await supabase
     .from("camera")
-    .select("*")
+    .select(CAMERA_COLUMNS)
     .eq("bridge_id", bridgeId)
     .eq("is_registered", false)
     .order("camera_name");
@@ -127,7 +132,7 @@ export async function registerCamera(
       registered_at: new Date().toISOString(),
     })
     .eq("id", cameraId)
-    .select()
+    .select(CAMERA_COLUMNS)
     .single();
 
   if (error) {
@@ -11,6 +11,16 @@ export type UpdateTable<T extends keyof Database["public"]["Tables"]> =
 // Available tables
 export type Site = Table<"site">;
 
+// Columns of bridges that users can read. Credentials and pairing tokens of
+// bridges are readable only by the functions of the database.
+export const BRIDGE_COLUMNS =
+  "id, bridge_uuid, site_id, bridge_name, healthy, version, status, credentials_revoked_at, last_checked_at, created_at, updated_at";
+
+// Columns of cameras that users can read. Credentials of cameras are readable
+// only by bridges.
+export const CAMERA_COLUMNS =
+  "id, bridge_id, camera_name, ip_address, source, is_registered, healthy, timezone, settings, config_error, last_checked_at, created_at, updated_at";
+
 // Database utility functions
 export const getTableName = <T extends keyof Database["public"]["Tables"]>(
   table: T
@@ -3,35 +3,88 @@ import type { Database } from "./supabase/types";
 import { getClientId } from "./clientId";
 import { listenForRequestResponse } from "./hooks/use-realtime-responses";
 
+type BridgeMethod = "GET" | "POST" | "PATCH" | "DELETE";
+
 interface FetchBridgeParams {
   bridgeId: number;
   path: string;
+  method?: BridgeMethod;
   body?: Record<string, unknown>;
 }
 
 interface FetchBridgeResult {
   success: boolean;
+  status?: number;
   data?: unknown;
   error?: string;
+  code?: string;
 }
 
 interface BridgeResponse {
   success: boolean;
+  // status code of the control API of the bridge, for paths starting with /v3/
+  status?: number;
   data?: unknown;
   error?: string;
+  // error code set by the bridge: bad_request, forbidden, not_found, conflict,
+  // unavailable, busy, timeout or internal
+  code?: string;
+}
+
+/**
+ * Encodes a value into JSON with sorted keys and without spaces, as the bridge does
+ * when computing the digest of the body of a request.
+ */
+function canonicalJSON(value: unknown): string {
+  if (Array.isArray(value)) {
+    return `[${value.map((v) => canonicalJSON(v ?? null)).join(",")}]`;
+  }
+
+  if (value !== null && typeof value === "object") {
+    const entries = Object.keys(value)
+      .sort()
+      .filter((k) => (value as Record<string, unknown>)[k] !== undefined)
+      .map(
+        (k) =>
+          `${canonicalJSON(k)}:${canonicalJSON((value as Record<string, unknown>)[k])}`
+      );
+    return `{${entries.join(",")}}`;
+  }
+
+  // the bridge escapes line and paragraph separators
+  return (JSON.stringify(value) ?? "null")
+    .replace(/\u2028/g, "\\u2028")
+    .replace(/\u2029/g, "\\u2029");
+}
+
+/**
+ * Returns the hex SHA-256 of the body of a request, that the bridge checks
+ * before executing it.
+ */
+async function bodyDigest(body: unknown): Promise<string> {
+  const data = new TextEncoder().encode(canonicalJSON(body ?? null));
+  const hash = await crypto.subtle.digest("SHA-256", data);
+  return Array.from(new Uint8Array(hash))
+    .map((b) => b.toString(16).padStart(2, "0"))
+    .join("");
 }
 
 /**
  * Fetches data from a bridge using Supabase Realtime broadcast for requests
  * and Postgres Changes for listening to responses
  * @param bridgeId - The ID of the bridge to send the request to
- * @param path - The API path to request
+ * Paths starting with /v3/ are forwarded to the control API of the bridge, which checks
+ * the role of the user in the site: viewers can read, operators can kick sessions and
+ * delete recordings, admins can change the configuration.
+ * @param path - The API path to request, i.e. /v3/paths/list
+ * @param method - HTTP method of the request, GET by default
  * @param body - Optional request body
  * @returns Promise that resolves with the response data when completed
  */
 export async function fetchBridge({
   bridgeId,
   path,
+  method = "GET",
   body,
 }: FetchBridgeParams): Promise<FetchBridgeResult> {
   const supabase = createClient();
@@ -40,6 +93,25 @@ export async function fetchBridge({
     // Generate unique request ID
     const requestId = crypto.randomUUID();
 
+    // Record the request, in order for the bridge to check who sent it.
+    // The bridge accepts each request once, and only with the recorded body.
+    const { error: requestError } = await supabase
+      .from("bridge_request")
+      .insert({
+        request_id: requestId,
+        bridge_id: bridgeId,
+        request_method: method,
+        request_path: path,
+        request_body_hash: await bodyDigest(body),
+      });
+
+    if (requestError) {
+      return {
+        success: false,
+        error: `Failed to record request: ${requestError.message}`,
+      };
+    }
+
     // Create channel name for this bridge
     const channelName = `bridge-${bridgeId}`;
 
@@ -70,6 +142,7 @@ export async function fetchBridge({
               event: path,
               payload: {
                 request_id: requestId,
+                method,
                 path,
                 body,
                 requester_id: requesterId,
@@ -93,12 +166,15 @@ export async function fetchBridge({
                 if (responseData?.success) {
                   resolve({
                     success: true,
+                    status: responseData.status,
                     data: responseData.data,
                   });
                 } else {
                   resolve({
                     success: false,
+                    status: responseData?.status,
                     error: responseData?.error || "Request failed",
+                    code: responseData?.code,
                   });
                 }
               })
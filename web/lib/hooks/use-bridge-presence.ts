@@ -0,0 +1,54 @@
+import { useEffect, useState } from "react";
+import { createClient } from "@/lib/supabase/client";
+
+// key of the bridge in the presence of its channel
+const BRIDGE_PRESENCE_KEY = "bridge";
+
+export interface BridgePresence {
+  version: string;
+  started_at: string;
+  uptime: number; // seconds, when the metadata was published
+  cameras: number;
+  cameras_ready: number;
+  cameras_recording: number;
+}
+
+/**
+ * Tracks whether bridges are online, through their presence on the bridge-<id> channels.
+ * Bridges are mapped to their metadata when online, to null when offline, and are
+ * missing until the state of their channel has been received.
+ */
+export function useBridgePresence(bridgeIds: number[]) {
+  const [presences, setPresences] = useState<
+    Record<number, BridgePresence | null>
+  >({});
+
+  const key = bridgeIds.join(",");
+
+  useEffect(() => {
+    const supabase = createClient();
+
+    const channels = bridgeIds.map((bridgeId) => {
+      const channel = supabase.channel(`bridge-${bridgeId}`);
+
+      return channel
+        .on("presence", { event: "sync" }, () => {
+          const state = channel.presenceState<BridgePresence>();
+          const metas = state[BRIDGE_PRESENCE_KEY] ?? [];
+
+          // the last connection of the bridge is the most recent one
+          const meta = metas.length > 0 ? metas[metas.length - 1] : null;
+
+          setPresences((prev) => ({ ...prev, [bridgeId]: meta }));
+        })
+        .subscribe();
+    });
+
+    return () => {
+      channels.forEach((channel) => supabase.removeChannel(channel));
+    };
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [key]);
+
+  return presences;
+}
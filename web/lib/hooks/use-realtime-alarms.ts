@@ -112,6 +112,7 @@ export function useRealtimeAlarms(siteId?: number) {
         let query = supabase
           .from("alarm")
           .select("*")
+          .eq("is_test", false) // alarms of walk tests are not notified
           .order("created_at", { ascending: false });
 
         if (siteId) {
@@ -147,6 +148,9 @@ export function useRealtimeAlarms(siteId?: number) {
       .on("broadcast", { event: "alarm-insert" }, (payload) => {
         console.log("New alarm received via broadcast:", payload);
         const newAlarm = payload.payload as Alarm;
+        if (newAlarm.is_test) {
+          return; // alarms of walk tests are not notified
+        }
         setAlarms((prev) => [newAlarm, ...prev]);
 
         // Update global state if this is the global listener
@@ -22,13 +22,18 @@ export type Database = {
           camera_id: number
           created_at: string
           id: number
+          incident_id: number | null
           is_read: boolean
+          is_test: boolean
           last_alarm_at: string
+          priority: number
           read_at: string | null
           site_id: number
           snapshot_url: string | null
           updated_at: string
+          vendor_event: string | null
           video_url: string | null
+          walk_test_id: number | null
         }
         Insert: {
           alarm_name: string
@@ -37,13 +42,18 @@ export type Database = {
           camera_id: number
           created_at?: string
           id?: never
+          incident_id?: number | null
           is_read?: boolean
+          is_test?: boolean
           last_alarm_at?: string
+          priority?: number
           read_at?: string | null
           site_id: number
           snapshot_url?: string | null
           updated_at?: string
+          vendor_event?: string | null
           video_url?: string | null
+          walk_test_id?: number | null
         }
         Update: {
           alarm_name?: string
@@ -52,13 +62,18 @@ export type Database = {
           camera_id?: number
           created_at?: string
           id?: never
+          incident_id?: number | null
           is_read?: boolean
+          is_test?: boolean
           last_alarm_at?: string
+          priority?: number
           read_at?: string | null
           site_id?: number
           snapshot_url?: string | null
           updated_at?: string
+          vendor_event?: string | null
           video_url?: string | null
+          walk_test_id?: number | null
         }
         Relationships: [
           {
@@ -75,6 +90,20 @@ export type Database = {
             referencedRelation: "camera"
             referencedColumns: ["id"]
           },
+          {
+            foreignKeyName: "alarm_incident_id_fkey"
+            columns: ["incident_id"]
+            isOneToOne: false
+            referencedRelation: "incident"
+            referencedColumns: ["id"]
+          },
+          {
+            foreignKeyName: "alarm_walk_test_id_fkey"
+            columns: ["walk_test_id"]
+            isOneToOne: false
+            referencedRelation: "walk_test"
+            referencedColumns: ["id"]
+          },
           {
             foreignKeyName: "alarm_site_id_fkey"
             columns: ["site_id"]
@@ -87,36 +116,57 @@ export type Database = {
       bridge: {
         Row: {
           access_token: string | null
+          access_token_expires_at: string | null
           bridge_name: string
           bridge_uuid: string
           created_at: string
+          credentials_revoked_at: string | null
           healthy: boolean
           id: number
           last_checked_at: string
+          revoked_secret_hash: string | null
+          revoked_site_id: number | null
+          secret_hash: string | null
           site_id: number | null
+          status: Json | null
           updated_at: string
+          version: string | null
         }
         Insert: {
           access_token?: string | null
+          access_token_expires_at?: string | null
           bridge_name: string
           bridge_uuid: string
           created_at?: string
+          credentials_revoked_at?: string | null
           healthy?: boolean
           id?: never
           last_checked_at?: string
+          revoked_secret_hash?: string | null
+          revoked_site_id?: number | null
+          secret_hash?: string | null
           site_id?: number | null
+          status?: Json | null
           updated_at?: string
+          version?: string | null
         }
         Update: {
           access_token?: string | null
+          access_token_expires_at?: string | null
           bridge_name?: string
           bridge_uuid?: string
           created_at?: string
+          credentials_revoked_at?: string | null
           healthy?: boolean
           id?: never
           last_checked_at?: string
+          revoked_secret_hash?: string | null
+          revoked_site_id?: number | null
+          secret_hash?: string | null
           site_id?: number | null
+          status?: Json | null
           updated_at?: string
+          version?: string | null
         }
         Relationships: [
           {
@@ -128,42 +178,133 @@ export type Database = {
           },
         ]
       }
+      bridge_config: {
+        Row: {
+          applied_at: string | null
+          bridge_id: number
+          config: Json
+          created_at: string
+          error: string | null
+          status: string
+          updated_at: string
+        }
+        Insert: {
+          applied_at?: string | null
+          bridge_id: number
+          config?: Json
+          created_at?: string
+          error?: string | null
+          status?: string
+          updated_at?: string
+        }
+        Update: {
+          applied_at?: string | null
+          bridge_id?: number
+          config?: Json
+          created_at?: string
+          error?: string | null
+          status?: string
+          updated_at?: string
+        }
+        Relationships: [
+          {
+            foreignKeyName: "bridge_config_bridge_id_fkey"
+            columns: ["bridge_id"]
+            isOneToOne: true
+            referencedRelation: "bridge"
+            referencedColumns: ["id"]
+          },
+        ]
+      }
+      bridge_request: {
+        Row: {
+          bridge_id: number
+          created_at: string
+          request_body_hash: string
+          request_id: string
+          request_method: string
+          request_path: string
+          user_id: string
+        }
+        Insert: {
+          bridge_id: number
+          created_at?: string
+          request_body_hash: string
+          request_id: string
+          request_method: string
+          request_path: string
+          user_id?: string
+        }
+        Update: {
+          bridge_id?: number
+          created_at?: string
+          request_body_hash?: string
+          request_id?: string
+          request_method?: string
+          request_path?: string
+          user_id?: string
+        }
+        Relationships: [
+          {
+            foreignKeyName: "bridge_request_bridge_id_fkey"
+            columns: ["bridge_id"]
+            isOneToOne: false
+            referencedRelation: "bridge"
+            referencedColumns: ["id"]
+          },
+        ]
+      }
       camera: {
         Row: {
           bridge_id: number
           camera_name: string
+          config_error: string | null
           created_at: string
           healthy: boolean
           id: number
           ip_address: string
           is_registered: boolean
           last_checked_at: string
+          password: string | null
+          settings: Json
           source: string
+          timezone: string | null
           updated_at: string
+          username: string | null
         }
         Insert: {
           bridge_id: number
           camera_name: string
+          config_error?: string | null
           created_at?: string
           healthy?: boolean
           id?: never
           ip_address: string
           is_registered?: boolean
           last_checked_at?: string
+          password?: string | null
+          settings?: Json
           source: string
+          timezone?: string | null
           updated_at?: string
+          username?: string | null
         }
         Update: {
           bridge_id?: number
           camera_name?: string
+          config_error?: string | null
           created_at?: string
           healthy?: boolean
           id?: never
           ip_address?: string
           is_registered?: boolean
           last_checked_at?: string
+          password?: string | null
+          settings?: Json
           source?: string
+          timezone?: string | null
           updated_at?: string
+          username?: string | null
         }
         Relationships: [
           {
@@ -175,6 +316,180 @@ export type Database = {
           },
         ]
       }
+      incident: {
+        Row: {
+          alarm_count: number
+          bridge_id: number
+          camera_ids: number[]
+          clip_request: Json | null
+          closed_at: string | null
+          created_at: string
+          id: number
+          last_alarm_at: string
+          site_id: number
+          started_at: string
+          status: string
+          timeline: Json
+          updated_at: string
+        }
+        Insert: {
+          alarm_count?: number
+          bridge_id: number
+          camera_ids?: number[]
+          clip_request?: Json | null
+          closed_at?: string | null
+          created_at?: string
+          id?: never
+          last_alarm_at?: string
+          site_id: number
+          started_at?: string
+          status?: string
+          timeline?: Json
+          updated_at?: string
+        }
+        Update: {
+          alarm_count?: number
+          bridge_id?: number
+          camera_ids?: number[]
+          clip_request?: Json | null
+          closed_at?: string | null
+          created_at?: string
+          id?: never
+          last_alarm_at?: string
+          site_id?: number
+          started_at?: string
+          status?: string
+          timeline?: Json
+          updated_at?: string
+        }
+        Relationships: [
+          {
+            foreignKeyName: "incident_bridge_id_fkey"
+            columns: ["bridge_id"]
+            isOneToOne: false
+            referencedRelation: "bridge"
+            referencedColumns: ["id"]
+          },
+          {
+            foreignKeyName: "incident_site_id_fkey"
+            columns: ["site_id"]
+            isOneToOne: false
+            referencedRelation: "site"
+            referencedColumns: ["id"]
+          },
+        ]
+      }
+      pairing_attempt: {
+        Row: {
+          created_at: string
+          id: number
+          succeeded: boolean
+          user_id: string
+        }
+        Insert: {
+          created_at?: string
+          id?: never
+          succeeded: boolean
+          user_id: string
+        }
+        Update: {
+          created_at?: string
+          id?: never
+          succeeded?: boolean
+          user_id?: string
+        }
+        Relationships: []
+      }
+      panel: {
+        Row: {
+          account: string
+          bridge_id: number
+          created_at: string
+          id: number
+          panel_name: string
+          site_id: number
+          updated_at: string
+        }
+        Insert: {
+          account: string
+          bridge_id: number
+          created_at?: string
+          id?: never
+          panel_name: string
+          site_id: number
+          updated_at?: string
+        }
+        Update: {
+          account?: string
+          bridge_id?: number
+          created_at?: string
+          id?: never
+          panel_name?: string
+          site_id?: number
+          updated_at?: string
+        }
+        Relationships: [
+          {
+            foreignKeyName: "panel_bridge_id_fkey"
+            columns: ["bridge_id"]
+            isOneToOne: false
+            referencedRelation: "bridge"
+            referencedColumns: ["id"]
+          },
+          {
+            foreignKeyName: "panel_site_id_fkey"
+            columns: ["site_id"]
+            isOneToOne: false
+            referencedRelation: "site"
+            referencedColumns: ["id"]
+          },
+        ]
+      }
+      panel_zone: {
+        Row: {
+          camera_id: number
+          created_at: string
+          id: number
+          panel_id: number
+          updated_at: string
+          zone: number
+          zone_name: string | null
+        }
+        Insert: {
+          camera_id: number
+          created_at?: string
+          id?: never
+          panel_id: number
+          updated_at?: string
+          zone: number
+          zone_name?: string | null
+        }
+        Update: {
+          camera_id?: number
+          created_at?: string
+          id?: never
+          panel_id?: number
+          updated_at?: string
+          zone?: number
+          zone_name?: string | null
+        }
+        Relationships: [
+          {
+            foreignKeyName: "panel_zone_camera_id_fkey"
+            columns: ["camera_id"]
+            isOneToOne: false
+            referencedRelation: "camera"
+            referencedColumns: ["id"]
+          },
+          {
+            foreignKeyName: "panel_zone_panel_id_fkey"
+            columns: ["panel_id"]
+            isOneToOne: false
+            referencedRelation: "panel"
+            referencedColumns: ["id"]
+          },
+        ]
+      }
       response: {
         Row: {
           bridge_id: number
@@ -252,12 +567,140 @@ export type Database = {
         }
         Relationships: []
       }
+      site_member: {
+        Row: {
+          created_at: string
+          role: string
+          site_id: number
+          updated_at: string
+          user_id: string
+        }
+        Insert: {
+          created_at?: string
+          role?: string
+          site_id: number
+          updated_at?: string
+          user_id: string
+        }
+        Update: {
+          created_at?: string
+          role?: string
+          site_id?: number
+          updated_at?: string
+          user_id?: string
+        }
+        Relationships: [
+          {
+            foreignKeyName: "site_member_site_id_fkey"
+            columns: ["site_id"]
+            isOneToOne: false
+            referencedRelation: "site"
+            referencedColumns: ["id"]
+          },
+        ]
+      }
+      walk_test: {
+        Row: {
+          alarms: Json
+          bridge_id: number
+          camera_ids: number[]
+          created_at: string
+          expires_at: string
+          finished_at: string | null
+          id: number
+          report: Json | null
+          site_id: number
+          started_at: string
+          status: string
+          updated_at: string
+        }
+        Insert: {
+          alarms?: Json
+          bridge_id: number
+          camera_ids?: number[]
+          created_at?: string
+          expires_at: string
+          finished_at?: string | null
+          id?: never
+          report?: Json | null
+          site_id: number
+          started_at?: string
+          status?: string
+          updated_at?: string
+        }
+        Update: {
+          alarms?: Json
+          bridge_id?: number
+          camera_ids?: number[]
+          created_at?: string
+          expires_at?: string
+          finished_at?: string | null
+          id?: never
+          report?: Json | null
+          site_id?: number
+          started_at?: string
+          status?: string
+          updated_at?: string
+        }
+        Relationships: [
+          {
+            foreignKeyName: "walk_test_bridge_id_fkey"
+            columns: ["bridge_id"]
+            isOneToOne: false
+            referencedRelation: "bridge"
+            referencedColumns: ["id"]
+          },
+          {
+            foreignKeyName: "walk_test_site_id_fkey"
+            columns: ["site_id"]
+            isOneToOne: false
+            referencedRelation: "site"
+            referencedColumns: ["id"]
+          },
+        ]
+      }
     }
     Views: {
       [_ in never]: never
     }
     Functions: {
-      [_ in never]: never
+      current_bridge_id: {
+        Args: Record<PropertyKey, never>
+        Returns: number
+      }
+      current_site_id: {
+        Args: Record<PropertyKey, never>
+        Returns: number
+      }
+      pair_bridge: {
+        Args: { p_access_token: string; p_site_id: number }
+        Returns: number
+      }
+      reprovision_bridge: {
+        Args: { p_bridge_id: number }
+        Returns: undefined
+      }
+      request_role: {
+        Args: {
+          p_body_hash: string
+          p_method: string
+          p_path: string
+          p_request_id: string
+        }
+        Returns: string
+      }
+      revoke_bridge: {
+        Args: { p_bridge_id: number }
+        Returns: undefined
+      }
+      site_role: {
+        Args: { p_site_id: number }
+        Returns: string
+      }
+      unpair_bridge: {
+        Args: { p_bridge_id: number }
+        Returns: undefined
+      }
     }
     Enums: {
       [_ in never]: never